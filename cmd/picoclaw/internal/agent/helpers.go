@@ -3,6 +3,7 @@ package agent
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/agent"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -50,6 +52,12 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 	msgBus := bus.NewMessageBus()
 	defer msgBus.Close()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Stop()
+
+	mcpManager := mcp.NewManager(cfg.Tools.MCP)
+	defer mcpManager.Close()
+	agentLoop.UseMCP(mcpManager)
+	mcpManager.Start(context.Background())
 
 	// Print agent startup info (only for interactive mode)
 	startupInfo := agentLoop.GetStartupInfo()
@@ -61,6 +69,12 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 		})
 
 	if message != "" {
+		reader := bufio.NewReader(os.Stdin)
+		agentLoop.SetApprovalPrompter(approvalPrompter(func(prompt string) (string, error) {
+			fmt.Print(prompt)
+			return reader.ReadString('\n')
+		}))
+
 		ctx := context.Background()
 		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
 		if err != nil {
@@ -94,6 +108,12 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	}
 	defer rl.Close()
 
+	agentLoop.SetApprovalPrompter(approvalPrompter(func(p string) (string, error) {
+		rl.SetPrompt(p)
+		defer rl.SetPrompt(prompt)
+		return rl.Readline()
+	}))
+
 	for {
 		line, err := rl.Readline()
 		if err != nil {
@@ -128,6 +148,10 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 
 func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	reader := bufio.NewReader(os.Stdin)
+	agentLoop.SetApprovalPrompter(approvalPrompter(func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		return reader.ReadString('\n')
+	}))
 	for {
 		fmt.Print(fmt.Sprintf("%s You: ", internal.Logo))
 		line, err := reader.ReadString('\n')
@@ -160,3 +184,17 @@ func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 		fmt.Printf("\n%s %s\n\n", internal.Logo, response)
 	}
 }
+
+// approvalPrompter asks on the terminal before running tool calls whose
+// approval policy is "ask". readLine shows a prompt and reads the answer.
+func approvalPrompter(readLine func(prompt string) (string, error)) agent.ApprovalPrompter {
+	return func(_ context.Context, req agent.ApprovalRequest) bool {
+		args, _ := json.Marshal(req.Arguments)
+		answer, err := readLine(fmt.Sprintf("Allow %s %s? [y/N] ", req.Tool, args))
+		if err != nil {
+			return false
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
@@ -31,10 +31,15 @@ import (
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/openaiapi"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/triggers"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 func gatewayCmd(debug bool) error {
@@ -90,6 +95,8 @@ func gatewayCmd(debug bool) error {
 		cfg,
 	)
 
+	triggerService := setupTriggers(agentLoop, msgBus, cfg)
+
 	heartbeatService := heartbeat.NewHeartbeatService(
 		cfg.WorkspacePath(),
 		cfg.Heartbeat.Interval,
@@ -133,6 +140,12 @@ func gatewayCmd(debug bool) error {
 	agentLoop.SetChannelManager(channelManager)
 	agentLoop.SetMediaStore(mediaStore)
 
+	// Transcribe inbound voice messages before the agent sees them
+	if transcriber := voice.NewTranscriber(cfg); transcriber != nil {
+		agentLoop.UseInbound(voice.NewInboundMiddleware(transcriber, mediaStore))
+		fmt.Println("✓ Voice transcription enabled")
+	}
+
 	enabledChannels := channelManager.GetEnabledChannels()
 	if len(enabledChannels) > 0 {
 		fmt.Printf("✓ Channels enabled: %s\n", enabledChannels)
@@ -151,17 +164,30 @@ func gatewayCmd(debug bool) error {
 	}
 	fmt.Println("✓ Cron service started")
 
+	if err := triggerService.Start(); err != nil {
+		fmt.Printf("Error starting trigger service: %v\n", err)
+	}
+	fmt.Println("✓ Trigger service started")
+
 	if err := heartbeatService.Start(); err != nil {
 		fmt.Printf("Error starting heartbeat service: %v\n", err)
 	}
 	fmt.Println("✓ Heartbeat service started")
 
+	mcpManager := mcp.NewManager(cfg.Tools.MCP)
+	agentLoop.UseMCP(mcpManager)
+	mcpManager.Start(ctx)
+	if n := len(mcpManager.Clients()); n > 0 {
+		fmt.Printf("✓ MCP servers configured: %d\n", n)
+	}
+
 	stateManager := state.NewManager(cfg.WorkspacePath())
 	deviceService := devices.NewService(devices.Config{
 		Enabled:    cfg.Devices.Enabled,
 		MonitorUSB: cfg.Devices.MonitorUSB,
 	}, stateManager)
 	deviceService.SetBus(msgBus)
+	deviceService.AddListener(triggerService.HandleDeviceEvent)
 	if err := deviceService.Start(ctx); err != nil {
 		fmt.Printf("Error starting device service: %v\n", err)
 	} else if cfg.Devices.Enabled {
@@ -172,12 +198,36 @@ func gatewayCmd(debug bool) error {
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
 	addr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Gateway.Port)
 	channelManager.SetupHTTPServer(addr, healthServer)
+	usageToken := cfg.Gateway.UsageToken
+	if usageToken == "" {
+		usageToken = cfg.Gateway.OpenAIAPI.Token
+	}
+	tracker := agentLoop.UsageTracker()
+	usageEndpoint := tracker != nil && usageToken != ""
+	if usageEndpoint {
+		channelManager.HandleHTTP("/usage", tracker.Handler(usageToken))
+	}
+	channelManager.HandleHTTP(triggers.WebhookPrefix, triggerService)
+	openAIAPI := cfg.Gateway.OpenAIAPI.Enabled && cfg.Gateway.OpenAIAPI.Token != ""
+	if openAIAPI {
+		apiServer := openaiapi.NewServer(agentLoop, cfg.Gateway.OpenAIAPI.Token)
+		apiServer.SetMediaStore(mediaStore)
+		channelManager.HandleHTTP("/v1/", apiServer)
+	} else if cfg.Gateway.OpenAIAPI.Enabled {
+		fmt.Println("⚠ Warning: gateway.openai_api is enabled but has no token; the API is not served")
+	}
 
 	if err := channelManager.StartAll(ctx); err != nil {
 		fmt.Printf("Error starting channels: %v\n", err)
 	}
 
 	fmt.Printf("✓ Health endpoints available at http://%s:%d/health and /ready\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	if usageEndpoint {
+		fmt.Printf("✓ Usage endpoint available at http://%s:%d/usage\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	}
+	if openAIAPI {
+		fmt.Printf("✓ OpenAI-compatible API available at http://%s:%d/v1\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	}
 
 	go agentLoop.Run(ctx)
 
@@ -201,6 +251,8 @@ func gatewayCmd(debug bool) error {
 	deviceService.Stop()
 	heartbeatService.Stop()
 	cronService.Stop()
+	triggerService.Stop()
+	mcpManager.Close()
 	mediaStore.Stop()
 	agentLoop.Stop()
 	fmt.Println("✓ Gateway stopped")
@@ -233,3 +285,48 @@ func setupCronTool(
 
 	return cronService
 }
+
+func setupTriggers(agentLoop *agent.AgentLoop, msgBus *bus.MessageBus, cfg *config.Config) *triggers.Service {
+	storePath := filepath.Join(cfg.WorkspacePath(), "triggers", "rules.json")
+	triggerService := triggers.NewService(storePath, cfg.WorkspacePath(), nil)
+	agentLoop.RegisterTool(tools.NewTriggerTool(triggerService, cfg.Agents.Defaults.RestrictToWorkspace,
+		cfg.Devices.Enabled && cfg.Devices.MonitorUSB))
+
+	triggerService.SetOnFire(func(rule *triggers.Rule, message string) error {
+		channel, chatID := rule.Action.Channel, rule.Action.To
+		if channel == "" || chatID == "" {
+			channel, chatID = "cli", "direct"
+		}
+		publish := func(content string) {
+			pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer pubCancel()
+			msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: content})
+		}
+
+		if rule.Action.Kind == triggers.ActionDeliver {
+			publish(message)
+			return nil
+		}
+
+		// Each rule gets its own session, whose turns the agent loop runs
+		// in order alongside the chat sessions.
+		agentID := rule.Action.AgentID
+		if agentID == "" {
+			agentID = agentLoop.DefaultAgentID()
+		}
+		response, err := agentLoop.ProcessForAgent(context.Background(), agent.DirectRequest{
+			AgentID:    agentID,
+			SessionKey: routing.BuildAgentTriggerSessionKey(agentID, rule.ID),
+			Channel:    channel,
+			ChatID:     chatID,
+			SenderID:   "trigger",
+			Content:    message,
+		})
+		if err == nil && response != "" {
+			publish(response)
+		}
+		return err
+	})
+
+	return triggerService
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 const Logo = "🦞"
@@ -23,8 +24,27 @@ func GetConfigPath() string {
 	return filepath.Join(home, ".picoclaw", "config.json")
 }
 
+// LoadConfig loads the config file and applies its process-wide settings,
+// such as the outbound HTTP egress policy.
 func LoadConfig() (*config.Config, error) {
-	return config.LoadConfig(GetConfigPath())
+	cfg, err := config.LoadConfig(GetConfigPath())
+	if err != nil {
+		return nil, err
+	}
+
+	egress := cfg.Tools.Egress
+	policy, err := utils.NewEgressPolicy(utils.EgressOptions{
+		AllowPrivate:     egress.AllowPrivate,
+		Allow:            egress.Allow,
+		MaxResponseBytes: egress.MaxResponseBytes,
+		MaxDownloadBytes: egress.MaxDownloadBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tools.egress: %w", err)
+	}
+	utils.SetDefaultEgressPolicy(policy)
+
+	return cfg, nil
 }
 
 // FormatVersion returns the version string with optional git commit
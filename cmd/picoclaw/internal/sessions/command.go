@@ -0,0 +1,18 @@
+package sessions
+
+import "github.com/spf13/cobra"
+
+func NewSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Manage stored conversation sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newMigrateCommand())
+
+	return cmd
+}
@@ -0,0 +1,32 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionsCommand(t *testing.T) {
+	cmd := NewSessionsCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "sessions", cmd.Use)
+	assert.Equal(t, "Manage stored conversation sessions", cmd.Short)
+
+	assert.True(t, cmd.HasSubCommands())
+	require.Len(t, cmd.Commands(), 1)
+
+	migrate := cmd.Commands()[0]
+	assert.Equal(t, "migrate", migrate.Name())
+	assert.True(t, migrate.HasExample())
+	for _, flag := range []string{"from", "to", "workspace"} {
+		assert.NotNil(t, migrate.Flags().Lookup(flag), "missing flag %s", flag)
+	}
+}
+
+func TestMigrateCmd_SameStore(t *testing.T) {
+	err := migrateCmd("json", "json", t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package sessions
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newMigrateCommand() *cobra.Command {
+	var from, to, workspace string
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy sessions from one storage backend to another",
+		Args:  cobra.NoArgs,
+		Example: `  picoclaw sessions migrate --to sqlite
+  picoclaw sessions migrate --from sqlite --to json
+  picoclaw sessions migrate --to sqlite --workspace ~/.picoclaw/workspace-coder`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return migrateCmd(from, to, workspace)
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", session.StoreJSON, "Store to copy sessions from (json or sqlite)")
+	cmd.Flags().StringVar(&to, "to", "", "Store to copy sessions to (json or sqlite)")
+	cmd.Flags().StringVar(&workspace, "workspace", "",
+		"Agent workspace whose sessions to migrate (default: the configured workspace)")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func migrateCmd(from, to, workspace string) error {
+	if from == to {
+		return fmt.Errorf("--from and --to must be different stores")
+	}
+	if workspace == "" {
+		cfg, err := internal.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+		workspace = cfg.WorkspacePath()
+	}
+	dir := filepath.Join(workspace, "sessions")
+
+	src, err := session.OpenStore(from, dir)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := session.OpenStore(to, dir)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	n, err := session.Migrate(src, dst)
+	if err != nil {
+		return fmt.Errorf("migrated %d sessions before failing: %w", n, err)
+	}
+	fmt.Printf("Migrated %d sessions from %s to %s in %s\n", n, from, to, dir)
+	fmt.Printf("Set \"session\": {\"store\": %q} in your config to use them.\n", to)
+	return nil
+}
@@ -0,0 +1,90 @@
+package trigger
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/triggers"
+)
+
+func newAddCommand(storePath func() string, usbMonitored func() bool) *cobra.Command {
+	var (
+		rule     triggers.Rule
+		file     string
+		webhook  bool
+		usb      bool
+		cronExpr string
+		deliver  bool
+		debounce float64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a new trigger",
+		Args:  cobra.NoArgs,
+		Example: `picoclaw trigger add -n notes --file notes/*.md -m "Summarize the changes to {{.path}}" --debounce 30
+picoclaw trigger add -n deploy --webhook -m "Deploy finished: {{.json.status}}" --deliver --channel telegram --to 123
+picoclaw trigger add -n arduino --usb --usb-vendor 2341 --usb-action add -m "An Arduino was plugged in"`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			switch {
+			case file != "":
+				rule.Source.Kind = triggers.SourceFile
+				rule.Source.Path = file
+			case webhook:
+				rule.Source.Kind = triggers.SourceWebhook
+			case usb:
+				rule.Source.Kind = triggers.SourceUSB
+			case cronExpr != "":
+				rule.Source.Kind = triggers.SourceCron
+				rule.Source.Expr = cronExpr
+			default:
+				return fmt.Errorf("one of --file, --webhook, --usb or --cron must be specified")
+			}
+			rule.Action.Kind = triggers.ActionAgent
+			if deliver {
+				rule.Action.Kind = triggers.ActionDeliver
+			}
+			rule.DebounceMS = int64(debounce * 1000)
+
+			ts := triggers.NewService(storePath(), "", nil)
+			added, err := ts.AddRule(rule)
+			if err != nil {
+				return fmt.Errorf("error adding trigger: %w", err)
+			}
+
+			fmt.Printf("✓ Added trigger '%s' (%s)\n", added.Name, added.ID)
+			if added.Source.Kind == triggers.SourceWebhook {
+				fmt.Printf("  POST to the gateway at %s\n", triggers.WebhookPath(added.ID))
+				fmt.Printf("  with header \"Authorization: Bearer %s\"\n", added.Source.Token)
+			}
+			if added.Source.Kind == triggers.SourceUSB && !usbMonitored() {
+				fmt.Println("  ⚠ USB monitoring is off; set devices.enabled and devices.monitor_usb in the config")
+				fmt.Println("    for this trigger to fire")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&rule.Name, "name", "n", "", "Trigger name")
+	cmd.Flags().StringVarP(&rule.Action.Template, "message", "m", "", "Message template (default describes the event)")
+	cmd.Flags().StringVar(&file, "file", "", "Watch a workspace file, directory or glob pattern")
+	cmd.Flags().BoolVar(&webhook, "webhook", false, "Fire on an authenticated POST to the gateway")
+	cmd.Flags().BoolVar(&usb, "usb", false, "Fire when a USB device is plugged in or out")
+	cmd.Flags().StringVar(&rule.Source.Vendor, "usb-vendor", "", "USB vendor name or hex ID to match")
+	cmd.Flags().StringVar(&rule.Source.Product, "usb-product", "", "USB product name or hex ID to match")
+	cmd.Flags().StringVar(&rule.Source.Action, "usb-action", "", "Only fire on USB 'add' or 'remove'")
+	cmd.Flags().StringVarP(&cronExpr, "cron", "c", "", "Fire on a cron expression (e.g. '0 9 * * *')")
+	cmd.Flags().StringVar(&rule.Source.Token, "token", "", "Webhook token (default: random)")
+	cmd.Flags().BoolVarP(&deliver, "deliver", "d", false, "Send the message to the channel instead of the agent")
+	cmd.Flags().StringVar(&rule.Action.AgentID, "agent", "", "Agent to prompt (default: routed by channel)")
+	cmd.Flags().StringVar(&rule.Action.To, "to", "", "Recipient for delivery")
+	cmd.Flags().StringVar(&rule.Action.Channel, "channel", "", "Channel for delivery")
+	cmd.Flags().Float64Var(&debounce, "debounce", 0, "Fire once events stop arriving for N seconds")
+
+	_ = cmd.MarkFlagRequired("name")
+	cmd.MarkFlagsMutuallyExclusive("file", "webhook", "usb", "cron")
+
+	return cmd
+}
@@ -0,0 +1,84 @@
+package trigger
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/triggers"
+)
+
+func TestNewAddSubcommand(t *testing.T) {
+	fn := func() string { return "" }
+	cmd := newAddCommand(fn, func() bool { return true })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "add", cmd.Use)
+	assert.Equal(t, "Add a new trigger", cmd.Short)
+
+	assert.True(t, cmd.HasFlags())
+	assert.True(t, cmd.HasExample())
+
+	for _, name := range []string{
+		"message", "file", "webhook", "usb", "usb-vendor", "usb-product", "usb-action",
+		"cron", "token", "deliver", "agent", "to", "channel", "debounce",
+	} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %q", name)
+	}
+
+	nameFlag := cmd.Flags().Lookup("name")
+	require.NotNil(t, nameFlag)
+
+	val, found := nameFlag.Annotations[cobra.BashCompOneRequiredFlag]
+	require.True(t, found)
+	require.NotEmpty(t, val)
+	assert.Equal(t, "true", val[0])
+}
+
+func TestNewAddCommandSourcesMutuallyExclusive(t *testing.T) {
+	cmd := newAddCommand(func() string { return "testing" }, func() bool { return true })
+
+	cmd.SetArgs([]string{
+		"--name", "rule",
+		"--webhook",
+		"--cron", "0 9 * * *",
+	})
+
+	err := cmd.Execute()
+	require.Error(t, err)
+}
+
+func TestNewAddCommandRequiresSource(t *testing.T) {
+	cmd := newAddCommand(func() string { return "testing" }, func() bool { return true })
+
+	cmd.SetArgs([]string{"--name", "rule"})
+
+	err := cmd.Execute()
+	require.Error(t, err)
+}
+
+func TestNewAddCommandSavesRule(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "triggers", "rules.json")
+	cmd := newAddCommand(func() string { return storePath }, func() bool { return true })
+
+	cmd.SetArgs([]string{
+		"--name", "notes",
+		"--file", "notes/*.md",
+		"--debounce", "1.5",
+		"--deliver",
+		"--channel", "telegram",
+		"--to", "123",
+	})
+	require.NoError(t, cmd.Execute())
+
+	rules := triggers.NewService(storePath, "", nil).ListRules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, triggers.SourceFile, rules[0].Source.Kind)
+	assert.Equal(t, "notes/*.md", rules[0].Source.Path)
+	assert.Equal(t, triggers.ActionDeliver, rules[0].Action.Kind)
+	assert.Equal(t, int64(1500), rules[0].DebounceMS)
+}
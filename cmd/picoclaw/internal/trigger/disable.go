@@ -0,0 +1,16 @@
+package trigger
+
+import "github.com/spf13/cobra"
+
+func newDisableCommand(storePath func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "disable",
+		Short:   "Disable a trigger",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw trigger disable 3f2a9c1d8e7b6a50`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			triggerSetEnabled(storePath(), args[0], false)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,16 @@
+package trigger
+
+import "github.com/spf13/cobra"
+
+func newEnableCommand(storePath func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "enable",
+		Short:   "Enable a trigger",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw trigger enable 3f2a9c1d8e7b6a50`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			triggerSetEnabled(storePath(), args[0], true)
+			return nil
+		},
+	}
+}
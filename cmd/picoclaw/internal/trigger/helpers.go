@@ -0,0 +1,62 @@
+package trigger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/triggers"
+)
+
+func triggerListCmd(storePath string) {
+	ts := triggers.NewService(storePath, "", nil)
+	rules := ts.ListRules()
+
+	if len(rules) == 0 {
+		fmt.Println("No triggers.")
+		return
+	}
+
+	fmt.Println("\nTriggers:")
+	fmt.Println("---------")
+	for _, rule := range rules {
+		status := "enabled"
+		if !rule.Enabled {
+			status = "disabled"
+		}
+
+		lastFired := "never"
+		if rule.State.LastFiredAtMS != nil {
+			lastFired = time.UnixMilli(*rule.State.LastFiredAtMS).Format("2006-01-02 15:04")
+			lastFired += fmt.Sprintf(" (%s)", rule.State.LastStatus)
+		}
+
+		fmt.Printf("  %s (%s)\n", rule.Name, rule.ID)
+		fmt.Printf("    Source: %s\n", rule.Describe())
+		fmt.Printf("    Action: %s\n", rule.Action.Kind)
+		fmt.Printf("    Status: %s\n", status)
+		fmt.Printf("    Last fired: %s\n", lastFired)
+	}
+}
+
+func triggerRemoveCmd(storePath, id string) {
+	ts := triggers.NewService(storePath, "", nil)
+	if ts.RemoveRule(id) {
+		fmt.Printf("✓ Removed trigger %s\n", id)
+	} else {
+		fmt.Printf("✗ Trigger %s not found\n", id)
+	}
+}
+
+func triggerSetEnabled(storePath, id string, enabled bool) {
+	ts := triggers.NewService(storePath, "", nil)
+	rule := ts.EnableRule(id, enabled)
+	if rule == nil {
+		fmt.Printf("✗ Trigger %s not found\n", id)
+		return
+	}
+	status := "enabled"
+	if !enabled {
+		status = "disabled"
+	}
+	fmt.Printf("✓ Trigger '%s' %s\n", rule.Name, status)
+}
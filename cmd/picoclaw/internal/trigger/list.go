@@ -0,0 +1,17 @@
+package trigger
+
+import "github.com/spf13/cobra"
+
+func newListCommand(storePath func() string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all triggers",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			triggerListCmd(storePath())
+			return nil
+		},
+	}
+
+	return cmd
+}
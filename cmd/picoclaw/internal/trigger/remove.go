@@ -0,0 +1,18 @@
+package trigger
+
+import "github.com/spf13/cobra"
+
+func newRemoveCommand(storePath func() string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "remove",
+		Short:   "Remove a trigger by ID",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw trigger remove 3f2a9c1d8e7b6a50`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			triggerRemoveCmd(storePath(), args[0])
+			return nil
+		},
+	}
+
+	return cmd
+}
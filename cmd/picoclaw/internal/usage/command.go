@@ -0,0 +1,56 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func NewUsageCommand() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "usage [today|month|all]",
+		Short: "Show token usage and cost",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `  picoclaw usage
+  picoclaw usage month
+  picoclaw usage all --json`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			period := usage.PeriodToday
+			if len(args) > 0 {
+				period = args[0]
+			}
+			return usageCmd(period, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the summary as JSON")
+
+	return cmd
+}
+
+func usageCmd(period string, asJSON bool) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	summary, err := usage.NewTracker(cfg.WorkspacePath()).Summary(period)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summary)
+	}
+	fmt.Print(usage.Format(summary))
+	return nil
+}
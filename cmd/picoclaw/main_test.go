@@ -39,8 +39,11 @@ func TestNewPicoclawCommand(t *testing.T) {
 		"gateway",
 		"migrate",
 		"onboard",
+		"sessions",
 		"skills",
 		"status",
+		"trigger",
+		"usage",
 		"version",
 	}
 
@@ -0,0 +1,632 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Approval policies, see config.ApprovalConfig.
+const (
+	approvalAlways = "always"
+	approvalAsk    = "ask"
+	approvalNever  = "never"
+)
+
+// defaultApprovalTimeout is used when tools.approval.timeout_seconds is unset.
+const defaultApprovalTimeout = 10 * time.Minute
+
+// approvalResumeKey marks the inbound message that resumes a turn once its
+// approval has been answered or has expired.
+const approvalResumeKey = "approval_resume"
+
+// errApprovalSuspended is returned when a turn stops to wait for an approval.
+// The approval stays pending in the workspace state and resumes the turn
+// once answered.
+var errApprovalSuspended = errors.New("turn suspended waiting for approval")
+
+// approvalDecision is how a pending approval was settled.
+type approvalDecision int
+
+const (
+	approvalApproved approvalDecision = iota
+	approvalDenied
+	approvalExpired
+	approvalUnavailable // nobody can be asked on the originating channel
+	approvalNoWait      // the turn cannot wait for an answer, e.g. a delegated task
+)
+
+// ApprovalRequest describes a tool call that needs a user's approval.
+type ApprovalRequest struct {
+	Tool      string
+	Arguments map[string]any
+}
+
+// ApprovalPrompter asks the local user to approve a tool call requested on
+// the CLI, where there is no chat to send a prompt to. It returns true to
+// run the call.
+type ApprovalPrompter func(ctx context.Context, req ApprovalRequest) bool
+
+// SetApprovalPrompter sets the prompter used for "ask" tool calls on the
+// cli channel. Without one such calls are refused.
+func (al *AgentLoop) SetApprovalPrompter(p ApprovalPrompter) {
+	al.approvalPrompter = p
+}
+
+// approvalPolicy maps tool calls to "always", "ask" or "never".
+type approvalPolicy struct {
+	defaultPolicy string
+	tools         map[string]string
+	rules         []approvalRule
+}
+
+type approvalRule struct {
+	tool    string
+	pattern *regexp.Regexp
+	policy  string
+}
+
+// newApprovalPolicy compiles cfg. Unknown policies are treated as "ask" and
+// rules with invalid patterns are skipped, both with a warning.
+func newApprovalPolicy(cfg config.ApprovalConfig) *approvalPolicy {
+	p := &approvalPolicy{
+		defaultPolicy: approvalAlways,
+		tools:         make(map[string]string, len(cfg.Tools)),
+	}
+	if cfg.Default != "" {
+		p.defaultPolicy = normalizeApprovalPolicy(cfg.Default)
+	}
+	for name, policy := range cfg.Tools {
+		p.tools[name] = normalizeApprovalPolicy(policy)
+	}
+	for _, r := range cfg.Rules {
+		rule := approvalRule{tool: r.Tool, policy: normalizeApprovalPolicy(r.Policy)}
+		if rule.tool == "" {
+			rule.tool = "*"
+		}
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				logger.WarnCF("agent", "Skipping approval rule with invalid pattern", map[string]any{
+					"tool":    r.Tool,
+					"pattern": r.Pattern,
+					"error":   err.Error(),
+				})
+				continue
+			}
+			rule.pattern = re
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p
+}
+
+func normalizeApprovalPolicy(policy string) string {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case approvalAlways, approvalAsk, approvalNever:
+		return p
+	default:
+		logger.WarnCF("agent", "Unknown approval policy, using \"ask\"", map[string]any{"policy": policy})
+		return approvalAsk
+	}
+}
+
+// decide returns the policy for a call of tool name with args. A call that
+// amounts to calls of other tools is also held to their policies, and the
+// strictest policy applies.
+func (p *approvalPolicy) decide(name string, args map[string]any) string {
+	policy := p.decideTool(name, args)
+	for _, c := range coveredCalls(name, args) {
+		if other := p.decideTool(c.tool, c.args); policyRank[other] > policyRank[policy] {
+			policy = other
+		}
+	}
+	return policy
+}
+
+// policyRank orders the policies from least to most strict.
+var policyRank = map[string]int{approvalAlways: 0, approvalAsk: 1, approvalNever: 2}
+
+type coveredCall struct {
+	tool string
+	args map[string]any
+}
+
+// coveredCalls returns the calls of other tools that a call of name with
+// args amounts to, so that their policies cannot be sidestepped: starting or
+// feeding a background process runs a shell command as exec does, and
+// apply_patch changes files as write_file and edit_file do.
+func coveredCalls(name string, args map[string]any) []coveredCall {
+	switch name {
+	case "process":
+		switch args["action"] {
+		case "start":
+			return []coveredCall{{"exec", map[string]any{"command": args["command"]}}}
+		case "write":
+			return []coveredCall{{"exec", map[string]any{"command": args["input"]}}}
+		}
+	case "apply_patch":
+		return []coveredCall{{"write_file", args}, {"edit_file", args}}
+	}
+	return nil
+}
+
+// decideTool returns the policy of tool name for args alone: the first
+// matching rule, else the tool's policy, else the default.
+func (p *approvalPolicy) decideTool(name string, args map[string]any) string {
+	for _, r := range p.rules {
+		if r.tool != "*" && r.tool != name {
+			continue
+		}
+		if r.pattern == nil || matchesStringArg(r.pattern, args) {
+			return r.policy
+		}
+	}
+	if policy, ok := p.tools[name]; ok {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// matchesStringArg reports whether re matches any string in v, searching
+// nested maps and slices.
+func matchesStringArg(re *regexp.Regexp, v any) bool {
+	switch v := v.(type) {
+	case string:
+		return re.MatchString(v)
+	case map[string]any:
+		for _, item := range v {
+			if matchesStringArg(re, item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if matchesStringArg(re, item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// approvals tracks pending approvals, mirrored to the workspace state so they
+// survive a restart. Once settled, an approval is handed to a resume that
+// continues its turn.
+type approvals struct {
+	mu      sync.Mutex
+	state   *state.Manager // may be nil
+	pending map[string]state.PendingApproval
+	resumes map[string]approvalResume
+}
+
+type approvalResume struct {
+	approval state.PendingApproval
+	decision approvalDecision
+}
+
+func newApprovals(sm *state.Manager) *approvals {
+	a := &approvals{
+		state:   sm,
+		pending: make(map[string]state.PendingApproval),
+		resumes: make(map[string]approvalResume),
+	}
+	if sm != nil {
+		for _, p := range sm.GetPendingApprovals() {
+			a.pending[p.ID] = p
+		}
+	}
+	return a
+}
+
+// add records p.
+func (a *approvals) add(p state.PendingApproval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending[p.ID] = p
+	if a.state != nil {
+		if err := a.state.AddPendingApproval(p); err != nil {
+			logger.WarnCF("agent", "Failed to persist pending approval", map[string]any{
+				"id":    p.ID,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// get returns the pending approval with the given ID.
+func (a *approvals) get(id string) (state.PendingApproval, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.pending[id]
+	return p, ok
+}
+
+// list returns all pending approvals.
+func (a *approvals) list() []state.PendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]state.PendingApproval, 0, len(a.pending))
+	for _, p := range a.pending {
+		list = append(list, p)
+	}
+	return list
+}
+
+// resolve removes the approval with the given ID so that the caller can
+// resume its turn. ok is false if the approval is no longer pending.
+func (a *approvals) resolve(id string) (state.PendingApproval, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, ok := a.pending[id]
+	if !ok {
+		return p, false
+	}
+	delete(a.pending, id)
+	if a.state != nil {
+		if err := a.state.RemovePendingApproval(id); err != nil {
+			logger.WarnCF("agent", "Failed to remove pending approval", map[string]any{
+				"id":    id,
+				"error": err.Error(),
+			})
+		}
+	}
+	return p, true
+}
+
+// armResume stores a settled approval for the session worker that resumes
+// its turn, and returns the message that triggers the resume.
+func (a *approvals) armResume(p state.PendingApproval, d approvalDecision) bus.InboundMessage {
+	a.mu.Lock()
+	a.resumes[p.ID] = approvalResume{approval: p, decision: d}
+	a.mu.Unlock()
+
+	return bus.InboundMessage{
+		Channel:    p.Channel,
+		SenderID:   p.RequestedBy,
+		ChatID:     p.ChatID,
+		SessionKey: p.SessionKey,
+		Metadata:   map[string]string{approvalResumeKey: p.ID},
+	}
+}
+
+// takeResume returns the armed resume msg triggers, if any.
+func (a *approvals) takeResume(msg bus.InboundMessage) (approvalResume, bool) {
+	id := msg.Metadata[approvalResumeKey]
+	if id == "" {
+		return approvalResume{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r, ok := a.resumes[id]
+	delete(a.resumes, id)
+	return r, ok
+}
+
+func newApprovalID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (al *AgentLoop) approvalTimeout() time.Duration {
+	if s := al.cfg.Tools.Approval.TimeoutSeconds; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultApprovalTimeout
+}
+
+// needsApproval reports whether tc must be approved before it runs.
+func (al *AgentLoop) needsApproval(tc providers.ToolCall) bool {
+	return al.approvalPolicy.decide(tc.Name, tc.Arguments) == approvalAsk
+}
+
+// requestApproval asks the originating chat to approve tc. The turn is then
+// parked: requestApproval returns errApprovalSuspended, the session's queue
+// is held without occupying a worker, and the turn resumes through
+// resumeApproval once the request is answered or expires. The session is
+// saved first so the turn can also be resumed after a restart. Where nobody
+// can be asked in a chat, or the turn cannot wait, the decision is returned
+// directly.
+func (al *AgentLoop) requestApproval(
+	ctx context.Context,
+	agent *AgentInstance,
+	tc providers.ToolCall,
+	opts processOptions,
+) (approvalDecision, error) {
+	if opts.Channel == "" || opts.ChatID == "" || constants.IsInternalChannel(opts.Channel) {
+		if opts.Channel == "cli" && al.approvalPrompter != nil {
+			if al.approvalPrompter(ctx, ApprovalRequest{Tool: tc.Name, Arguments: tc.Arguments}) {
+				return approvalApproved, nil
+			}
+			return approvalDenied, nil
+		}
+		logger.WarnCF("agent", "Tool call needs approval but nobody can be asked", map[string]any{
+			"tool":    tc.Name,
+			"channel": opts.Channel,
+		})
+		return approvalUnavailable, nil
+	}
+	if opts.NoApprovalWait {
+		logger.WarnCF("agent", "Tool call needs approval but the turn cannot wait for it", map[string]any{
+			"tool":        tc.Name,
+			"session_key": opts.SessionKey,
+		})
+		return approvalNoWait, nil
+	}
+
+	now := time.Now()
+	p := state.PendingApproval{
+		ID:          newApprovalID(),
+		AgentID:     agent.ID,
+		SessionKey:  opts.SessionKey,
+		Channel:     opts.Channel,
+		ChatID:      opts.ChatID,
+		RequestedBy: opts.SenderID,
+		ToolCallID:  tc.ID,
+		Tool:        tc.Name,
+		Arguments:   tc.Arguments,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(al.approvalTimeout()),
+	}
+	agent.Sessions.Save(opts.SessionKey)
+	al.dispatcher.Park(p.SessionKey)
+	al.approvals.add(p)
+
+	logger.InfoCF("agent", "Waiting for tool call approval", map[string]any{
+		"id":          p.ID,
+		"tool":        p.Tool,
+		"session_key": p.SessionKey,
+	})
+	al.bus.PublishOutbound(ctx, approvalPrompt(p))
+	al.scheduleApprovalExpiry(p)
+	return 0, errApprovalSuspended
+}
+
+// scheduleApprovalExpiry resumes p's turn as expired once p.ExpiresAt has
+// passed, unless it was answered by then. Approvals that expire while the
+// loop is not running are left for restoreApprovals.
+func (al *AgentLoop) scheduleApprovalExpiry(p state.PendingApproval) {
+	time.AfterFunc(max(time.Until(p.ExpiresAt), 0), func() {
+		if !al.running.Load() {
+			return
+		}
+		if p, ok := al.approvals.resolve(p.ID); ok {
+			al.resumeTurn(context.Background(), p, approvalExpired)
+		}
+	})
+}
+
+// resumeTurn unparks p's session with the message that resumes its turn.
+func (al *AgentLoop) resumeTurn(ctx context.Context, p state.PendingApproval, d approvalDecision) {
+	al.dispatcher.Unpark(ctx, p.SessionKey, al.approvals.armResume(p, d))
+}
+
+// approvalPrompt builds the message asking the chat to approve p.
+func approvalPrompt(p state.PendingApproval) bus.OutboundMessage {
+	argsJSON, _ := json.Marshal(p.Arguments)
+	approve, deny := "/approve "+p.ID, "/deny "+p.ID
+	content := fmt.Sprintf(
+		"Approval needed to run %s:\n%s\n\nReply %s or %s (expires in %s).",
+		p.Tool,
+		utils.Truncate(string(argsJSON), 500),
+		approve,
+		deny,
+		time.Until(p.ExpiresAt).Round(time.Second),
+	)
+	return bus.OutboundMessage{
+		Channel: p.Channel,
+		ChatID:  p.ChatID,
+		Content: content,
+		Buttons: []bus.Button{
+			{Text: "Approve", Data: approve},
+			{Text: "Deny", Data: deny},
+		},
+	}
+}
+
+// approvalOutcome returns the tool result recorded for a call that was not
+// approved and the reply that ends the turn.
+func approvalOutcome(tool string, d approvalDecision) (toolResult, reply string) {
+	switch d {
+	case approvalExpired:
+		return "Tool call was not approved in time and did not run.",
+			fmt.Sprintf("Cancelled: running %s was not approved in time.", tool)
+	case approvalUnavailable:
+		return "Tool call requires user approval, which cannot be requested on this channel.",
+			fmt.Sprintf("Cancelled: running %s needs approval, which cannot be requested here.", tool)
+	case approvalNoWait:
+		return "Tool call requires user approval, which delegated tasks cannot wait for.",
+			fmt.Sprintf("Cancelled: running %s needs approval, which delegated tasks cannot wait for.", tool)
+	default:
+		return "Tool call was denied by the user and did not run.",
+			fmt.Sprintf("Cancelled: running %s was denied.", tool)
+	}
+}
+
+// handleApprovalCommand handles "/approve <id>" and "/deny <id>": the
+// settled approval is passed to resume, which continues its turn.
+func (al *AgentLoop) handleApprovalCommand(
+	msg bus.InboundMessage,
+	resume func(p state.PendingApproval, d approvalDecision),
+) (string, bool) {
+	fields := strings.Fields(msg.Content)
+	if len(fields) == 0 || (fields[0] != "/approve" && fields[0] != "/deny") {
+		return "", false
+	}
+	if len(fields) != 2 {
+		return fmt.Sprintf("Usage: %s <id>", fields[0]), true
+	}
+
+	id := fields[1]
+	p, ok := al.approvals.get(id)
+	if !ok {
+		return fmt.Sprintf("No pending approval with id %s", id), true
+	}
+	if !al.canApprove(msg, p) {
+		return "You are not allowed to answer this approval request", true
+	}
+
+	d, ack := approvalApproved, fmt.Sprintf("Approved: running %s", p.Tool)
+	if fields[0] == "/deny" {
+		d, ack = approvalDenied, fmt.Sprintf("Denied: %s will not run", p.Tool)
+	}
+	p, ok = al.approvals.resolve(id)
+	if !ok {
+		return fmt.Sprintf("No pending approval with id %s", id), true
+	}
+
+	logger.InfoCF("agent", "Approval answered", map[string]any{
+		"id":        id,
+		"tool":      p.Tool,
+		"approved":  d == approvalApproved,
+		"sender_id": msg.SenderID,
+	})
+	resume(p, d)
+	return ack, true
+}
+
+// canApprove reports whether the sender of msg may answer p: a configured
+// approver, or, without approvers, the requester in the same chat.
+func (al *AgentLoop) canApprove(msg bus.InboundMessage, p state.PendingApproval) bool {
+	if approvers := al.cfg.Tools.Approval.Approvers; len(approvers) > 0 {
+		for _, a := range approvers {
+			if a == msg.SenderID || identity.MatchAllowed(msg.Sender, a) {
+				return true
+			}
+		}
+		return false
+	}
+	if msg.Channel != p.Channel || msg.ChatID != p.ChatID {
+		return false
+	}
+	return p.RequestedBy == "" || p.RequestedBy == "cron" || p.RequestedBy == msg.SenderID
+}
+
+// restoreApprovals parks the sessions of approvals left pending by a
+// previous run and schedules their expiry.
+func (al *AgentLoop) restoreApprovals() {
+	for _, p := range al.approvals.list() {
+		al.dispatcher.Park(p.SessionKey)
+		al.scheduleApprovalExpiry(p)
+	}
+}
+
+// resumeApproval continues the turn that was waiting for p once it has been
+// settled with d: the remaining tool calls of the turn are run (or skipped)
+// and, if the call was approved, the LLM loop carries on.
+func (al *AgentLoop) resumeApproval(ctx context.Context, p state.PendingApproval, d approvalDecision) (string, error) {
+	agent, ok := al.registry.GetAgent(p.AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+	if agent == nil {
+		return "", fmt.Errorf("no agent available to resume approval %s", p.ID)
+	}
+	agent = turnAgent(agent)
+
+	opts := processOptions{
+		SessionKey:      p.SessionKey,
+		Channel:         p.Channel,
+		ChatID:          p.ChatID,
+		SenderID:        p.RequestedBy,
+		DefaultResponse: defaultResponse,
+		EnableSummary:   true,
+		Stream:          true,
+	}
+	ctx = tools.WithToolContext(ctx, opts.Channel, opts.ChatID)
+	ctx = tools.WithToolSession(ctx, opts.SessionKey)
+	ctx = usage.WithScope(ctx, agent.ID, opts.SessionKey)
+
+	history := agent.Sessions.GetHistory(opts.SessionKey)
+	calls := unansweredToolCalls(history)
+	found := false
+	for _, tc := range calls {
+		found = found || tc.ID == p.ToolCallID
+	}
+	if !found {
+		logger.WarnCF("agent", "Approved tool call is no longer pending in the session", map[string]any{
+			"id":          p.ID,
+			"session_key": p.SessionKey,
+		})
+		return "", nil
+	}
+
+	logger.InfoCF("agent", "Resuming turn after approval", map[string]any{
+		"id":          p.ID,
+		"agent_id":    agent.ID,
+		"session_key": opts.SessionKey,
+		"approved":    d == approvalApproved,
+	})
+
+	messages := agent.ContextBuilder.BuildMessages(
+		history,
+		agent.Sessions.GetSummary(opts.SessionKey),
+		"",
+		nil,
+		opts.Channel,
+		opts.ChatID,
+	)
+	messages, finalContent, err := al.runToolCalls(ctx, agent, calls, messages, opts, 0,
+		map[string]approvalDecision{p.ToolCallID: d})
+	iteration := 0
+	if err == nil && finalContent == "" {
+		finalContent, iteration, err = al.runLLMIteration(ctx, agent, messages, opts)
+	}
+	if errors.Is(err, errApprovalSuspended) {
+		agent.Sessions.Save(opts.SessionKey)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return al.finishTurn(ctx, agent, opts, finalContent, iteration), nil
+}
+
+// unansweredToolCalls returns the tool calls of the last assistant message in
+// history that have no tool result yet.
+func unansweredToolCalls(history []providers.Message) []providers.ToolCall {
+	for i := len(history) - 1; i >= 0; i-- {
+		msg := history[i]
+		if msg.Role != "assistant" {
+			continue
+		}
+		if len(msg.ToolCalls) == 0 {
+			return nil
+		}
+		answered := make(map[string]bool)
+		for _, m := range history[i+1:] {
+			if m.Role == "tool" {
+				answered[m.ToolCallID] = true
+			}
+		}
+		var calls []providers.ToolCall
+		for _, tc := range msg.ToolCalls {
+			if !answered[tc.ID] {
+				calls = append(calls, providers.NormalizeToolCall(tc))
+			}
+		}
+		return calls
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestApprovalPolicy_Decide(t *testing.T) {
+	p := newApprovalPolicy(config.ApprovalConfig{
+		Default: "always",
+		Tools:   map[string]string{"write_file": "ask", "spi": "bogus"},
+		Rules: []config.ApprovalRule{
+			{Tool: "exec", Pattern: `\bshutdown\b`, Policy: "never"},
+			{Tool: "exec", Pattern: `\brm\b`, Policy: "ask"},
+			{Tool: "*", Pattern: `/etc/`, Policy: "Never"},
+			{Tool: "exec", Pattern: `(`, Policy: "never"},
+		},
+	})
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		want string
+	}{
+		{"exec", map[string]any{"command": "ls -la"}, approvalAlways},
+		{"exec", map[string]any{"command": "rm -rf build"}, approvalAsk},
+		{"exec", map[string]any{"command": "sudo shutdown now; rm x"}, approvalNever},
+		{"read_file", map[string]any{"path": "/etc/passwd"}, approvalNever},
+		{"edit_file", map[string]any{"edits": []any{map[string]any{"path": "/etc/hosts"}}}, approvalNever},
+		{"write_file", map[string]any{"path": "notes.md"}, approvalAsk},
+		{"spi", nil, approvalAsk},
+		{"web_fetch", map[string]any{"url": "https://example.com"}, approvalAlways},
+		{"process", map[string]any{"action": "start", "name": "a", "command": "rm -rf build"}, approvalAsk},
+		{"process", map[string]any{"action": "start", "name": "a", "command": "shutdown"}, approvalNever},
+		{"process", map[string]any{"action": "write", "name": "a", "input": "rm x\n"}, approvalAsk},
+		{"process", map[string]any{"action": "read", "name": "rm"}, approvalAlways},
+		{"apply_patch", map[string]any{"patch": "--- a/notes.md\n+++ b/notes.md\n"}, approvalAsk},
+		{"apply_patch", map[string]any{"edits": []any{map[string]any{"path": "/etc/hosts"}}}, approvalNever},
+	}
+	for _, tt := range tests {
+		if got := p.decide(tt.tool, tt.args); got != tt.want {
+			t.Errorf("decide(%s, %v) = %s, want %s", tt.tool, tt.args, got, tt.want)
+		}
+	}
+
+	if got := newApprovalPolicy(config.ApprovalConfig{}).decide("exec", nil); got != approvalAlways {
+		t.Errorf("empty config: decide = %s, want always", got)
+	}
+}
+
+func TestBatchToolCalls_ExclusiveCallsRunAlone(t *testing.T) {
+	registry, _ := newSleepRegistry(0)
+	exclusive := func(tc providers.ToolCall) bool { return tc.ID == "call_1" }
+
+	batches := batchToolCalls(registry, calls("fetch", "fetch", "fetch"), 4, exclusive)
+	if len(batches) != 3 || batches[1].parallel || len(batches[1].calls) != 1 {
+		t.Errorf("expected the exclusive call in its own batch, got %+v", batches)
+	}
+}
+
+// countingTool records how often it ran.
+type countingTool struct {
+	name string
+	runs atomic.Int32
+}
+
+func (t *countingTool) Name() string               { return t.name }
+func (t *countingTool) Description() string        { return "counts" }
+func (t *countingTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (t *countingTool) Execute(context.Context, map[string]any) *tools.ToolResult {
+	t.runs.Add(1)
+	return tools.SilentResult("ran")
+}
+
+func newApprovalTestLoop(
+	t *testing.T,
+	workspace string,
+	approval config.ApprovalConfig,
+	provider providers.LLMProvider,
+) (*AgentLoop, *bus.MessageBus, *countingTool) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+		Tools: config.ToolsConfig{Approval: approval},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, provider)
+	tool := &countingTool{name: "deploy"}
+	al.registry.GetDefaultAgent().Tools.Register(tool)
+	return al, msgBus, tool
+}
+
+func startLoop(t *testing.T, al *AgentLoop) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		al.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func nextOutbound(t *testing.T, msgBus *bus.MessageBus) bus.OutboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("expected outbound message")
+	}
+	return out
+}
+
+// approvalID extracts the approval ID from a prompt's approve button.
+func approvalID(t *testing.T, prompt bus.OutboundMessage) string {
+	t.Helper()
+	if len(prompt.Buttons) != 2 || !strings.HasPrefix(prompt.Buttons[0].Data, "/approve ") {
+		t.Fatalf("expected an approval prompt with buttons, got %+v", prompt)
+	}
+	return strings.TrimPrefix(prompt.Buttons[0].Data, "/approve ")
+}
+
+func chatMessage(sender, content string) bus.InboundMessage {
+	return bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: sender,
+		ChatID:   "100",
+		Content:  content,
+		Peer:     bus.Peer{Kind: "direct", ID: sender},
+	}
+}
+
+func TestApproval_ApproveRunsTool(t *testing.T) {
+	provider := &scriptedToolProvider{calls: calls("deploy")}
+	al, msgBus, tool := newApprovalTestLoop(t, t.TempDir(),
+		config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}, provider)
+	startLoop(t, al)
+
+	ctx := context.Background()
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "ship it"))
+
+	prompt := nextOutbound(t, msgBus)
+	id := approvalID(t, prompt)
+	if !strings.Contains(prompt.Content, "/deny "+id) {
+		t.Errorf("prompt should mention the text commands, got %q", prompt.Content)
+	}
+	if tool.runs.Load() != 0 {
+		t.Fatal("tool ran before approval")
+	}
+
+	msgBus.PublishInbound(ctx, chatMessage("telegram:2", "/approve "+id))
+	if out := nextOutbound(t, msgBus); !strings.Contains(out.Content, "not allowed") {
+		t.Errorf("expected other users to be refused, got %q", out.Content)
+	}
+
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "/approve "+id))
+	if out := nextOutbound(t, msgBus); out.Content != "Approved: running deploy" {
+		t.Errorf("ack = %q", out.Content)
+	}
+	if out := nextOutbound(t, msgBus); out.Content != "done" {
+		t.Errorf("final response = %q, want done", out.Content)
+	}
+	if tool.runs.Load() != 1 {
+		t.Errorf("tool ran %d times, want 1", tool.runs.Load())
+	}
+	if pending := al.approvals.list(); len(pending) != 0 {
+		t.Errorf("approval still pending: %+v", pending)
+	}
+}
+
+func TestApproval_ButtonsClearedOnlyWhenAnswered(t *testing.T) {
+	provider := &scriptedToolProvider{calls: calls("deploy")}
+	al, msgBus, _ := newApprovalTestLoop(t, t.TempDir(),
+		config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}, provider)
+	startLoop(t, al)
+
+	ctx := context.Background()
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "ship it"))
+	id := approvalID(t, nextOutbound(t, msgBus))
+
+	press := func(sender string) bus.InboundMessage {
+		msg := chatMessage(sender, "/approve "+id)
+		msg.MessageID = "55"
+		msg.Metadata = map[string]string{"callback": "true"}
+		return msg
+	}
+	msgBus.PublishInbound(ctx, press("telegram:2"))
+	if out := nextOutbound(t, msgBus); out.ClearButtonsOf != "" {
+		t.Errorf("a refused press should leave the buttons, got %+v", out)
+	}
+	msgBus.PublishInbound(ctx, press("telegram:1"))
+	if out := nextOutbound(t, msgBus); out.ClearButtonsOf != "55" {
+		t.Errorf("an accepted press should clear the prompt's buttons, got %+v", out)
+	}
+}
+
+func TestApproval_DenyAbortsTurn(t *testing.T) {
+	provider := &scriptedToolProvider{calls: calls("deploy", "deploy")}
+	al, msgBus, tool := newApprovalTestLoop(t, t.TempDir(),
+		config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}, provider)
+	startLoop(t, al)
+
+	ctx := context.Background()
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "ship it"))
+	id := approvalID(t, nextOutbound(t, msgBus))
+
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "/deny "+id))
+	nextOutbound(t, msgBus) // ack
+	if out := nextOutbound(t, msgBus); out.Content != "Cancelled: running deploy was denied." {
+		t.Errorf("final response = %q", out.Content)
+	}
+	if tool.runs.Load() != 0 {
+		t.Errorf("denied tool ran")
+	}
+	if n := provider.n.Load(); n != 1 {
+		t.Errorf("LLM called %d times, want the turn to end after the denial", n)
+	}
+
+	var results []string
+	for _, m := range al.registry.GetDefaultAgent().Sessions.GetHistory(al.dispatchKey(chatMessage("telegram:1", ""))) {
+		if m.Role == "tool" {
+			results = append(results, m.ToolCallID)
+		}
+	}
+	if len(results) != 2 {
+		t.Errorf("expected results recorded for both calls, got %v", results)
+	}
+}
+
+func TestApproval_Timeout(t *testing.T) {
+	provider := &scriptedToolProvider{calls: calls("deploy")}
+	al, msgBus, tool := newApprovalTestLoop(t, t.TempDir(),
+		config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}, TimeoutSeconds: 1}, provider)
+	startLoop(t, al)
+
+	msgBus.PublishInbound(context.Background(), chatMessage("telegram:1", "ship it"))
+	approvalID(t, nextOutbound(t, msgBus))
+	if out := nextOutbound(t, msgBus); !strings.Contains(out.Content, "not approved in time") {
+		t.Errorf("final response = %q", out.Content)
+	}
+	if tool.runs.Load() != 0 {
+		t.Errorf("expired tool ran")
+	}
+}
+
+func TestApproval_ParkedTurnFreesWorker(t *testing.T) {
+	provider := &scriptedToolProvider{calls: calls("deploy")}
+	al, msgBus, tool := newApprovalTestLoop(t, t.TempDir(),
+		config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}, provider)
+	al.dispatcher = newSessionDispatcher(1, al.handleInbound)
+	startLoop(t, al)
+
+	ctx := context.Background()
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "ship it"))
+	id := approvalID(t, nextOutbound(t, msgBus))
+
+	// A follow-up in the parked session waits for the approval...
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "still there?"))
+
+	// ...while another session gets the only worker.
+	group := chatMessage("telegram:2", "hello")
+	group.ChatID = "200"
+	group.Peer = bus.Peer{Kind: "group", ID: "200"}
+	msgBus.PublishInbound(ctx, group)
+	if out := nextOutbound(t, msgBus); out.ChatID != "200" || out.Content != "done" {
+		t.Fatalf("expected the other session to be answered, got %+v", out)
+	}
+
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "/approve "+id))
+	for _, want := range []string{"Approved: running deploy", "done", "done"} {
+		if out := nextOutbound(t, msgBus); out.ChatID != "100" || out.Content != want {
+			t.Errorf("got %q in chat %s, want %q", out.Content, out.ChatID, want)
+		}
+	}
+	if tool.runs.Load() != 1 {
+		t.Errorf("tool ran %d times, want 1", tool.runs.Load())
+	}
+}
+
+func TestApproval_NeverAndUnavailable(t *testing.T) {
+	provider := &scriptedToolProvider{calls: calls("deploy")}
+	al, _, tool := newApprovalTestLoop(t, t.TempDir(),
+		config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}, provider)
+
+	// Nobody can be asked on the CLI without a prompter.
+	resp, err := al.ProcessDirect(context.Background(), "ship it", "agent:main:cli")
+	if err != nil || !strings.Contains(resp, "cannot be requested") {
+		t.Errorf("ProcessDirect = %q, %v", resp, err)
+	}
+
+	provider.n.Store(0)
+	var asked ApprovalRequest
+	al.SetApprovalPrompter(func(_ context.Context, req ApprovalRequest) bool {
+		asked = req
+		return true
+	})
+	if resp, _ := al.ProcessDirect(context.Background(), "ship it", "agent:main:cli"); resp != "done" {
+		t.Errorf("approved via prompter: response = %q", resp)
+	}
+	if asked.Tool != "deploy" || tool.runs.Load() != 1 {
+		t.Errorf("prompter asked %+v, tool runs %d", asked, tool.runs.Load())
+	}
+
+	provider.n.Store(0)
+	al.approvalPolicy = newApprovalPolicy(config.ApprovalConfig{Tools: map[string]string{"deploy": "never"}})
+	al.ProcessDirect(context.Background(), "ship it", "agent:main:cli")
+	if tool.runs.Load() != 1 {
+		t.Errorf("tool with policy never ran")
+	}
+}
+
+func TestApproval_ResumesAfterRestart(t *testing.T) {
+	workspace := t.TempDir()
+	approval := config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}
+
+	al, msgBus, _ := newApprovalTestLoop(t, workspace, approval, &scriptedToolProvider{calls: calls("deploy")})
+	resp, err := al.ProcessDirectWithChannel(context.Background(), "ship it", "agent:main:ops", "telegram", "100")
+	if err != nil || resp != "" {
+		t.Fatalf("parked turn returned %q, %v", resp, err)
+	}
+	id := approvalID(t, nextOutbound(t, msgBus))
+
+	// A new loop picks the approval up from the workspace state.
+	provider := &scriptedToolProvider{}
+	provider.n.Store(1)
+	al2, msgBus2, tool := newApprovalTestLoop(t, workspace, approval, provider)
+	if _, ok := al2.approvals.get(id); !ok {
+		t.Fatalf("approval %s not restored", id)
+	}
+	startLoop(t, al2)
+
+	msgBus2.PublishInbound(context.Background(), chatMessage("telegram:7", "/approve "+id))
+	if out := nextOutbound(t, msgBus2); out.Content != "Approved: running deploy" {
+		t.Errorf("ack = %q", out.Content)
+	}
+	if out := nextOutbound(t, msgBus2); out.Content != "done" {
+		t.Errorf("resumed turn response = %q, want done", out.Content)
+	}
+	if tool.runs.Load() != 1 {
+		t.Errorf("tool ran %d times after resume, want 1", tool.runs.Load())
+	}
+
+	history := al2.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:ops")
+	if n := len(history); n < 2 || history[n-2].Role != "tool" || history[n-1].Content != "done" {
+		t.Errorf("unexpected history after resume: %+v", history)
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// budgetBreach describes the first budget limit an agent has reached.
+type budgetBreach struct {
+	period string // "daily" or "monthly"
+	cost   bool   // cost limit (true) or token limit (false)
+	used   float64
+	limit  float64
+}
+
+func (b budgetBreach) String() string {
+	if b.cost {
+		return fmt.Sprintf("%s budget of $%.2f has been reached ($%.2f spent)", b.period, b.limit, b.used)
+	}
+	return fmt.Sprintf("%s budget of %.0f tokens has been reached (%.0f used)", b.period, b.limit, b.used)
+}
+
+// checkBudget returns the first limit in b that day or month has reached.
+func checkBudget(b *config.BudgetConfig, day, month usage.Totals) (budgetBreach, bool) {
+	if b == nil {
+		return budgetBreach{}, false
+	}
+	switch {
+	case b.DailyUSD > 0 && day.CostUSD >= b.DailyUSD:
+		return budgetBreach{period: "daily", cost: true, used: day.CostUSD, limit: b.DailyUSD}, true
+	case b.MonthlyUSD > 0 && month.CostUSD >= b.MonthlyUSD:
+		return budgetBreach{period: "monthly", cost: true, used: month.CostUSD, limit: b.MonthlyUSD}, true
+	case b.DailyTokens > 0 && day.TotalTokens >= b.DailyTokens:
+		return budgetBreach{period: "daily", used: float64(day.TotalTokens), limit: float64(b.DailyTokens)}, true
+	case b.MonthlyTokens > 0 && month.TotalTokens >= b.MonthlyTokens:
+		return budgetBreach{period: "monthly", used: float64(month.TotalTokens), limit: float64(b.MonthlyTokens)}, true
+	}
+	return budgetBreach{}, false
+}
+
+// applyBudget enforces the agent's budget before a turn. Within budget the
+// agent is returned unchanged. Past a cost limit the turn runs on the
+// cheapest candidate that is cheaper than the primary model, if any;
+// otherwise a refusal message for the user is returned.
+func (al *AgentLoop) applyBudget(agent *AgentInstance) (*AgentInstance, string) {
+	if agent.Budget == nil || al.usageTracker == nil {
+		return agent, ""
+	}
+	day, month := al.usageTracker.AgentTotals(agent.ID)
+	breach, exceeded := checkBudget(agent.Budget, day, month)
+	if !exceeded {
+		return agent, ""
+	}
+
+	if breach.cost {
+		if c, ok := al.cheaperCandidate(agent); ok {
+			logger.WarnCF("agent", "Budget reached, using cheaper model", map[string]any{
+				"agent_id": agent.ID,
+				"budget":   breach.String(),
+				"model":    c.Model,
+			})
+			downgraded := *agent
+			downgraded.Provider, downgraded.Model = agent.CandidateProvider(c.Provider, c.Model)
+			downgraded.Candidates = []providers.FallbackCandidate{c}
+			downgraded.ContextWindow = al.contextWindowOf(agent, c.Provider, c.Model)
+			return &downgraded, ""
+		}
+	}
+
+	logger.WarnCF("agent", "Budget reached, refusing request", map[string]any{
+		"agent_id": agent.ID,
+		"budget":   breach.String(),
+	})
+	retry := "tomorrow"
+	if breach.period == "monthly" {
+		retry = "next month"
+	}
+	return agent, fmt.Sprintf("Sorry, this agent's %s. Please try again %s.", breach.String(), retry)
+}
+
+// cheaperCandidate returns the lowest-priced fallback candidate that costs
+// less than the agent's primary model. Models without input_price and
+// output_price have no known price and are never picked; if the primary
+// itself is unpriced there is nothing to compare against.
+func (al *AgentLoop) cheaperCandidate(agent *AgentInstance) (providers.FallbackCandidate, bool) {
+	if len(agent.Candidates) < 2 {
+		return providers.FallbackCandidate{}, false
+	}
+	priceOf := func(c providers.FallbackCandidate) (float64, bool) {
+		p := al.models.ModelPrice(providers.ModelKey(c.Provider, c.Model))
+		return p.Input + p.Output, p.Input > 0 || p.Output > 0
+	}
+
+	best := agent.Candidates[0]
+	bestPrice, ok := priceOf(best)
+	if !ok {
+		return providers.FallbackCandidate{}, false
+	}
+	found := false
+	for _, c := range agent.Candidates[1:] {
+		if price, ok := priceOf(c); ok && price < bestPrice {
+			best, bestPrice, found = c, price, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func newBudgetTestLoop(t *testing.T, fallbacks []string) (*AgentLoop, *recordingImageProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "premium",
+				ModelFallbacks:    fallbacks,
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+				Budget:            &config.BudgetConfig{DailyUSD: 1},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "premium", Model: "openai/gpt-premium", InputPrice: 10, OutputPrice: 30},
+			{
+				ModelName: "cheap", Model: "openai/gpt-cheap", InputPrice: 0.1, OutputPrice: 0.4,
+				Capabilities: &config.ModelCapabilitiesConfig{ContextWindow: 16000},
+			},
+			{ModelName: "unpriced", Model: "openai/gpt-unpriced"},
+			{
+				ModelName: "large", Model: "groq/gpt-large",
+				Capabilities: &config.ModelCapabilitiesConfig{ContextWindow: 32000},
+			},
+		},
+	}
+	provider := &recordingImageProvider{}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider), provider
+}
+
+func TestCheckBudget(t *testing.T) {
+	b := &config.BudgetConfig{DailyUSD: 1, MonthlyTokens: 1000}
+
+	if _, exceeded := checkBudget(b, usage.Totals{CostUSD: 0.5}, usage.Totals{TotalTokens: 10}); exceeded {
+		t.Error("expected usage within budget")
+	}
+	breach, exceeded := checkBudget(b, usage.Totals{CostUSD: 1.2}, usage.Totals{})
+	if !exceeded || !breach.cost || breach.period != "daily" {
+		t.Errorf("expected daily cost breach, got %+v", breach)
+	}
+	breach, exceeded = checkBudget(b, usage.Totals{}, usage.Totals{TotalTokens: 1000})
+	if !exceeded || breach.cost || breach.period != "monthly" {
+		t.Errorf("expected monthly token breach, got %+v", breach)
+	}
+	if _, exceeded := checkBudget(nil, usage.Totals{CostUSD: 100}, usage.Totals{}); exceeded {
+		t.Error("expected no budget to mean no limit")
+	}
+}
+
+func TestAgentLoop_BudgetFallsBackToCheaperModel(t *testing.T) {
+	al, provider := newBudgetTestLoop(t, []string{"cheap"})
+	msg := bus.InboundMessage{Channel: "test", SenderID: "u", ChatID: "c", Content: "hi"}
+
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	al.usageTracker.Record(usage.Entry{AgentID: "main", Model: "premium", CostUSD: 2})
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(provider.models) != 2 || provider.models[0] != "gpt-premium" || provider.models[1] != "gpt-cheap" {
+		t.Fatalf("expected premium then cheap model, got %v", provider.models)
+	}
+
+	downgraded, _ := al.applyBudget(turnAgent(al.registry.GetDefaultAgent()))
+	if downgraded.ContextWindow != 16000 {
+		t.Errorf("downgraded ContextWindow = %d, want the cheap model's 16000", downgraded.ContextWindow)
+	}
+}
+
+func TestAgentLoop_FallbackResizesContextWindow(t *testing.T) {
+	al, provider := newBudgetTestLoop(t, []string{"large"})
+	provider.failFirst = errors.New("status: 429 rate limited")
+	primary := al.registry.GetDefaultAgent()
+
+	agent := turnAgent(primary)
+	messages := []providers.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "hi"}}
+	if _, _, err := al.runLLMIteration(context.Background(), agent, messages, processOptions{
+		SessionKey: "s", Channel: "test", ChatID: "c",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.models) != 2 || provider.models[1] != "gpt-large" {
+		t.Fatalf("expected the fallback to answer, got %v", provider.models)
+	}
+	if agent.ContextWindow != 32000 {
+		t.Errorf("turn ContextWindow = %d, want the fallback's 32000", agent.ContextWindow)
+	}
+	if primary.ContextWindow != 4096 {
+		t.Errorf("agent ContextWindow = %d, want it left at 4096", primary.ContextWindow)
+	}
+}
+
+func TestAgentLoop_BudgetRefusesWithoutCheaperModel(t *testing.T) {
+	// An unpriced fallback has no known cost, so it is not a cheaper model.
+	for _, fallbacks := range [][]string{nil, {"unpriced"}} {
+		al, provider := newBudgetTestLoop(t, fallbacks)
+		al.usageTracker.Record(usage.Entry{AgentID: "main", Model: "premium", CostUSD: 2})
+
+		resp, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel: "test", SenderID: "u", ChatID: "c", Content: "hi",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(provider.models) != 0 {
+			t.Fatalf("fallbacks %v: expected no LLM call over budget, got %v", fallbacks, provider.models)
+		}
+		if !strings.Contains(resp, "daily budget of $1.00") {
+			t.Errorf("fallbacks %v: expected a clear refusal, got %q", fallbacks, resp)
+		}
+	}
+}
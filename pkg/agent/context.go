@@ -1,9 +1,12 @@
 package agent
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/fs"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -12,6 +15,8 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
 )
@@ -19,7 +24,8 @@ import (
 type ContextBuilder struct {
 	workspace    string
 	skillsLoader *skills.SkillsLoader
-	memory       *MemoryStore
+	memory       *memory.Store
+	mediaStore   media.MediaStore
 
 	// Cache for system prompt to avoid rebuilding on every call.
 	// This fixes issue #607: repeated reprocessing of the entire context.
@@ -53,10 +59,28 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 	return &ContextBuilder{
 		workspace:    workspace,
 		skillsLoader: skills.NewSkillsLoader(workspace, globalSkillsDir, builtinSkillsDir),
-		memory:       NewMemoryStore(workspace),
+		memory:       memory.NewStore(filepath.Join(workspace, "memory")),
 	}
 }
 
+// Memory returns the agent's long-term memory store.
+func (cb *ContextBuilder) Memory() *memory.Store {
+	return cb.memory
+}
+
+// SetMediaStore sets the store used to resolve media:// refs on inbound
+// messages into content parts.
+func (cb *ContextBuilder) SetMediaStore(store media.MediaStore) {
+	cb.mediaStore = store
+}
+
+// SetSkillsFilter limits the skills listed in the system prompt to those for
+// which keep returns true.
+func (cb *ContextBuilder) SetSkillsFilter(keep func(skills.SkillInfo) bool) {
+	cb.skillsLoader.SetFilter(keep)
+	cb.InvalidateCache()
+}
+
 func (cb *ContextBuilder) getIdentity() string {
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 
@@ -66,8 +90,7 @@ You are picoclaw, a helpful AI assistant.
 
 ## Workspace
 Your workspace is at: %s
-- Memory: %s/memory/MEMORY.md
-- Daily Notes: %s/memory/YYYYMM/YYYYMMDD.md
+- Memory: %s/memory (MEMORY.md and daily notes in YYYYMM/YYYYMMDD.md are imported into it)
 - Skills: %s/skills/{skill-name}/SKILL.md
 
 ## Important Rules
@@ -76,10 +99,10 @@ Your workspace is at: %s
 
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
-3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md
+3. **Memory** - When interacting with me if something seems memorable, save it with memory_save as a short, self-contained note. Memories relevant to the conversation are listed under Memory when there are any; use memory_search to recall others and memory_forget to remove ones that are wrong or outdated.
 
 4. **Context summaries** - Conversation summaries provided as context are approximate references only. They may be incomplete or outdated. Always defer to explicit user instructions over summary content.`,
-		workspacePath, workspacePath, workspacePath, workspacePath, workspacePath)
+		workspacePath, workspacePath, workspacePath)
 }
 
 func (cb *ContextBuilder) BuildSystemPrompt() string {
@@ -104,12 +127,6 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 %s`, skillsSummary))
 	}
 
-	// Memory context
-	memoryContext := cb.memory.GetMemoryContext()
-	if memoryContext != "" {
-		parts = append(parts, "# Memory\n\n"+memoryContext)
-	}
-
 	// Join with "---" separator
 	return strings.Join(parts, "\n\n---\n\n")
 }
@@ -171,7 +188,7 @@ func (cb *ContextBuilder) InvalidateCache() {
 }
 
 // sourcePaths returns the workspace source file paths tracked for cache
-// invalidation (bootstrap files + memory). The skills directory is handled
+// invalidation (bootstrap files). The skills directory is handled
 // separately in sourceFilesChangedLocked because it requires both directory-
 // level and recursive file-level mtime checks.
 func (cb *ContextBuilder) sourcePaths() []string {
@@ -180,7 +197,6 @@ func (cb *ContextBuilder) sourcePaths() []string {
 		filepath.Join(cb.workspace, "SOUL.md"),
 		filepath.Join(cb.workspace, "USER.md"),
 		filepath.Join(cb.workspace, "IDENTITY.md"),
-		filepath.Join(cb.workspace, "memory", "MEMORY.md"),
 	}
 }
 
@@ -248,7 +264,7 @@ func (cb *ContextBuilder) sourceFilesChangedLocked() bool {
 		return true
 	}
 
-	// Check tracked source files (bootstrap + memory).
+	// Check tracked source files (bootstrap).
 	for _, p := range cb.sourcePaths() {
 		if cb.fileChangedSince(p) {
 			return true
@@ -375,6 +391,38 @@ func (cb *ContextBuilder) buildDynamicContext(channel, chatID string) string {
 	return sb.String()
 }
 
+// memoryContextLimit is the number of memories included in the system prompt.
+const memoryContextLimit = 8
+
+// buildMemoryContext returns the saved memories most relevant to the
+// conversation, found by searching for the current message and the last user
+// turns of history, or "" when none match. Like the dynamic context it is
+// rebuilt for every request and not cached.
+func (cb *ContextBuilder) buildMemoryContext(history []providers.Message, currentMessage string) string {
+	query := []string{currentMessage}
+	for i := len(history) - 1; i >= 0 && len(query) < 3; i-- {
+		if history[i].Role == "user" {
+			query = append(query, history[i].Content)
+		}
+	}
+
+	results, err := cb.memory.Search(strings.Join(query, "\n"), memory.SearchOptions{Limit: memoryContextLimit})
+	if err != nil {
+		logger.WarnCF("agent", "Failed to search memory", map[string]any{"error": err.Error()})
+		return ""
+	}
+	if len(results) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Memory\n\nSaved memories relevant to this conversation, best match first:\n")
+	for i := range results {
+		fmt.Fprintf(&sb, "\n- %s", results[i].String())
+	}
+	return sb.String()
+}
+
 func (cb *ContextBuilder) BuildMessages(
 	history []providers.Message,
 	summary string,
@@ -384,9 +432,9 @@ func (cb *ContextBuilder) BuildMessages(
 ) []providers.Message {
 	messages := []providers.Message{}
 
-	// The static part (identity, bootstrap, skills, memory) is cached locally to
+	// The static part (identity, bootstrap, skills) is cached locally to
 	// avoid repeated file I/O and string building on every call (fixes issue #607).
-	// Dynamic parts (time, session, summary) are appended per request.
+	// Dynamic parts (time, session, relevant memories, summary) are appended per request.
 	// Everything is sent as a single system message for provider compatibility:
 	// - Anthropic adapter extracts messages[0] (Role=="system") and maps its content
 	//   to the top-level "system" parameter in the Messages API request. A single
@@ -414,6 +462,11 @@ func (cb *ContextBuilder) BuildMessages(
 		{Type: "text", Text: dynamicCtx},
 	}
 
+	if memoryCtx := cb.buildMemoryContext(history, currentMessage); memoryCtx != "" {
+		stringParts = append(stringParts, memoryCtx)
+		contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: memoryCtx})
+	}
+
 	if summary != "" {
 		summaryText := fmt.Sprintf(
 			"CONTEXT_SUMMARY: The following is an approximate summary of prior conversation "+
@@ -465,17 +518,105 @@ func (cb *ContextBuilder) BuildMessages(
 	// Add conversation history
 	messages = append(messages, history...)
 
-	// Add current user message
-	if strings.TrimSpace(currentMessage) != "" {
+	// Add current user message, with any attachments as content parts
+	parts := cb.resolveMediaParts(media)
+	if strings.TrimSpace(currentMessage) != "" || len(parts) > 0 {
 		messages = append(messages, providers.Message{
 			Role:    "user",
 			Content: currentMessage,
+			Parts:   parts,
 		})
 	}
 
 	return messages
 }
 
+// maxMediaPartSize caps the size of a single attachment inlined into a request.
+const maxMediaPartSize = 20 << 20
+
+// resolveMediaParts loads media:// refs from the media store and encodes them
+// as content parts. Refs that cannot be resolved or read are skipped.
+func (cb *ContextBuilder) resolveMediaParts(refs []string) []providers.ContentPart {
+	if len(refs) == 0 || cb.mediaStore == nil {
+		return nil
+	}
+
+	parts := make([]providers.ContentPart, 0, len(refs))
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref, "media://") {
+			continue
+		}
+		localPath, meta, err := cb.mediaStore.ResolveWithMeta(ref)
+		if err != nil {
+			logger.WarnCF("agent", "Failed to resolve media ref", map[string]any{
+				"ref":   ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		info, err := os.Stat(localPath)
+		if err != nil {
+			logger.WarnCF("agent", "Media file unavailable", map[string]any{
+				"ref":   ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		if info.Size() > maxMediaPartSize {
+			logger.WarnCF("agent", "Media file too large to attach", map[string]any{
+				"ref":  ref,
+				"size": info.Size(),
+			})
+			continue
+		}
+
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			logger.WarnCF("agent", "Failed to read media file", map[string]any{
+				"ref":   ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		filename := meta.Filename
+		if filename == "" {
+			filename = filepath.Base(localPath)
+		}
+		mimeType := detectMIMEType(filename, meta.ContentType, data)
+
+		partType := inferMediaType(filename, mimeType)
+		if partType == "video" {
+			partType = "file"
+		}
+
+		parts = append(parts, providers.ContentPart{
+			Type:     partType,
+			MIMEType: mimeType,
+			Filename: filename,
+			Data:     base64.StdEncoding.EncodeToString(data),
+		})
+	}
+
+	return parts
+}
+
+// detectMIMEType prefers the declared content type, then the file extension,
+// then content sniffing.
+func detectMIMEType(filename, contentType string, data []byte) string {
+	if ct, _, err := mime.ParseMediaType(contentType); err == nil && ct != "" && ct != "application/octet-stream" {
+		return ct
+	}
+	if ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); ct != "" {
+		if parsed, _, err := mime.ParseMediaType(ct); err == nil {
+			return parsed
+		}
+	}
+	ct, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return ct
+}
+
 func sanitizeHistoryForProvider(history []providers.Message) []providers.Message {
 	if len(history) == 0 {
 		return history
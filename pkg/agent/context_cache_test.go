@@ -146,10 +146,10 @@ func TestMtimeAutoInvalidation(t *testing.T) {
 			checkField: "Updated Identity",
 		},
 		{
-			name:       "memory file change",
-			file:       "memory/MEMORY.md",
-			contentV1:  "# Memory\nUser likes Go.",
-			contentV2:  "# Memory\nUser likes Rust.",
+			name:       "user file change",
+			file:       "USER.md",
+			contentV1:  "# User\nUser likes Go.",
+			contentV2:  "# User\nUser likes Rust.",
 			checkField: "User likes Rust",
 		},
 	}
@@ -286,9 +286,9 @@ func TestNewFileCreationInvalidatesCache(t *testing.T) {
 			checkField: "Be kind and helpful",
 		},
 		{
-			name:       "new memory file",
-			file:       "memory/MEMORY.md",
-			content:    "# Memory\nUser prefers dark mode.",
+			name:       "new user file",
+			file:       "USER.md",
+			content:    "# User\nUser prefers dark mode.",
 			checkField: "User prefers dark mode",
 		},
 	}
@@ -1,8 +1,13 @@
 package agent
 
 import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -207,3 +212,87 @@ func assertRoles(t *testing.T, msgs []providers.Message, expected ...string) {
 		}
 	}
 }
+
+func TestBuildMessages_ResolvesMediaRefs(t *testing.T) {
+	tmpDir := t.TempDir()
+	imgPath := filepath.Join(tmpDir, "photo.png")
+	pngHeader := []byte("\x89PNG\r\n\x1a\n0000")
+	if err := os.WriteFile(imgPath, pngHeader, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := media.NewFileMediaStore()
+	ref, err := store.Store(imgPath, media.MediaMeta{Filename: "photo.png"}, "scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := NewContextBuilder(tmpDir)
+	cb.SetMediaStore(store)
+
+	msgs := cb.BuildMessages(nil, "", "what is this?", []string{ref, "media://missing"}, "test", "chat1")
+	last := msgs[len(msgs)-1]
+	if last.Role != "user" || last.Content != "what is this?" {
+		t.Fatalf("unexpected user message: %+v", last)
+	}
+	if len(last.Parts) != 1 {
+		t.Fatalf("expected 1 content part, got %d", len(last.Parts))
+	}
+	part := last.Parts[0]
+	if part.Type != "image" || part.MIMEType != "image/png" || part.Filename != "photo.png" {
+		t.Errorf("unexpected part metadata: %+v", part)
+	}
+	if part.Data != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Errorf("unexpected part data %q", part.Data)
+	}
+}
+
+func TestBuildMessages_MediaOnlyMessage(t *testing.T) {
+	tmpDir := t.TempDir()
+	docPath := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(docPath, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := media.NewFileMediaStore()
+	ref, err := store.Store(docPath, media.MediaMeta{Filename: "notes.txt"}, "scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := NewContextBuilder(tmpDir)
+	cb.SetMediaStore(store)
+
+	msgs := cb.BuildMessages(nil, "", "", []string{ref}, "test", "chat1")
+	last := msgs[len(msgs)-1]
+	if last.Role != "user" || len(last.Parts) != 1 || last.Parts[0].Type != "file" {
+		t.Fatalf("expected a user message with one file part, got %+v", last)
+	}
+}
+
+func TestBuildMessages_IncludesRelevantMemories(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "memory"), 0o755)
+	os.WriteFile(filepath.Join(tmpDir, "memory", "MEMORY.md"),
+		[]byte("- The user's dog is named Biscuit\n- The user works night shifts\n"), 0o644)
+
+	cb := NewContextBuilder(tmpDir)
+	cb.Memory().Save("The user is allergic to peanuts", []string{"health"}, "cli:direct")
+
+	history := []providers.Message{msg("user", "any snack ideas?"), msg("assistant", "Sure!")}
+	sys := cb.BuildMessages(history, "", "is this granola ok with my peanut allergy?", nil, "cli", "direct")[0]
+	if !strings.Contains(sys.Content, "allergic to peanuts") {
+		t.Errorf("expected the relevant memory in the system prompt, got:\n%s", sys.Content)
+	}
+	if strings.Contains(sys.Content, "Biscuit") || strings.Contains(sys.Content, "night shifts") {
+		t.Error("expected unrelated memories to be left out of the system prompt")
+	}
+	if strings.Contains(cb.BuildSystemPromptWithCache(), "peanuts") {
+		t.Error("expected memories to stay out of the cached static prompt")
+	}
+
+	sys = cb.BuildMessages(nil, "", "tell me about Biscuit", nil, "cli", "direct")[0]
+	if !strings.Contains(sys.Content, "dog is named Biscuit") {
+		t.Error("expected memories imported from MEMORY.md to be retrievable")
+	}
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// defaultMaxDelegationDepth applies to agents without subagents.max_depth.
+const defaultMaxDelegationDepth = 3
+
+// delegator runs tasks spawned with an agent_id on the target agent from the
+// registry, with that agent's model, workspace, skills, tools and budget.
+// Each task gets its own session in the target agent's namespace. Its result
+// goes back to the delegating agent, so tool calls that need approval are
+// refused rather than parked until someone answers in the chat.
+type delegator struct {
+	al *AgentLoop
+}
+
+// enableDelegation lets every agent's spawned tasks run on other agents.
+func (al *AgentLoop) enableDelegation() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || agent.Tasks == nil {
+			continue
+		}
+		maxDepth := defaultMaxDelegationDepth
+		if agent.Subagents != nil && agent.Subagents.MaxDepth > 0 {
+			maxDepth = agent.Subagents.MaxDepth
+		}
+		agent.Tasks.SetDelegator(agent.ID, maxDepth, delegator{al: al})
+	}
+}
+
+func (d delegator) ResolveAgent(agentID string) (string, bool) {
+	agent, ok := d.al.registry.GetAgent(agentID)
+	if !ok {
+		return "", false
+	}
+	return agent.ID, true
+}
+
+func (d delegator) Delegate(ctx context.Context, req tools.DelegateRequest) (string, error) {
+	agent, ok := d.al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "", fmt.Errorf("agent %q not found", req.AgentID)
+	}
+
+	chain := tools.DelegationChain(ctx)
+	parentID := routing.DefaultAgentID
+	if len(chain) > 1 {
+		parentID = chain[len(chain)-2]
+	}
+	sessionKey := routing.BuildAgentDelegationSessionKey(agent.ID, parentID, req.TaskID)
+
+	logger.InfoCF("agent", "Running delegated task",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"task_id":     req.TaskID,
+			"chain":       strings.Join(chain, " -> "),
+			"session_key": sessionKey,
+		})
+
+	return d.al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         req.Channel,
+		ChatID:          req.ChatID,
+		UserMessage:     fmt.Sprintf("[Delegated by agent %s] %s", parentID, req.Task),
+		DefaultResponse: defaultResponse,
+		EnableSummary:   false,
+		SendResponse:    false,
+		NoApprovalWait:  true,
+	})
+}
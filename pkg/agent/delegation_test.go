@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// modelEchoProvider answers with the model it was called with.
+type modelEchoProvider struct {
+	mu    sync.Mutex
+	users []string
+}
+
+func (p *modelEchoProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	_ []providers.ToolDefinition,
+	model string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	p.users = append(p.users, messages[len(messages)-1].Content)
+	p.mu.Unlock()
+	return &providers.LLMResponse{Content: "answered by " + model}, nil
+}
+
+func (p *modelEchoProvider) GetDefaultModel() string { return "test" }
+
+func TestDelegation_RunsOnTargetAgent(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         filepath.Join(dir, "main"),
+				Model:             "test",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true, Subagents: &config.SubagentsConfig{AllowAgents: []string{"coder"}}},
+				{
+					ID:        "coder",
+					Workspace: filepath.Join(dir, "coder"),
+					Model:     &config.AgentModelConfig{Primary: "coder-model"},
+				},
+			},
+		},
+	}
+	provider := &modelEchoProvider{}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, provider)
+	mainAgent, _ := al.registry.GetAgent("main")
+	coder, _ := al.registry.GetAgent("coder")
+
+	reply, err := mainAgent.Tasks.Spawn(context.Background(), "write code", "", "Coder", "telegram", "100", nil)
+	if err != nil || !strings.Contains(reply, "Delegated task subagent-1 to agent 'coder'") {
+		t.Fatalf("unexpected spawn reply %q, %v", reply, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	announce, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected the result to be announced")
+	}
+	if announce.Metadata["agent_id"] != "main" {
+		t.Errorf("announce should go back to main, got metadata %v", announce.Metadata)
+	}
+	_, payload, _ := strings.Cut(announce.Content, "Result:\n")
+	var result tools.DelegationResult
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		t.Fatalf("result is not a structured payload: %q", announce.Content)
+	}
+	if result.AgentID != "coder" || result.Status != tools.TaskCompleted ||
+		result.Result != "answered by coder-model" || strings.Join(result.Chain, ",") != "main,coder" {
+		t.Errorf("unexpected delegation result %+v", result)
+	}
+
+	history := coder.Sessions.GetHistory("agent:coder:delegate:main:subagent-1")
+	if len(history) != 2 || history[0].Content != "[Delegated by agent main] write code" {
+		t.Errorf("delegated task should run in the coder's session, got %+v", history)
+	}
+}
+
+func TestDelegation_RefusesToolCallsNeedingApproval(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         filepath.Join(dir, "main"),
+				Model:             "test",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true, Subagents: &config.SubagentsConfig{AllowAgents: []string{"ops"}}},
+				{ID: "ops", Workspace: filepath.Join(dir, "ops")},
+			},
+		},
+		Tools: config.ToolsConfig{Approval: config.ApprovalConfig{Tools: map[string]string{"deploy": "ask"}}},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &scriptedToolProvider{calls: calls("deploy")})
+	mainAgent, _ := al.registry.GetAgent("main")
+	ops, _ := al.registry.GetAgent("ops")
+	tool := &countingTool{name: "deploy"}
+	ops.Tools.Register(tool)
+
+	if _, err := mainAgent.Tasks.Spawn(context.Background(), "ship it", "", "ops", "telegram", "100", nil); err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	announce, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected the result to be announced")
+	}
+	_, payload, _ := strings.Cut(announce.Content, "Result:\n")
+	var result tools.DelegationResult
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		t.Fatalf("result is not a structured payload: %q", announce.Content)
+	}
+	if !strings.Contains(result.Result, "needs approval") {
+		t.Errorf("delegating agent should learn why the task stopped, got %+v", result)
+	}
+	if tool.runs.Load() != 0 {
+		t.Error("tool needing approval ran in a delegated task")
+	}
+	if pending := al.approvals.list(); len(pending) != 0 {
+		t.Errorf("delegated task parked on an approval: %+v", pending)
+	}
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// defaultMaxConcurrentSessions is used when agents.defaults.max_concurrent_sessions is unset.
+const defaultMaxConcurrentSessions = 4
+
+// sessionDispatcher runs inbound messages of different sessions in parallel while
+// keeping messages within one session strictly ordered.
+//
+// Each session with pending messages gets a single goroutine that drains its
+// queue in order. The number of messages being processed at the same time is
+// bounded by a shared pool of worker slots. A parked session, e.g. one whose
+// turn waits for a tool call approval, keeps its messages queued without
+// holding a slot until it is unparked.
+type sessionDispatcher struct {
+	mu     sync.Mutex
+	queues map[string]*sessionQueue
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	handle func(ctx context.Context, msg bus.InboundMessage)
+}
+
+type sessionQueue struct {
+	msgs   []bus.InboundMessage
+	active bool // a drain goroutine is running
+	parked bool
+}
+
+func newSessionDispatcher(
+	maxWorkers int,
+	handle func(ctx context.Context, msg bus.InboundMessage),
+) *sessionDispatcher {
+	if maxWorkers <= 0 {
+		maxWorkers = defaultMaxConcurrentSessions
+	}
+	return &sessionDispatcher{
+		queues: make(map[string]*sessionQueue),
+		slots:  make(chan struct{}, maxWorkers),
+		handle: handle,
+	}
+}
+
+// Dispatch enqueues msg on the queue for sessionKey, starting a drain goroutine
+// for the session if none is active and the session is not parked.
+func (d *sessionDispatcher) Dispatch(ctx context.Context, sessionKey string, msg bus.InboundMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q := d.queueLocked(sessionKey)
+	q.msgs = append(q.msgs, msg)
+	d.startLocked(ctx, sessionKey, q)
+}
+
+// Park holds further messages of sessionKey in its queue until Unpark. The
+// message being handled, if any, finishes and frees its slot as usual.
+func (d *sessionDispatcher) Park(sessionKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queueLocked(sessionKey).parked = true
+}
+
+// Unpark puts msg at the front of the queue for sessionKey and resumes
+// draining it.
+func (d *sessionDispatcher) Unpark(ctx context.Context, sessionKey string, msg bus.InboundMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q := d.queueLocked(sessionKey)
+	q.msgs = append([]bus.InboundMessage{msg}, q.msgs...)
+	q.parked = false
+	d.startLocked(ctx, sessionKey, q)
+}
+
+// Wait blocks until every queued message of an unparked session has been
+// handled or dropped.
+func (d *sessionDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *sessionDispatcher) queueLocked(sessionKey string) *sessionQueue {
+	q, ok := d.queues[sessionKey]
+	if !ok {
+		q = &sessionQueue{}
+		d.queues[sessionKey] = q
+	}
+	return q
+}
+
+func (d *sessionDispatcher) startLocked(ctx context.Context, sessionKey string, q *sessionQueue) {
+	if q.active || q.parked || len(q.msgs) == 0 {
+		return
+	}
+	q.active = true
+	d.wg.Add(1)
+	go d.drain(ctx, sessionKey, q)
+}
+
+func (d *sessionDispatcher) drain(ctx context.Context, sessionKey string, q *sessionQueue) {
+	defer d.wg.Done()
+
+	for {
+		d.mu.Lock()
+		if q.parked || len(q.msgs) == 0 {
+			q.active = false
+			if !q.parked {
+				delete(d.queues, sessionKey)
+			}
+			d.mu.Unlock()
+			return
+		}
+		msg := q.msgs[0]
+		q.msgs = q.msgs[1:]
+		d.mu.Unlock()
+
+		acquired := false
+		select {
+		case d.slots <- struct{}{}:
+			acquired = true
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			// Shutting down: drop whatever is left for this session.
+			if acquired {
+				<-d.slots
+			}
+			d.mu.Lock()
+			q.msgs = nil
+			q.active = false
+			if !q.parked {
+				delete(d.queues, sessionKey)
+			}
+			d.mu.Unlock()
+			return
+		}
+
+		d.handle(ctx, msg)
+		<-d.slots
+	}
+}
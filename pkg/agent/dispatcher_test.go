@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestSessionDispatcher_PreservesOrderWithinSession(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+
+	d := newSessionDispatcher(4, func(_ context.Context, msg bus.InboundMessage) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, msg.Content)
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	want := []string{"1", "2", "3", "4", "5"}
+	for _, c := range want {
+		d.Dispatch(ctx, "session-a", bus.InboundMessage{Content: c})
+	}
+	d.Wait()
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, got)
+		}
+	}
+}
+
+func TestSessionDispatcher_RunsSessionsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+
+	d := newSessionDispatcher(2, func(_ context.Context, msg bus.InboundMessage) {
+		started <- msg.Content
+		<-release
+	})
+
+	ctx := context.Background()
+	d.Dispatch(ctx, "session-a", bus.InboundMessage{Content: "a"})
+	d.Dispatch(ctx, "session-b", bus.InboundMessage{Content: "b"})
+
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected both sessions to start while the other is blocked")
+		}
+	}
+	close(release)
+	d.Wait()
+}
+
+func TestSessionDispatcher_LimitsWorkers(t *testing.T) {
+	var active, peak atomic.Int32
+
+	d := newSessionDispatcher(2, func(_ context.Context, _ bus.InboundMessage) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		active.Add(-1)
+	})
+
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		d.Dispatch(ctx, key, bus.InboundMessage{Content: key})
+	}
+	d.Wait()
+
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 concurrent workers, got %d", peak.Load())
+	}
+}
+
+func TestSessionDispatcher_DropsQueuedOnCancel(t *testing.T) {
+	var handled atomic.Int32
+	release := make(chan struct{})
+
+	d := newSessionDispatcher(1, func(_ context.Context, _ bus.InboundMessage) {
+		handled.Add(1)
+		<-release
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.Dispatch(ctx, "a", bus.InboundMessage{Content: "1"})
+	d.Dispatch(ctx, "b", bus.InboundMessage{Content: "2"})
+
+	// Let the first message occupy the only slot, then shut down.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(release)
+	d.Wait()
+
+	if handled.Load() != 1 {
+		t.Errorf("expected only the in-flight message to be handled, got %d", handled.Load())
+	}
+}
+
+func TestSessionDispatcher_ParkHoldsSessionWithoutSlot(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+
+	var d *sessionDispatcher
+	d = newSessionDispatcher(1, func(_ context.Context, msg bus.InboundMessage) {
+		mu.Lock()
+		got = append(got, msg.Content)
+		mu.Unlock()
+		if msg.Content == "a1" {
+			d.Park("a")
+		}
+	})
+
+	ctx := context.Background()
+	d.Dispatch(ctx, "a", bus.InboundMessage{Content: "a1"})
+	d.Wait()
+	d.Dispatch(ctx, "a", bus.InboundMessage{Content: "a2"})
+	d.Dispatch(ctx, "b", bus.InboundMessage{Content: "b1"})
+	d.Wait()
+
+	mu.Lock()
+	if len(got) != 2 || got[1] != "b1" {
+		t.Fatalf("expected only b1 to run while a is parked, got %v", got)
+	}
+	mu.Unlock()
+
+	d.Unpark(ctx, "a", bus.InboundMessage{Content: "resume"})
+	d.Wait()
+
+	want := []string{"a1", "b1", "resume", "a2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
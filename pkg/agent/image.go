@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register GIF decoder
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// maxImageDimension is the longest edge images are downscaled to after a
+// provider rejects them for their dimensions or size.
+const maxImageDimension = 1568
+
+// hasImageParts reports whether any message carries image content.
+func hasImageParts(messages []providers.Message) bool {
+	for _, m := range messages {
+		for _, p := range m.Parts {
+			if p.Type == "image" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isImageInputError reports whether err is a provider rejection of an image
+// for its dimensions or file size.
+func isImageInputError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return providers.IsImageDimensionError(msg) || providers.IsImageSizeError(msg)
+}
+
+// downscaleImageParts returns a copy of messages with every image larger than
+// maxDim on its longest edge re-encoded as a smaller JPEG. The bool result is
+// false when no image could be shrunk.
+func downscaleImageParts(messages []providers.Message, maxDim int) ([]providers.Message, bool) {
+	out := make([]providers.Message, len(messages))
+	copy(out, messages)
+
+	changed := false
+	for i, m := range out {
+		if len(m.Parts) == 0 {
+			continue
+		}
+		parts := make([]providers.ContentPart, len(m.Parts))
+		copy(parts, m.Parts)
+		for j, p := range parts {
+			if p.Type != "image" {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(p.Data)
+			if err != nil {
+				continue
+			}
+			scaled, err := downscaleImage(data, maxDim)
+			if err != nil || scaled == nil {
+				continue
+			}
+			parts[j].Data = base64.StdEncoding.EncodeToString(scaled)
+			parts[j].MIMEType = "image/jpeg"
+			changed = true
+		}
+		out[i].Parts = parts
+	}
+	return out, changed
+}
+
+// dropImageParts returns a copy of messages with image parts removed and a
+// short note, giving reason, added to the text so the model knows an image
+// was sent.
+func dropImageParts(messages []providers.Message, reason string) []providers.Message {
+	out := make([]providers.Message, len(messages))
+	copy(out, messages)
+
+	for i, m := range out {
+		if len(m.Parts) == 0 {
+			continue
+		}
+		kept := make([]providers.ContentPart, 0, len(m.Parts))
+		dropped := 0
+		for _, p := range m.Parts {
+			if p.Type == "image" {
+				dropped++
+				continue
+			}
+			kept = append(kept, p)
+		}
+		if dropped == 0 {
+			continue
+		}
+		out[i].Parts = kept
+		note := fmt.Sprintf("[%d image(s) omitted: %s]", dropped, reason)
+		if out[i].Content == "" {
+			out[i].Content = note
+		} else {
+			out[i].Content += "\n" + note
+		}
+	}
+	return out
+}
+
+// downscaleImage decodes data and, if its longest edge exceeds maxDim, returns
+// a box-filtered JPEG no larger than maxDim. It returns nil when the image is
+// already small enough.
+func downscaleImage(data []byte, maxDim int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxDim && h <= maxDim {
+		return nil, nil
+	}
+
+	nw, nh := maxDim, h*maxDim/w
+	if h > w {
+		nw, nh = w*maxDim/h, maxDim
+	}
+	nw, nh = max(nw, 1), max(nh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := range nh {
+		y0 := b.Min.Y + y*h/nh
+		y1 := max(b.Min.Y+(y+1)*h/nh, y0+1)
+		for x := range nw {
+			x0 := b.Min.X + x*w/nw
+			x1 := max(b.Min.X+(x+1)*w/nw, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
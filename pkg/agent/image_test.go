@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownscaleImage(t *testing.T) {
+	data := encodeTestPNG(t, 400, 100)
+
+	scaled, err := downscaleImage(data, 200)
+	if err != nil {
+		t.Fatalf("downscaleImage() error: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(scaled))
+	if err != nil {
+		t.Fatalf("expected JPEG output: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 50 {
+		t.Errorf("expected 200x50, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	small, err := downscaleImage(data, 1000)
+	if err != nil || small != nil {
+		t.Errorf("expected no-op for small image, got %d bytes, err=%v", len(small), err)
+	}
+}
+
+func TestDropImageParts(t *testing.T) {
+	messages := []providers.Message{{
+		Role:    "user",
+		Content: "look",
+		Parts: []providers.ContentPart{
+			{Type: "image", Data: "aW1n"},
+			{Type: "file", Data: "ZmlsZQ=="},
+		},
+	}}
+
+	out := dropImageParts(messages, "too large")
+	if hasImageParts(out) {
+		t.Fatal("expected image parts to be removed")
+	}
+	if len(out[0].Parts) != 1 || out[0].Parts[0].Type != "file" {
+		t.Errorf("expected non-image parts to be kept, got %+v", out[0].Parts)
+	}
+	if out[0].Content == "look" {
+		t.Error("expected a note about the omitted image")
+	}
+	if len(messages[0].Parts) != 2 {
+		t.Error("expected input messages to be left untouched")
+	}
+}
+
+type recordingImageProvider struct {
+	models    []string
+	parts     [][]providers.ContentPart
+	contents  []string
+	failFirst error
+}
+
+func (p *recordingImageProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	_ []providers.ToolDefinition,
+	model string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	p.models = append(p.models, model)
+	p.parts = append(p.parts, messages[len(messages)-1].Parts)
+	p.contents = append(p.contents, messages[len(messages)-1].Content)
+	if p.failFirst != nil && len(p.models) == 1 {
+		return nil, p.failFirst
+	}
+	return &providers.LLMResponse{Content: "seen"}, nil
+}
+
+func (p *recordingImageProvider) GetDefaultModel() string { return "text-model" }
+
+func newImageTestLoop(t *testing.T, provider providers.LLMProvider, imgData []byte) (*AgentLoop, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "text-model",
+				ImageModel:        "vision-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	imgPath := filepath.Join(tmpDir, "shot.png")
+	if err := os.WriteFile(imgPath, imgData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := media.NewFileMediaStore()
+	ref, err := store.Store(imgPath, media.MediaMeta{Filename: "shot.png"}, "scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.SetMediaStore(store)
+	return al, ref
+}
+
+func TestAgentLoop_RoutesImageTurnsToImageModel(t *testing.T) {
+	provider := &recordingImageProvider{}
+	al, ref := newImageTestLoop(t, provider, encodeTestPNG(t, 8, 8))
+
+	_, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "u", ChatID: "c", Content: "what is this?", Media: []string{ref},
+	})
+	if err != nil {
+		t.Fatalf("processMessage() error: %v", err)
+	}
+	_, err = al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "u", ChatID: "c", Content: "thanks",
+	})
+	if err != nil {
+		t.Fatalf("processMessage() error: %v", err)
+	}
+
+	if len(provider.models) != 2 || provider.models[0] != "vision-model" || provider.models[1] != "text-model" {
+		t.Fatalf("expected image turn on vision-model then text-model, got %v", provider.models)
+	}
+	if len(provider.parts[0]) != 1 || provider.parts[0][0].Type != "image" {
+		t.Fatalf("expected image part in request, got %+v", provider.parts[0])
+	}
+}
+
+func TestAgentLoop_OmitsImagesForTextOnlyModel(t *testing.T) {
+	provider := &recordingImageProvider{}
+	al, ref := newImageTestLoop(t, provider, encodeTestPNG(t, 8, 8))
+	agent := al.registry.GetDefaultAgent()
+	agent.Model = "deepseek-chat"
+	agent.ImageCandidates = nil
+
+	_, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "u", ChatID: "c", Content: "what is this?", Media: []string{ref},
+	})
+	if err != nil {
+		t.Fatalf("processMessage() error: %v", err)
+	}
+	if len(provider.parts) != 1 || len(provider.parts[0]) != 0 {
+		t.Fatalf("expected no image sent to a text-only model, got %+v", provider.parts)
+	}
+	if !strings.Contains(provider.contents[0], "does not support images") {
+		t.Errorf("expected a note about the omitted image, got %q", provider.contents[0])
+	}
+}
+
+func TestAgentLoop_DownscalesRejectedImage(t *testing.T) {
+	provider := &recordingImageProvider{failFirst: errors.New("image dimensions exceed max 1568x1568")}
+	al, ref := newImageTestLoop(t, provider, encodeTestPNG(t, maxImageDimension*2, 10))
+
+	resp, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "u", ChatID: "c", Content: "what is this?", Media: []string{ref},
+	})
+	if err != nil {
+		t.Fatalf("processMessage() error: %v", err)
+	}
+	if resp != "seen" {
+		t.Fatalf("expected recovered response, got %q", resp)
+	}
+	if len(provider.parts) != 2 {
+		t.Fatalf("expected a retry after the image error, got %d calls", len(provider.parts))
+	}
+
+	retried := provider.parts[1][0]
+	if retried.MIMEType != "image/jpeg" {
+		t.Fatalf("expected downscaled JPEG on retry, got %s", retried.MIMEType)
+	}
+	data, _ := base64.StdEncoding.DecodeString(retried.Data)
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != maxImageDimension {
+		t.Errorf("expected width %d, got %d", maxImageDimension, img.Bounds().Dx())
+	}
+}
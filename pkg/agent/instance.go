@@ -6,9 +6,11 @@ import (
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -29,8 +31,19 @@ type AgentInstance struct {
 	ContextBuilder *ContextBuilder
 	Tools          *tools.ToolRegistry
 	Subagents      *config.SubagentsConfig
-	SkillsFilter   []string
+	Tasks          *tools.SubagentManager // background tasks started with spawn
+	SkillsFilter   []string               // skills allowlist; nil allows every skill
 	Candidates     []providers.FallbackCandidate
+	Budget         *config.BudgetConfig
+	// MCPServers is the agent's tools.mcp allowlist; nil allows every server.
+	MCPServers []string
+
+	// ImageCandidates are the image_model candidates used for turns that
+	// carry image content; empty when no image model is configured.
+	ImageCandidates []providers.FallbackCandidate
+	// Providers holds the providers of model_list entries. Candidates
+	// without an entry, or with no pool, use Provider.
+	Providers *providers.ProviderPool
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -51,24 +64,33 @@ func NewAgentInstance(
 	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict))
-	toolsRegistry.Register(tools.NewExecToolWithConfig(workspace, restrict, cfg))
+	toolsRegistry.Register(tools.NewGlobTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewGrepTool(workspace, restrict))
+	execTool := tools.NewExecToolWithConfig(workspace, restrict, cfg)
+	toolsRegistry.Register(execTool)
+	toolsRegistry.Register(tools.NewProcessTool(execTool, cfg))
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewApplyPatchTool(workspace, restrict))
 
-	sessionsDir := filepath.Join(workspace, "sessions")
-	sessionsManager := session.NewSessionManager(sessionsDir)
+	sessionsManager := newSessionManager(cfg, filepath.Join(workspace, "sessions"))
 
 	contextBuilder := NewContextBuilder(workspace)
+	toolsRegistry.Register(tools.NewMemorySaveTool(contextBuilder.Memory()))
+	toolsRegistry.Register(tools.NewMemorySearchTool(contextBuilder.Memory()))
+	toolsRegistry.Register(tools.NewMemoryForgetTool(contextBuilder.Memory()))
 
 	agentID := routing.DefaultAgentID
 	agentName := ""
 	var subagents *config.SubagentsConfig
+	var mcpServers []string
 	var skillsFilter []string
 
 	if agentCfg != nil {
 		agentID = routing.NormalizeAgentID(agentCfg.ID)
 		agentName = agentCfg.Name
 		subagents = agentCfg.Subagents
+		mcpServers = agentCfg.MCPServers
 		skillsFilter = agentCfg.Skills
 	}
 
@@ -134,7 +156,27 @@ func NewAgentInstance(
 
 	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, resolveFromModelList)
 
-	return &AgentInstance{
+	// Size the context from the primary model's window, falling back to the
+	// output limit for models the catalog doesn't know.
+	contextWindow := maxTokens
+	if len(candidates) > 0 {
+		caps := providers.NewModelIndex(cfg.ModelList).
+			Capabilities(providers.ModelKey(candidates[0].Provider, candidates[0].Model))
+		if caps.ContextWindow > 0 {
+			contextWindow = caps.ContextWindow
+		}
+	}
+
+	var imageCandidates []providers.FallbackCandidate
+	if strings.TrimSpace(defaults.ImageModel) != "" {
+		imageModelCfg := providers.ModelConfig{
+			Primary:   defaults.ImageModel,
+			Fallbacks: defaults.ImageModelFallbacks,
+		}
+		imageCandidates = providers.ResolveCandidatesWithLookup(imageModelCfg, defaults.Provider, resolveFromModelList)
+	}
+
+	agent := &AgentInstance{
 		ID:             agentID,
 		Name:           agentName,
 		Model:          model,
@@ -143,7 +185,7 @@ func NewAgentInstance(
 		MaxIterations:  maxIter,
 		MaxTokens:      maxTokens,
 		Temperature:    temperature,
-		ContextWindow:  maxTokens,
+		ContextWindow:  contextWindow,
 		Provider:       provider,
 		Sessions:       sessionsManager,
 		ContextBuilder: contextBuilder,
@@ -151,7 +193,78 @@ func NewAgentInstance(
 		Subagents:      subagents,
 		SkillsFilter:   skillsFilter,
 		Candidates:     candidates,
+		Budget:         resolveAgentBudget(agentCfg, defaults),
+		MCPServers:     mcpServers,
+
+		ImageCandidates: imageCandidates,
+	}
+	contextBuilder.SetSkillsFilter(agent.skillAvailable)
+	return agent
+}
+
+// CanUseMCPServer reports whether the agent may use tools from the named MCP
+// server. Agents without an mcp_servers list may use every server.
+func (a *AgentInstance) CanUseMCPServer(server string) bool {
+	if a.MCPServers == nil {
+		return true
+	}
+	for _, allowed := range a.MCPServers {
+		if allowed == "*" || allowed == server {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUseSkill reports whether the named skill is on the agent's skills
+// allowlist. Agents without a skills list may use every skill.
+func (a *AgentInstance) CanUseSkill(name string) bool {
+	if a.SkillsFilter == nil {
+		return true
+	}
+	for _, allowed := range a.SkillsFilter {
+		if allowed == "*" || allowed == name {
+			return true
+		}
 	}
+	return false
+}
+
+// skillAvailable reports whether the agent may use skill s: it must be on
+// the allowlist and every tool the skill requires must be registered.
+func (a *AgentInstance) skillAvailable(s skills.SkillInfo) bool {
+	if !a.CanUseSkill(s.Name) {
+		return false
+	}
+	return len(s.MissingTools(func(name string) bool {
+		_, ok := a.Tools.Get(name)
+		return ok
+	})) == 0
+}
+
+// usePool makes the agent take its providers from pool. When the primary
+// model has its own model_list entry, Provider and Model become that entry's
+// provider and model ID, so summaries and subagents use the same endpoint as
+// the agent's turns.
+func (a *AgentInstance) usePool(pool *providers.ProviderPool) {
+	a.Providers = pool
+	if len(a.Candidates) == 0 {
+		return
+	}
+	if p, modelID, ok := pool.ForCandidate(a.Candidates[0]); ok {
+		a.Provider, a.Model = p, modelID
+	}
+}
+
+// CandidateProvider returns the provider and model ID to use for a fallback
+// candidate: the provider of the candidate's own model_list entry if it has
+// one, otherwise Provider.
+func (a *AgentInstance) CandidateProvider(provider, model string) (providers.LLMProvider, string) {
+	c := providers.FallbackCandidate{Provider: provider, Model: model}
+	if p, modelID, ok := a.Providers.ForCandidate(c); ok {
+		return p, modelID
+	}
+	return a.Provider, model
 }
 
 // resolveAgentWorkspace determines the workspace directory for an agent.
@@ -167,6 +280,27 @@ func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentD
 	return filepath.Join(home, ".picoclaw", "workspace-"+id)
 }
 
+// newSessionManager creates the session manager of an agent from the
+// session.store config, falling back to JSON files if the store can't be
+// opened.
+func newSessionManager(cfg *config.Config, dir string) *session.SessionManager {
+	store, err := session.OpenStore(cfg.Session.Store, dir)
+	if err != nil {
+		logger.ErrorCF("agent", "Failed to open session store, using JSON files",
+			map[string]any{"store": cfg.Session.Store, "dir": dir, "error": err.Error()})
+		return session.NewSessionManager(dir)
+	}
+	return session.NewSessionManagerWithStore(store, cfg.Session.CacheSize)
+}
+
+// resolveAgentBudget returns the agent's own budget, falling back to the defaults.
+func resolveAgentBudget(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) *config.BudgetConfig {
+	if agentCfg != nil && agentCfg.Budget != nil {
+		return agentCfg.Budget
+	}
+	return defaults.Budget
+}
+
 // resolveAgentModel resolves the primary model for an agent.
 func resolveAgentModel(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && agentCfg.Model != nil && strings.TrimSpace(agentCfg.Model.Primary) != "" {
@@ -1,9 +1,17 @@
 package agent
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
 
@@ -167,3 +175,142 @@ func TestNewAgentInstance_ResolveCandidatesFromModelListAliasWithoutProtocol(t *
 		t.Fatalf("candidate model = %q, want %q", agent.Candidates[0].Model, "glm-5")
 	}
 }
+
+func TestAgentInstance_CanUseMCPServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []string
+		want    map[string]bool
+	}{
+		{"unset allows all", nil, map[string]bool{"github": true, "fs": true}},
+		{"empty denies all", []string{}, map[string]bool{"github": false, "fs": false}},
+		{"explicit list", []string{"github"}, map[string]bool{"github": true, "fs": false}},
+		{"wildcard", []string{"*"}, map[string]bool{"github": true, "fs": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &AgentInstance{MCPServers: tt.servers}
+			for server, want := range tt.want {
+				if got := a.CanUseMCPServer(server); got != want {
+					t.Errorf("CanUseMCPServer(%q) = %v, want %v", server, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAgentLoop_FallbackUsesCandidateProvider(t *testing.T) {
+	var gotModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotModel = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"from ollama"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "claude",
+				ModelFallbacks:    []string{"local"},
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "claude", Model: "anthropic/claude-sonnet", APIKey: "k"},
+			{ModelName: "local", Model: "ollama/qwen", APIBase: server.URL},
+		},
+	}
+	primary := &recordingImageProvider{failFirst: errors.New("status 429: rate limit exceeded")}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), primary)
+
+	resp, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "u", ChatID: "c", Content: "hi",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.models) != 1 || primary.models[0] != "claude-sonnet" {
+		t.Errorf("expected the primary provider to get claude-sonnet once, got %v", primary.models)
+	}
+	if resp != "from ollama" || gotModel != "qwen" {
+		t.Errorf("expected the fallback's own endpoint to answer for qwen, got %q from model %q", resp, gotModel)
+	}
+}
+
+func TestAgentLoop_AgentModelUsesOwnProvider(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "main",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "local", Model: &config.AgentModelConfig{Primary: "local"}},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "main", Model: "openai/gpt-4o", APIKey: "k"},
+			{ModelName: "local", Model: "ollama/qwen", APIBase: "http://localhost:11434/v1"},
+		},
+	}
+	primary := &recordingImageProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), primary)
+
+	main, _ := al.registry.GetAgent("main")
+	if main.Provider == nil || main.Model != "gpt-4o" {
+		t.Errorf("expected main agent to keep gpt-4o, got %q", main.Model)
+	}
+	local, _ := al.registry.GetAgent("local")
+	if local.Provider == main.Provider || local.Model != "qwen" {
+		t.Errorf("expected local agent to use its own provider for qwen, got %T %q", local.Provider, local.Model)
+	}
+}
+
+func TestNewAgentInstance_SkillsAllowlist(t *testing.T) {
+	workspace := t.TempDir()
+	writeSkill := func(name, metadata string) {
+		dir := filepath.Join(workspace, "skills", name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		content := "---\nname: " + name + "\ndescription: The " + name + " skill\n" + metadata + "---\n"
+		if err := os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSkill("github", "")
+	writeSkill("tmux", "")
+	writeSkill("hardware", `metadata: {"nanobot":{"requires":{"tools":["spi"]}}}`+"\n")
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: workspace, Model: "test-model"},
+		},
+	}
+	agentCfg := &config.AgentConfig{ID: "support", Workspace: workspace, Skills: []string{"github", "hardware"}}
+	agent := NewAgentInstance(agentCfg, &cfg.Agents.Defaults, cfg, &mockProvider{})
+
+	prompt := agent.ContextBuilder.BuildSystemPrompt()
+	if !strings.Contains(prompt, "<name>github</name>") {
+		t.Error("expected allowed skill in the system prompt")
+	}
+	if strings.Contains(prompt, "<name>tmux</name>") {
+		t.Error("expected skill missing from the allowlist to be hidden")
+	}
+	if strings.Contains(prompt, "<name>hardware</name>") {
+		t.Error("expected skill whose required tool is not registered to be hidden")
+	}
+	if !agent.CanUseSkill("github") || agent.CanUseSkill("tmux") {
+		t.Error("expected CanUseSkill to follow the skills list")
+	}
+}
@@ -9,8 +9,10 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,12 +24,14 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -41,31 +45,82 @@ type AgentLoop struct {
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
 	mediaStore     media.MediaStore
+	inbound        []InboundMiddleware
+	usageTracker   *usage.Tracker
+	models         *providers.ModelIndex
+
+	// dispatcher serializes turns per session; see Run and ProcessForAgent.
+	dispatcher  *sessionDispatcher
+	directTurns sync.Map // id -> *directTurn
+	directSeq   atomic.Uint64
+
+	approvalPolicy   *approvalPolicy
+	approvals        *approvals
+	approvalPrompter ApprovalPrompter
+
+	mcpMu    sync.Mutex
+	mcpTools map[string]map[string]bool // server -> names of its registered tools
 }
 
+// InboundMiddleware rewrites an inbound message before the agent processes it,
+// e.g. to transcribe attached voice notes into its text content.
+type InboundMiddleware func(ctx context.Context, msg bus.InboundMessage) bus.InboundMessage
+
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string   // Session identifier for history/context
+	Channel         string   // Target channel for tool execution
+	ChatID          string   // Target chat ID for tool execution
+	SenderID        string   // Sender of the message, who may approve its tool calls
+	UserMessage     string   // User message content (may include prefix)
+	Media           []string // media:// refs attached to the user message
+	DefaultResponse string   // Response when LLM returns empty
+	EnableSummary   bool     // Whether to trigger summarization
+	SendResponse    bool     // Whether to send response via bus
+	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	NoApprovalWait  bool     // Refuse tool calls that need approval instead of parking the turn
+	Stream          bool     // Whether to stream partial replies into the channel placeholder
+
+	// OnDelta, when set, receives the streamed content of every LLM call
+	// instead of the channel placeholder.
+	OnDelta func(delta string)
 }
 
 const defaultResponse = "I've completed processing but have no response to give. Increase `max_tool_iterations` in config.json."
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
+	// Enforce model_list RPM limits and record token usage on every LLM
+	// call: agent turns, summarization, subagents and tool loops all go
+	// through these wrapped providers.
+	limiter := providers.NewRateLimiter(cfg.ModelList)
+	var tracker *usage.Tracker
+	if workspace := cfg.WorkspacePath(); workspace != "" {
+		tracker = usage.NewTracker(workspace)
+	}
+	wrap := func(p providers.LLMProvider) providers.LLMProvider {
+		return providers.WithUsage(providers.WithRateLimit(p, limiter), tracker, cfg.ModelList)
+	}
+	provider = wrap(provider)
+
+	// Models with their own model_list entry get their own provider, created
+	// on first use; the given provider serves the default model's entry.
+	pool := providers.NewProviderPool(cfg, wrap)
+	pool.Set(cfg.Agents.Defaults.GetModelName(), provider)
+
 	registry := NewAgentRegistry(cfg, provider)
+	for _, agentID := range registry.ListAgentIDs() {
+		if agent, ok := registry.GetAgent(agentID); ok {
+			agent.usePool(pool)
+		}
+	}
 
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	registerSharedTools(cfg, msgBus, registry)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
 	fallbackChain := providers.NewFallbackChain(cooldown)
+	fallbackChain.SetRateLimiter(limiter)
 
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
@@ -74,22 +129,28 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
-	return &AgentLoop{
-		bus:         msgBus,
-		cfg:         cfg,
-		registry:    registry,
-		state:       stateManager,
-		summarizing: sync.Map{},
-		fallback:    fallbackChain,
-	}
+	al := &AgentLoop{
+		bus:            msgBus,
+		cfg:            cfg,
+		registry:       registry,
+		state:          stateManager,
+		summarizing:    sync.Map{},
+		fallback:       fallbackChain,
+		usageTracker:   tracker,
+		models:         providers.NewModelIndex(cfg.ModelList),
+		approvalPolicy: newApprovalPolicy(cfg.Tools.Approval),
+		approvals:      newApprovals(stateManager),
+	}
+	al.dispatcher = newSessionDispatcher(cfg.Agents.Defaults.MaxConcurrentSessions, al.handleInbound)
+	al.enableDelegation()
+	return al
 }
 
-// registerSharedTools registers tools that are shared across all agents (web, message, spawn).
+// registerSharedTools registers tools that are shared across all agents (web, message, spawn, tasks).
 func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
-	provider providers.LLMProvider,
 ) {
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
@@ -143,11 +204,15 @@ func registerSharedTools(
 			cfg.Tools.Skills.SearchCache.MaxSize,
 			time.Duration(cfg.Tools.Skills.SearchCache.TTLSeconds)*time.Second,
 		)
-		agent.Tools.Register(tools.NewFindSkillsTool(registryMgr, searchCache))
-		agent.Tools.Register(tools.NewInstallSkillTool(registryMgr, agent.Workspace))
+		findSkillsTool := tools.NewFindSkillsTool(registryMgr, searchCache)
+		findSkillsTool.SetAllowlistChecker(agent.CanUseSkill)
+		agent.Tools.Register(findSkillsTool)
+		installSkillTool := tools.NewInstallSkillTool(registryMgr, agent.Workspace)
+		installSkillTool.SetAllowlistChecker(agent.CanUseSkill)
+		agent.Tools.Register(installSkillTool)
 
 		// Spawn tool with allowlist checker
-		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
+		subagentManager := tools.NewSubagentManager(agent.Provider, agent.Model, agent.Workspace, msgBus)
 		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
 		spawnTool := tools.NewSpawnTool(subagentManager)
 		currentAgentID := agentID
@@ -155,11 +220,27 @@ func registerSharedTools(
 			return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
 		})
 		agent.Tools.Register(spawnTool)
+		agent.Tools.Register(tools.NewTaskListTool(subagentManager))
+		agent.Tools.Register(tools.NewTaskStatusTool(subagentManager))
+		agent.Tools.Register(tools.NewTaskCancelTool(subagentManager))
+		agent.Tasks = subagentManager
 	}
 }
 
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
+	defer al.running.Store(false)
+
+	dispatcher := al.dispatcher
+	defer dispatcher.Wait()
+
+	// Turns parked on an approval resume at the front of their session's
+	// queue once the approval is answered, also after a restart.
+	resume := func(p state.PendingApproval, d approvalDecision) {
+		al.resumeTurn(ctx, p, d)
+	}
+	al.restoreApprovals()
+	al.markInterruptedTasks()
 
 	for al.running.Load() {
 		select {
@@ -171,69 +252,168 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			// Process message
-			func() {
-				// TODO: Re-enable media cleanup after inbound media is properly consumed by the agent.
-				// Currently disabled because files are deleted before the LLM can access their content.
-				// defer func() {
-				// 	if al.mediaStore != nil && msg.MediaScope != "" {
-				// 		if releaseErr := al.mediaStore.ReleaseAll(msg.MediaScope); releaseErr != nil {
-				// 			logger.WarnCF("agent", "Failed to release media", map[string]any{
-				// 				"scope": msg.MediaScope,
-				// 				"error": releaseErr.Error(),
-				// 			})
-				// 		}
-				// 	}
-				// }()
-
-				response, err := al.processMessage(ctx, msg)
-				if err != nil {
-					response = fmt.Sprintf("Error processing message: %v", err)
+			// Approval answers bypass the session queue, which stays parked
+			// until the turn waiting for them resumes.
+			answered := false
+			if reply, handled := al.handleApprovalCommand(msg, func(p state.PendingApproval, d approvalDecision) {
+				answered = true
+				resume(p, d)
+			}); handled {
+				out := bus.OutboundMessage{
+					Channel: msg.Channel,
+					ChatID:  msg.ChatID,
+					Content: reply,
 				}
-
-				if response != "" {
-					// Check if the message tool already sent a response during this round.
-					// If so, skip publishing to avoid duplicate messages to the user.
-					// Use default agent's tools to check (message tool is shared).
-					alreadySent := false
-					defaultAgent := al.registry.GetDefaultAgent()
-					if defaultAgent != nil {
-						if tool, ok := defaultAgent.Tools.Get("message"); ok {
-							if mt, ok := tool.(*tools.MessageTool); ok {
-								alreadySent = mt.HasSentInRound()
-							}
-						}
-					}
-
-					if !alreadySent {
-						al.bus.PublishOutbound(ctx, bus.OutboundMessage{
-							Channel: msg.Channel,
-							ChatID:  msg.ChatID,
-							Content: response,
-						})
-						logger.InfoCF("agent", "Published outbound response",
-							map[string]any{
-								"channel":     msg.Channel,
-								"chat_id":     msg.ChatID,
-								"content_len": len(response),
-							})
-					} else {
-						logger.DebugCF(
-							"agent",
-							"Skipped outbound (message tool already sent)",
-							map[string]any{"channel": msg.Channel},
-						)
-					}
+				// A prompt answered with its buttons loses them, so they are
+				// pressed only once; other presses leave them for the approver.
+				if answered && msg.Metadata["callback"] == "true" {
+					out.ClearButtonsOf = msg.MessageID
 				}
-			}()
+				al.bus.PublishOutbound(ctx, out)
+				continue
+			}
+
+			// Messages of one session run in order; different sessions run in parallel.
+			dispatcher.Dispatch(ctx, al.dispatchKey(msg), msg)
 		}
 	}
 
 	return nil
 }
 
+// markInterruptedTasks marks the tasks a previous run left running as
+// interrupted. It runs only here, in the long-running loop, so that a
+// one-off `picoclaw agent` does not mistake the gateway's tasks for leftovers.
+func (al *AgentLoop) markInterruptedTasks() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok && agent.Tasks != nil {
+			agent.Tasks.MarkInterrupted()
+		}
+	}
+}
+
+// handleInbound processes a single inbound message and publishes the response.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	if turn, ok := al.takeDirectTurn(msg); ok {
+		al.runDirectTurn(turn)
+		return
+	}
+
+	// Inbound media is inlined into the LLM request while the message is
+	// processed, so the files can be released once processing is done.
+	defer func() {
+		if al.mediaStore != nil && msg.MediaScope != "" {
+			if releaseErr := al.mediaStore.ReleaseAll(msg.MediaScope); releaseErr != nil {
+				logger.WarnCF("agent", "Failed to release media", map[string]any{
+					"scope": msg.MediaScope,
+					"error": releaseErr.Error(),
+				})
+			}
+		}
+	}()
+
+	// Track tool side effects for this message only, so concurrent sessions
+	// sharing the same tool instances don't observe each other's sends.
+	roundCtx, round := tools.WithRound(ctx)
+
+	var response string
+	var err error
+	if r, ok := al.approvals.takeResume(msg); ok {
+		response, err = al.resumeApproval(roundCtx, r.approval, r.decision)
+	} else {
+		for _, mw := range al.inbound {
+			msg = mw(roundCtx, msg)
+		}
+		response, err = al.processMessage(roundCtx, msg)
+	}
+	if err != nil {
+		response = fmt.Sprintf("Error processing message: %v", err)
+	}
+
+	if response == "" {
+		return
+	}
+
+	// If the message tool already sent a response during this round,
+	// skip publishing to avoid duplicate messages to the user.
+	if round.HasSentMessage() {
+		logger.DebugCF(
+			"agent",
+			"Skipped outbound (message tool already sent)",
+			map[string]any{"channel": msg.Channel},
+		)
+		return
+	}
+
+	al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: msg.Channel,
+		ChatID:  msg.ChatID,
+		Content: response,
+	})
+	logger.InfoCF("agent", "Published outbound response",
+		map[string]any{
+			"channel":     msg.Channel,
+			"chat_id":     msg.ChatID,
+			"content_len": len(response),
+		})
+}
+
+// dispatchKey returns the key used to serialize processing of msg.
+// It is the session key the message will be processed under.
+func (al *AgentLoop) dispatchKey(msg bus.InboundMessage) string {
+	if msg.Channel == "system" {
+		if agent := al.registry.GetDefaultAgent(); agent != nil {
+			return routing.BuildAgentMainSessionKey(agent.ID)
+		}
+		return msg.Channel
+	}
+	_, sessionKey, _ := al.resolveRoute(msg)
+	return sessionKey
+}
+
+// resolveRoute determines the agent and session key that handle msg.
+// The returned agent is nil if no agent is available.
+func (al *AgentLoop) resolveRoute(msg bus.InboundMessage) (*AgentInstance, string, routing.ResolvedRoute) {
+	route := al.registry.ResolveRoute(routing.RouteInput{
+		Channel:    msg.Channel,
+		AccountID:  msg.Metadata["account_id"],
+		Peer:       extractPeer(msg),
+		ParentPeer: extractParentPeer(msg),
+		GuildID:    msg.Metadata["guild_id"],
+		TeamID:     msg.Metadata["team_id"],
+	})
+
+	agent, ok := al.registry.GetAgent(route.AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+
+	// Use routed session key, but honor pre-set agent-scoped keys (for ProcessDirect/cron)
+	sessionKey := route.SessionKey
+	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
+		sessionKey = msg.SessionKey
+	}
+
+	return agent, sessionKey, route
+}
+
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+
+	// Background processes would otherwise outlive the agent.
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			if t, ok := agent.Tools.Get("process"); ok {
+				if pt, ok := t.(*tools.ProcessTool); ok {
+					pt.KillAll()
+				}
+			}
+		}
+	}
+
+	if err := al.usageTracker.Close(); err != nil {
+		logger.WarnCF("agent", "Failed to save usage", map[string]any{"error": err.Error()})
+	}
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
@@ -251,6 +431,83 @@ func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 // SetMediaStore injects a MediaStore for media lifecycle management.
 func (al *AgentLoop) SetMediaStore(s media.MediaStore) {
 	al.mediaStore = s
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.ContextBuilder.SetMediaStore(s)
+		}
+	}
+}
+
+// UsageTracker returns the tracker recording token usage and cost, or nil
+// when the workspace is not set.
+func (al *AgentLoop) UsageTracker() *usage.Tracker {
+	return al.usageTracker
+}
+
+// UseInbound registers middleware applied, in order, to every inbound message
+// before it is processed. Must be called before Run.
+func (al *AgentLoop) UseInbound(mw ...InboundMiddleware) {
+	al.inbound = append(al.inbound, mw...)
+}
+
+// UseMCP registers the tools of m's servers with every agent whose
+// mcp_servers allowlist permits them. Tools are (re-)registered each time a
+// server connects, so servers that come up late or restart with a changed
+// tool list are picked up; tools the server no longer lists are removed.
+// Call after SetMediaStore.
+func (al *AgentLoop) UseMCP(m *mcp.Manager) {
+	m.OnConnect(func(c *mcp.Client) {
+		al.registerMCPTools(c.Name(), func(taken func(string) bool) []tools.Tool {
+			return mcp.NewTools(c, al.mediaStore, taken)
+		})
+	})
+}
+
+// registerMCPTools replaces the tools registered for server with the ones
+// returned by build. build is given the names held by other servers, so a
+// colliding name is renamed instead of replacing another server's tool, and
+// only names previously registered for server are removed.
+func (al *AgentLoop) registerMCPTools(server string, build func(taken func(string) bool) []tools.Tool) {
+	al.mcpMu.Lock()
+	defer al.mcpMu.Unlock()
+
+	if al.mcpTools == nil {
+		al.mcpTools = make(map[string]map[string]bool)
+	}
+	mcpTools := build(func(name string) bool {
+		for other, names := range al.mcpTools {
+			if other != server && names[name] {
+				return true
+			}
+		}
+		return false
+	})
+	current := make(map[string]bool, len(mcpTools))
+	for _, t := range mcpTools {
+		current[t.Name()] = true
+	}
+	previous := al.mcpTools[server]
+	al.mcpTools[server] = current
+
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || !agent.CanUseMCPServer(server) {
+			continue
+		}
+		for name := range previous {
+			if !current[name] {
+				agent.Tools.Unregister(name)
+			}
+		}
+		for _, t := range mcpTools {
+			agent.Tools.Register(t)
+		}
+		logger.InfoCF("agent", "Registered MCP tools", map[string]any{
+			"agent_id": agentID,
+			"server":   server,
+			"tools":    len(mcpTools),
+		})
+	}
 }
 
 // inferMediaType determines the media type ("image", "audio", "video", "file")
@@ -320,6 +577,141 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 	return al.processMessage(ctx, msg)
 }
 
+// DirectRequest is a turn submitted straight to one agent, bypassing channel
+// routing, e.g. by the OpenAI-compatible HTTP API.
+type DirectRequest struct {
+	AgentID    string
+	SessionKey string
+	Channel    string
+	ChatID     string
+	SenderID   string
+	Content    string
+	Media      []string // media:// refs attached to the message
+
+	// History, when not nil, replaces the session's history before the
+	// turn, for clients that send the whole conversation with each request.
+	History []providers.Message
+
+	// OnDelta, when set, receives streamed content deltas. Only providers
+	// that support streaming produce deltas.
+	OnDelta func(delta string)
+}
+
+// directTurnKey marks the inbound message that runs a queued DirectRequest.
+const directTurnKey = "direct_turn"
+
+// directTurn is a DirectRequest waiting in its session's dispatcher queue.
+type directTurn struct {
+	ctx   context.Context
+	agent *AgentInstance
+	msg   bus.InboundMessage
+	req   DirectRequest
+	done  chan directResult
+}
+
+type directResult struct {
+	response string
+	err      error
+}
+
+// ProcessForAgent runs req on the agent named by req.AgentID and returns the
+// final response. Slash commands are handled as for chat messages. The turn
+// is queued behind other work on req.SessionKey and counts towards
+// max_concurrent_sessions, like a chat message.
+func (al *AgentLoop) ProcessForAgent(ctx context.Context, req DirectRequest) (string, error) {
+	agent, ok := al.registry.GetAgent(req.AgentID)
+	if !ok {
+		return "", fmt.Errorf("agent %q not found", req.AgentID)
+	}
+
+	id := strconv.FormatUint(al.directSeq.Add(1), 10)
+	msg := bus.InboundMessage{
+		Channel:    req.Channel,
+		SenderID:   req.SenderID,
+		ChatID:     req.ChatID,
+		Content:    req.Content,
+		Media:      req.Media,
+		SessionKey: req.SessionKey,
+		Metadata:   map[string]string{directTurnKey: id},
+	}
+	turn := &directTurn{ctx: ctx, agent: agent, msg: msg, req: req, done: make(chan directResult, 1)}
+	al.directTurns.Store(id, turn)
+
+	// The queue outlives this request; the turn itself watches ctx.
+	al.dispatcher.Dispatch(context.WithoutCancel(ctx), req.SessionKey, msg)
+
+	select {
+	case res := <-turn.done:
+		return res.response, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// takeDirectTurn returns the queued DirectRequest msg runs, if any.
+func (al *AgentLoop) takeDirectTurn(msg bus.InboundMessage) (*directTurn, bool) {
+	id := msg.Metadata[directTurnKey]
+	if id == "" {
+		return nil, false
+	}
+	turn, ok := al.directTurns.LoadAndDelete(id)
+	if !ok {
+		return nil, false
+	}
+	return turn.(*directTurn), true
+}
+
+// runDirectTurn runs a queued DirectRequest on its session worker and hands
+// the result back to ProcessForAgent.
+func (al *AgentLoop) runDirectTurn(turn *directTurn) {
+	ctx := turn.ctx
+	if err := ctx.Err(); err != nil {
+		turn.done <- directResult{err: err}
+		return
+	}
+	ctx, _ = tools.WithRound(ctx)
+
+	msg := turn.msg
+	for _, mw := range al.inbound {
+		msg = mw(ctx, msg)
+	}
+	if response, handled := al.handleCommand(ctx, msg); handled {
+		turn.done <- directResult{response: response}
+		return
+	}
+	if turn.req.History != nil {
+		turn.agent.Sessions.GetOrCreate(turn.req.SessionKey)
+		turn.agent.Sessions.SetHistory(turn.req.SessionKey, turn.req.History)
+	}
+
+	response, err := al.runAgentLoop(ctx, turn.agent, processOptions{
+		SessionKey:      turn.req.SessionKey,
+		Channel:         msg.Channel,
+		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
+		UserMessage:     msg.Content,
+		Media:           msg.Media,
+		DefaultResponse: defaultResponse,
+		EnableSummary:   true,
+		SendResponse:    false,
+		OnDelta:         turn.req.OnDelta,
+	})
+	turn.done <- directResult{response: response, err: err}
+}
+
+// ListAgentIDs returns the IDs of all configured agents.
+func (al *AgentLoop) ListAgentIDs() []string {
+	return al.registry.ListAgentIDs()
+}
+
+// DefaultAgentID returns the ID of the default agent, or "" if there is none.
+func (al *AgentLoop) DefaultAgentID() string {
+	if agent := al.registry.GetDefaultAgent(); agent != nil {
+		return agent.ID
+	}
+	return ""
+}
+
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
@@ -366,36 +758,11 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	}
 
 	// Route to determine agent and session key
-	route := al.registry.ResolveRoute(routing.RouteInput{
-		Channel:    msg.Channel,
-		AccountID:  msg.Metadata["account_id"],
-		Peer:       extractPeer(msg),
-		ParentPeer: extractParentPeer(msg),
-		GuildID:    msg.Metadata["guild_id"],
-		TeamID:     msg.Metadata["team_id"],
-	})
-
-	agent, ok := al.registry.GetAgent(route.AgentID)
-	if !ok {
-		agent = al.registry.GetDefaultAgent()
-	}
+	agent, sessionKey, route := al.resolveRoute(msg)
 	if agent == nil {
 		return "", fmt.Errorf("no agent available for route (agent_id=%s)", route.AgentID)
 	}
 
-	// Reset message-tool state for this round so we don't skip publishing due to a previous round.
-	if tool, ok := agent.Tools.Get("message"); ok {
-		if mt, ok := tool.(tools.ContextualTool); ok {
-			mt.SetContext(msg.Channel, msg.ChatID)
-		}
-	}
-
-	// Use routed session key, but honor pre-set agent-scoped keys (for ProcessDirect/cron)
-	sessionKey := route.SessionKey
-	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
-		sessionKey = msg.SessionKey
-	}
-
 	logger.InfoCF("agent", "Routed message",
 		map[string]any{
 			"agent_id":    agent.ID,
@@ -407,10 +774,13 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		SessionKey:      sessionKey,
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
 		UserMessage:     msg.Content,
+		Media:           msg.Media,
 		DefaultResponse: defaultResponse,
 		EnableSummary:   true,
 		SendResponse:    false,
+		Stream:          true,
 	})
 }
 
@@ -453,8 +823,14 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		return "", nil
 	}
 
-	// Use default agent for system messages
+	// Results of spawned tasks go back to the agent that spawned them; other
+	// system messages use the default agent
 	agent := al.registry.GetDefaultAgent()
+	if spawnerID := msg.Metadata["agent_id"]; spawnerID != "" {
+		if spawner, ok := al.registry.GetAgent(spawnerID); ok {
+			agent = spawner
+		}
+	}
 	if agent == nil {
 		return "", fmt.Errorf("no default agent for system message")
 	}
@@ -486,8 +862,24 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		}
 	}
 
-	// 1. Update tool contexts
-	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
+	// 1. Carry the tool context for this invocation; tool instances are shared
+	// between concurrently processed sessions and must not hold it.
+	ctx = tools.WithToolContext(ctx, opts.Channel, opts.ChatID)
+	ctx = tools.WithToolSession(ctx, opts.SessionKey)
+	ctx = usage.WithScope(ctx, agent.ID, opts.SessionKey)
+
+	// Enforce the agent's budget before spending anything on this turn.
+	agent, refusal := al.applyBudget(turnAgent(agent))
+	if refusal != "" {
+		if opts.SendResponse {
+			al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel: opts.Channel,
+				ChatID:  opts.ChatID,
+				Content: refusal,
+			})
+		}
+		return refusal, nil
+	}
 
 	// 2. Build messages (skip history for heartbeat)
 	var history []providers.Message
@@ -500,7 +892,7 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		history,
 		summary,
 		opts.UserMessage,
-		nil,
+		opts.Media,
 		opts.Channel,
 		opts.ChatID,
 	)
@@ -510,10 +902,27 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 
 	// 4. Run LLM iteration loop
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
+	if errors.Is(err, errApprovalSuspended) {
+		// The turn continues once the pending approval is answered.
+		agent.Sessions.Save(opts.SessionKey)
+		return "", nil
+	}
 	if err != nil {
 		return "", err
 	}
 
+	return al.finishTurn(ctx, agent, opts, finalContent, iteration), nil
+}
+
+// finishTurn records the final response of a turn, triggers summarization
+// and sends the response if requested. It returns the response.
+func (al *AgentLoop) finishTurn(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	finalContent string,
+	iteration int,
+) string {
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
 	// This is controlled by the tool's Silent flag and ForUser content
 
@@ -550,7 +959,7 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 			"final_length": len(finalContent),
 		})
 
-	return finalContent, nil
+	return finalContent
 }
 
 func (al *AgentLoop) targetReasoningChannelID(channelName string) (chatID string) {
@@ -581,7 +990,24 @@ func (al *AgentLoop) handleReasoning(ctx context.Context, reasoningContent, chan
 	})
 }
 
-// runLLMIteration executes the LLM call loop with tool handling.
+// turnAgent returns a copy of agent for a single turn, so that fields that
+// depend on the model serving the turn, such as ContextWindow, can follow it.
+func turnAgent(agent *AgentInstance) *AgentInstance {
+	t := *agent
+	return &t
+}
+
+// contextWindowOf returns the context window of the given model, falling back
+// to agent's output limit for models the catalog doesn't know.
+func (al *AgentLoop) contextWindowOf(agent *AgentInstance, provider, model string) int {
+	if caps := al.models.Capabilities(providers.ModelKey(provider, model)); caps.ContextWindow > 0 {
+		return caps.ContextWindow
+	}
+	return agent.MaxTokens
+}
+
+// runLLMIteration executes the LLM call loop with tool handling. When a
+// fallback model answers, agent, a turnAgent copy, is resized for it.
 func (al *AgentLoop) runLLMIteration(
 	ctx context.Context,
 	agent *AgentInstance,
@@ -629,20 +1055,78 @@ func (al *AgentLoop) runLLMIteration(
 		var response *providers.LLMResponse
 		var err error
 
+		chat := func(
+			ctx context.Context,
+			provider providers.LLMProvider,
+			model string,
+			imageModel bool,
+		) (*providers.LLMResponse, error) {
+			caps := al.models.Capabilities(model)
+			toolDefs := providerToolDefs
+			if !caps.Tools {
+				toolDefs = nil
+			}
+			// Known text-only models are told about images instead of being
+			// sent them; a configured image model is trusted to take them.
+			messages := messages
+			if !imageModel && caps.Known && !caps.Vision && hasImageParts(messages) {
+				messages = dropImageParts(messages, "the model does not support images")
+			}
+			maxTokens := agent.MaxTokens
+			if caps.MaxOutputTokens > 0 && maxTokens > caps.MaxOutputTokens {
+				maxTokens = caps.MaxOutputTokens
+			}
+			llmOpts := map[string]any{
+				"max_tokens":       maxTokens,
+				"temperature":      agent.Temperature,
+				"prompt_cache_key": agent.ID,
+			}
+			if sp, ok := provider.(providers.StreamingProvider); ok && caps.Streaming {
+				if opts.OnDelta != nil {
+					return sp.ChatStream(ctx, messages, toolDefs, model, llmOpts, opts.OnDelta)
+				}
+				if w := al.newStreamWriter(ctx, opts); w != nil {
+					return sp.ChatStream(ctx, messages, toolDefs, model, llmOpts, w.OnDelta)
+				}
+			}
+			return provider.Chat(ctx, messages, toolDefs, model, llmOpts)
+		}
+
 		callLLM := func() (*providers.LLMResponse, error) {
+			// Turns carrying images go to the configured image model candidates.
+			if len(agent.ImageCandidates) > 0 && al.fallback != nil && hasImageParts(messages) {
+				fbResult, fbErr := al.fallback.ExecuteImage(ctx, agent.ImageCandidates,
+					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
+						p, modelID := agent.CandidateProvider(provider, model)
+						return chat(ctx, p, modelID, true)
+					},
+				)
+				if fbErr != nil {
+					return nil, fbErr
+				}
+				logger.DebugCF("agent", "Image request served",
+					map[string]any{
+						"agent_id": agent.ID,
+						"provider": fbResult.Provider,
+						"model":    fbResult.Model,
+					})
+				return fbResult.Response, nil
+			}
 			if len(agent.Candidates) > 1 && al.fallback != nil {
 				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
-							"max_tokens":       agent.MaxTokens,
-							"temperature":      agent.Temperature,
-							"prompt_cache_key": agent.ID,
-						})
+						p, modelID := agent.CandidateProvider(provider, model)
+						return chat(ctx, p, modelID, false)
 					},
 				)
 				if fbErr != nil {
 					return nil, fbErr
 				}
+				// Size the rest of the turn, summarization included, for the
+				// model that answered.
+				if fbResult.Provider != "" {
+					agent.ContextWindow = al.contextWindowOf(agent, fbResult.Provider, fbResult.Model)
+				}
 				if fbResult.Provider != "" && len(fbResult.Attempts) > 0 {
 					logger.InfoCF("agent", fmt.Sprintf("Fallback: succeeded with %s/%s after %d attempts",
 						fbResult.Provider, fbResult.Model, len(fbResult.Attempts)+1),
@@ -650,11 +1134,7 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
-				"max_tokens":       agent.MaxTokens,
-				"temperature":      agent.Temperature,
-				"prompt_cache_key": agent.ID,
-			})
+			return chat(ctx, agent.Provider, agent.Model, false)
 		}
 
 		// Retry loop for context/token errors
@@ -665,6 +1145,25 @@ func (al *AgentLoop) runLLMIteration(
 				break
 			}
 
+			// Images rejected for their dimensions or size: shrink them once,
+			// then drop them so the turn can still be answered.
+			if isImageInputError(err) && retry < maxRetries && hasImageParts(messages) {
+				if retry == 0 {
+					if scaled, ok := downscaleImageParts(messages, maxImageDimension); ok {
+						logger.WarnCF("agent", "Image rejected by model, retrying downscaled", map[string]any{
+							"error": err.Error(),
+						})
+						messages = scaled
+						continue
+					}
+				}
+				logger.WarnCF("agent", "Image rejected by model, retrying without images", map[string]any{
+					"error": err.Error(),
+				})
+				messages = dropImageParts(messages, "rejected by the model as too large")
+				continue
+			}
+
 			errMsg := strings.ToLower(err.Error())
 			isContextError := strings.Contains(errMsg, "token") ||
 				strings.Contains(errMsg, "context") ||
@@ -782,119 +1281,23 @@ func (al *AgentLoop) runLLMIteration(
 		// Save assistant message with tool calls to session
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
-		// Execute tool calls
-		for _, tc := range normalizedToolCalls {
-			argsJSON, _ := json.Marshal(tc.Arguments)
-			argsPreview := utils.Truncate(string(argsJSON), 200)
-			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-				map[string]any{
-					"agent_id":  agent.ID,
-					"tool":      tc.Name,
-					"iteration": iteration,
-				})
-
-			// Create async callback for tools that implement AsyncTool
-			// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
-			// Instead, they notify the agent via PublishInbound, and the agent decides
-			// whether to forward the result to the user (in processSystemMessage).
-			asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
-				// Log the async completion but don't send directly to user
-				// The agent will handle user notification via processSystemMessage
-				if !result.Silent && result.ForUser != "" {
-					logger.InfoCF("agent", "Async tool completed, agent will handle notification",
-						map[string]any{
-							"tool":        tc.Name,
-							"content_len": len(result.ForUser),
-						})
-				}
-			}
-
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
-
-			// Send ForUser content to user immediately if not Silent
-			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
-				al.bus.PublishOutbound(ctx, bus.OutboundMessage{
-					Channel: opts.Channel,
-					ChatID:  opts.ChatID,
-					Content: toolResult.ForUser,
-				})
-				logger.DebugCF("agent", "Sent tool result to user",
-					map[string]any{
-						"tool":        tc.Name,
-						"content_len": len(toolResult.ForUser),
-					})
-			}
-
-			// If tool returned media refs, publish them as outbound media
-			if len(toolResult.Media) > 0 && opts.SendResponse {
-				parts := make([]bus.MediaPart, 0, len(toolResult.Media))
-				for _, ref := range toolResult.Media {
-					part := bus.MediaPart{Ref: ref}
-					// Populate metadata from MediaStore when available
-					if al.mediaStore != nil {
-						if _, meta, err := al.mediaStore.ResolveWithMeta(ref); err == nil {
-							part.Filename = meta.Filename
-							part.ContentType = meta.ContentType
-							part.Type = inferMediaType(meta.Filename, meta.ContentType)
-						}
-					}
-					parts = append(parts, part)
-				}
-				al.bus.PublishOutboundMedia(ctx, bus.OutboundMediaMessage{
-					Channel: opts.Channel,
-					ChatID:  opts.ChatID,
-					Parts:   parts,
-				})
-			}
-
-			// Determine content for LLM based on tool result
-			contentForLLM := toolResult.ForLLM
-			if contentForLLM == "" && toolResult.Err != nil {
-				contentForLLM = toolResult.Err.Error()
-			}
-
-			toolResultMsg := providers.Message{
-				Role:       "tool",
-				Content:    contentForLLM,
-				ToolCallID: tc.ID,
-			}
-			messages = append(messages, toolResultMsg)
-
-			// Save tool result message to session
-			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+		// Execute tool calls. Consecutive concurrency-safe calls run in
+		// parallel; results are recorded in the original call order so the
+		// transcript stays valid. A call that was not approved ends the turn.
+		var reply string
+		messages, reply, err = al.runToolCalls(ctx, agent, normalizedToolCalls, messages, opts, iteration, nil)
+		if err != nil {
+			return "", iteration, err
+		}
+		if reply != "" {
+			finalContent = reply
+			break
 		}
 	}
 
 	return finalContent, iteration, nil
 }
 
-// updateToolContexts updates the context for tools that need channel/chatID info.
-func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
-	// Use ContextualTool interface instead of type assertions
-	if tool, ok := agent.Tools.Get("message"); ok {
-		if mt, ok := tool.(tools.ContextualTool); ok {
-			mt.SetContext(channel, chatID)
-		}
-	}
-	if tool, ok := agent.Tools.Get("spawn"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
-		}
-	}
-	if tool, ok := agent.Tools.Get("subagent"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
-		}
-	}
-}
-
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
@@ -1049,6 +1452,7 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
+	ctx = usage.WithScope(ctx, agent.ID, sessionKey)
 
 	history := agent.Sessions.GetHistory(sessionKey)
 	summary := agent.Sessions.GetSummary(sessionKey)
@@ -1069,8 +1473,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		msgTokens := len(m.Content) / 2
-		if msgTokens > maxMessageTokens {
+		if estimateMessageTokens(m) > maxMessageTokens {
 			omitted = true
 			continue
 		}
@@ -1165,15 +1568,35 @@ func (al *AgentLoop) summarizeBatch(
 }
 
 // estimateTokens estimates the number of tokens in a message list.
-// Uses a safe heuristic of 2.5 characters per token to account for CJK and other
-// overheads better than the previous 3 chars/token.
 func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
-	totalChars := 0
+	total := 0
 	for _, m := range messages {
-		totalChars += utf8.RuneCountInString(m.Content)
+		total += estimateMessageTokens(m)
+	}
+	return total
+}
+
+// estimateMessageTokens estimates the tokens of one message. ASCII text
+// averages about 4 characters per token, while other scripts (CJK in
+// particular) come close to a token per character. Tool call arguments count
+// as text, and every message adds a few tokens of framing.
+func estimateMessageTokens(m providers.Message) int {
+	text := m.Content
+	for _, tc := range m.ToolCalls {
+		if tc.Function != nil {
+			text += tc.Function.Name + tc.Function.Arguments
+		}
 	}
-	// 2.5 chars per token = totalChars * 2 / 5
-	return totalChars * 2 / 5
+
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return 4 + (ascii+3)/4 + other
 }
 
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
@@ -1187,6 +1610,14 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		return "", false
 	}
 
+	// Approvals answered through ProcessDirect and the HTTP API resume their
+	// turn in its session's queue; the result goes to the originating chat.
+	if reply, handled := al.handleApprovalCommand(msg, func(p state.PendingApproval, d approvalDecision) {
+		al.resumeTurn(context.WithoutCancel(ctx), p, d)
+	}); handled {
+		return reply, true
+	}
+
 	cmd := parts[0]
 	args := parts[1:]
 
@@ -1201,7 +1632,8 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 			if defaultAgent == nil {
 				return "No default agent configured", true
 			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
+			return fmt.Sprintf("Current model: %s (%s)", defaultAgent.Model,
+				al.models.Capabilities(defaultAgent.Model)), true
 		case "channel":
 			return fmt.Sprintf("Current channel: %s", msg.Channel), true
 		case "agents":
@@ -1234,6 +1666,23 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 			return fmt.Sprintf("Unknown list target: %s", args[0]), true
 		}
 
+	case "/usage":
+		period := usage.PeriodToday
+		if len(args) > 0 {
+			period = args[0]
+		}
+		summary, err := al.usageTracker.Summary(period)
+		if err != nil {
+			return "Usage: /usage [today|month|all]", true
+		}
+		return usage.Format(summary), true
+
+	case "/undo":
+		return al.undoLastPatch(msg), true
+
+	case "/tasks":
+		return al.handleTasksCommand(msg, args), true
+
 	case "/switch":
 		if len(args) < 3 || args[1] != "to" {
 			return "Usage: /switch [model|channel] to <name>", true
@@ -1266,6 +1715,63 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 	return "", false
 }
 
+// undoLastPatch reverts the last patch apply_patch made in the session of
+// msg and notes the undo in the session history, so the model doesn't assume
+// its changes are still in place.
+func (al *AgentLoop) undoLastPatch(msg bus.InboundMessage) string {
+	agent, sessionKey, _ := al.resolveRoute(msg)
+	if agent == nil {
+		return "No default agent configured"
+	}
+	tool, ok := agent.Tools.Get("apply_patch")
+	patchTool, isPatchTool := tool.(*tools.ApplyPatchTool)
+	if !ok || !isPatchTool {
+		return "Undo is not available"
+	}
+
+	reply, err := patchTool.Undo(sessionKey)
+	if errors.Is(err, tools.ErrNothingToUndo) {
+		return "Nothing to undo"
+	}
+	if err != nil {
+		return fmt.Sprintf("Undo failed: %v", err)
+	}
+	agent.Sessions.AddMessage(sessionKey, "user", "/undo")
+	agent.Sessions.AddMessage(sessionKey, "assistant", reply)
+	agent.Sessions.Save(sessionKey)
+	return reply
+}
+
+// handleTasksCommand lists the background tasks spawned from the chat of msg,
+// or shows or cancels one of them.
+func (al *AgentLoop) handleTasksCommand(msg bus.InboundMessage, args []string) string {
+	agent, _, _ := al.resolveRoute(msg)
+	if agent == nil {
+		return "No default agent configured"
+	}
+	if agent.Tasks == nil {
+		return "Tasks are not available"
+	}
+
+	if len(args) == 0 {
+		return tools.FormatTaskList(agent.Tasks.ListTasksFor(msg.Channel, msg.ChatID))
+	}
+	if len(args) != 2 || (args[0] != "show" && args[0] != "cancel") {
+		return "Usage: /tasks [show|cancel <id>]"
+	}
+	task, ok := agent.Tasks.GetTask(args[1])
+	if !ok || !task.SpawnedFrom(msg.Channel, msg.ChatID) {
+		return fmt.Sprintf("No task with id %s", args[1])
+	}
+	if args[0] == "show" {
+		return tools.FormatTask(task)
+	}
+	if err := agent.Tasks.Cancel(task.ID); err != nil {
+		return fmt.Sprintf("Cancel failed: %v", err)
+	}
+	return fmt.Sprintf("Canceled task %s", task.ID)
+}
+
 // extractPeer extracts the routing peer from the inbound message's structured Peer field.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	if msg.Peer.Kind == "" {
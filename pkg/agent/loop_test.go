@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -199,6 +202,51 @@ func TestToolRegistry_ToolRegistration(t *testing.T) {
 	}
 }
 
+func TestRegisterMCPTools_KeepsServersApart(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	agent := al.registry.GetDefaultAgent()
+
+	// build mimics mcp.NewTools: a name held by another server gets a suffix.
+	build := func(server string, names ...string) func(func(string) bool) []tools.Tool {
+		return func(taken func(string) bool) []tools.Tool {
+			var result []tools.Tool
+			for _, name := range names {
+				if taken(name) {
+					name += "_" + server
+				}
+				result = append(result, &countingTool{name: name})
+			}
+			return result
+		}
+	}
+
+	al.registerMCPTools("a", build("a", "mcp_x"))
+	al.registerMCPTools("b", build("b", "mcp_x"))
+	for _, name := range []string{"mcp_x", "mcp_x_b"} {
+		if _, ok := agent.Tools.Get(name); !ok {
+			t.Errorf("expected %s to be registered", name)
+		}
+	}
+
+	al.registerMCPTools("b", build("b"))
+	if _, ok := agent.Tools.Get("mcp_x"); !ok {
+		t.Error("reconnecting server b removed server a's tool")
+	}
+	if _, ok := agent.Tools.Get("mcp_x_b"); ok {
+		t.Error("expected server b's stale tool to be removed")
+	}
+}
+
 // TestToolContext_Updates verifies tool context is updated with channel/chatID
 func TestToolContext_Updates(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
@@ -798,3 +846,306 @@ func TestHandleReasoning(t *testing.T) {
 		}
 	})
 }
+
+type echoMockProvider struct{}
+
+func (m *echoMockProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	_ []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: messages[len(messages)-1].Content}, nil
+}
+
+func (m *echoMockProvider) GetDefaultModel() string { return "echo" }
+
+func TestHandleInbound_AppliesMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "echo",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &echoMockProvider{})
+	al.UseInbound(
+		func(_ context.Context, msg bus.InboundMessage) bus.InboundMessage {
+			msg.Content = "transcript\n" + msg.Content
+			return msg
+		},
+		func(_ context.Context, msg bus.InboundMessage) bus.InboundMessage {
+			msg.Content += "!"
+			return msg
+		},
+	)
+
+	al.handleInbound(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "u", ChatID: "c", Content: "[voice]",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("expected outbound message")
+	}
+	if out.Content != "transcript\n[voice]!" {
+		t.Errorf("expected middleware to run in order, got %q", out.Content)
+	}
+}
+
+type streamingEchoProvider struct{ echoMockProvider }
+
+func (m *streamingEchoProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*providers.LLMResponse, error) {
+	resp, err := m.Chat(ctx, messages, tools, model, options)
+	for _, word := range strings.SplitAfter(resp.Content, " ") {
+		onDelta(word)
+	}
+	return resp, err
+}
+
+func TestProcessForAgent_StreamsToOnDelta(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "echo",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &streamingEchoProvider{})
+
+	var deltas []string
+	resp, err := al.ProcessForAgent(context.Background(), DirectRequest{
+		AgentID:    al.DefaultAgentID(),
+		SessionKey: "agent:main:openai:direct:x",
+		Channel:    "openai",
+		ChatID:     "x",
+		Content:    "hello over there",
+		OnDelta:    func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatalf("ProcessForAgent: %v", err)
+	}
+	if resp != "hello over there" || strings.Join(deltas, "") != resp || len(deltas) != 3 {
+		t.Errorf("resp = %q, deltas = %q", resp, deltas)
+	}
+	agent, _ := al.registry.GetAgent(al.DefaultAgentID())
+	if h := agent.Sessions.GetHistory("agent:main:openai:direct:x"); len(h) != 2 {
+		t.Errorf("session history = %d messages, want 2", len(h))
+	}
+
+	if _, err := al.ProcessForAgent(context.Background(), DirectRequest{AgentID: "nobody", Content: "hi"}); err == nil {
+		t.Error("expected error for unknown agent")
+	}
+}
+
+// overlapProvider records whether two calls were ever in flight at once.
+type overlapProvider struct {
+	inFlight atomic.Int32
+	overlap  atomic.Bool
+}
+
+func (p *overlapProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	_ []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	if p.inFlight.Add(1) > 1 {
+		p.overlap.Store(true)
+	}
+	defer p.inFlight.Add(-1)
+	time.Sleep(50 * time.Millisecond)
+	return &providers.LLMResponse{Content: "re: " + messages[len(messages)-1].Content}, nil
+}
+
+func (p *overlapProvider) GetDefaultModel() string { return "overlap" }
+
+func TestProcessForAgent_SerializesSameSession(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "overlap",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+	provider := &overlapProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	const sessionKey = "agent:main:openai:direct:x"
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, content := range []string{"first", "second"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := al.ProcessForAgent(context.Background(), DirectRequest{
+				AgentID:    al.DefaultAgentID(),
+				SessionKey: sessionKey,
+				Channel:    "openai",
+				ChatID:     "x",
+				Content:    content,
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("ProcessForAgent: %v", err)
+		}
+	}
+
+	if provider.overlap.Load() {
+		t.Error("expected turns on one session to run one at a time")
+	}
+	agent, _ := al.registry.GetAgent(al.DefaultAgentID())
+	history := agent.Sessions.GetHistory(sessionKey)
+	if len(history) != 4 {
+		t.Fatalf("session history = %d messages, want 4", len(history))
+	}
+	for i := 0; i < 4; i += 2 {
+		if history[i].Role != "user" || history[i+1].Content != "re: "+history[i].Content {
+			t.Errorf("turns interleaved: %+v", history)
+		}
+	}
+}
+
+func TestUndoCommand_RevertsLastPatch(t *testing.T) {
+	workspace := t.TempDir()
+	target := filepath.Join(workspace, "notes.md")
+	provider := &scriptedToolProvider{calls: []providers.ToolCall{{
+		ID:   "call_0",
+		Name: "apply_patch",
+		Arguments: map[string]any{"edits": []any{
+			map[string]any{"path": target, "old_text": "", "new_text": "hello\n"},
+		}},
+	}}}
+	al, msgBus, _ := newApprovalTestLoop(t, workspace, config.ApprovalConfig{}, provider)
+	startLoop(t, al)
+
+	ctx := context.Background()
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "write notes"))
+	if out := nextOutbound(t, msgBus); out.Content != "done" {
+		t.Fatalf("reply = %q", out.Content)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Fatalf("patch not applied: %v", err)
+	}
+
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "/undo"))
+	if out := nextOutbound(t, msgBus); !strings.Contains(out.Content, "removed "+target) {
+		t.Errorf("undo reply = %q", out.Content)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("expected notes.md to be removed, stat err = %v", err)
+	}
+
+	msgBus.PublishInbound(ctx, chatMessage("telegram:1", "/undo"))
+	if out := nextOutbound(t, msgBus); out.Content != "Nothing to undo" {
+		t.Errorf("second undo reply = %q", out.Content)
+	}
+}
+
+type capabilityRecordingProvider struct {
+	tools     int
+	maxTokens any
+	streamed  bool
+}
+
+func (p *capabilityRecordingProvider) Chat(
+	_ context.Context,
+	_ []providers.Message,
+	tools []providers.ToolDefinition,
+	_ string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	p.tools = len(tools)
+	p.maxTokens = options["max_tokens"]
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *capabilityRecordingProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+	_ func(delta string),
+) (*providers.LLMResponse, error) {
+	p.streamed = true
+	return p.Chat(ctx, messages, tools, model, options)
+}
+
+func (p *capabilityRecordingProvider) GetDefaultModel() string { return "o1-mini" }
+
+func TestAgentLoop_UsesModelCapabilities(t *testing.T) {
+	noStreaming := false
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "mini",
+				MaxTokens:         100000,
+				MaxToolIterations: 3,
+			},
+		},
+		ModelList: []config.ModelConfig{{
+			ModelName:    "mini",
+			Model:        "openai/o1-mini",
+			Capabilities: &config.ModelCapabilitiesConfig{Streaming: &noStreaming},
+		}},
+	}
+	provider := &capabilityRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	agent := al.registry.GetDefaultAgent()
+	if agent.ContextWindow != 128000 {
+		t.Errorf("ContextWindow = %d, want the catalog's 128000", agent.ContextWindow)
+	}
+
+	var deltas []string
+	_, err := al.ProcessForAgent(context.Background(), DirectRequest{
+		AgentID: agent.ID,
+		Content: "hi",
+		OnDelta: func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.tools != 0 {
+		t.Errorf("expected tools to be withheld from a model without tool support, got %d", provider.tools)
+	}
+	if provider.maxTokens != 65536 {
+		t.Errorf("max_tokens = %v, want the model's 65536 output limit", provider.maxTokens)
+	}
+	if provider.streamed {
+		t.Error("expected no streaming when the model config disables it")
+	}
+
+	reply, _ := al.handleCommand(context.Background(), bus.InboundMessage{Content: "/show model"})
+	if !strings.Contains(reply, "context 128000 tokens") || !strings.Contains(reply, "supports reasoning") {
+		t.Errorf("unexpected /show model reply: %s", reply)
+	}
+}
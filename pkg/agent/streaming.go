@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+)
+
+// defaultStreamingInterval is used when agents.defaults.streaming_interval_ms is unset.
+const defaultStreamingInterval = time.Second
+
+// placeholderEditor replaces the text of a channel's pending placeholder message.
+// Implemented by channels.Manager.
+type placeholderEditor interface {
+	EditPlaceholder(ctx context.Context, channel, chatID, content string) bool
+}
+
+// streamWriter accumulates streamed content deltas for a single LLM call and
+// pushes throttled partial updates into the chat's placeholder message. The
+// final response still goes out through the bus, which edits the same
+// placeholder one last time.
+type streamWriter struct {
+	ctx      context.Context
+	editor   placeholderEditor
+	channel  string
+	chatID   string
+	interval time.Duration
+
+	buf      strings.Builder
+	lastEdit time.Time
+	stopped  bool
+}
+
+// newStreamWriter returns a writer for the given chat, or nil when partial
+// updates should not be sent (streaming disabled, internal channel, or no
+// channel manager to edit through).
+func (al *AgentLoop) newStreamWriter(ctx context.Context, opts processOptions) *streamWriter {
+	if !opts.Stream || al.channelManager == nil || !al.cfg.Agents.Defaults.Streaming {
+		return nil
+	}
+	if constants.IsInternalChannel(opts.Channel) {
+		return nil
+	}
+
+	interval := time.Duration(al.cfg.Agents.Defaults.StreamingIntervalMS) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultStreamingInterval
+	}
+	return &streamWriter{
+		ctx:      ctx,
+		editor:   al.channelManager,
+		channel:  opts.Channel,
+		chatID:   opts.ChatID,
+		interval: interval,
+	}
+}
+
+// OnDelta records a content delta and edits the placeholder if the throttle
+// interval has elapsed. Once an edit is refused (no placeholder, or a channel
+// that cannot edit) the writer stops trying for the rest of the call.
+func (w *streamWriter) OnDelta(delta string) {
+	w.buf.WriteString(delta)
+	if w.stopped || time.Since(w.lastEdit) < w.interval {
+		return
+	}
+
+	content := strings.TrimSpace(w.buf.String())
+	if content == "" {
+		return
+	}
+	w.lastEdit = time.Now()
+	if !w.editor.EditPlaceholder(w.ctx, w.channel, w.chatID, content) {
+		w.stopped = true
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type recordingEditor struct {
+	edits  []string
+	refuse bool
+}
+
+func (e *recordingEditor) EditPlaceholder(_ context.Context, _, _, content string) bool {
+	e.edits = append(e.edits, content)
+	return !e.refuse
+}
+
+func TestStreamWriter_ThrottlesEdits(t *testing.T) {
+	editor := &recordingEditor{}
+	w := &streamWriter{ctx: context.Background(), editor: editor, interval: time.Hour}
+
+	w.OnDelta("Hel")
+	w.OnDelta("lo")
+	w.OnDelta(" world")
+
+	if len(editor.edits) != 1 || editor.edits[0] != "Hel" {
+		t.Fatalf("expected a single immediate edit, got %v", editor.edits)
+	}
+
+	w.lastEdit = time.Time{}
+	w.OnDelta("!")
+	if len(editor.edits) != 2 || editor.edits[1] != "Hello world!" {
+		t.Fatalf("expected accumulated content on next edit, got %v", editor.edits)
+	}
+}
+
+func TestStreamWriter_StopsWhenEditRefused(t *testing.T) {
+	editor := &recordingEditor{refuse: true}
+	w := &streamWriter{ctx: context.Background(), editor: editor}
+
+	w.OnDelta("a")
+	w.OnDelta("b")
+	w.OnDelta("c")
+
+	if len(editor.edits) != 1 {
+		t.Fatalf("expected writer to stop after a refused edit, got %d edits", len(editor.edits))
+	}
+}
+
+func TestNewStreamWriter_Disabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	al := &AgentLoop{cfg: cfg}
+
+	opts := processOptions{Channel: "telegram", ChatID: "1", Stream: true}
+	if al.newStreamWriter(context.Background(), opts) != nil {
+		t.Fatal("expected nil writer without a channel manager")
+	}
+}
@@ -0,0 +1,254 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// defaultMaxParallelTools is used when agents.defaults.max_parallel_tools is unset.
+const defaultMaxParallelTools = 4
+
+// maxParallelTools returns the per-turn cap on concurrently running tool calls.
+func (al *AgentLoop) maxParallelTools() int {
+	if n := al.cfg.Agents.Defaults.MaxParallelTools; n > 0 {
+		return n
+	}
+	return defaultMaxParallelTools
+}
+
+// toolBatch is a run of tool calls that may execute together.
+type toolBatch struct {
+	calls    []providers.ToolCall
+	parallel bool
+}
+
+// batchToolCalls splits calls, in order, into batches: each run of
+// consecutive concurrency-safe calls forms one parallel batch, and every other
+// call runs alone. Calls for which exclusive (if set) returns true always run
+// alone. With a limit of 1 all calls run sequentially.
+func batchToolCalls(
+	registry *tools.ToolRegistry,
+	calls []providers.ToolCall,
+	limit int,
+	exclusive func(providers.ToolCall) bool,
+) []toolBatch {
+	var batches []toolBatch
+	for _, tc := range calls {
+		safe := limit > 1 && registry.IsConcurrencySafe(tc.Name) && (exclusive == nil || !exclusive(tc))
+		if n := len(batches); safe && n > 0 && batches[n-1].parallel {
+			batches[n-1].calls = append(batches[n-1].calls, tc)
+			continue
+		}
+		batches = append(batches, toolBatch{calls: []providers.ToolCall{tc}, parallel: safe})
+	}
+	return batches
+}
+
+// executeToolBatch runs the calls of a batch, at most maxParallelTools at a
+// time, and returns their results in call order.
+func (al *AgentLoop) executeToolBatch(
+	ctx context.Context,
+	agent *AgentInstance,
+	batch toolBatch,
+	opts processOptions,
+	iteration int,
+) []*tools.ToolResult {
+	results := make([]*tools.ToolResult, len(batch.calls))
+	if !batch.parallel || len(batch.calls) == 1 {
+		for i, tc := range batch.calls {
+			results[i] = al.executeToolCall(ctx, agent, tc, opts, iteration)
+		}
+		return results
+	}
+
+	logger.DebugCF("agent", "Running tool calls in parallel",
+		map[string]any{
+			"agent_id": agent.ID,
+			"count":    len(batch.calls),
+		})
+
+	sem := make(chan struct{}, al.maxParallelTools())
+	var wg sync.WaitGroup
+	for i, tc := range batch.calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = al.executeToolCall(ctx, agent, tc, opts, iteration)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// executeToolCall runs a single tool call.
+func (al *AgentLoop) executeToolCall(
+	ctx context.Context,
+	agent *AgentInstance,
+	tc providers.ToolCall,
+	opts processOptions,
+	iteration int,
+) *tools.ToolResult {
+	argsJSON, _ := json.Marshal(tc.Arguments)
+	argsPreview := utils.Truncate(string(argsJSON), 200)
+	logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+		map[string]any{
+			"agent_id":  agent.ID,
+			"tool":      tc.Name,
+			"iteration": iteration,
+		})
+
+	if al.approvalPolicy.decide(tc.Name, tc.Arguments) == approvalNever {
+		logger.WarnCF("agent", "Tool call refused by approval policy",
+			map[string]any{
+				"agent_id": agent.ID,
+				"tool":     tc.Name,
+			})
+		return tools.ErrorResult(fmt.Sprintf("Tool %s is not allowed by the approval policy", tc.Name))
+	}
+
+	// Create async callback for tools that implement AsyncTool
+	// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
+	// Instead, they notify the agent via PublishInbound, and the agent decides
+	// whether to forward the result to the user (in processSystemMessage).
+	asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
+		// Log the async completion but don't send directly to user
+		// The agent will handle user notification via processSystemMessage
+		if !result.Silent && result.ForUser != "" {
+			logger.InfoCF("agent", "Async tool completed, agent will handle notification",
+				map[string]any{
+					"tool":        tc.Name,
+					"content_len": len(result.ForUser),
+				})
+		}
+	}
+
+	return agent.Tools.ExecuteWithContext(
+		ctx,
+		tc.Name,
+		tc.Arguments,
+		opts.Channel,
+		opts.ChatID,
+		asyncCallback,
+	)
+}
+
+// runToolCalls executes calls in batches, appending their results to
+// messages and the session in call order. A call whose policy is "ask" waits
+// for approval first, unless decided already holds the decision for its ID.
+// When a call is not approved, it and the calls after it are recorded as not
+// run and the reply that ends the turn is returned.
+func (al *AgentLoop) runToolCalls(
+	ctx context.Context,
+	agent *AgentInstance,
+	calls []providers.ToolCall,
+	messages []providers.Message,
+	opts processOptions,
+	iteration int,
+	decided map[string]approvalDecision,
+) ([]providers.Message, string, error) {
+	done := 0
+	for _, batch := range batchToolCalls(agent.Tools, calls, al.maxParallelTools(), al.needsApproval) {
+		if tc := batch.calls[0]; len(batch.calls) == 1 && al.needsApproval(tc) {
+			d, ok := decided[tc.ID]
+			if !ok {
+				var err error
+				if d, err = al.requestApproval(ctx, agent, tc, opts); err != nil {
+					return messages, "", err
+				}
+			}
+			if d != approvalApproved {
+				content, reply := approvalOutcome(tc.Name, d)
+				results := []*tools.ToolResult{tools.ErrorResult(content)}
+				for range calls[done+1:] {
+					results = append(results, tools.ErrorResult(
+						"Skipped: an earlier tool call in this turn was not approved."))
+				}
+				messages = al.recordToolResults(ctx, agent, calls[done:], results, messages, opts)
+				return messages, reply, nil
+			}
+		}
+
+		results := al.executeToolBatch(ctx, agent, batch, opts, iteration)
+		messages = al.recordToolResults(ctx, agent, batch.calls, results, messages, opts)
+		done += len(batch.calls)
+	}
+	return messages, "", nil
+}
+
+// recordToolResults forwards user-facing output and media of results to the
+// chat and appends the results to messages and the session.
+func (al *AgentLoop) recordToolResults(
+	ctx context.Context,
+	agent *AgentInstance,
+	calls []providers.ToolCall,
+	results []*tools.ToolResult,
+	messages []providers.Message,
+	opts processOptions,
+) []providers.Message {
+	for i, tc := range calls {
+		toolResult := results[i]
+
+		// Send ForUser content to user immediately if not Silent
+		if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
+			al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel: opts.Channel,
+				ChatID:  opts.ChatID,
+				Content: toolResult.ForUser,
+			})
+			logger.DebugCF("agent", "Sent tool result to user",
+				map[string]any{
+					"tool":        tc.Name,
+					"content_len": len(toolResult.ForUser),
+				})
+		}
+
+		// If tool returned media refs, publish them as outbound media
+		if len(toolResult.Media) > 0 && opts.SendResponse {
+			parts := make([]bus.MediaPart, 0, len(toolResult.Media))
+			for _, ref := range toolResult.Media {
+				part := bus.MediaPart{Ref: ref}
+				// Populate metadata from MediaStore when available
+				if al.mediaStore != nil {
+					if _, meta, err := al.mediaStore.ResolveWithMeta(ref); err == nil {
+						part.Filename = meta.Filename
+						part.ContentType = meta.ContentType
+						part.Type = inferMediaType(meta.Filename, meta.ContentType)
+					}
+				}
+				parts = append(parts, part)
+			}
+			al.bus.PublishOutboundMedia(ctx, bus.OutboundMediaMessage{
+				Channel: opts.Channel,
+				ChatID:  opts.ChatID,
+				Parts:   parts,
+			})
+		}
+
+		// Determine content for LLM based on tool result
+		contentForLLM := toolResult.ForLLM
+		if contentForLLM == "" && toolResult.Err != nil {
+			contentForLLM = toolResult.Err.Error()
+		}
+
+		toolResultMsg := providers.Message{
+			Role:       "tool",
+			Content:    contentForLLM,
+			ToolCallID: tc.ID,
+		}
+		messages = append(messages, toolResultMsg)
+
+		// Save tool result message to session
+		agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+	}
+	return messages
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// sleepTool sleeps, tracking how many instances run at once.
+type sleepTool struct {
+	name    string
+	safe    bool
+	delay   time.Duration
+	running *atomic.Int32
+	peak    *atomic.Int32
+}
+
+func (t *sleepTool) Name() string               { return t.name }
+func (t *sleepTool) Description() string        { return "sleeps" }
+func (t *sleepTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (t *sleepTool) ConcurrencySafe() bool      { return t.safe }
+
+func (t *sleepTool) Execute(_ context.Context, args map[string]any) *tools.ToolResult {
+	n := t.running.Add(1)
+	for {
+		p := t.peak.Load()
+		if n <= p || t.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(t.delay)
+	t.running.Add(-1)
+	return tools.SilentResult(fmt.Sprintf("%s:%v", t.name, args["n"]))
+}
+
+func newSleepRegistry(delay time.Duration) (*tools.ToolRegistry, *atomic.Int32) {
+	var running, peak atomic.Int32
+	r := tools.NewToolRegistry()
+	r.Register(&sleepTool{name: "fetch", safe: true, delay: delay, running: &running, peak: &peak})
+	r.Register(&sleepTool{name: "write", delay: delay, running: &running, peak: &peak})
+	return r, &peak
+}
+
+func calls(names ...string) []providers.ToolCall {
+	out := make([]providers.ToolCall, len(names))
+	for i, name := range names {
+		out[i] = providers.ToolCall{ID: fmt.Sprintf("call_%d", i), Name: name, Arguments: map[string]any{"n": i}}
+	}
+	return out
+}
+
+func TestBatchToolCalls(t *testing.T) {
+	registry, _ := newSleepRegistry(0)
+
+	batches := batchToolCalls(registry, calls("fetch", "fetch", "write", "fetch", "missing", "fetch", "fetch"), 4, nil)
+	var got []string
+	for _, b := range batches {
+		got = append(got, fmt.Sprintf("%d/%v", len(b.calls), b.parallel))
+	}
+	want := []string{"2/true", "1/false", "1/true", "1/false", "2/true"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+
+	if b := batchToolCalls(registry, calls("fetch", "fetch"), 1, nil); len(b) != 2 || b[0].parallel {
+		t.Errorf("limit 1 should run calls one by one, got %+v", b)
+	}
+}
+
+func TestRunLLMIteration_ParallelToolCallsKeepOrder(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+				MaxParallelTools:  2,
+			},
+		},
+	}
+	provider := &scriptedToolProvider{calls: calls("fetch", "fetch", "fetch", "write")}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	var running, peak atomic.Int32
+	delay := 50 * time.Millisecond
+	agent.Tools.Register(&sleepTool{name: "fetch", safe: true, delay: delay, running: &running, peak: &peak})
+	agent.Tools.Register(&sleepTool{name: "write", delay: delay, running: &running, peak: &peak})
+
+	start := time.Now()
+	resp, err := al.ProcessDirect(context.Background(), "go", "agent:main:test")
+	if err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if resp != "done" {
+		t.Errorf("response = %q", resp)
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak concurrency = %d, want the cap of 2", p)
+	}
+	// 3 fetches at 2 at a time take 2 rounds, then the write: 3 rounds, not 4.
+	if elapsed >= 4*delay {
+		t.Errorf("elapsed %s, want parallel fetches", elapsed)
+	}
+
+	var results []string
+	for _, m := range agent.Sessions.GetHistory("agent:main:test") {
+		if m.Role == "tool" {
+			results = append(results, m.ToolCallID+"="+m.Content)
+		}
+	}
+	want := []string{"call_0=fetch:0", "call_1=fetch:1", "call_2=fetch:2", "call_3=write:3"}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("tool results = %v, want %v", results, want)
+	}
+}
+
+// scriptedToolProvider requests the given tool calls once, then answers "done".
+type scriptedToolProvider struct {
+	calls []providers.ToolCall
+	n     atomic.Int32
+}
+
+func (p *scriptedToolProvider) Chat(
+	_ context.Context,
+	_ []providers.Message,
+	_ []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	if p.n.Add(1) == 1 {
+		return &providers.LLMResponse{ToolCalls: p.calls}, nil
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *scriptedToolProvider) GetDefaultModel() string { return "test" }
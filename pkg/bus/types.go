@@ -30,9 +30,21 @@ type InboundMessage struct {
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel string   `json:"channel"`
+	ChatID  string   `json:"chat_id"`
+	Content string   `json:"content"`
+	Buttons []Button `json:"buttons,omitempty"` // optional inline buttons, where supported
+	// ClearButtonsOf is the ID of an earlier message in the chat whose inline
+	// buttons are removed, e.g. an approval prompt that has been answered.
+	ClearButtonsOf string `json:"clear_buttons_of,omitempty"`
+}
+
+// Button is an inline button attached to an outbound message. Pressing it
+// sends Data back to the agent as if the user had typed it, so channels
+// without buttons can show Data as a text command instead.
+type Button struct {
+	Text string `json:"text"`
+	Data string `json:"data"`
 }
 
 // MediaPart describes a single media attachment to send.
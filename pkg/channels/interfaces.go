@@ -15,6 +15,12 @@ type MessageEditor interface {
 	EditMessage(ctx context.Context, chatID string, messageID string, content string) error
 }
 
+// ButtonRemover — channels that can remove the inline buttons of a message they sent.
+// messageID is always string; channels convert platform-specific types internally.
+type ButtonRemover interface {
+	RemoveButtons(ctx context.Context, chatID string, messageID string) error
+}
+
 // ReactionCapable — channels that can add a reaction (e.g. 👀) to an inbound message.
 // ReactToMessage adds a reaction and returns an undo function to remove it.
 // The undo function MUST be idempotent and safe to call multiple times.
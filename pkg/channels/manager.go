@@ -113,11 +113,25 @@ func (m *Manager) RecordReactionUndo(channel, chatID string, undo func()) {
 	m.reactionUndos.Store(key, reactionEntry{undo: undo, createdAt: time.Now()})
 }
 
-// preSend handles typing stop, reaction undo, and placeholder editing before sending a message.
-// Returns true if the message was edited into a placeholder (skip Send).
+// preSend handles typing stop, reaction undo, button removal and placeholder editing before
+// sending a message. Returns true if the message was edited into a placeholder (skip Send).
+// Messages with buttons are always sent as new messages and leave the placeholder for the
+// next reply.
 func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMessage, ch Channel) bool {
 	key := name + ":" + msg.ChatID
 
+	if msg.ClearButtonsOf != "" {
+		if remover, ok := ch.(ButtonRemover); ok {
+			if err := remover.RemoveButtons(ctx, msg.ChatID, msg.ClearButtonsOf); err != nil {
+				logger.DebugCF("channels", "Failed to remove inline buttons", map[string]any{
+					"channel":    name,
+					"message_id": msg.ClearButtonsOf,
+					"error":      err.Error(),
+				})
+			}
+		}
+	}
+
 	// 1. Stop typing
 	if v, loaded := m.typingStops.LoadAndDelete(key); loaded {
 		if entry, ok := v.(typingEntry); ok {
@@ -133,6 +147,9 @@ func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMess
 	}
 
 	// 3. Try editing placeholder
+	if len(msg.Buttons) > 0 {
+		return false
+	}
 	if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
 		if entry, ok := v.(placeholderEntry); ok && entry.id != "" {
 			if editor, ok := ch.(MessageEditor); ok {
@@ -147,6 +164,60 @@ func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMess
 	return false
 }
 
+// EditPlaceholder replaces the text of the recorded placeholder for chatID with
+// content, leaving the placeholder in place so the final outbound message still
+// edits it. It is used to show partial (streamed) responses and returns false
+// when there is nothing to edit: no placeholder, a channel that cannot edit
+// messages, or a failed edit. Content is truncated to the channel's maximum
+// message length, and the edit is skipped if the channel's rate limit has no
+// token available right now.
+func (m *Manager) EditPlaceholder(ctx context.Context, channel, chatID, content string) bool {
+	key := channel + ":" + chatID
+	v, ok := m.placeholders.Load(key)
+	if !ok {
+		return false
+	}
+	entry, ok := v.(placeholderEntry)
+	if !ok || entry.id == "" {
+		return false
+	}
+
+	m.mu.RLock()
+	ch, exists := m.channels[channel]
+	w := m.workers[channel]
+	m.mu.RUnlock()
+	if !exists {
+		return false
+	}
+	editor, ok := ch.(MessageEditor)
+	if !ok {
+		return false
+	}
+
+	if w != nil && !w.limiter.Allow() {
+		// Keep the stream going; a later update will carry this content too.
+		return true
+	}
+
+	if mlp, ok := ch.(MessageLengthProvider); ok {
+		if maxLen := mlp.MaxMessageLength(); maxLen > 0 {
+			if runes := []rune(content); len(runes) > maxLen {
+				content = string(runes[:maxLen])
+			}
+		}
+	}
+
+	if err := editor.EditMessage(ctx, chatID, entry.id, content); err != nil {
+		logger.DebugCF("channels", "Placeholder edit failed", map[string]any{
+			"channel": channel,
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
 func NewManager(cfg *config.Config, messageBus *bus.MessageBus, store media.MediaStore) (*Manager, error) {
 	m := &Manager{
 		channels:   make(map[string]Channel),
@@ -307,6 +378,15 @@ func (m *Manager) SetupHTTPServer(addr string, healthServer *health.Server) {
 	}
 }
 
+// HandleHTTP registers an extra handler on the shared HTTP server.
+// Must be called after SetupHTTPServer and before StartAll.
+func (m *Manager) HandleHTTP(pattern string, handler http.Handler) {
+	if m.mux == nil {
+		return
+	}
+	m.mux.Handle(pattern, handler)
+}
+
 func (m *Manager) StartAll(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -463,9 +543,12 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 			}
 			if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
 				chunks := SplitMessage(msg.Content, maxLen)
-				for _, chunk := range chunks {
+				for i, chunk := range chunks {
 					chunkMsg := msg
 					chunkMsg.Content = chunk
+					if i < len(chunks)-1 {
+						chunkMsg.Buttons = nil // buttons go with the last chunk
+					}
 					m.sendWithRetry(ctx, name, w, chunkMsg)
 				}
 			} else {
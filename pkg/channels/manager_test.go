@@ -497,6 +497,129 @@ func TestPreSend_PlaceholderEditFails_FallsThrough(t *testing.T) {
 	}
 }
 
+func TestPreSend_ButtonsKeepPlaceholder(t *testing.T) {
+	m := newTestManager()
+	var edits []string
+
+	ch := &mockMessageEditor{
+		mockChannel: mockChannel{
+			sendFn: func(_ context.Context, _ bus.OutboundMessage) error {
+				return nil
+			},
+		},
+		editFn: func(_ context.Context, _, _, content string) error {
+			edits = append(edits, content)
+			return nil
+		},
+	}
+
+	m.RecordPlaceholder("test", "123", "456")
+
+	prompt := bus.OutboundMessage{
+		Channel: "test",
+		ChatID:  "123",
+		Content: "Approve?",
+		Buttons: []bus.Button{{Text: "Approve", Data: "/approve x"}},
+	}
+	if m.preSend(context.Background(), "test", prompt, ch) {
+		t.Fatal("expected message with buttons to be sent, not edited into the placeholder")
+	}
+
+	final := bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "done"}
+	if !m.preSend(context.Background(), "test", final, ch) {
+		t.Fatal("expected the next reply to edit the placeholder")
+	}
+	if len(edits) != 1 || edits[0] != "done" {
+		t.Fatalf("edits = %v, want [done]", edits)
+	}
+}
+
+type mockButtonRemover struct {
+	mockChannel
+	removeFn func(ctx context.Context, chatID, messageID string) error
+}
+
+func (m *mockButtonRemover) RemoveButtons(ctx context.Context, chatID, messageID string) error {
+	return m.removeFn(ctx, chatID, messageID)
+}
+
+func TestPreSend_ClearsButtons(t *testing.T) {
+	m := newTestManager()
+	var removed []string
+	ch := &mockButtonRemover{
+		removeFn: func(_ context.Context, chatID, messageID string) error {
+			removed = append(removed, chatID+"/"+messageID)
+			return nil
+		},
+	}
+
+	m.preSend(context.Background(), "test", bus.OutboundMessage{ChatID: "123", Content: "ok"}, ch)
+	if len(removed) != 0 {
+		t.Fatalf("buttons removed without ClearButtonsOf: %v", removed)
+	}
+	msg := bus.OutboundMessage{ChatID: "123", Content: "Approved", ClearButtonsOf: "456"}
+	m.preSend(context.Background(), "test", msg, ch)
+	if len(removed) != 1 || removed[0] != "123/456" {
+		t.Fatalf("removed = %v, want [123/456]", removed)
+	}
+}
+
+func TestEditPlaceholder_KeepsPlaceholderForFinalSend(t *testing.T) {
+	m := newTestManager()
+	var edits []string
+
+	ch := &mockMessageEditor{
+		mockChannel: mockChannel{
+			sendFn: func(_ context.Context, _ bus.OutboundMessage) error {
+				t.Fatal("expected Send to NOT be called")
+				return nil
+			},
+		},
+		editFn: func(_ context.Context, _, messageID, content string) error {
+			if messageID != "456" {
+				t.Fatalf("expected messageID 456, got %s", messageID)
+			}
+			edits = append(edits, content)
+			return nil
+		},
+	}
+	m.channels["test"] = ch
+	m.RecordPlaceholder("test", "123", "456")
+
+	if !m.EditPlaceholder(context.Background(), "test", "123", "partial") {
+		t.Fatal("expected EditPlaceholder to succeed")
+	}
+
+	msg := bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "final"}
+	if !m.preSend(context.Background(), "test", msg, ch) {
+		t.Fatal("expected final message to edit the same placeholder")
+	}
+
+	if len(edits) != 2 || edits[0] != "partial" || edits[1] != "final" {
+		t.Fatalf("expected edits [partial final], got %v", edits)
+	}
+}
+
+func TestEditPlaceholder_NoEditorOrPlaceholder(t *testing.T) {
+	m := newTestManager()
+	m.channels["plain"] = &mockChannel{}
+	m.RecordPlaceholder("plain", "123", "456")
+
+	if m.EditPlaceholder(context.Background(), "plain", "123", "partial") {
+		t.Fatal("expected false for a channel that cannot edit messages")
+	}
+
+	m.channels["test"] = &mockMessageEditor{
+		editFn: func(_ context.Context, _, _, _ string) error {
+			t.Fatal("expected EditMessage to NOT be called without a placeholder")
+			return nil
+		},
+	}
+	if m.EditPlaceholder(context.Background(), "test", "123", "partial") {
+		t.Fatal("expected false when no placeholder was recorded")
+	}
+}
+
 func TestPreSend_TypingStopCalled(t *testing.T) {
 	m := newTestManager()
 	var stopCalled bool
@@ -136,6 +136,10 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleCallbackQuery(ctx, query)
+	}, th.AnyCallbackQueryWithMessage())
+
 	c.SetRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -178,6 +182,9 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	// Typing/placeholder handled by Manager.preSend — just send the message
 	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 	tgMsg.ParseMode = telego.ModeHTML
+	if len(msg.Buttons) > 0 {
+		tgMsg.ReplyMarkup = inlineKeyboard(msg.Buttons)
+	}
 
 	if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
 		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
@@ -239,6 +246,20 @@ func (c *TelegramChannel) EditMessage(ctx context.Context, chatID string, messag
 	return err
 }
 
+// RemoveButtons implements channels.ButtonRemover.
+func (c *TelegramChannel) RemoveButtons(ctx context.Context, chatID string, messageID string) error {
+	cid, err := parseChatID(chatID)
+	if err != nil {
+		return err
+	}
+	mid, err := strconv.Atoi(messageID)
+	if err != nil {
+		return err
+	}
+	_, err = c.bot.EditMessageReplyMarkup(ctx, tu.EditMessageReplyMarkup(tu.ID(cid), mid, nil))
+	return err
+}
+
 // SendPlaceholder implements channels.PlaceholderCapable.
 // It sends a placeholder message (e.g. "Thinking... 💭") that will later be
 // edited to the actual response via EditMessage (channels.MessageEditor).
@@ -509,6 +530,69 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	return nil
 }
 
+// handleCallbackQuery turns an inline button press into an inbound message
+// carrying the button's data. The buttons stay until the agent accepts the
+// answer, e.g. from an approver, and removes them with ClearButtonsOf.
+func (c *TelegramChannel) handleCallbackQuery(ctx context.Context, query telego.CallbackQuery) error {
+	_ = c.bot.AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID))
+
+	if query.Data == "" || query.Message == nil {
+		return nil
+	}
+
+	platformID := fmt.Sprintf("%d", query.From.ID)
+	sender := bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    query.From.Username,
+		DisplayName: query.From.FirstName,
+	}
+	if !c.IsAllowedSender(sender) {
+		logger.DebugCF("telegram", "Callback query rejected by allowlist", map[string]any{
+			"user_id": platformID,
+		})
+		return nil
+	}
+
+	chat := query.Message.GetChat()
+	messageID := query.Message.GetMessageID()
+
+	peerKind := "direct"
+	peerID := platformID
+	if chat.Type != "private" {
+		peerKind = "group"
+		peerID = fmt.Sprintf("%d", chat.ID)
+	}
+
+	c.HandleMessage(c.ctx,
+		bus.Peer{Kind: peerKind, ID: peerID},
+		fmt.Sprintf("%d", messageID),
+		platformID,
+		fmt.Sprintf("%d", chat.ID),
+		query.Data,
+		nil,
+		map[string]string{
+			"user_id":    platformID,
+			"username":   query.From.Username,
+			"first_name": query.From.FirstName,
+			"is_group":   fmt.Sprintf("%t", chat.Type != "private"),
+			"callback":   "true",
+		},
+		sender,
+	)
+	return nil
+}
+
+// inlineKeyboard lays out buttons in a single row.
+func inlineKeyboard(buttons []bus.Button) *telego.InlineKeyboardMarkup {
+	row := make([]telego.InlineKeyboardButton, 0, len(buttons))
+	for _, b := range buttons {
+		row = append(row, tu.InlineKeyboardButton(b.Text).WithCallbackData(b.Data))
+	}
+	return tu.InlineKeyboard(row)
+}
+
 func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
@@ -58,6 +58,7 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+	Voice     VoiceConfig     `json:"voice"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -78,7 +79,7 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 || c.Session.Store != "" || c.Session.CacheSize != 0 {
 		aux.Session = &c.Session
 	}
 
@@ -135,13 +136,32 @@ type AgentConfig struct {
 	Name      string            `json:"name,omitempty"`
 	Workspace string            `json:"workspace,omitempty"`
 	Model     *AgentModelConfig `json:"model,omitempty"`
-	Skills    []string          `json:"skills,omitempty"`
-	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	// Skills lists the skills this agent may see and install. Unset means
+	// all skills; an empty list means none; "*" allows all.
+	Skills    []string         `json:"skills,omitempty"`
+	Subagents *SubagentsConfig `json:"subagents,omitempty"`
+	Budget    *BudgetConfig    `json:"budget,omitempty"`
+	// MCPServers lists the tools.mcp servers this agent may use. Unset means
+	// all servers; an empty list means none; "*" allows all.
+	MCPServers []string `json:"mcp_servers,omitempty"`
+}
+
+// BudgetConfig caps an agent's spend per calendar day and month (local time).
+// Zero values mean no limit. Cost limits only apply to models with prices in
+// model_list; token limits count every model.
+type BudgetConfig struct {
+	DailyUSD      float64 `json:"daily_usd,omitempty"`
+	MonthlyUSD    float64 `json:"monthly_usd,omitempty"`
+	DailyTokens   int     `json:"daily_tokens,omitempty"`
+	MonthlyTokens int     `json:"monthly_tokens,omitempty"`
 }
 
 type SubagentsConfig struct {
 	AllowAgents []string          `json:"allow_agents,omitempty"`
 	Model       *AgentModelConfig `json:"model,omitempty"`
+	// MaxDepth bounds how many agents a delegated task may pass through
+	// below this agent. Zero uses the default of 3.
+	MaxDepth int `json:"max_depth,omitempty"`
 }
 
 type PeerMatch struct {
@@ -165,20 +185,30 @@ type AgentBinding struct {
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	// Store selects where sessions are kept: "json" (default) or "sqlite",
+	// which needs a build with -tags sqlite.
+	Store string `json:"store,omitempty"`
+	// CacheSize is how many sessions each agent keeps in memory; 0 means 64.
+	CacheSize int `json:"cache_size,omitempty"`
 }
 
 type AgentDefaults struct {
-	Workspace           string   `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
-	RestrictToWorkspace bool     `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
-	Provider            string   `json:"provider"                        env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
-	ModelName           string   `json:"model_name,omitempty"            env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_NAME"`
-	Model               string   `json:"model"                           env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"` // Deprecated: use model_name instead
-	ModelFallbacks      []string `json:"model_fallbacks,omitempty"`
-	ImageModel          string   `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
-	ImageModelFallbacks []string `json:"image_model_fallbacks,omitempty"`
-	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
-	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
-	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	Workspace             string        `json:"workspace"                         env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	RestrictToWorkspace   bool          `json:"restrict_to_workspace"             env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
+	Provider              string        `json:"provider"                          env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
+	ModelName             string        `json:"model_name,omitempty"              env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_NAME"`
+	Model                 string        `json:"model"                             env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"` // Deprecated: use model_name instead
+	ModelFallbacks        []string      `json:"model_fallbacks,omitempty"`
+	ImageModel            string        `json:"image_model,omitempty"             env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
+	ImageModelFallbacks   []string      `json:"image_model_fallbacks,omitempty"`
+	MaxTokens             int           `json:"max_tokens"                        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	Temperature           *float64      `json:"temperature,omitempty"             env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	MaxToolIterations     int           `json:"max_tool_iterations"               env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxConcurrentSessions int           `json:"max_concurrent_sessions,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_CONCURRENT_SESSIONS"` // Sessions processed in parallel, 0 means default
+	MaxParallelTools      int           `json:"max_parallel_tools,omitempty"      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOLS"`      // Concurrency-safe tool calls run in parallel per turn, 0 means default, 1 disables
+	Streaming             bool          `json:"streaming"                         env:"PICOCLAW_AGENTS_DEFAULTS_STREAMING"`               // Stream partial replies into the placeholder message
+	StreamingIntervalMS   int           `json:"streaming_interval_ms,omitempty"   env:"PICOCLAW_AGENTS_DEFAULTS_STREAMING_INTERVAL_MS"`   // Minimum time between partial edits
+	Budget                *BudgetConfig `json:"budget,omitempty"`
 }
 
 // GetModelName returns the effective model name for the agent defaults.
@@ -377,6 +407,19 @@ type HeartbeatConfig struct {
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
 }
 
+// VoiceConfig selects the speech-to-text backend used to transcribe inbound
+// voice messages. Backend is "groq", "openai" (any OpenAI-compatible
+// /audio/transcriptions endpoint, e.g. a local whisper server) or "none".
+// When empty, Groq is used if a Groq API key is configured.
+type VoiceConfig struct {
+	Backend        string `json:"backend"                   env:"PICOCLAW_VOICE_BACKEND"`
+	APIKey         string `json:"api_key,omitempty"         env:"PICOCLAW_VOICE_API_KEY"`
+	APIBase        string `json:"api_base,omitempty"        env:"PICOCLAW_VOICE_API_BASE"`
+	Model          string `json:"model,omitempty"           env:"PICOCLAW_VOICE_MODEL"`
+	Language       string `json:"language,omitempty"        env:"PICOCLAW_VOICE_LANGUAGE"`
+	RequestTimeout int    `json:"request_timeout,omitempty" env:"PICOCLAW_VOICE_REQUEST_TIMEOUT"` // seconds
+}
+
 type DevicesConfig struct {
 	Enabled    bool `json:"enabled"     env:"PICOCLAW_DEVICES_ENABLED"`
 	MonitorUSB bool `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
@@ -474,6 +517,24 @@ type ModelConfig struct {
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
+
+	// Pricing for usage accounting, in USD per million tokens
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+
+	// Capabilities overrides the built-in capabilities of the model.
+	Capabilities *ModelCapabilitiesConfig `json:"capabilities,omitempty"`
+}
+
+// ModelCapabilitiesConfig overrides what the built-in model catalog says a
+// model supports. Unset fields keep the catalog's values.
+type ModelCapabilitiesConfig struct {
+	ContextWindow   int   `json:"context_window,omitempty"`    // Input plus output tokens
+	MaxOutputTokens int   `json:"max_output_tokens,omitempty"` // Largest max_tokens the model accepts
+	Vision          *bool `json:"vision,omitempty"`
+	Tools           *bool `json:"tools,omitempty"`
+	Reasoning       *bool `json:"reasoning,omitempty"`
+	Streaming       *bool `json:"streaming,omitempty"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -488,8 +549,20 @@ func (c *ModelConfig) Validate() error {
 }
 
 type GatewayConfig struct {
-	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
-	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	Host      string          `json:"host"       env:"PICOCLAW_GATEWAY_HOST"`
+	Port      int             `json:"port"       env:"PICOCLAW_GATEWAY_PORT"`
+	OpenAIAPI OpenAIAPIConfig `json:"openai_api"`
+	// UsageToken is the bearer token required by GET /usage. When empty, the
+	// OpenAI API token is used; with neither, /usage is not served.
+	UsageToken string `json:"usage_token,omitempty" env:"PICOCLAW_GATEWAY_USAGE_TOKEN"`
+}
+
+// OpenAIAPIConfig enables the OpenAI-compatible /v1/chat/completions and
+// /v1/models endpoints on the gateway. Requests must carry Token as a bearer
+// token.
+type OpenAIAPIConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_GATEWAY_OPENAI_API_ENABLED"`
+	Token   string `json:"token"   env:"PICOCLAW_GATEWAY_OPENAI_API_TOKEN"`
 }
 
 type BraveConfig struct {
@@ -530,9 +603,34 @@ type CronToolsConfig struct {
 	ExecTimeoutMinutes int `json:"exec_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES"` // 0 means no timeout
 }
 
+// ExecConfig configures the exec tool and the process tool, which shares its
+// guards and sandbox. MaxProcesses caps the background processes the process
+// tool keeps per agent, and ProcessBufferKB the output kept for each.
 type ExecConfig struct {
-	EnableDenyPatterns bool     `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
-	CustomDenyPatterns []string `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	EnableDenyPatterns bool              `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
+	CustomDenyPatterns []string          `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	MaxProcesses       int               `json:"max_processes"        env:"PICOCLAW_TOOLS_EXEC_MAX_PROCESSES"`
+	ProcessBufferKB    int               `json:"process_buffer_kb"    env:"PICOCLAW_TOOLS_EXEC_PROCESS_BUFFER_KB"`
+	Sandbox            ExecSandboxConfig `json:"sandbox"`
+}
+
+// ExecSandboxConfig isolates the commands run by the exec tool. Backend is
+// "none" (run on the host), "namespace" (Linux namespaces, seccomp and
+// Landlock; no daemon needed) or "container" (a rootless podman or docker
+// container). Sandboxed commands see the workspace read-only unless
+// WritableWorkspace is set, and can write to ScratchDir (relative to the
+// workspace, default ".sandbox").
+type ExecSandboxConfig struct {
+	Backend           string  `json:"backend"            env:"PICOCLAW_TOOLS_EXEC_SANDBOX_BACKEND"`
+	Network           bool    `json:"network"            env:"PICOCLAW_TOOLS_EXEC_SANDBOX_NETWORK"`
+	WritableWorkspace bool    `json:"writable_workspace" env:"PICOCLAW_TOOLS_EXEC_SANDBOX_WRITABLE_WORKSPACE"`
+	ScratchDir        string  `json:"scratch_dir"        env:"PICOCLAW_TOOLS_EXEC_SANDBOX_SCRATCH_DIR"`
+	MemoryMB          int     `json:"memory_mb"          env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MEMORY_MB"`
+	CPUs              float64 `json:"cpus"               env:"PICOCLAW_TOOLS_EXEC_SANDBOX_CPUS"`
+	PIDs              int     `json:"pids"               env:"PICOCLAW_TOOLS_EXEC_SANDBOX_PIDS"`
+	CgroupParent      string  `json:"cgroup_parent"      env:"PICOCLAW_TOOLS_EXEC_SANDBOX_CGROUP_PARENT"`
+	Runtime           string  `json:"runtime"            env:"PICOCLAW_TOOLS_EXEC_SANDBOX_RUNTIME"`
+	Image             string  `json:"image"              env:"PICOCLAW_TOOLS_EXEC_SANDBOX_IMAGE"`
 }
 
 type MediaCleanupConfig struct {
@@ -547,6 +645,67 @@ type ToolsConfig struct {
 	Exec         ExecConfig         `json:"exec"`
 	Skills       SkillsToolsConfig  `json:"skills"`
 	MediaCleanup MediaCleanupConfig `json:"media_cleanup"`
+	MCP          MCPConfig          `json:"mcp"`
+	Egress       EgressConfig       `json:"egress"`
+	Approval     ApprovalConfig     `json:"approval"`
+}
+
+// ApprovalConfig decides which tool calls need a human's go-ahead. A policy
+// is "always" (run without asking), "ask" (pause the turn and ask in the
+// originating chat) or "never" (refuse). Rules are checked first, in order;
+// a rule matches when its tool matches ("*" for any) and its pattern, if set,
+// matches one of the call's string arguments. Tools then gives per-tool
+// policies and Default covers the rest.
+//
+// Approvers lists the users allowed to answer prompts, in the same format as
+// channel allow_from lists. When empty, the user who sent the message that
+// triggered the call may approve it from the same chat.
+type ApprovalConfig struct {
+	Default        string            `json:"default"         env:"PICOCLAW_TOOLS_APPROVAL_DEFAULT"`
+	Tools          map[string]string `json:"tools"`
+	Rules          []ApprovalRule    `json:"rules"`
+	Approvers      []string          `json:"approvers"       env:"PICOCLAW_TOOLS_APPROVAL_APPROVERS"`
+	TimeoutSeconds int               `json:"timeout_seconds" env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_SECONDS"`
+}
+
+// ApprovalRule sets Policy for calls of Tool whose string arguments match
+// the regular expression Pattern.
+type ApprovalRule struct {
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern,omitempty"`
+	Policy  string `json:"policy"`
+}
+
+// EgressConfig limits outbound HTTP requests made by tools, skill registries
+// and media downloads. Loopback, link-local, private and cloud metadata
+// addresses are blocked unless AllowPrivate is set or they are listed in
+// Allow (hostnames, "*.domain" wildcards, IPs or CIDR ranges).
+type EgressConfig struct {
+	AllowPrivate     bool     `json:"allow_private"      env:"PICOCLAW_TOOLS_EGRESS_ALLOW_PRIVATE"`
+	Allow            []string `json:"allow"              env:"PICOCLAW_TOOLS_EGRESS_ALLOW"`
+	MaxResponseBytes int64    `json:"max_response_bytes" env:"PICOCLAW_TOOLS_EGRESS_MAX_RESPONSE_BYTES"`
+	MaxDownloadBytes int64    `json:"max_download_bytes" env:"PICOCLAW_TOOLS_EGRESS_MAX_DOWNLOAD_BYTES"`
+}
+
+// MCPConfig lists the Model Context Protocol servers whose tools are exposed
+// to agents.
+type MCPConfig struct {
+	Servers []MCPServerConfig `json:"servers"`
+}
+
+// MCPServerConfig describes one MCP server. Servers with a Command are spawned
+// and spoken to over stdio; servers with a URL use the streamable HTTP
+// transport.
+type MCPServerConfig struct {
+	Name      string            `json:"name"`
+	Disabled  bool              `json:"disabled,omitempty"`
+	Transport string            `json:"transport,omitempty"` // "stdio" or "http"; inferred when empty
+	Command   string            `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Timeout   int               `json:"timeout,omitempty"` // seconds per request, default 60
 }
 
 type SkillsToolsConfig struct {
@@ -10,13 +10,17 @@ func DefaultConfig() *Config {
 	return &Config{
 		Agents: AgentsConfig{
 			Defaults: AgentDefaults{
-				Workspace:           "~/.picoclaw/workspace",
-				RestrictToWorkspace: true,
-				Provider:            "",
-				Model:               "",
-				MaxTokens:           32768,
-				Temperature:         nil, // nil means use provider default
-				MaxToolIterations:   50,
+				Workspace:             "~/.picoclaw/workspace",
+				RestrictToWorkspace:   true,
+				Provider:              "",
+				Model:                 "",
+				MaxTokens:             32768,
+				Temperature:           nil, // nil means use provider default
+				MaxToolIterations:     50,
+				MaxConcurrentSessions: 4,
+				MaxParallelTools:      4,
+				Streaming:             true,
+				StreamingIntervalMS:   1000,
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -320,6 +324,22 @@ func DefaultConfig() *Config {
 			},
 			Exec: ExecConfig{
 				EnableDenyPatterns: true,
+				MaxProcesses:       4,
+				ProcessBufferKB:    64,
+				Sandbox: ExecSandboxConfig{
+					Backend:  "none",
+					MemoryMB: 512,
+					CPUs:     1,
+					PIDs:     256,
+				},
+			},
+			Egress: EgressConfig{
+				MaxResponseBytes: 10 * 1024 * 1024,
+				MaxDownloadBytes: 50 * 1024 * 1024,
+			},
+			Approval: ApprovalConfig{
+				Default:        "always",
+				TimeoutSeconds: 600,
 			},
 			Skills: SkillsToolsConfig{
 				Registries: SkillsRegistriesConfig{
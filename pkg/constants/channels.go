@@ -7,6 +7,7 @@ var internalChannels = map[string]struct{}{
 	"cli":      {},
 	"system":   {},
 	"subagent": {},
+	"openai":   {}, // OpenAI-compatible HTTP API; replies go back in the HTTP response
 }
 
 // IsInternalChannel returns true if the channel is an internal channel.
@@ -18,10 +18,12 @@ type Service struct {
 	bus     *bus.MessageBus
 	state   *state.Manager
 	sources []events.EventSource
-	enabled bool
-	ctx     context.Context
-	cancel  context.CancelFunc
-	mu      sync.RWMutex
+	// listeners receive every device event, in addition to the notification.
+	listeners []func(*events.DeviceEvent)
+	enabled   bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.RWMutex
 }
 
 type Config struct {
@@ -50,6 +52,14 @@ func (s *Service) SetBus(msgBus *bus.MessageBus) {
 	s.bus = msgBus
 }
 
+// AddListener registers fn to be called with every device event, e.g. to
+// fire triggers. Must be called before Start.
+func (s *Service) AddListener(fn func(*events.DeviceEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
 func (s *Service) Start(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -102,6 +112,13 @@ func (s *Service) handleEvents(kind events.Kind, eventCh <-chan *events.DeviceEv
 			continue
 		}
 		s.sendNotification(ev)
+
+		s.mu.RLock()
+		listeners := s.listeners
+		s.mu.RUnlock()
+		for _, fn := range listeners {
+			fn(ev)
+		}
 	}
 }
 
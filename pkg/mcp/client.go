@@ -0,0 +1,294 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultTimeout    = 60 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// Client is a connection to one MCP server. When the server crashes or the
+// HTTP session is lost, the next call reconnects transparently, backing off
+// exponentially while the server keeps failing. It is safe for concurrent use.
+type Client struct {
+	cfg     config.MCPServerConfig
+	timeout time.Duration
+	dial    func() (transport, error)
+
+	mu          sync.Mutex
+	t           transport
+	tools       []ToolInfo
+	nextAttempt time.Time
+	delay       time.Duration
+	onConnect   func(*Client)
+}
+
+// NewClient creates a client for cfg. It does not connect.
+func NewClient(cfg config.MCPServerConfig) (*Client, error) {
+	timeout := defaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	c := &Client{cfg: cfg, timeout: timeout}
+
+	transportKind := strings.ToLower(cfg.Transport)
+	if transportKind == "" {
+		transportKind = "stdio"
+		if cfg.Command == "" && cfg.URL != "" {
+			transportKind = "http"
+		}
+	}
+	switch transportKind {
+	case "stdio":
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("mcp server %q: command is required for stdio", cfg.Name)
+		}
+		c.dial = func() (transport, error) { return startStdio(cfg) }
+	case "http", "streamable-http", "streamable_http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("mcp server %q: url is required for http", cfg.Name)
+		}
+		httpClient := &http.Client{Timeout: timeout}
+		c.dial = func() (transport, error) { return newHTTPTransport(cfg.URL, cfg.Headers, httpClient), nil }
+	default:
+		return nil, fmt.Errorf("mcp server %q: unknown transport %q", cfg.Name, cfg.Transport)
+	}
+	return c, nil
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string {
+	return c.cfg.Name
+}
+
+// Tools returns the tools listed by the server on the last connect.
+func (c *Client) Tools() []ToolInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ToolInfo(nil), c.tools...)
+}
+
+// Connected reports whether the client has a live connection.
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.alive()
+}
+
+// done returns a channel that is closed when the current connection ends.
+func (c *Client) done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.t == nil {
+		return closedChan
+	}
+	return c.t.done()
+}
+
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// alive must be called with c.mu held.
+func (c *Client) alive() bool {
+	if c.t == nil {
+		return false
+	}
+	select {
+	case <-c.t.done():
+		return false
+	default:
+		return true
+	}
+}
+
+// Connect performs the initialize handshake and lists the server's tools,
+// replacing any previous connection.
+func (c *Client) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	err := c.connectLocked(ctx)
+	cb := c.onConnect
+	c.mu.Unlock()
+
+	if err == nil && cb != nil {
+		cb(c)
+	}
+	return err
+}
+
+// connectLocked must be called with c.mu held.
+func (c *Client) connectLocked(ctx context.Context) error {
+	if c.t != nil {
+		c.t.close()
+		c.t = nil
+	}
+
+	t, err := c.dial()
+	if err != nil {
+		return c.failed(err)
+	}
+	tools, err := handshake(ctx, t, c.timeout)
+	if err != nil {
+		t.close()
+		return c.failed(err)
+	}
+
+	c.t = t
+	c.tools = tools
+	c.delay = 0
+	c.nextAttempt = time.Time{}
+	logger.InfoCF("mcp", "Connected to MCP server", map[string]any{"server": c.cfg.Name, "tools": len(tools)})
+	return nil
+}
+
+// failed schedules the next reconnect attempt. Must be called with c.mu held.
+func (c *Client) failed(err error) error {
+	if c.delay == 0 {
+		c.delay = minReconnectDelay
+	} else {
+		c.delay = min(c.delay*2, maxReconnectDelay)
+	}
+	c.nextAttempt = time.Now().Add(c.delay)
+	return fmt.Errorf("mcp server %q: %w", c.cfg.Name, err)
+}
+
+func handshake(ctx context.Context, t transport, timeout time.Duration) ([]ToolInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	raw, err := t.call(ctx, "initialize", map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "picoclaw", "version": "1.0"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	var init initializeResult
+	if err := json.Unmarshal(raw, &init); err != nil {
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if err := t.notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, fmt.Errorf("initialized: %w", err)
+	}
+
+	var tools []ToolInfo
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+		raw, err := t.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		var page listToolsResult
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// transport returns a live transport, reconnecting when the previous one has
+// died and the backoff delay has passed.
+func (c *Client) transport(ctx context.Context) (transport, error) {
+	c.mu.Lock()
+	if c.alive() {
+		t := c.t
+		c.mu.Unlock()
+		return t, nil
+	}
+	if wait := time.Until(c.nextAttempt); wait > 0 {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("mcp server %q is unavailable, retrying in %s", c.cfg.Name, wait.Round(time.Second))
+	}
+	logger.InfoCF("mcp", "Reconnecting to MCP server", map[string]any{"server": c.cfg.Name})
+	err := c.connectLocked(ctx)
+	t, cb := c.t, c.onConnect
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if cb != nil {
+		cb(c)
+	}
+	return t, nil
+}
+
+// CallTool invokes a tool on the server. A call that fails because the
+// connection was lost is retried once on a fresh connection.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	params := map[string]any{"name": name, "arguments": args}
+
+	var raw json.RawMessage
+	for attempt := 0; attempt < 2; attempt++ {
+		t, err := c.transport(ctx)
+		if err != nil {
+			return nil, err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		raw, err = t.call(callCtx, "tools/call", params)
+		cancel()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errTransportClosed) || attempt == 1 {
+			return nil, err
+		}
+		c.drop(t)
+	}
+
+	var result CallToolResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("tools/call: %w", err)
+	}
+	return &result, nil
+}
+
+// drop discards t if it is still the current transport so the next call
+// reconnects immediately.
+func (c *Client) drop(t transport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.t == t {
+		c.t.close()
+		c.t = nil
+	}
+}
+
+// Close disconnects from the server.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.t == nil {
+		return nil
+	}
+	err := c.t.close()
+	c.t = nil
+	return err
+}
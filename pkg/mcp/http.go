@@ -0,0 +1,228 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// httpTransport implements the streamable HTTP transport: every message is
+// POSTed to the server URL and the reply comes back either as a JSON body or
+// as a server-sent event stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	nextID  atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newHTTPTransport(url string, headers map[string]string, client *http.Client) *httpTransport {
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		client:  client,
+		closed:  make(chan struct{}),
+	}
+}
+
+func (t *httpTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	resp, err := t.post(ctx, &request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var m *message
+	if mediaType == "text/event-stream" {
+		m, err = t.readEvents(ctx, resp.Body, id)
+	} else {
+		m, err = readJSONResponse(resp.Body, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	return m.Result, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params any) error {
+	resp, err := t.post(ctx, &request{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
+// post sends one message and checks the status code. Network failures and a
+// lost session are reported as errTransportClosed so the client reconnects.
+func (t *httpTransport) post(ctx context.Context, v any) (*http.Response, error) {
+	select {
+	case <-t.closed:
+		return nil, errTransportClosed
+	default:
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v", errTransportClosed, err)
+	}
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		t.mu.Lock()
+		t.sessionID = sid
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && t.session() != "":
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: session expired", errTransportClosed)
+	case resp.StatusCode >= 500:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: HTTP %d", errTransportClosed, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("mcp: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if sid := t.session(); sid != "" {
+		req.Header.Set("Mcp-Session-Id", sid)
+	}
+}
+
+func (t *httpTransport) session() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+func readJSONResponse(r io.Reader, id int64) (*message, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errTransportClosed, err)
+	}
+	data = bytes.TrimSpace(data)
+	var batch []message
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, fmt.Errorf("mcp: invalid response: %w", err)
+		}
+	} else {
+		var m message
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("mcp: invalid response: %w", err)
+		}
+		batch = []message{m}
+	}
+	for i := range batch {
+		if matchID(&batch[i], id) {
+			return &batch[i], nil
+		}
+	}
+	return nil, fmt.Errorf("mcp: no response for request %d", id)
+}
+
+// readEvents reads an SSE stream until the response to request id arrives.
+// Server requests on the stream are answered with a separate POST.
+func (t *httpTransport) readEvents(ctx context.Context, r io.Reader, id int64) (*message, error) {
+	reader := bufio.NewReader(r)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			data.WriteByte('\n')
+		}
+		if (line == "" || err != nil) && data.Len() > 0 {
+			var m message
+			if json.Unmarshal([]byte(data.String()), &m) == nil {
+				if matchID(&m, id) {
+					return &m, nil
+				}
+				if !m.isResponse() && len(m.ID) > 0 {
+					if resp, err := t.post(ctx, replyTo(&m)); err == nil {
+						resp.Body.Close()
+					}
+				}
+			}
+			data.Reset()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: event stream ended before response", errTransportClosed)
+		}
+	}
+}
+
+func matchID(m *message, id int64) bool {
+	if !m.isResponse() {
+		return false
+	}
+	got, err := strconv.ParseInt(string(m.ID), 10, 64)
+	return err == nil && got == id
+}
+
+func (t *httpTransport) done() <-chan struct{} {
+	return t.closed
+}
+
+// close ends the session on the server, if it issued one.
+func (t *httpTransport) close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		sid := t.session()
+		if sid == "" {
+			return
+		}
+		req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+		if err != nil {
+			return
+		}
+		t.setHeaders(req)
+		if resp, err := t.client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Manager owns the clients of all configured MCP servers.
+type Manager struct {
+	clients []*Client
+
+	mu        sync.Mutex
+	listeners []func(*Client)
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewManager creates clients for every enabled server in cfg. Invalid server
+// entries are logged and skipped.
+func NewManager(cfg config.MCPConfig) *Manager {
+	m := &Manager{}
+	seen := make(map[string]bool)
+	for _, sc := range cfg.Servers {
+		if sc.Disabled {
+			continue
+		}
+		if sc.Name == "" || seen[sc.Name] {
+			logger.WarnCF("mcp", "Skipping MCP server with missing or duplicate name", map[string]any{"name": sc.Name})
+			continue
+		}
+		c, err := NewClient(sc)
+		if err != nil {
+			logger.WarnCF("mcp", "Skipping invalid MCP server", map[string]any{"error": err.Error()})
+			continue
+		}
+		seen[sc.Name] = true
+		c.onConnect = m.connected
+		m.clients = append(m.clients, c)
+	}
+	return m
+}
+
+// Clients returns all managed clients, connected or not.
+func (m *Manager) Clients() []*Client {
+	if m == nil {
+		return nil
+	}
+	return m.clients
+}
+
+// OnConnect registers fn to be called with a client every time it connects
+// or reconnects, and immediately for clients that are already connected.
+func (m *Manager) OnConnect(fn func(*Client)) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.listeners = append(m.listeners, fn)
+	m.mu.Unlock()
+
+	for _, c := range m.clients {
+		if c.Connected() {
+			fn(c)
+		}
+	}
+}
+
+func (m *Manager) connected(c *Client) {
+	m.mu.Lock()
+	listeners := append([]func(*Client){}, m.listeners...)
+	m.mu.Unlock()
+	for _, fn := range listeners {
+		fn(c)
+	}
+}
+
+// Start connects to all servers concurrently and returns once each has
+// either connected or failed its first attempt. Afterwards every server is
+// supervised in the background: failed or crashed servers are reconnected
+// with backoff until the manager is closed.
+func (m *Manager) Start(ctx context.Context) {
+	if m == nil || len(m.clients) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m.mu.Lock()
+	m.cancel = cancel
+	m.mu.Unlock()
+
+	var first sync.WaitGroup
+	for _, c := range m.clients {
+		first.Add(1)
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			if err := c.Connect(ctx); err != nil {
+				logger.WarnCF("mcp", "Failed to connect to MCP server", map[string]any{
+					"server": c.Name(),
+					"error":  err.Error(),
+				})
+			}
+			first.Done()
+			m.supervise(ctx, c)
+		}()
+	}
+	first.Wait()
+}
+
+// supervise keeps c connected until ctx is done.
+func (m *Manager) supervise(ctx context.Context, c *Client) {
+	for {
+		if !c.Connected() && !m.reconnect(ctx, c) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done():
+			logger.WarnCF("mcp", "MCP server disconnected", map[string]any{"server": c.Name()})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(minReconnectDelay):
+		}
+	}
+}
+
+// reconnect retries c until it connects or ctx is done, in which case it
+// returns false.
+func (m *Manager) reconnect(ctx context.Context, c *Client) bool {
+	for {
+		c.mu.Lock()
+		wait := time.Until(c.nextAttempt)
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+		if c.Connected() {
+			return true
+		}
+		if err := c.Connect(ctx); err == nil {
+			return true
+		}
+	}
+}
+
+// Close stops background reconnects and disconnects every server.
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+
+	for _, c := range m.clients {
+		c.Close()
+	}
+}
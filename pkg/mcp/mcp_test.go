@@ -0,0 +1,313 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// serve answers one JSON-RPC request the way a small MCP server would. The
+// server has two tools, split over two tools/list pages: "echo" returns its
+// text argument and "fail" reports a tool error.
+func serve(m *message) (result any, rpcErr *RPCError) {
+	switch m.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": protocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "fake", "version": "1"},
+		}, nil
+	case "tools/list":
+		var p struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(m.Params, &p)
+		if p.Cursor == "" {
+			return map[string]any{
+				"tools": []map[string]any{{
+					"name":        "echo",
+					"description": "Echo text",
+					"inputSchema": map[string]any{"type": "object"},
+				}},
+				"nextCursor": "page2",
+			}, nil
+		}
+		return map[string]any{"tools": []map[string]any{{"name": "fail"}}}, nil
+	case "tools/call":
+		var p struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		json.Unmarshal(m.Params, &p)
+		switch p.Name {
+		case "echo":
+			return map[string]any{"content": []map[string]any{{"type": "text", "text": p.Arguments["text"]}}}, nil
+		case "fail":
+			return map[string]any{"content": []map[string]any{{"type": "text", "text": "boom"}}, "isError": true}, nil
+		}
+		return nil, &RPCError{Code: -32602, Message: "unknown tool " + p.Name}
+	}
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found"}
+}
+
+func reply(m *message) []byte {
+	result, rpcErr := serve(m)
+	data, _ := json.Marshal(response{JSONRPC: "2.0", ID: m.ID, Result: result, Error: rpcErr})
+	return data
+}
+
+// TestHelperProcess is not a real test: it runs a fake stdio MCP server when
+// started by startStdio in the tests below. Calling the "crash" tool makes
+// the process exit.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("PICOCLAW_MCP_HELPER") != "1" {
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var m message
+		if json.Unmarshal(scanner.Bytes(), &m) != nil || len(m.ID) == 0 {
+			continue
+		}
+		if m.Method == "tools/call" && strings.Contains(string(m.Params), `"crash"`) {
+			os.Exit(3)
+		}
+		fmt.Fprintf(os.Stderr, "handling %s\n", m.Method)
+		os.Stdout.Write(append(reply(&m), '\n'))
+	}
+	os.Exit(0)
+}
+
+func helperServer() config.MCPServerConfig {
+	return config.MCPServerConfig{
+		Name:    "helper",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestHelperProcess"},
+		Env:     map[string]string{"PICOCLAW_MCP_HELPER": "1"},
+	}
+}
+
+func TestStdioClient_ConnectAndCall(t *testing.T) {
+	c, err := NewClient(helperServer())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	tools := c.Tools()
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" {
+		t.Fatalf("Tools() = %+v, want echo and fail from both pages", tools)
+	}
+
+	res, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "hi" {
+		t.Errorf("CallTool content = %+v, want hi", res.Content)
+	}
+
+	_, err = c.CallTool(context.Background(), "missing", nil)
+	if _, ok := err.(*RPCError); !ok {
+		t.Errorf("CallTool(missing) error = %v, want *RPCError", err)
+	}
+}
+
+func TestStdioClient_ReconnectsAfterCrash(t *testing.T) {
+	c, err := NewClient(helperServer())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	var connects atomic.Int32
+	c.onConnect = func(*Client) { connects.Add(1) }
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// The crash kills the process; the call fails on the fresh connection too.
+	if _, err := c.CallTool(context.Background(), "crash", nil); err == nil {
+		t.Fatal("expected crash call to fail")
+	}
+	<-c.done()
+
+	res, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "back"})
+	if err != nil {
+		t.Fatalf("CallTool after crash: %v", err)
+	}
+	if res.Content[0].Text != "back" {
+		t.Errorf("content = %q, want back", res.Content[0].Text)
+	}
+	if got := connects.Load(); got < 2 {
+		t.Errorf("connects = %d, want reconnect after crash", got)
+	}
+}
+
+// newHTTPServer serves MCP over streamable HTTP, answering tools/call with an
+// SSE stream and everything else with JSON.
+func newHTTPServer(t *testing.T, sessions *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var current atomic.Value
+	current.Store("")
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var m message
+		if err := json.Unmarshal(body, &m); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if m.Method == "initialize" {
+			id := fmt.Sprintf("s%d", sessions.Add(1))
+			current.Store(id)
+			w.Header().Set("Mcp-Session-Id", id)
+		} else if sid := r.Header.Get("Mcp-Session-Id"); sid != current.Load().(string) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+
+		if len(m.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if m.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", `{"jsonrpc":"2.0","method":"notifications/progress"}`)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", reply(&m))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply(&m))
+	}))
+}
+
+func TestHTTPClient_SessionAndSSE(t *testing.T) {
+	var sessions atomic.Int32
+	srv := newHTTPServer(t, &sessions)
+	defer srv.Close()
+
+	c, err := NewClient(config.MCPServerConfig{
+		Name:    "remote",
+		URL:     srv.URL,
+		Headers: map[string]string{"X-Token": "secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if len(c.Tools()) != 2 {
+		t.Fatalf("Tools() = %+v, want 2", c.Tools())
+	}
+
+	res, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "over sse"})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if res.Content[0].Text != "over sse" {
+		t.Errorf("content = %q, want %q", res.Content[0].Text, "over sse")
+	}
+
+	// Losing the session (server restart) triggers a transparent reconnect.
+	c.mu.Lock()
+	c.t.(*httpTransport).sessionID = "stale"
+	c.mu.Unlock()
+	if _, err := c.CallTool(context.Background(), "echo", map[string]any{"text": "again"}); err != nil {
+		t.Fatalf("CallTool after session loss: %v", err)
+	}
+	if got := sessions.Load(); got != 2 {
+		t.Errorf("sessions = %d, want a new session after reconnect", got)
+	}
+}
+
+func TestHTTPClient_AuthErrorIsNotRetried(t *testing.T) {
+	var sessions atomic.Int32
+	srv := newHTTPServer(t, &sessions)
+	defer srv.Close()
+
+	c, err := NewClient(config.MCPServerConfig{Name: "remote", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	err = c.Connect(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Connect error = %v, want HTTP 401", err)
+	}
+	if _, err := c.CallTool(context.Background(), "echo", nil); err == nil ||
+		!strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("CallTool during backoff = %v, want unavailable", err)
+	}
+}
+
+func TestNewClient_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MCPServerConfig
+	}{
+		{"stdio without command", config.MCPServerConfig{Name: "a", Transport: "stdio"}},
+		{"http without url", config.MCPServerConfig{Name: "b", Transport: "http"}},
+		{"unknown transport", config.MCPServerConfig{Name: "c", Transport: "ws", URL: "ws://x"}},
+		{"nothing set", config.MCPServerConfig{Name: "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestManager_OnConnect(t *testing.T) {
+	var sessions atomic.Int32
+	srv := newHTTPServer(t, &sessions)
+	defer srv.Close()
+
+	m := NewManager(config.MCPConfig{Servers: []config.MCPServerConfig{
+		{Name: "remote", URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}},
+		{Name: "off", URL: srv.URL, Disabled: true},
+		{Name: "remote", URL: srv.URL},
+	}})
+	defer m.Close()
+
+	if len(m.Clients()) != 1 {
+		t.Fatalf("Clients() = %d, want disabled and duplicate servers skipped", len(m.Clients()))
+	}
+
+	var got []string
+	m.OnConnect(func(c *Client) { got = append(got, c.Name()) })
+	m.Start(context.Background())
+	if len(got) != 1 || got[0] != "remote" {
+		t.Fatalf("OnConnect calls = %v, want [remote]", got)
+	}
+
+	var late []string
+	m.OnConnect(func(c *Client) { late = append(late, c.Name()) })
+	if len(late) != 1 {
+		t.Errorf("late OnConnect calls = %v, want immediate call for connected server", late)
+	}
+}
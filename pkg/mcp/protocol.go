@@ -0,0 +1,124 @@
+// Package mcp is a Model Context Protocol client. It connects to external
+// tool servers over stdio or streamable HTTP and exposes their tools to
+// agents as regular tools.Tool implementations.
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// protocolVersion is the MCP revision this client speaks.
+const protocolVersion = "2025-03-26"
+
+// JSON-RPC error codes used by the client.
+const (
+	codeMethodNotFound = -32601
+)
+
+// errTransportClosed is returned for calls on a transport whose connection or
+// process has gone away.
+var errTransportClosed = errors.New("mcp: transport closed")
+
+// request is an outgoing JSON-RPC request or notification (ID nil).
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// response is an outgoing reply to a server-initiated request.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// message is any incoming JSON-RPC message: a response to one of our
+// requests, or a request or notification from the server.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+}
+
+func (m *message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// RPCError is a JSON-RPC error returned by the server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// ToolInfo is a tool advertised by tools/list.
+type ToolInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	InputSchema map[string]any   `json:"inputSchema,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are the server's hints about a tool's behavior.
+type ToolAnnotations struct {
+	ReadOnlyHint bool `json:"readOnlyHint,omitempty"`
+}
+
+// Content is one item of a tools/call result.
+type Content struct {
+	Type     string    `json:"type"` // text, image, audio, resource
+	Text     string    `json:"text,omitempty"`
+	Data     string    `json:"data,omitempty"` // base64 for image and audio
+	MimeType string    `json:"mimeType,omitempty"`
+	Resource *Resource `json:"resource,omitempty"`
+}
+
+// Resource is an embedded resource in a tools/call result.
+type Resource struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// CallToolResult is the result of tools/call.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
+
+type listToolsResult struct {
+	Tools      []ToolInfo `json:"tools"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// replyTo answers a server-initiated request. Only ping is supported.
+func replyTo(m *message) *response {
+	if m.Method == "ping" {
+		return &response{JSONRPC: "2.0", ID: m.ID, Result: struct{}{}}
+	}
+	return &response{
+		JSONRPC: "2.0",
+		ID:      m.ID,
+		Error:   &RPCError{Code: codeMethodNotFound, Message: "method not found: " + m.Method},
+	}
+}
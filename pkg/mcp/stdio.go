@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// transport carries JSON-RPC messages to one server.
+type transport interface {
+	// call sends a request and waits for its result.
+	call(ctx context.Context, method string, params any) (json.RawMessage, error)
+	// notify sends a notification.
+	notify(ctx context.Context, method string, params any) error
+	// done is closed once the transport can no longer be used.
+	done() <-chan struct{}
+	close() error
+}
+
+// stdioTransport spawns the server and exchanges newline-delimited JSON-RPC
+// messages over its stdin and stdout. The server's stderr is logged.
+type stdioTransport struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	nextID atomic.Int64
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan *message
+	closed  chan struct{}
+	err     error
+}
+
+func startStdio(cfg config.MCPServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %q: %w", cfg.Command, err)
+	}
+
+	t := &stdioTransport{
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *message),
+		closed:  make(chan struct{}),
+	}
+	go t.logStderr(stderr)
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) logStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		logger.DebugCF("mcp", "Server stderr", map[string]any{"server": t.name, "line": scanner.Text()})
+	}
+}
+
+func (t *stdioTransport) readLoop(r io.Reader) {
+	reader := bufio.NewReader(r)
+	var readErr error
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	waitErr := t.cmd.Wait()
+	if waitErr == nil && readErr != io.EOF {
+		waitErr = readErr
+	}
+	logger.InfoCF("mcp", "Server process exited", map[string]any{"server": t.name, "error": fmt.Sprint(waitErr)})
+
+	t.mu.Lock()
+	t.err = errTransportClosed
+	for id, ch := range t.pending {
+		close(ch)
+		delete(t.pending, id)
+	}
+	t.mu.Unlock()
+	close(t.closed)
+}
+
+func (t *stdioTransport) dispatch(line []byte) {
+	var m message
+	if err := json.Unmarshal(line, &m); err != nil {
+		logger.DebugCF("mcp", "Ignoring non JSON-RPC output", map[string]any{"server": t.name})
+		return
+	}
+	switch {
+	case m.isResponse():
+		id, err := strconv.ParseInt(string(m.ID), 10, 64)
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[id]
+		delete(t.pending, id)
+		t.mu.Unlock()
+		if ok {
+			ch <- &m
+		}
+	case len(m.ID) > 0:
+		if err := t.write(replyTo(&m)); err != nil {
+			logger.WarnCF("mcp", "Failed to answer server request", map[string]any{
+				"server": t.name,
+				"method": m.Method,
+				"error":  err.Error(),
+			})
+		}
+	}
+	// Server notifications (logging, list_changed, progress) are ignored.
+}
+
+func (t *stdioTransport) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(data); err != nil {
+		return fmt.Errorf("%w: %v", errTransportClosed, err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	ch := make(chan *message, 1)
+
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	if err := t.write(&request{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case m, ok := <-ch:
+		if !ok {
+			return nil, errTransportClosed
+		}
+		if m.Error != nil {
+			return nil, m.Error
+		}
+		return m.Result, nil
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+		t.write(&request{
+			JSONRPC: "2.0",
+			Method:  "notifications/cancelled",
+			Params:  map[string]any{"requestId": id, "reason": ctx.Err().Error()},
+		})
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, method string, params any) error {
+	return t.write(&request{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (t *stdioTransport) done() <-chan struct{} {
+	return t.closed
+}
+
+// close closes the server's stdin and kills it if it has not exited within
+// a grace period.
+func (t *stdioTransport) close() error {
+	t.writeMu.Lock()
+	t.stdin.Close()
+	t.writeMu.Unlock()
+
+	select {
+	case <-t.closed:
+	case <-time.After(2 * time.Second):
+		if t.cmd.Process != nil {
+			t.cmd.Process.Kill()
+		}
+		<-t.closed
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// maxToolNameLen is the longest tool name accepted by the major LLM APIs.
+const maxToolNameLen = 64
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ToolName returns the namespaced name under which an MCP tool is exposed to
+// the model: "mcp_<server>_<tool>", restricted to [a-zA-Z0-9_-] and 64 chars.
+// Names that are too long are cut short and end in a hash of the full name,
+// so tools sharing a long prefix stay distinct.
+func ToolName(server, tool string) string {
+	name := "mcp_" + unsafeNameChars.ReplaceAllString(server, "_") + "_" + unsafeNameChars.ReplaceAllString(tool, "_")
+	if len(name) > maxToolNameLen {
+		return withHashSuffix(name, server+"\x00"+tool)
+	}
+	return name
+}
+
+// withHashSuffix shortens name to fit maxToolNameLen together with a short
+// hash of key.
+func withHashSuffix(name, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	if len(name) > maxToolNameLen-len(suffix) {
+		name = name[:maxToolNameLen-len(suffix)]
+	}
+	return name + suffix
+}
+
+// Tool exposes one MCP server tool as a tools.Tool.
+type Tool struct {
+	client *Client
+	info   ToolInfo
+	store  media.MediaStore
+	name   string
+}
+
+// NewTools wraps every tool currently listed by c. Images returned by the
+// tools are saved to store; with a nil store they are described in text only.
+// Tools whose names map to the same ToolName, e.g. "read.file" and
+// "read_file", or to a name for which taken reports true, e.g. one already
+// registered by another server, are told apart by a hash suffix. taken may be
+// nil.
+func NewTools(c *Client, store media.MediaStore, taken func(name string) bool) []tools.Tool {
+	infos := c.Tools()
+	result := make([]tools.Tool, 0, len(infos))
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		name := ToolName(c.Name(), info.Name)
+		if seen[name] || (taken != nil && taken(name)) {
+			name = withHashSuffix(name, c.Name()+"\x00"+info.Name)
+			logger.WarnCF("mcp", "MCP tool name collides with another tool, renamed", map[string]any{
+				"server": c.Name(),
+				"tool":   info.Name,
+				"name":   name,
+			})
+		}
+		seen[name] = true
+		result = append(result, &Tool{client: c, info: info, store: store, name: name})
+	}
+	return result
+}
+
+func (t *Tool) Name() string {
+	if t.name != "" {
+		return t.name
+	}
+	return ToolName(t.client.Name(), t.info.Name)
+}
+
+// Server returns the name of the MCP server providing the tool.
+func (t *Tool) Server() string {
+	return t.client.Name()
+}
+
+// ConcurrencySafe reports the server's readOnlyHint annotation: read-only
+// tools may run in parallel with other calls.
+func (t *Tool) ConcurrencySafe() bool {
+	return t.info.Annotations != nil && t.info.Annotations.ReadOnlyHint
+}
+
+func (t *Tool) Description() string {
+	desc := t.info.Description
+	if desc == "" {
+		desc = t.info.Name
+	}
+	return fmt.Sprintf("[MCP server %s] %s", t.client.Name(), desc)
+}
+
+func (t *Tool) Parameters() map[string]any {
+	if len(t.info.InputSchema) == 0 {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return t.info.InputSchema
+}
+
+func (t *Tool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	res, err := t.client.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("MCP tool %s failed: %v", t.info.Name, err)).WithError(err)
+	}
+	return t.toResult(res)
+}
+
+// toResult maps MCP content onto a ToolResult: text (and text resources) goes
+// to the LLM, images become media refs.
+func (t *Tool) toResult(res *CallToolResult) *tools.ToolResult {
+	var (
+		parts []string
+		refs  []string
+	)
+	for _, c := range res.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image":
+			ref, err := t.storeImage(c)
+			if err != nil {
+				logger.WarnCF("mcp", "Failed to store MCP image", map[string]any{
+					"tool":  t.Name(),
+					"error": err.Error(),
+				})
+				parts = append(parts, fmt.Sprintf("[image (%s) could not be delivered: %v]", c.MimeType, err))
+				continue
+			}
+			refs = append(refs, ref)
+			parts = append(parts, fmt.Sprintf("[image (%s) sent to the user]", c.MimeType))
+		case "resource":
+			if c.Resource == nil {
+				continue
+			}
+			if c.Resource.Text != "" {
+				parts = append(parts, fmt.Sprintf("[resource %s]\n%s", c.Resource.URI, c.Resource.Text))
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource %s (%s)]", c.Resource.URI, c.Resource.MimeType))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[unsupported %s content]", c.Type))
+		}
+	}
+
+	text := strings.Join(parts, "\n")
+	if res.IsError {
+		if text == "" {
+			text = "MCP tool reported an error"
+		}
+		return tools.ErrorResult(text)
+	}
+	if text == "" {
+		text = "(no output)"
+	}
+	if len(refs) > 0 {
+		return tools.MediaResult(text, refs)
+	}
+	return tools.SilentResult(text)
+}
+
+// storeImage decodes base64 image content to a temp file and registers it
+// in the media store.
+func (t *Tool) storeImage(c Content) (string, error) {
+	if t.store == nil {
+		return "", fmt.Errorf("no media store available")
+	}
+	data, err := base64.StdEncoding.DecodeString(c.Data)
+	if err != nil {
+		return "", fmt.Errorf("invalid image data: %w", err)
+	}
+
+	ext := ".bin"
+	if exts, _ := mime.ExtensionsByType(c.MimeType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	dir := filepath.Join(os.TempDir(), "picoclaw_media")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "mcp-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return t.store.Store(f.Name(), media.MediaMeta{
+		Filename:    filepath.Base(f.Name()),
+		ContentType: c.MimeType,
+		Source:      "tool:" + t.Name(),
+	}, "mcp:"+t.client.Name())
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+func TestToolName(t *testing.T) {
+	tests := []struct {
+		server, tool, want string
+	}{
+		{"github", "create_issue", "mcp_github_create_issue"},
+		{"my server", "read.file", "mcp_my_server_read_file"},
+	}
+	for _, tt := range tests {
+		if got := ToolName(tt.server, tt.tool); got != tt.want {
+			t.Errorf("ToolName(%q, %q) = %q, want %q", tt.server, tt.tool, got, tt.want)
+		}
+	}
+
+	long1 := ToolName("fs", strings.Repeat("x", 80)+"_a")
+	long2 := ToolName("fs", strings.Repeat("x", 80)+"_b")
+	if len(long1) != maxToolNameLen || len(long2) != maxToolNameLen {
+		t.Errorf("expected long names to be cut to %d chars, got %q and %q", maxToolNameLen, long1, long2)
+	}
+	if long1 == long2 || !strings.HasPrefix(long1, "mcp_fs_xxx") {
+		t.Errorf("expected distinct hashed names, got %q and %q", long1, long2)
+	}
+}
+
+func TestNewTools_DisambiguatesCollisions(t *testing.T) {
+	c, err := NewClient(config.MCPServerConfig{Name: "srv", URL: "http://127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.tools = []ToolInfo{{Name: "read.file"}, {Name: "read_file"}}
+
+	wrapped := NewTools(c, nil, nil)
+	if len(wrapped) != 2 {
+		t.Fatalf("expected 2 tools, got %d", len(wrapped))
+	}
+	if wrapped[0].Name() != "mcp_srv_read_file" {
+		t.Errorf("first tool = %q, want mcp_srv_read_file", wrapped[0].Name())
+	}
+	if wrapped[1].Name() == wrapped[0].Name() || !strings.HasPrefix(wrapped[1].Name(), "mcp_srv_read_file_") {
+		t.Errorf("expected the colliding tool to get a hash suffix, got %q", wrapped[1].Name())
+	}
+}
+
+func TestNewTools_AvoidsNamesTakenByOtherServers(t *testing.T) {
+	c, err := NewClient(config.MCPServerConfig{Name: "srv", URL: "http://127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.tools = []ToolInfo{{Name: "read_file"}, {Name: "write_file"}}
+
+	wrapped := NewTools(c, nil, func(name string) bool { return name == "mcp_srv_read_file" })
+	if !strings.HasPrefix(wrapped[0].Name(), "mcp_srv_read_file_") {
+		t.Errorf("expected a hash suffix on the taken name, got %q", wrapped[0].Name())
+	}
+	if wrapped[1].Name() != "mcp_srv_write_file" {
+		t.Errorf("second tool = %q, want mcp_srv_write_file", wrapped[1].Name())
+	}
+}
+
+func newTestTool(t *testing.T, store media.MediaStore) *Tool {
+	t.Helper()
+	c, err := NewClient(config.MCPServerConfig{Name: "srv", URL: "http://127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return &Tool{client: c, info: ToolInfo{Name: "shot", Description: "Take a screenshot"}, store: store}
+}
+
+func TestTool_Metadata(t *testing.T) {
+	tool := newTestTool(t, nil)
+	if tool.Name() != "mcp_srv_shot" {
+		t.Errorf("Name() = %q", tool.Name())
+	}
+	if !strings.Contains(tool.Description(), "Take a screenshot") {
+		t.Errorf("Description() = %q", tool.Description())
+	}
+	if tool.Parameters()["type"] != "object" {
+		t.Errorf("Parameters() = %v, want default object schema", tool.Parameters())
+	}
+	if tool.ConcurrencySafe() {
+		t.Error("tools without readOnlyHint must not run in parallel")
+	}
+	tool.info.Annotations = &ToolAnnotations{ReadOnlyHint: true}
+	if !tool.ConcurrencySafe() {
+		t.Error("read-only tools should be concurrency safe")
+	}
+}
+
+func TestTool_ToResult(t *testing.T) {
+	store := media.NewFileMediaStore()
+	tool := newTestTool(t, store)
+
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG fake"))
+	res := tool.toResult(&CallToolResult{Content: []Content{
+		{Type: "text", Text: "here you go"},
+		{Type: "image", Data: png, MimeType: "image/png"},
+		{Type: "resource", Resource: &Resource{URI: "file:///a.txt", Text: "contents"}},
+	}})
+	if res.IsError {
+		t.Fatalf("unexpected error result: %s", res.ForLLM)
+	}
+	if len(res.Media) != 1 {
+		t.Fatalf("Media = %v, want one ref", res.Media)
+	}
+	path, meta, err := store.ResolveWithMeta(res.Media[0])
+	if err != nil {
+		t.Fatalf("ResolveWithMeta: %v", err)
+	}
+	defer store.ReleaseAll("mcp:srv")
+	data, _ := os.ReadFile(path)
+	if string(data) != "\x89PNG fake" || meta.ContentType != "image/png" {
+		t.Errorf("stored image = %q (%s)", data, meta.ContentType)
+	}
+	for _, want := range []string{"here you go", "sent to the user", "file:///a.txt", "contents"} {
+		if !strings.Contains(res.ForLLM, want) {
+			t.Errorf("ForLLM = %q, missing %q", res.ForLLM, want)
+		}
+	}
+
+	errRes := tool.toResult(&CallToolResult{IsError: true, Content: []Content{{Type: "text", Text: "denied"}}})
+	if !errRes.IsError || errRes.ForLLM != "denied" {
+		t.Errorf("error result = %+v", errRes)
+	}
+}
+
+func TestTool_ImageWithoutStore(t *testing.T) {
+	tool := newTestTool(t, nil)
+	res := tool.toResult(&CallToolResult{Content: []Content{{Type: "image", Data: "AAAA", MimeType: "image/png"}}})
+	if len(res.Media) != 0 || !strings.Contains(res.ForLLM, "could not be delivered") {
+		t.Errorf("result = %+v, want text-only fallback", res)
+	}
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: k1 controls term frequency saturation, b how strongly
+// scores are normalized by record length.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// index is an in-memory BM25 inverted index over the records of a store.
+type index struct {
+	docs     map[string]map[string]int // record ID -> term frequencies
+	lengths  map[string]int            // record ID -> number of terms
+	df       map[string]int            // term -> number of records containing it
+	totalLen int
+}
+
+func newIndex() *index {
+	return &index{
+		docs:    make(map[string]map[string]int),
+		lengths: make(map[string]int),
+		df:      make(map[string]int),
+	}
+}
+
+// add indexes text under id, replacing what was indexed under id before.
+func (ix *index) add(id, text string) {
+	ix.remove(id)
+	terms := tokenize(text)
+	tf := make(map[string]int, len(terms))
+	for _, term := range terms {
+		tf[term]++
+	}
+	for term := range tf {
+		ix.df[term]++
+	}
+	ix.docs[id] = tf
+	ix.lengths[id] = len(terms)
+	ix.totalLen += len(terms)
+}
+
+func (ix *index) remove(id string) {
+	tf, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for term := range tf {
+		if ix.df[term]--; ix.df[term] <= 0 {
+			delete(ix.df, term)
+		}
+	}
+	ix.totalLen -= ix.lengths[id]
+	delete(ix.docs, id)
+	delete(ix.lengths, id)
+}
+
+// score returns the BM25 score of every record matching at least one term of
+// query.
+func (ix *index) score(query string) map[string]float64 {
+	scores := make(map[string]float64)
+	n := float64(len(ix.docs))
+	if n == 0 {
+		return scores
+	}
+	avgLen := float64(ix.totalLen) / n
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		df := float64(ix.df[term])
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range ix.docs {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(ix.lengths[id])/avgLen
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+	return scores
+}
+
+// stopWords are common English words left out of the index.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "do": true, "for": true, "from": true,
+	"has": true, "have": true, "i": true, "in": true, "is": true, "it": true,
+	"me": true, "my": true, "of": true, "on": true, "or": true, "so": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "we": true,
+	"what": true, "with": true, "you": true, "your": true,
+}
+
+// tokenize splits text into lowercase search terms. Runs of letters and
+// digits form words; Chinese, Japanese and Korean characters, which are not
+// separated by spaces, are each a term of their own.
+func tokenize(text string) []string {
+	var terms []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		if term := normalizeTerm(word.String()); term != "" {
+			terms = append(terms, term)
+		}
+		word.Reset()
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// normalizeTerm drops stop words and reduces simple English plurals, so
+// that "likes" matches "like".
+func normalizeTerm(word string) string {
+	if stopWords[word] {
+		return ""
+	}
+	if len(word) > 3 && strings.HasSuffix(word, "s") &&
+		!strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is") {
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,238 @@
+package memory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// longTermFile is the single memory file of earlier versions.
+	longTermFile = "MEMORY.md"
+
+	// Tags of records imported from memory files.
+	tagLongTerm  = "long-term"
+	tagDailyNote = "daily-note"
+)
+
+var (
+	reMonthDir  = regexp.MustCompile(`^\d{6}$`)
+	reDailyNote = regexp.MustCompile(`^(\d{8})\.md$`)
+	reListItem  = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+`)
+	reHeading   = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+)
+
+// memoryFile is a markdown memory file found in the memory directory.
+type memoryFile struct {
+	source string // path relative to the memory directory, with forward slashes
+	path   string
+	tag    string
+	date   time.Time // creation time given to its records
+	mtime  time.Time
+}
+
+// syncFilesLocked imports MEMORY.md and the daily notes (YYYYMM/YYYYMMDD.md)
+// in the memory directory, one record per paragraph or list item. A file is
+// imported again when its mtime changes, replacing the records imported from
+// it before, and the records of deleted files are dropped. The files
+// themselves are left in place, so editing them keeps working. Imported
+// records are identified by their file and content (see importedID), so a
+// paragraph that survives an edit keeps its ID and the tags merged into it by
+// Save, and one that was forgotten is not imported again. Must be called with
+// s.mu held.
+func (s *Store) syncFilesLocked() {
+	files := s.memoryFiles()
+	present := make(map[string]bool, len(files))
+	changed := false
+
+	for _, f := range files {
+		present[f.source] = true
+		if prev, ok := s.imported[f.source]; ok && prev.Equal(f.mtime) {
+			continue
+		}
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			logger.WarnCF("memory", "Failed to read memory file", map[string]any{
+				"file":  f.path,
+				"error": err.Error(),
+			})
+			continue
+		}
+		previous := s.dropSourceLocked(f.source)
+		forgotten := make(map[string]bool)
+		for id, source := range s.forgotten {
+			if source == f.source {
+				forgotten[id] = true
+				delete(s.forgotten, id)
+			}
+		}
+		for _, c := range splitMarkdown(string(data)) {
+			id := importedID(f.source, c.text)
+			if forgotten[id] {
+				s.forgotten[id] = f.source
+				continue
+			}
+			if _, ok := s.records[id]; ok {
+				continue
+			}
+			r := &Record{
+				ID:      id,
+				Content: c.text,
+				Tags:    normalizeTags([]string{f.tag, c.heading}),
+				Source:  f.source,
+				Created: f.date,
+				Updated: f.mtime,
+			}
+			if prev, ok := previous[id]; ok {
+				r.Tags = normalizeTags(append(r.Tags, prev.Tags...))
+				if prev.Updated.After(r.Updated) {
+					r.Updated = prev.Updated
+				}
+			}
+			s.addLocked(r)
+		}
+		s.imported[f.source] = f.mtime
+		changed = true
+		logger.InfoCF("memory", "Imported memory file", map[string]any{"file": f.source})
+	}
+
+	for source := range s.imported {
+		if !present[source] {
+			s.dropSourceLocked(source)
+			delete(s.imported, source)
+			for id, src := range s.forgotten {
+				if src == source {
+					delete(s.forgotten, id)
+				}
+			}
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := s.saveLocked(); err != nil {
+			logger.WarnCF("memory", "Failed to save imported memory", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// dropSourceLocked removes the records imported from source and returns them
+// by ID.
+func (s *Store) dropSourceLocked(source string) map[string]*Record {
+	dropped := make(map[string]*Record)
+	for id, r := range s.records {
+		if r.Source == source {
+			dropped[id] = r
+			s.removeLocked(id)
+		}
+	}
+	return dropped
+}
+
+// importedID derives the ID of a record imported from a memory file from the
+// file and the record's content, so it stays the same across imports. The "f"
+// keeps it apart from the numbered IDs of saved records.
+func importedID(source, content string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + content))
+	return "mem-f" + hex.EncodeToString(sum[:4])
+}
+
+// memoryFiles lists MEMORY.md and the daily note files in the memory
+// directory.
+func (s *Store) memoryFiles() []memoryFile {
+	var files []memoryFile
+	longTerm := filepath.Join(s.dir, longTermFile)
+	if info, err := os.Stat(longTerm); err == nil && !info.IsDir() {
+		files = append(files, memoryFile{
+			source: longTermFile,
+			path:   longTerm,
+			tag:    tagLongTerm,
+			date:   info.ModTime(),
+			mtime:  info.ModTime(),
+		})
+	}
+
+	months, _ := os.ReadDir(s.dir)
+	for _, month := range months {
+		if !month.IsDir() || !reMonthDir.MatchString(month.Name()) {
+			continue
+		}
+		days, _ := os.ReadDir(filepath.Join(s.dir, month.Name()))
+		for _, day := range days {
+			m := reDailyNote.FindStringSubmatch(day.Name())
+			if day.IsDir() || m == nil {
+				continue
+			}
+			date, err := time.ParseInLocation("20060102", m[1], time.Local)
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(s.dir, month.Name(), day.Name())
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			files = append(files, memoryFile{
+				source: month.Name() + "/" + day.Name(),
+				path:   path,
+				tag:    tagDailyNote,
+				date:   date,
+				mtime:  info.ModTime(),
+			})
+		}
+	}
+	return files
+}
+
+// chunk is a paragraph or list item of a markdown file and the heading it
+// appears under.
+type chunk struct {
+	heading string
+	text    string
+}
+
+// splitMarkdown splits a markdown memory file into paragraphs and list items.
+// Headings are not chunks themselves; each chunk carries the nearest heading
+// above it, lowercased with spaces replaced by hyphens, for use as a tag.
+func splitMarkdown(text string) []chunk {
+	var chunks []chunk
+	var heading string
+	var current []string
+	flush := func() {
+		if t := strings.TrimSpace(strings.Join(current, "\n")); t != "" {
+			chunks = append(chunks, chunk{heading: heading, text: t})
+		}
+		current = nil
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case reHeading.MatchString(trimmed):
+			flush()
+			heading = headingTag(reHeading.FindStringSubmatch(trimmed)[1])
+		case reListItem.MatchString(trimmed) && line == strings.TrimLeft(line, " \t"):
+			// A top-level list item starts a new chunk; nested items and
+			// continuation lines stay with their parent.
+			flush()
+			current = append(current, reListItem.ReplaceAllString(trimmed, ""))
+		default:
+			current = append(current, trimmed)
+		}
+	}
+	flush()
+	return chunks
+}
+
+func headingTag(heading string) string {
+	return strings.Join(strings.Fields(strings.ToLower(heading)), "-")
+}
@@ -0,0 +1,352 @@
+// Package memory is the agent's long-term memory: short records with tags,
+// the session they were saved from and timestamps, kept in the workspace and
+// retrieved with BM25 keyword search.
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// ErrNotFound is returned for a record ID the store does not hold.
+var ErrNotFound = errors.New("memory record not found")
+
+// Record is one remembered fact, preference or note.
+type Record struct {
+	ID      string    `json:"id"`
+	Content string    `json:"content"`
+	Tags    []string  `json:"tags,omitempty"`
+	Session string    `json:"session,omitempty"` // session the record was saved from
+	Source  string    `json:"source,omitempty"`  // memory file the record was imported from
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// HasTag reports whether the record carries tag.
+func (r *Record) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the record on one line for display, e.g.
+// "[mem-3] Likes dark mode (tags: preferences; 2026-03-14)".
+func (r *Record) String() string {
+	content := strings.Join(strings.Fields(r.Content), " ")
+	meta := r.Updated.Format("2006-01-02")
+	if len(r.Tags) > 0 {
+		meta = "tags: " + strings.Join(r.Tags, ", ") + "; " + meta
+	}
+	return fmt.Sprintf("[%s] %s (%s)", r.ID, content, meta)
+}
+
+// Result is a record matching a search, with its relevance score.
+type Result struct {
+	Record
+	Score float64
+}
+
+// SearchOptions narrow a search.
+type SearchOptions struct {
+	Tags  []string // only records carrying all of these tags
+	Limit int      // maximum number of results; 0 means DefaultSearchLimit
+}
+
+// DefaultSearchLimit is the number of results a search returns by default.
+const DefaultSearchLimit = 5
+
+// Store holds the memory records of one workspace in memory/records.json.
+// It is loaded on first use. MEMORY.md and the daily notes
+// (memory/YYYYMM/YYYYMMDD.md) of earlier versions are imported as records,
+// see syncFilesLocked.
+type Store struct {
+	dir  string
+	file string
+
+	mu        sync.Mutex
+	loaded    bool
+	records   map[string]*Record
+	imported  map[string]time.Time // source file -> mtime when imported
+	forgotten map[string]string    // ID of a forgotten imported record -> its source file
+	index     *index
+	nextID    int
+}
+
+// storeFile is the on-disk format of a store.
+type storeFile struct {
+	Records   []*Record            `json:"records"`
+	Imported  map[string]time.Time `json:"imported,omitempty"`
+	Forgotten map[string]string    `json:"forgotten,omitempty"`
+}
+
+// NewStore creates a store for the memory directory dir (workspace/memory).
+func NewStore(dir string) *Store {
+	return &Store{
+		dir:  dir,
+		file: filepath.Join(dir, "records.json"),
+	}
+}
+
+// Save adds a record. Saving content the store already holds updates that
+// record instead, merging the tags.
+func (s *Store) Save(content string, tags []string, session string) (Record, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Record{}, errors.New("memory content is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return Record{}, err
+	}
+
+	now := time.Now()
+	for _, r := range s.records {
+		if strings.EqualFold(r.Content, content) {
+			r.Tags = normalizeTags(append(r.Tags, tags...))
+			r.Updated = now
+			s.index.add(r.ID, indexText(r))
+			return *r, s.saveLocked()
+		}
+	}
+
+	r := &Record{
+		ID:      s.newIDLocked(),
+		Content: content,
+		Tags:    normalizeTags(tags),
+		Session: session,
+		Created: now,
+		Updated: now,
+	}
+	s.addLocked(r)
+	return *r, s.saveLocked()
+}
+
+// Forget removes the record with the given ID and returns it. A record
+// imported from a memory file stays forgotten when the file is imported again,
+// as long as the file still holds the same paragraph.
+func (s *Store) Forget(id string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return Record{}, err
+	}
+	r, ok := s.records[id]
+	if !ok {
+		return Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	s.removeLocked(id)
+	if r.Source != "" {
+		s.forgotten[id] = r.Source
+	}
+	return *r, s.saveLocked()
+}
+
+// Get returns the record with the given ID.
+func (s *Store) Get(id string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return Record{}, false
+	}
+	r, ok := s.records[id]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+// Len returns the number of records.
+func (s *Store) Len() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return 0, err
+	}
+	return len(s.records), nil
+}
+
+// Search returns the records most relevant to query, best first. Ties are
+// broken in favor of more recently updated records. With an empty query, the
+// most recently updated records carrying opts.Tags are returned.
+func (s *Store) Search(query string, opts SearchOptions) ([]Result, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	tags := normalizeTags(opts.Tags)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	s.syncFilesLocked()
+
+	var results []Result
+	if strings.TrimSpace(query) == "" {
+		if len(tags) == 0 {
+			return nil, nil
+		}
+		for _, r := range s.records {
+			results = append(results, Result{Record: *r})
+		}
+	} else {
+		for id, score := range s.index.score(query) {
+			results = append(results, Result{Record: *s.records[id], Score: score})
+		}
+	}
+
+	filtered := results[:0]
+	for _, res := range results {
+		if hasAllTags(&res.Record, tags) {
+			filtered = append(filtered, res)
+		}
+	}
+	results = filtered
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Updated.Equal(b.Updated) {
+			return a.Updated.After(b.Updated)
+		}
+		return a.ID < b.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// loadLocked reads the records file on first use and imports the memory
+// files. A records file that can't be read is reported on every call rather
+// than overwritten. Must be called with s.mu held.
+func (s *Store) loadLocked() error {
+	if s.loaded {
+		return nil
+	}
+
+	var stored storeFile
+	data, err := os.ReadFile(s.file)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", s.file, err)
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to read memory records: %w", err)
+	}
+
+	s.records = make(map[string]*Record, len(stored.Records))
+	s.imported = stored.Imported
+	if s.imported == nil {
+		s.imported = make(map[string]time.Time)
+	}
+	s.forgotten = stored.Forgotten
+	if s.forgotten == nil {
+		s.forgotten = make(map[string]string)
+	}
+	s.index = newIndex()
+	s.nextID = 1
+	for _, r := range stored.Records {
+		s.addLocked(r)
+		if n, err := strconv.Atoi(strings.TrimPrefix(r.ID, "mem-")); err == nil && n >= s.nextID {
+			s.nextID = n + 1
+		}
+	}
+	s.loaded = true
+
+	s.syncFilesLocked()
+	return nil
+}
+
+// saveLocked writes the records file. Must be called with s.mu held.
+func (s *Store) saveLocked() error {
+	stored := storeFile{
+		Records:   make([]*Record, 0, len(s.records)),
+		Imported:  s.imported,
+		Forgotten: s.forgotten,
+	}
+	for _, r := range s.records {
+		stored.Records = append(stored.Records, r)
+	}
+	sort.Slice(stored.Records, func(i, j int) bool {
+		a, b := stored.Records[i], stored.Records[j]
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	})
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(s.file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save memory records: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) newIDLocked() string {
+	id := fmt.Sprintf("mem-%d", s.nextID)
+	s.nextID++
+	return id
+}
+
+func (s *Store) addLocked(r *Record) {
+	s.records[r.ID] = r
+	s.index.add(r.ID, indexText(r))
+}
+
+func (s *Store) removeLocked(id string) {
+	delete(s.records, id)
+	s.index.remove(id)
+}
+
+// indexText is the text a record is found by: its content and tags.
+func indexText(r *Record) string {
+	return r.Content + " " + strings.Join(r.Tags, " ")
+}
+
+// normalizeTags lowercases, trims, deduplicates and sorts tags.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func hasAllTags(r *Record, tags []string) bool {
+	for _, tag := range tags {
+		if !r.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
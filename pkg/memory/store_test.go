@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveSearchForget(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	goRec, err := s.Save("User prefers Go for backend services", []string{"Preferences", "code"}, "telegram:1")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if goRec.ID != "mem-1" || goRec.Session != "telegram:1" || goRec.Created.IsZero() {
+		t.Errorf("unexpected record %+v", goRec)
+	}
+	if len(goRec.Tags) != 2 || goRec.Tags[0] != "code" || goRec.Tags[1] != "preferences" {
+		t.Errorf("expected normalized tags, got %v", goRec.Tags)
+	}
+	s.Save("The user's cat is called Miso", []string{"pets"}, "telegram:1")
+	s.Save("Deploys go out on Fridays after the backend review", nil, "cli:direct")
+
+	results, err := s.Search("which backend language does the user prefer?", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != "mem-1" || results[0].Score <= results[1].Score {
+		t.Fatalf("expected the Go preference ranked first, got %+v", results)
+	}
+
+	if results, _ := s.Search("backend", SearchOptions{Tags: []string{"preferences"}}); len(results) != 1 {
+		t.Errorf("expected the tag filter to keep one record, got %+v", results)
+	}
+	if results, _ := s.Search("", SearchOptions{Tags: []string{"pets"}}); len(results) != 1 || results[0].ID != "mem-2" {
+		t.Errorf("expected an empty query to list records by tag, got %+v", results)
+	}
+
+	// Saving the same content again updates the record.
+	again, _ := s.Save("user prefers go for backend services", []string{"language"}, "")
+	if again.ID != "mem-1" || len(again.Tags) != 3 {
+		t.Errorf("expected the existing record with merged tags, got %+v", again)
+	}
+
+	if _, err := s.Forget("mem-2"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if _, err := s.Forget("mem-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// A new store over the same directory sees the saved records.
+	reopened := NewStore(dir)
+	if n, _ := reopened.Len(); n != 2 {
+		t.Fatalf("expected 2 persisted records, got %d", n)
+	}
+	if _, ok := reopened.Get("mem-2"); ok {
+		t.Error("expected the forgotten record to stay forgotten")
+	}
+	if rec, _ := reopened.Save("New fact", nil, ""); rec.ID != "mem-4" {
+		t.Errorf("expected IDs to continue after a reload, got %s", rec.ID)
+	}
+}
+
+func TestStore_SearchCJK(t *testing.T) {
+	s := NewStore(t.TempDir())
+	s.Save("用户喜欢喝绿茶", nil, "")
+	s.Save("用户住在上海", nil, "")
+
+	results, err := s.Search("喜欢什么茶", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Content != "用户喜欢喝绿茶" {
+		t.Errorf("expected the tea record first, got %+v", results)
+	}
+}
+
+func TestStore_ImportsMemoryFiles(t *testing.T) {
+	dir := t.TempDir()
+	longTerm := filepath.Join(dir, "MEMORY.md")
+	os.WriteFile(longTerm, []byte("# Preferences\n\n- Likes dark mode\n- Writes Rust\n  at work\n\nLives in Lisbon.\n"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "202603"), 0o755)
+	os.WriteFile(filepath.Join(dir, "202603", "20260314.md"), []byte("# 2026-03-14\n\nFixed the garden pump.\n"), 0o644)
+
+	s := NewStore(dir)
+	if n, err := s.Len(); err != nil || n != 4 {
+		t.Fatalf("expected 4 imported records, got %d (%v)", n, err)
+	}
+
+	results, _ := s.Search("rust", SearchOptions{})
+	if len(results) != 1 || results[0].Content != "Writes Rust\nat work" || results[0].Source != "MEMORY.md" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if !results[0].HasTag(tagLongTerm) || !results[0].HasTag("preferences") {
+		t.Errorf("expected long-term and heading tags, got %v", results[0].Tags)
+	}
+
+	daily, _ := s.Search("garden pump", SearchOptions{Tags: []string{tagDailyNote}})
+	if len(daily) != 1 || daily[0].Created.Format("2006-01-02") != "2026-03-14" {
+		t.Fatalf("expected the daily note dated by its file name, got %+v", daily)
+	}
+
+	// Editing MEMORY.md replaces its records.
+	os.WriteFile(longTerm, []byte("- Likes light mode\n"), 0o644)
+	future := time.Now().Add(2 * time.Second)
+	os.Chtimes(longTerm, future, future)
+	if results, _ := s.Search("mode", SearchOptions{}); len(results) != 1 || results[0].Content != "Likes light mode" {
+		t.Errorf("expected the edited file to be imported again, got %+v", results)
+	}
+
+	// Deleting a daily note drops its records.
+	os.Remove(filepath.Join(dir, "202603", "20260314.md"))
+	if results, _ := s.Search("garden", SearchOptions{}); len(results) != 0 {
+		t.Errorf("expected the deleted note's records to be dropped, got %+v", results)
+	}
+}
+
+func TestStore_ImportedRecordsSurviveReimport(t *testing.T) {
+	dir := t.TempDir()
+	longTerm := filepath.Join(dir, "MEMORY.md")
+	os.WriteFile(longTerm, []byte("- Likes dark mode\n- Writes Rust\n- Has a cat\n"), 0o644)
+
+	s := NewStore(dir)
+	rust, _ := s.Search("rust", SearchOptions{})
+	cat, _ := s.Search("cat", SearchOptions{})
+	if len(rust) != 1 || len(cat) != 1 {
+		t.Fatalf("expected the imported records, got %+v and %+v", rust, cat)
+	}
+	if _, err := s.Save("Writes Rust", []string{"work"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Forget(cat[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(longTerm, []byte("- Likes light mode\n- Writes Rust\n- Has a cat\n"), 0o644)
+	future := time.Now().Add(2 * time.Second)
+	os.Chtimes(longTerm, future, future)
+
+	reopened := NewStore(dir)
+	results, _ := reopened.Search("rust", SearchOptions{})
+	if len(results) != 1 || results[0].ID != rust[0].ID || !results[0].HasTag("work") {
+		t.Errorf("expected the re-imported record to keep its ID and tags, got %+v", results)
+	}
+	if results, _ := reopened.Search("cat", SearchOptions{}); len(results) != 0 {
+		t.Errorf("expected the forgotten record to stay forgotten, got %+v", results)
+	}
+	if n, _ := reopened.Len(); n != 2 {
+		t.Errorf("expected 2 records, got %d", n)
+	}
+}
+
+func TestStore_CorruptFileIsNotOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "records.json")
+	os.WriteFile(file, []byte("{not json"), 0o600)
+
+	s := NewStore(dir)
+	if _, err := s.Save("fact", nil, ""); err == nil {
+		t.Fatal("expected an error for an unreadable records file")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "{not json" {
+		t.Errorf("expected the records file to be left alone, got %q", data)
+	}
+}
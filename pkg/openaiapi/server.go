@@ -0,0 +1,455 @@
+// Package openaiapi exposes picoclaw agents through an OpenAI-compatible
+// HTTP API (/v1/chat/completions and /v1/models), so that existing OpenAI
+// clients can talk to a fully tooled agent with memory and skills.
+package openaiapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+// Channel is the channel name API turns are processed under.
+const Channel = "openai"
+
+// SessionHeader carries the client's conversation ID. Requests with the same
+// ID share a picoclaw session; requests without one each get a session of
+// their own.
+const SessionHeader = "X-Session-Id"
+
+// maxBodySize caps request bodies, which may carry base64 images.
+const maxBodySize = 20 << 20
+
+// Agents is the part of agent.AgentLoop the API needs.
+type Agents interface {
+	ListAgentIDs() []string
+	DefaultAgentID() string
+	ProcessForAgent(ctx context.Context, req agent.DirectRequest) (string, error)
+}
+
+// Server serves the OpenAI-compatible endpoints under /v1/.
+type Server struct {
+	agents  Agents
+	token   string
+	store   media.MediaStore
+	nowFunc func() time.Time // for testing
+}
+
+// NewServer creates a server for agents that accepts requests bearing token.
+func NewServer(agents Agents, token string) *Server {
+	return &Server{agents: agents, token: token, nowFunc: time.Now}
+}
+
+// SetMediaStore enables image inputs: data: URLs in image_url parts are
+// stored and attached to the turn.
+func (s *Server) SetMediaStore(store media.MediaStore) {
+	s.store = store
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid or missing API key")
+		return
+	}
+
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/v1/models":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET")
+			return
+		}
+		s.handleModels(w)
+	case "/v1/chat/completions":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use POST")
+			return
+		}
+		s.handleChatCompletions(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Unknown endpoint "+r.URL.Path)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(s.token)) == 1
+}
+
+type model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// handleModels lists every agent as a model, the default agent first.
+func (s *Server) handleModels(w http.ResponseWriter) {
+	ids := s.agents.ListAgentIDs()
+	def := s.agents.DefaultAgentID()
+	data := make([]model, 0, len(ids))
+	if def != "" {
+		data = append(data, model{ID: def, Object: "model", OwnedBy: "picoclaw"})
+	}
+	for _, id := range ids {
+		if id != def {
+			data = append(data, model{ID: id, Object: "model", OwnedBy: "picoclaw"})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	User     string        `json:"user,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Invalid JSON body: "+err.Error())
+		return
+	}
+
+	agentID, ok := s.resolveAgent(req.Model)
+	if !ok {
+		writeError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("The model %q does not exist", req.Model))
+		return
+	}
+
+	// picoclaw keeps a conversation in its own session, so only the latest
+	// user message is a new turn; earlier messages sent by the client are
+	// already in the session history.
+	lastIdx := -1
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			lastIdx = i
+			break
+		}
+	}
+	if lastIdx < 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "messages must contain a user message")
+		return
+	}
+
+	id := newCompletionID()
+	scope := "openai:" + id
+	text, images, err := s.parseContent(req.Messages[lastIdx].Content, scope)
+	if s.store != nil {
+		defer s.store.ReleaseAll(scope)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if strings.TrimSpace(text) == "" && len(images) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "the last user message is empty")
+		return
+	}
+
+	conversation := strings.TrimSpace(r.Header.Get(SessionHeader))
+	if conversation == "" {
+		conversation = strings.TrimSpace(req.User)
+	}
+	dr := agent.DirectRequest{
+		AgentID:    agentID,
+		SessionKey: sessionKey(agentID, conversation),
+		Channel:    Channel,
+		ChatID:     conversation,
+		SenderID:   req.User,
+		Content:    text,
+		Media:      images,
+	}
+	if conversation == "" {
+		// Without a conversation ID the request is a conversation of its
+		// own, seeded with the earlier messages the client sent.
+		dr.SessionKey = sessionKey(agentID, id)
+		dr.ChatID = id
+		dr.History = priorMessages(req.Messages[:lastIdx])
+	}
+
+	// Agent turns with tool calls routinely outlive the gateway's write
+	// timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	logger.InfoCF("openaiapi", "Chat completion request", map[string]any{
+		"agent_id":    agentID,
+		"session_key": dr.SessionKey,
+		"stream":      req.Stream,
+	})
+
+	if req.Stream {
+		s.stream(r.Context(), w, dr, id, agentID)
+		return
+	}
+
+	content, err := s.agents.ProcessForAgent(r.Context(), dr)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": s.nowFunc().Unix(),
+		"model":   agentID,
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]any{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+	})
+}
+
+// stream answers with server-sent chat.completion.chunk events. Content is
+// forwarded as the model streams it; if nothing was streamed (non-streaming
+// provider, slash command) the final response is sent as one chunk.
+func (s *Server) stream(ctx context.Context, w http.ResponseWriter, dr agent.DirectRequest, id, agentID string) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var mu sync.Mutex
+	created := s.nowFunc().Unix()
+	send := func(delta map[string]any, finish any) {
+		chunk := map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   agentID,
+			"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(map[string]any{"role": "assistant", "content": ""}, nil)
+	streamed := false
+	dr.OnDelta = func(delta string) {
+		if delta == "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		streamed = true
+		send(map[string]any{"content": delta}, nil)
+	}
+
+	content, err := s.agents.ProcessForAgent(ctx, dr)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		data, _ := json.Marshal(errorBody("server_error", err.Error()))
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	} else {
+		if !streamed && content != "" {
+			send(map[string]any{"content": content}, nil)
+		}
+		send(map[string]any{}, "stop")
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// resolveAgent maps the request's model field to an agent ID. An empty model
+// or "picoclaw" selects the default agent.
+func (s *Server) resolveAgent(model string) (string, bool) {
+	model = strings.TrimSpace(model)
+	if model == "" || strings.EqualFold(model, "picoclaw") {
+		id := s.agents.DefaultAgentID()
+		return id, id != ""
+	}
+	want := routing.NormalizeAgentID(model)
+	for _, id := range s.agents.ListAgentIDs() {
+		if id == want {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// sessionKey returns the session for a conversation with agentID.
+func sessionKey(agentID, conversation string) string {
+	return routing.BuildAgentPeerSessionKey(routing.SessionKeyParams{
+		AgentID: agentID,
+		Channel: Channel,
+		Peer:    &routing.RoutePeer{Kind: "direct", ID: conversation},
+		DMScope: routing.DMScopePerChannelPeer,
+	})
+}
+
+// parseContent flattens a message's content, either a string or an array of
+// parts, into text and media refs for its data: URL images.
+func (s *Server) parseContent(raw json.RawMessage, scope string) (string, []string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil, nil
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", nil, fmt.Errorf("unsupported message content")
+	}
+	var (
+		texts []string
+		refs  []string
+	)
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			texts = append(texts, p.Text)
+		case "image_url":
+			if p.ImageURL == nil {
+				continue
+			}
+			ref, err := s.storeImage(p.ImageURL.URL, scope)
+			if err != nil {
+				return "", nil, err
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return strings.Join(texts, "\n"), refs, nil
+}
+
+// priorMessages converts the user and assistant messages a client sent
+// before its latest one into session history. Images in them are dropped.
+func priorMessages(msgs []chatMessage) []providers.Message {
+	history := make([]providers.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Role != "user" && m.Role != "assistant" {
+			continue
+		}
+		if text := contentText(m.Content); text != "" {
+			history = append(history, providers.Message{Role: m.Role, Content: text})
+		}
+	}
+	return history
+}
+
+// contentText returns the text of a message's content, either a string or
+// an array of parts.
+func contentText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []contentPart
+	json.Unmarshal(raw, &parts)
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// storeImage saves a base64 data: URL to a temp file in the media store.
+func (s *Server) storeImage(url, scope string) (string, error) {
+	if s.store == nil {
+		return "", fmt.Errorf("image input is not supported")
+	}
+	header, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !strings.HasPrefix(url, "data:") || !ok || !strings.HasSuffix(header, ";base64") {
+		return "", fmt.Errorf("only base64 data: URLs are supported for images")
+	}
+	contentType := strings.TrimSuffix(header, ";base64")
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("invalid image data: %w", err)
+	}
+
+	ext := ".bin"
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	dir := filepath.Join(os.TempDir(), "picoclaw_media")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "openai-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	_, werr := f.Write(data)
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		os.Remove(f.Name())
+		return "", werr
+	}
+
+	return s.store.Store(f.Name(), media.MediaMeta{
+		Filename:    filepath.Base(f.Name()),
+		ContentType: contentType,
+		Source:      Channel,
+	}, scope)
+}
+
+func newCompletionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+func errorBody(code, message string) map[string]any {
+	return map[string]any{"error": map[string]any{"message": message, "type": code, "code": code}}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody(code, message))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,343 @@
+package openaiapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type fakeAgents struct {
+	deltas []string
+	reply  string
+	err    error
+	got    []agent.DirectRequest
+	store  media.MediaStore
+	images []string // contents of attached media, read during the turn
+}
+
+func (f *fakeAgents) ListAgentIDs() []string { return []string{"coder", "main"} }
+func (f *fakeAgents) DefaultAgentID() string { return "main" }
+
+func (f *fakeAgents) ProcessForAgent(_ context.Context, req agent.DirectRequest) (string, error) {
+	f.got = append(f.got, req)
+	for _, ref := range req.Media {
+		path, err := f.store.Resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		data, _ := os.ReadFile(path)
+		f.images = append(f.images, string(data))
+	}
+	if req.OnDelta != nil {
+		for _, d := range f.deltas {
+			req.OnDelta(d)
+		}
+	}
+	return f.reply, f.err
+}
+
+func do(t *testing.T, s *Server, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Auth(t *testing.T) {
+	s := NewServer(&fakeAgents{}, "secret")
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status = %d, want 401", auth, rec.Code)
+		}
+	}
+
+	open := NewServer(&fakeAgents{}, "")
+	if rec := do(t, open, http.MethodGet, "/v1/models", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty token: status = %d, want every request rejected", rec.Code)
+	}
+}
+
+func TestServer_Models(t *testing.T) {
+	s := NewServer(&fakeAgents{}, "secret")
+	rec := do(t, s, http.MethodGet, "/v1/models", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Data []model `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Data) != 2 || resp.Data[0].ID != "main" || resp.Data[1].ID != "coder" {
+		t.Errorf("models = %+v, want default agent first", resp.Data)
+	}
+}
+
+func TestServer_ChatCompletion(t *testing.T) {
+	agents := &fakeAgents{reply: "hello there"}
+	s := NewServer(agents, "secret")
+
+	body := `{"model":"Coder","messages":[
+		{"role":"system","content":"be nice"},
+		{"role":"user","content":"old question"},
+		{"role":"assistant","content":"old answer"},
+		{"role":"user","content":[{"type":"text","text":"new"},{"type":"text","text":"question"}]}
+	]}`
+	rec := do(t, s, http.MethodPost, "/v1/chat/completions", body, map[string]string{SessionHeader: "Chat-42"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Object != "chat.completion" || resp.Model != "coder" || len(resp.Choices) != 1 {
+		t.Fatalf("response = %s", rec.Body)
+	}
+	if c := resp.Choices[0]; c.Message.Content != "hello there" || c.Message.Role != "assistant" ||
+		c.FinishReason != "stop" {
+		t.Errorf("choice = %+v", c)
+	}
+
+	req := agents.got[0]
+	if req.AgentID != "coder" || req.Content != "new\nquestion" || req.Channel != Channel {
+		t.Errorf("request = %+v", req)
+	}
+	if req.SessionKey != "agent:coder:openai:direct:chat-42" {
+		t.Errorf("SessionKey = %q", req.SessionKey)
+	}
+}
+
+func TestServer_SessionKeyFallbacks(t *testing.T) {
+	agents := &fakeAgents{reply: "ok"}
+	s := NewServer(agents, "secret")
+
+	const path = "/v1/chat/completions"
+	do(t, s, http.MethodPost, path, `{"messages":[{"role":"user","content":"hi"}],"user":"alice"}`, nil)
+	do(t, s, http.MethodPost, path, `{"model":"picoclaw","messages":[
+		{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"again"}
+	]}`, nil)
+	do(t, s, http.MethodPost, path, `{"messages":[{"role":"user","content":"hi"}]}`, nil)
+
+	if got := agents.got[0].SessionKey; got != "agent:main:openai:direct:alice" {
+		t.Errorf("user field session = %q", got)
+	}
+	if agents.got[0].History != nil {
+		t.Errorf("conversation with an ID got history %+v", agents.got[0].History)
+	}
+
+	first, second := agents.got[1], agents.got[2]
+	if !strings.HasPrefix(first.SessionKey, "agent:main:openai:direct:chatcmpl-") ||
+		first.SessionKey == second.SessionKey {
+		t.Errorf("sessions without a conversation ID = %q, %q, want one per request",
+			first.SessionKey, second.SessionKey)
+	}
+	if len(first.History) != 2 || first.History[1].Content != "hello" || len(second.History) != 0 {
+		t.Errorf("history = %+v, %+v, want the messages each client sent", first.History, second.History)
+	}
+}
+
+// historyProvider answers with the number of messages it was sent.
+type historyProvider struct{}
+
+func (historyProvider) Chat(
+	_ context.Context,
+	messages []providers.Message,
+	_ []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	var texts []string
+	for _, m := range messages[1:] { // skip the system prompt
+		texts = append(texts, m.Content)
+	}
+	return &providers.LLMResponse{Content: strings.Join(texts, "|")}, nil
+}
+
+func (historyProvider) GetDefaultModel() string { return "test" }
+
+func TestServer_RequestsWithoutConversationDontShareHistory(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+	al := agent.NewAgentLoop(cfg, bus.NewMessageBus(), historyProvider{})
+	s := NewServer(al, "secret")
+
+	reply := func(body string) string {
+		rec := do(t, s, http.MethodPost, "/v1/chat/completions", body, nil)
+		var resp struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if len(resp.Choices) != 1 {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		return resp.Choices[0].Message.Content
+	}
+
+	if got := reply(`{"messages":[{"role":"user","content":"secret of client A"}]}`); got != "secret of client A" {
+		t.Errorf("first client saw %q", got)
+	}
+	got := reply(`{"messages":[{"role":"user","content":"earlier"},{"role":"assistant","content":"noted"},
+		{"role":"user","content":"question of client B"}]}`)
+	if got != "earlier|noted|question of client B" {
+		t.Errorf("second client saw %q, want only its own messages", got)
+	}
+}
+
+func TestServer_ChatCompletionErrors(t *testing.T) {
+	s := NewServer(&fakeAgents{err: fmt.Errorf("llm down")}, "secret")
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"bad json", `{`, http.StatusBadRequest},
+		{"unknown model", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, http.StatusNotFound},
+		{"no user message", `{"messages":[{"role":"system","content":"x"}]}`, http.StatusBadRequest},
+		{"image without store", `{"messages":[{"role":"user","content":[{"type":"image_url",` +
+			`"image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`, http.StatusBadRequest},
+		{"agent error", `{"messages":[{"role":"user","content":"hi"}]}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodPost, "/v1/chat/completions", tt.body, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), `"error"`) {
+				t.Errorf("body = %s, want OpenAI error object", rec.Body)
+			}
+		})
+	}
+}
+
+// streamEvents returns the decoded data payloads of an SSE body.
+func streamEvents(t *testing.T, body string) (chunks []map[string]any, done bool) {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk map[string]any
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", data, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, done
+}
+
+func streamedText(chunks []map[string]any) string {
+	var sb strings.Builder
+	for _, c := range chunks {
+		choices, _ := c["choices"].([]any)
+		if len(choices) == 0 {
+			continue
+		}
+		delta, _ := choices[0].(map[string]any)["delta"].(map[string]any)
+		s, _ := delta["content"].(string)
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+func TestServer_Stream(t *testing.T) {
+	agents := &fakeAgents{deltas: []string{"Hel", "lo"}, reply: "Hello"}
+	s := NewServer(agents, "secret")
+
+	rec := do(t, s, http.MethodPost, "/v1/chat/completions",
+		`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	chunks, done := streamEvents(t, rec.Body.String())
+	if !done {
+		t.Error("stream not terminated with [DONE]")
+	}
+	if got := streamedText(chunks); got != "Hello" {
+		t.Errorf("streamed text = %q, want Hello", got)
+	}
+	last := chunks[len(chunks)-1]["choices"].([]any)[0].(map[string]any)
+	if last["finish_reason"] != "stop" {
+		t.Errorf("last chunk = %v, want finish_reason stop", last)
+	}
+}
+
+func TestServer_StreamWithoutDeltas(t *testing.T) {
+	s := NewServer(&fakeAgents{reply: "whole reply"}, "secret")
+	rec := do(t, s, http.MethodPost, "/v1/chat/completions",
+		`{"stream":true,"messages":[{"role":"user","content":"/usage"}]}`, nil)
+	chunks, _ := streamEvents(t, rec.Body.String())
+	if got := streamedText(chunks); got != "whole reply" {
+		t.Errorf("streamed text = %q, want the final reply as one chunk", got)
+	}
+}
+
+func TestServer_ImageInput(t *testing.T) {
+	store := media.NewFileMediaStore()
+	agents := &fakeAgents{reply: "a cat", store: store}
+	s := NewServer(agents, "secret")
+	s.SetMediaStore(store)
+
+	img := base64.StdEncoding.EncodeToString([]byte("fake png"))
+	body := `{"messages":[{"role":"user","content":[{"type":"text","text":"what is this?"},` +
+		`{"type":"image_url","image_url":{"url":"data:image/png;base64,` + img + `"}}]}]}`
+	rec := do(t, s, http.MethodPost, "/v1/chat/completions", body, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if len(agents.images) != 1 || agents.images[0] != "fake png" {
+		t.Errorf("images seen by agent = %q", agents.images)
+	}
+	if _, err := store.Resolve(agents.got[0].Media[0]); err == nil {
+		t.Error("expected request media to be released after the turn")
+	}
+}
@@ -2,6 +2,7 @@ package anthropicprovider
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -21,6 +22,7 @@ type (
 	Message                = protocoltypes.Message
 	ToolDefinition         = protocoltypes.ToolDefinition
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+	ContentPart            = protocoltypes.ContentPart
 )
 
 const defaultBaseURL = "https://api.anthropic.com"
@@ -71,13 +73,9 @@ func (p *Provider) Chat(
 	model string,
 	options map[string]any,
 ) (*LLMResponse, error) {
-	var opts []option.RequestOption
-	if p.tokenSource != nil {
-		tok, err := p.tokenSource()
-		if err != nil {
-			return nil, fmt.Errorf("refreshing token: %w", err)
-		}
-		opts = append(opts, option.WithAuthToken(tok))
+	opts, err := p.requestOptions()
+	if err != nil {
+		return nil, err
 	}
 
 	params, err := buildParams(messages, tools, model, options)
@@ -93,6 +91,59 @@ func (p *Provider) Chat(
 	return parseResponse(resp), nil
 }
 
+// ChatStream is like Chat but streams the response, invoking onDelta with each
+// text delta as it arrives. The returned response is the accumulated message.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	opts, err := p.requestOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := buildParams(messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := p.client.Messages.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+
+	var msg anthropic.Message
+	for stream.Next() {
+		event := stream.Current()
+		if err := msg.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("claude stream: %w", err)
+		}
+		if ev, ok := event.AsAny().(anthropic.ContentBlockDeltaEvent); ok {
+			if delta, ok := ev.Delta.AsAny().(anthropic.TextDelta); ok && delta.Text != "" && onDelta != nil {
+				onDelta(delta.Text)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("claude API call: %w", err)
+	}
+
+	return parseResponse(&msg), nil
+}
+
+func (p *Provider) requestOptions() ([]option.RequestOption, error) {
+	if p.tokenSource == nil {
+		return nil, nil
+	}
+	tok, err := p.tokenSource()
+	if err != nil {
+		return nil, fmt.Errorf("refreshing token: %w", err)
+	}
+	return []option.RequestOption{option.WithAuthToken(tok)}, nil
+}
+
 func (p *Provider) GetDefaultModel() string {
 	return "claude-sonnet-4.6"
 }
@@ -132,6 +183,10 @@ func buildParams(
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
+			} else if len(msg.Parts) > 0 {
+				anthropicMessages = append(anthropicMessages,
+					anthropic.NewUserMessage(buildUserBlocks(msg.Content, msg.Parts)...),
+				)
 			} else {
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
@@ -185,6 +240,36 @@ func buildParams(
 	return params, nil
 }
 
+// buildUserBlocks converts a user message with attachments into content
+// blocks. Images and PDFs map to native blocks and plain text files become
+// text documents; other attachment types are noted in text since the
+// Messages API cannot accept them.
+func buildUserBlocks(text string, parts []ContentPart) []anthropic.ContentBlockParamUnion {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(parts)+1)
+	for _, part := range parts {
+		switch {
+		case part.Type == "image":
+			blocks = append(blocks, anthropic.NewImageBlockBase64(part.MIMEType, part.Data))
+		case part.MIMEType == "application/pdf":
+			blocks = append(blocks, anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: part.Data}))
+		case strings.HasPrefix(part.MIMEType, "text/"):
+			decoded, err := base64.StdEncoding.DecodeString(part.Data)
+			if err != nil {
+				continue
+			}
+			blocks = append(blocks, anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(decoded)}))
+		default:
+			blocks = append(blocks, anthropic.NewTextBlock(
+				fmt.Sprintf("[attachment %s (%s) not supported by this model]", part.Filename, part.MIMEType),
+			))
+		}
+	}
+	if text != "" {
+		blocks = append(blocks, anthropic.NewTextBlock(text))
+	}
+	return blocks
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
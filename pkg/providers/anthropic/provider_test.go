@@ -2,6 +2,7 @@ package anthropicprovider
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -103,6 +104,38 @@ func TestBuildParams_WithTools(t *testing.T) {
 	}
 }
 
+func TestBuildParams_ContentParts(t *testing.T) {
+	messages := []Message{{
+		Role:    "user",
+		Content: "Describe these",
+		Parts: []ContentPart{
+			{Type: "image", MIMEType: "image/png", Filename: "photo.png", Data: "aW1n"},
+			{Type: "file", MIMEType: "application/pdf", Filename: "doc.pdf", Data: "cGRm"},
+			{Type: "audio", MIMEType: "audio/ogg", Filename: "voice.ogg", Data: "YXVk"},
+		},
+	}}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[0].Content
+	if len(blocks) != 4 {
+		t.Fatalf("len(Content) = %d, want 4", len(blocks))
+	}
+	if blocks[0].OfImage == nil || blocks[0].OfImage.Source.OfBase64 == nil {
+		t.Fatal("expected base64 image block first")
+	}
+	if blocks[1].OfDocument == nil || blocks[1].OfDocument.Source.OfBase64 == nil {
+		t.Fatal("expected PDF document block second")
+	}
+	if blocks[2].OfText == nil {
+		t.Fatal("expected unsupported audio to be noted as text")
+	}
+	if blocks[3].OfText == nil || blocks[3].OfText.Text != "Describe these" {
+		t.Fatal("expected message text last")
+	}
+}
+
 func TestParseResponse_TextOnly(t *testing.T) {
 	resp := &anthropic.Message{
 		Content: []anthropic.ContentBlockUnion{},
@@ -193,6 +226,64 @@ func TestProvider_ChatRoundTrip(t *testing.T) {
 	}
 }
 
+func TestProvider_ChatStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_test","type":"message","role":"assistant","model":"claude-sonnet-4.6","content":[],"stop_reason":null,"usage":{"input_tokens":15,"output_tokens":0}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var reqBody map[string]any
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		if reqBody["stream"] != true {
+			http.Error(w, "expected stream", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(ev), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, ev)
+		}
+	}))
+	defer server.Close()
+
+	var deltas []string
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	resp, err := provider.ChatStream(
+		t.Context(),
+		[]Message{{Role: "user", Content: "Hello"}},
+		nil,
+		"claude-sonnet-4.6",
+		map[string]any{"max_tokens": 1024},
+		func(delta string) { deltas = append(deltas, delta) },
+	)
+	if err != nil {
+		t.Fatalf("ChatStream() error: %v", err)
+	}
+	if resp.Content != "Hello there" {
+		t.Errorf("Content = %q, want %q", resp.Content, "Hello there")
+	}
+	if len(deltas) != 2 || deltas[0] != "Hello" || deltas[1] != " there" {
+		t.Errorf("deltas = %v, want [Hello  there]", deltas)
+	}
+	if resp.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, "stop")
+	}
+}
+
 func TestProvider_GetDefaultModel(t *testing.T) {
 	p := NewProvider("test-token")
 	if got := p.GetDefaultModel(); got != "claude-sonnet-4.6" {
@@ -168,12 +168,18 @@ type antigravityContent struct {
 
 type antigravityPart struct {
 	Text                  string                       `json:"text,omitempty"`
+	InlineData            *antigravityInlineData       `json:"inlineData,omitempty"`
 	ThoughtSignature      string                       `json:"thoughtSignature,omitempty"`
 	ThoughtSignatureSnake string                       `json:"thought_signature,omitempty"`
 	FunctionCall          *antigravityFunctionCall     `json:"functionCall,omitempty"`
 	FunctionResponse      *antigravityFunctionResponse `json:"functionResponse,omitempty"`
 }
 
+type antigravityInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
 type antigravityFunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -235,9 +241,18 @@ func (p *AntigravityProvider) buildRequest(
 					}},
 				})
 			} else {
+				parts := make([]antigravityPart, 0, len(msg.Parts)+1)
+				for _, part := range msg.Parts {
+					parts = append(parts, antigravityPart{
+						InlineData: &antigravityInlineData{MimeType: part.MIMEType, Data: part.Data},
+					})
+				}
+				if msg.Content != "" || len(parts) == 0 {
+					parts = append(parts, antigravityPart{Text: msg.Content})
+				}
 				req.Contents = append(req.Contents, antigravityContent{
 					Role:  "user",
-					Parts: []antigravityPart{{Text: msg.Content}},
+					Parts: parts,
 				})
 			}
 		case "assistant":
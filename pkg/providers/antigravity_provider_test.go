@@ -54,3 +54,25 @@ func TestResolveToolResponseNameInfersNameFromGeneratedCallID(t *testing.T) {
 		t.Fatalf("expected inferred tool name search_docs, got %q", got)
 	}
 }
+
+func TestBuildRequestInlinesContentParts(t *testing.T) {
+	p := &AntigravityProvider{}
+
+	messages := []Message{{
+		Role:    "user",
+		Content: "what is this?",
+		Parts:   []ContentPart{{Type: "image", MIMEType: "image/jpeg", Data: "aW1n"}},
+	}}
+
+	req := p.buildRequest(messages, nil, "", nil)
+	if len(req.Contents) != 1 || len(req.Contents[0].Parts) != 2 {
+		t.Fatalf("expected 1 content with 2 parts, got %+v", req.Contents)
+	}
+	inline := req.Contents[0].Parts[0].InlineData
+	if inline == nil || inline.MimeType != "image/jpeg" || inline.Data != "aW1n" {
+		t.Fatalf("unexpected inline data: %+v", inline)
+	}
+	if req.Contents[0].Parts[1].Text != "what is this?" {
+		t.Fatalf("expected text part after attachments, got %q", req.Contents[0].Parts[1].Text)
+	}
+}
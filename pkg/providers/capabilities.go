@@ -0,0 +1,222 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ModelCapabilities describes what a model supports.
+type ModelCapabilities struct {
+	ContextWindow   int // input plus output tokens; 0 if unknown
+	MaxOutputTokens int // largest max_tokens the model accepts; 0 if unknown
+	Vision          bool
+	Tools           bool
+	Reasoning       bool
+	Streaming       bool
+	// Known is false when neither the built-in catalog nor model_list
+	// describes the model; the other fields are then permissive defaults.
+	Known bool
+}
+
+// defaultCapabilities are assumed for models nothing is known about, so that
+// unknown models keep getting tools and streaming.
+var defaultCapabilities = ModelCapabilities{Tools: true, Streaming: true}
+
+// builtinCapabilities is the built-in catalog, keyed by model family. A
+// family matches model IDs that equal it or continue it after a separator
+// ("gpt-4-0613", "llama3:8b"), so "gpt-4" does not cover "gpt-4.5-preview".
+// The longest matching family wins.
+var builtinCapabilities = map[string]ModelCapabilities{
+	"claude-":         catalogEntry(200000, 8192, capVision|capTools|capStreaming),
+	"claude-3-haiku":  catalogEntry(200000, 4096, capVision|capTools|capStreaming),
+	"claude-sonnet-4": catalogEntry(200000, 64000, capVision|capTools|capReasoning|capStreaming),
+	"claude-opus-4":   catalogEntry(200000, 32000, capVision|capTools|capReasoning|capStreaming),
+
+	"gpt-3.5-turbo":      catalogEntry(16385, 4096, capTools|capStreaming),
+	"gpt-4":              catalogEntry(8192, 8192, capTools|capStreaming),
+	"gpt-4-32k":          catalogEntry(32768, 8192, capTools|capStreaming),
+	"gpt-4-0125-preview": catalogEntry(128000, 4096, capTools|capStreaming),
+	"gpt-4-1106-preview": catalogEntry(128000, 4096, capTools|capStreaming),
+	"gpt-4-turbo":        catalogEntry(128000, 4096, capVision|capTools|capStreaming),
+	"gpt-4o":             catalogEntry(128000, 16384, capVision|capTools|capStreaming),
+	"gpt-4.1":            catalogEntry(1047576, 32768, capVision|capTools|capStreaming),
+	"gpt-4.5":            catalogEntry(128000, 16384, capVision|capTools|capStreaming),
+	"gpt-5":              catalogEntry(400000, 128000, capVision|capTools|capReasoning|capStreaming),
+	"o1":                 catalogEntry(200000, 100000, capVision|capTools|capReasoning|capStreaming),
+	"o1-mini":            catalogEntry(128000, 65536, capReasoning|capStreaming),
+	"o1-preview":         catalogEntry(128000, 32768, capReasoning|capStreaming),
+	"o3":                 catalogEntry(200000, 100000, capVision|capTools|capReasoning|capStreaming),
+	"o4-mini":            catalogEntry(200000, 100000, capVision|capTools|capReasoning|capStreaming),
+
+	"gemini-1.5":       catalogEntry(1048576, 8192, capVision|capTools|capStreaming),
+	"gemini-2.0-flash": catalogEntry(1048576, 8192, capVision|capTools|capStreaming),
+	"gemini-2.5":       catalogEntry(1048576, 65536, capVision|capTools|capReasoning|capStreaming),
+
+	"deepseek-chat":     catalogEntry(65536, 8192, capTools|capStreaming),
+	"deepseek-reasoner": catalogEntry(65536, 32768, capTools|capReasoning|capStreaming),
+
+	"glm-4":            catalogEntry(128000, 4096, capTools|capStreaming),
+	"moonshot-v1-8k":   catalogEntry(8192, 0, capTools|capStreaming),
+	"moonshot-v1-32k":  catalogEntry(32768, 0, capTools|capStreaming),
+	"moonshot-v1-128k": catalogEntry(131072, 0, capTools|capStreaming),
+	"kimi-k2":          catalogEntry(131072, 0, capTools|capStreaming),
+	"qwen":             catalogEntry(32768, 8192, capTools|capStreaming),
+	"qwen2":            catalogEntry(32768, 8192, capTools|capStreaming),
+	"qwen2.5":          catalogEntry(32768, 8192, capTools|capStreaming),
+	"qwen3":            catalogEntry(32768, 8192, capTools|capStreaming),
+	"mistral-large":    catalogEntry(131072, 0, capTools|capStreaming),
+	"llama3":           catalogEntry(8192, 0, capTools|capStreaming),
+	"llama-3":          catalogEntry(8192, 0, capTools|capStreaming),
+	"llama3.1":         catalogEntry(131072, 0, capTools|capStreaming),
+	"llama-3.1":        catalogEntry(131072, 0, capTools|capStreaming),
+	"llama3.2":         catalogEntry(131072, 0, capTools|capStreaming),
+	"llama-3.2":        catalogEntry(131072, 0, capTools|capStreaming),
+	"llama3.3":         catalogEntry(131072, 0, capTools|capStreaming),
+	"llama-3.3":        catalogEntry(131072, 0, capTools|capStreaming),
+}
+
+// capFlag is a set of the boolean capabilities of a catalog entry.
+type capFlag int
+
+const (
+	capVision capFlag = 1 << iota
+	capTools
+	capReasoning
+	capStreaming
+)
+
+// catalogEntry builds a built-in catalog entry.
+func catalogEntry(contextWindow, maxOutput int, flags capFlag) ModelCapabilities {
+	return ModelCapabilities{
+		ContextWindow:   contextWindow,
+		MaxOutputTokens: maxOutput,
+		Vision:          flags&capVision != 0,
+		Tools:           flags&capTools != 0,
+		Reasoning:       flags&capReasoning != 0,
+		Streaming:       flags&capStreaming != 0,
+		Known:           true,
+	}
+}
+
+// builtinFamilies are the keys of builtinCapabilities, longest first.
+var builtinFamilies = func() []string {
+	families := make([]string, 0, len(builtinCapabilities))
+	for family := range builtinCapabilities {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return len(families[i]) > len(families[j]) })
+	return families
+}()
+
+// inFamily reports whether model ID id belongs to family: it equals family,
+// or continues it after a separator. Letters, digits and dots continue the
+// family name itself ("gpt-4o", "gpt-4.5", "o1x") unless family already ends
+// in a separator, as "claude-" does.
+func inFamily(id, family string) bool {
+	if !strings.HasPrefix(id, family) {
+		return false
+	}
+	if len(id) == len(family) || strings.HasSuffix(family, "-") {
+		return true
+	}
+	c := id[len(family)]
+	isNameChar := c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z'
+	return !isNameChar
+}
+
+// LookupCapabilities returns the built-in capabilities of model, which may be
+// a bare model ID or carry protocol and vendor prefixes
+// ("openrouter/anthropic/claude-sonnet-4").
+func LookupCapabilities(model string) ModelCapabilities {
+	id := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	for _, family := range builtinFamilies {
+		if inFamily(id, family) {
+			return builtinCapabilities[family]
+		}
+	}
+	return defaultCapabilities
+}
+
+// Capabilities returns the capabilities of model: the built-in ones for its
+// model_list entry's model, with the entry's capabilities overrides applied.
+// Models without an entry are looked up in the built-in catalog directly.
+func (idx *ModelIndex) Capabilities(model string) ModelCapabilities {
+	mc, ok := idx.Lookup(model)
+	if !ok {
+		return LookupCapabilities(model)
+	}
+	caps := LookupCapabilities(mc.Model)
+	if o := mc.Capabilities; o != nil {
+		caps = applyCapabilities(caps, o)
+	}
+	return caps
+}
+
+// applyCapabilities overlays the set fields of o on caps.
+func applyCapabilities(caps ModelCapabilities, o *config.ModelCapabilitiesConfig) ModelCapabilities {
+	caps.Known = true
+	if o.ContextWindow > 0 {
+		caps.ContextWindow = o.ContextWindow
+	}
+	if o.MaxOutputTokens > 0 {
+		caps.MaxOutputTokens = o.MaxOutputTokens
+	}
+	for _, f := range []struct {
+		override *bool
+		field    *bool
+	}{
+		{o.Vision, &caps.Vision},
+		{o.Tools, &caps.Tools},
+		{o.Reasoning, &caps.Reasoning},
+		{o.Streaming, &caps.Streaming},
+	} {
+		if f.override != nil {
+			*f.field = *f.override
+		}
+	}
+	return caps
+}
+
+// String formats the capabilities for display, e.g.
+// "context 128000 tokens, max output 16384 tokens, supports vision, tools, streaming".
+func (c ModelCapabilities) String() string {
+	var parts []string
+	if c.ContextWindow > 0 {
+		parts = append(parts, fmt.Sprintf("context %d tokens", c.ContextWindow))
+	} else {
+		parts = append(parts, "context unknown")
+	}
+	if c.MaxOutputTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max output %d tokens", c.MaxOutputTokens))
+	}
+
+	var features []string
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{
+		{"vision", c.Vision},
+		{"tools", c.Tools},
+		{"reasoning", c.Reasoning},
+		{"streaming", c.Streaming},
+	} {
+		if f.on {
+			features = append(features, f.name)
+		}
+	}
+	if len(features) > 0 {
+		parts = append(parts, "supports "+strings.Join(features, ", "))
+	} else {
+		parts = append(parts, "text only")
+	}
+	if !c.Known {
+		parts = append(parts, "not in the model catalog")
+	}
+	return strings.Join(parts, ", ")
+}
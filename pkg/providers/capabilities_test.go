@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestLookupCapabilities(t *testing.T) {
+	tests := []struct {
+		model   string
+		window  int
+		tools   bool
+		known   bool
+		summary string
+	}{
+		{"gpt-4o-mini", 128000, true, true, "longest prefix"},
+		{"gpt-4", 8192, true, true, "exact prefix"},
+		{"openrouter/anthropic/claude-sonnet-4", 200000, true, true, "vendor prefixes"},
+		{"o1-mini", 128000, false, true, "no tool support"},
+		{"my-local-model", 0, true, false, "unknown model"},
+		{"gpt-4-0613", 8192, true, true, "dated snapshot"},
+		{"gpt-4-32k", 32768, true, true, "longer family after separator"},
+		{"gpt-4.5-preview", 128000, true, true, "dot continues the family name"},
+		{"gpt-4.7", 0, true, false, "dot is not a separator"},
+		{"o1-preview", 128000, false, true, "o1-preview has no tools"},
+		{"o1x", 0, true, false, "letter is not a separator"},
+		{"llama3:8b", 8192, true, true, "ollama tag"},
+		{"qwen2.5-72b-instruct", 32768, true, true, "versioned family"},
+	}
+	for _, tt := range tests {
+		caps := LookupCapabilities(tt.model)
+		if caps.ContextWindow != tt.window || caps.Tools != tt.tools || caps.Known != tt.known {
+			t.Errorf("%s: LookupCapabilities(%q) = %+v", tt.summary, tt.model, caps)
+		}
+	}
+}
+
+func TestModelIndex_CapabilitiesOverrides(t *testing.T) {
+	noVision := false
+	idx := NewModelIndex([]config.ModelConfig{
+		{ModelName: "fast", Model: "groq/llama-3.3-70b-versatile"},
+		{ModelName: "local", Model: "ollama/my-model", Capabilities: &config.ModelCapabilitiesConfig{
+			ContextWindow: 16384,
+			Vision:        &noVision,
+		}},
+	})
+
+	if caps := idx.Capabilities("fast"); caps.ContextWindow != 131072 || !caps.Known {
+		t.Errorf("expected catalog capabilities for fast, got %+v", caps)
+	}
+	caps := idx.Capabilities("my-model")
+	if caps.ContextWindow != 16384 || caps.Vision || !caps.Tools || !caps.Known {
+		t.Errorf("expected overrides on top of the defaults for local, got %+v", caps)
+	}
+	if got := caps.String(); got != "context 16384 tokens, supports tools, streaming" {
+		t.Errorf("String() = %q", got)
+	}
+}
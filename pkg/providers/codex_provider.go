@@ -224,6 +224,15 @@ func buildCodexParams(
 						},
 					},
 				})
+			} else if len(msg.Parts) > 0 {
+				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
+					OfMessage: &responses.EasyInputMessageParam{
+						Role: responses.EasyInputMessageRoleUser,
+						Content: responses.EasyInputMessageContentUnionParam{
+							OfInputItemContentList: buildCodexInputContent(msg.Content, msg.Parts),
+						},
+					},
+				})
 			} else {
 				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
 					OfMessage: &responses.EasyInputMessageParam{
@@ -439,3 +448,41 @@ func createCodexTokenSource() func() (string, string, error) {
 		return cred.AccessToken, cred.AccountID, nil
 	}
 }
+
+// buildCodexInputContent converts a user message with attachments into
+// Responses API input content. Images and files are inlined as data URLs;
+// audio is not accepted as message input and is noted in text instead.
+func buildCodexInputContent(text string, parts []ContentPart) responses.ResponseInputMessageContentListParam {
+	content := make(responses.ResponseInputMessageContentListParam, 0, len(parts)+1)
+	if text != "" {
+		content = append(content, responses.ResponseInputContentUnionParam{
+			OfInputText: &responses.ResponseInputTextParam{Text: text},
+		})
+	}
+	for _, part := range parts {
+		dataURL := "data:" + part.MIMEType + ";base64," + part.Data
+		switch part.Type {
+		case "image":
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputImage: &responses.ResponseInputImageParam{
+					Detail:   responses.ResponseInputImageDetailAuto,
+					ImageURL: openai.Opt(dataURL),
+				},
+			})
+		case "audio":
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputText: &responses.ResponseInputTextParam{
+					Text: fmt.Sprintf("[attachment %s (%s) not supported by this model]", part.Filename, part.MIMEType),
+				},
+			})
+		default:
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputFile: &responses.ResponseInputFileParam{
+					Filename: openai.Opt(part.Filename),
+					FileData: openai.Opt(dataURL),
+				},
+			})
+		}
+	}
+	return content
+}
@@ -643,3 +643,33 @@ func writeCompletedSSE(w http.ResponseWriter, response map[string]any) {
 	fmt.Fprintf(w, "data: %s\n\n", string(b))
 	fmt.Fprintf(w, "data: [DONE]\n\n")
 }
+
+func TestBuildCodexParams_ContentParts(t *testing.T) {
+	messages := []Message{{
+		Role:    "user",
+		Content: "what is this?",
+		Parts: []ContentPart{
+			{Type: "image", MIMEType: "image/png", Data: "aW1n"},
+			{Type: "file", MIMEType: "application/pdf", Filename: "doc.pdf", Data: "cGRm"},
+		},
+	}}
+	params := buildCodexParams(messages, nil, "gpt-4o", map[string]any{}, false)
+
+	msg := params.Input.OfInputItemList[0].OfMessage
+	if msg == nil {
+		t.Fatal("expected user message input item")
+	}
+	content := msg.Content.OfInputItemContentList
+	if len(content) != 3 {
+		t.Fatalf("len(content) = %d, want 3", len(content))
+	}
+	if content[0].OfInputText == nil || content[0].OfInputText.Text != "what is this?" {
+		t.Error("expected text part first")
+	}
+	if content[1].OfInputImage == nil || content[1].OfInputImage.ImageURL.Or("") != "data:image/png;base64,aW1n" {
+		t.Error("expected image data URL second")
+	}
+	if content[2].OfInputFile == nil || content[2].OfInputFile.Filename.Or("") != "doc.pdf" {
+		t.Error("expected file part third")
+	}
+}
@@ -10,6 +10,7 @@ import (
 // FallbackChain orchestrates model fallback across multiple candidates.
 type FallbackChain struct {
 	cooldown *CooldownTracker
+	limiter  *RateLimiter
 }
 
 // FallbackCandidate represents one model/provider to try.
@@ -41,6 +42,11 @@ func NewFallbackChain(cooldown *CooldownTracker) *FallbackChain {
 	return &FallbackChain{cooldown: cooldown}
 }
 
+// SetRateLimiter makes Execute skip candidates whose RPM budget is exhausted.
+func (fc *FallbackChain) SetRateLimiter(limiter *RateLimiter) {
+	fc.limiter = limiter
+}
+
 // ResolveCandidates parses model config into a deduplicated candidate list.
 func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandidate {
 	return ResolveCandidatesWithLookup(cfg, defaultProvider, nil)
@@ -89,10 +95,13 @@ func ResolveCandidatesWithLookup(
 }
 
 // Execute runs the fallback chain for text/chat requests.
-// It tries each candidate in order, respecting cooldowns and error classification.
+// It tries each candidate in order, respecting cooldowns, RPM limits and error classification.
 //
 // Behavior:
 //   - Candidates in cooldown are skipped (logged as skipped attempt).
+//   - Candidates whose RPM budget is exhausted are skipped in favor of the next
+//     candidate; if no other candidate succeeds, the chain waits for the one
+//     that frees up first and tries it.
 //   - context.Canceled aborts immediately (user abort, no fallback).
 //   - Non-retriable errors (format) abort immediately.
 //   - Retriable errors trigger fallback to next candidate.
@@ -111,7 +120,12 @@ func (fc *FallbackChain) Execute(
 		Attempts: make([]FallbackAttempt, 0, len(candidates)),
 	}
 
-	for i, candidate := range candidates {
+	var (
+		saturated     *FallbackCandidate
+		saturatedWait time.Duration
+	)
+
+	for _, candidate := range candidates {
 		// Check context before each attempt.
 		if ctx.Err() == context.Canceled {
 			return nil, context.Canceled
@@ -134,60 +148,108 @@ func (fc *FallbackChain) Execute(
 			continue
 		}
 
-		// Execute the run function.
-		start := time.Now()
-		resp, err := run(ctx, candidate.Provider, candidate.Model)
-		elapsed := time.Since(start)
-
-		if err == nil {
-			// Success.
-			fc.cooldown.MarkSuccess(candidate.Provider)
-			result.Response = resp
-			result.Provider = candidate.Provider
-			result.Model = candidate.Model
-			return result, nil
-		}
-
-		// Context cancellation: abort immediately, no fallback.
-		if ctx.Err() == context.Canceled {
+		// Check the RPM budget. A saturated candidate is not called (which
+		// would only earn a 429 and a cooldown); remember the one that frees
+		// up first in case nothing else works.
+		if wait := fc.limiter.Delay(ModelKey(candidate.Provider, candidate.Model)); wait > 0 {
+			if saturated == nil || wait < saturatedWait {
+				c := candidate
+				saturated, saturatedWait = &c, wait
+			}
 			result.Attempts = append(result.Attempts, FallbackAttempt{
 				Provider: candidate.Provider,
 				Model:    candidate.Model,
-				Error:    err,
-				Duration: elapsed,
+				Skipped:  true,
+				Reason:   FailoverRateLimit,
+				Error: fmt.Errorf(
+					"model %s/%s at RPM limit (%s until next request)",
+					candidate.Provider,
+					candidate.Model,
+					wait.Round(time.Millisecond),
+				),
 			})
-			return nil, context.Canceled
+			continue
 		}
 
-		// Classify the error.
-		failErr := ClassifyError(err, candidate.Provider, candidate.Model)
-
-		if failErr == nil {
-			// Unclassifiable error: do not fallback, return immediately.
-			result.Attempts = append(result.Attempts, FallbackAttempt{
-				Provider: candidate.Provider,
-				Model:    candidate.Model,
-				Error:    err,
-				Duration: elapsed,
-			})
-			return nil, fmt.Errorf("fallback: unclassified error from %s/%s: %w",
-				candidate.Provider, candidate.Model, err)
+		if done, err := fc.attempt(ctx, candidate, run, result); done {
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
 		}
+	}
 
-		// Non-retriable error: abort immediately.
-		if !failErr.IsRetriable() {
-			result.Attempts = append(result.Attempts, FallbackAttempt{
-				Provider: candidate.Provider,
-				Model:    candidate.Model,
-				Error:    failErr,
-				Reason:   failErr.Reason,
-				Duration: elapsed,
-			})
-			return nil, failErr
+	// Every available candidate was rate limited or failed: wait for the
+	// saturated candidate with the shortest delay rather than giving up.
+	if saturated != nil {
+		timer := time.NewTimer(saturatedWait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if done, err := fc.attempt(ctx, *saturated, run, result); done {
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
 		}
+	}
+
+	return nil, &FallbackExhaustedError{Attempts: result.Attempts}
+}
 
-		// Retriable error: mark failure and continue to next candidate.
-		fc.cooldown.MarkFailure(candidate.Provider, failErr.Reason)
+// attempt calls run for one candidate and records the outcome in result.
+// done is false when the error is retriable and the next candidate should be
+// tried; otherwise the chain stops, with err set if it must abort.
+func (fc *FallbackChain) attempt(
+	ctx context.Context,
+	candidate FallbackCandidate,
+	run func(ctx context.Context, provider, model string) (*LLMResponse, error),
+	result *FallbackResult,
+) (done bool, err error) {
+	start := time.Now()
+	resp, err := run(ctx, candidate.Provider, candidate.Model)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		// Success.
+		fc.cooldown.MarkSuccess(candidate.Provider)
+		result.Response = resp
+		result.Provider = candidate.Provider
+		result.Model = candidate.Model
+		return true, nil
+	}
+
+	// Context cancellation: abort immediately, no fallback.
+	if ctx.Err() == context.Canceled {
+		result.Attempts = append(result.Attempts, FallbackAttempt{
+			Provider: candidate.Provider,
+			Model:    candidate.Model,
+			Error:    err,
+			Duration: elapsed,
+		})
+		return true, context.Canceled
+	}
+
+	// Classify the error.
+	failErr := ClassifyError(err, candidate.Provider, candidate.Model)
+
+	if failErr == nil {
+		// Unclassifiable error: do not fallback, return immediately.
+		result.Attempts = append(result.Attempts, FallbackAttempt{
+			Provider: candidate.Provider,
+			Model:    candidate.Model,
+			Error:    err,
+			Duration: elapsed,
+		})
+		return true, fmt.Errorf("fallback: unclassified error from %s/%s: %w",
+			candidate.Provider, candidate.Model, err)
+	}
+
+	// Non-retriable error: abort immediately.
+	if !failErr.IsRetriable() {
 		result.Attempts = append(result.Attempts, FallbackAttempt{
 			Provider: candidate.Provider,
 			Model:    candidate.Model,
@@ -195,15 +257,19 @@ func (fc *FallbackChain) Execute(
 			Reason:   failErr.Reason,
 			Duration: elapsed,
 		})
-
-		// If this was the last candidate, return aggregate error.
-		if i == len(candidates)-1 {
-			return nil, &FallbackExhaustedError{Attempts: result.Attempts}
-		}
+		return true, failErr
 	}
 
-	// All candidates were skipped (all in cooldown).
-	return nil, &FallbackExhaustedError{Attempts: result.Attempts}
+	// Retriable error: mark failure and let the caller move on.
+	fc.cooldown.MarkFailure(candidate.Provider, failErr.Reason)
+	result.Attempts = append(result.Attempts, FallbackAttempt{
+		Provider: candidate.Provider,
+		Model:    candidate.Model,
+		Error:    failErr,
+		Reason:   failErr.Reason,
+		Duration: elapsed,
+	})
+	return false, nil
 }
 
 // ExecuteImage runs the fallback chain for image/vision requests.
@@ -292,7 +358,11 @@ func (e *FallbackExhaustedError) Error() string {
 	sb.WriteString(fmt.Sprintf("fallback: all %d candidates failed:", len(e.Attempts)))
 	for i, a := range e.Attempts {
 		if a.Skipped {
-			sb.WriteString(fmt.Sprintf("\n  [%d] %s/%s: skipped (cooldown)", i+1, a.Provider, a.Model))
+			reason := "cooldown"
+			if a.Error != nil {
+				reason = a.Error.Error()
+			}
+			sb.WriteString(fmt.Sprintf("\n  [%d] %s/%s: skipped (%s)", i+1, a.Provider, a.Model, reason))
 		} else {
 			sb.WriteString(fmt.Sprintf("\n  [%d] %s/%s: %v (reason=%s, %s)",
 				i+1, a.Provider, a.Model, a.Error, a.Reason, a.Duration.Round(time.Millisecond)))
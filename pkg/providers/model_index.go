@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ModelIndex maps the model strings seen at call time back to model_list
+// entries. Providers are called with either a model_name, a
+// "protocol/model" reference or the bare model ID; all three resolve to the
+// first entry that declares them.
+type ModelIndex struct {
+	byName  map[string]*config.ModelConfig
+	aliases map[string]string // ModelKey or lower-cased model ID -> model_name
+}
+
+// NewModelIndex indexes the given model_list entries.
+func NewModelIndex(models []config.ModelConfig) *ModelIndex {
+	idx := &ModelIndex{
+		byName:  make(map[string]*config.ModelConfig),
+		aliases: make(map[string]string),
+	}
+	for i := range models {
+		mc := &models[i]
+		if mc.ModelName == "" {
+			continue
+		}
+		if _, ok := idx.byName[mc.ModelName]; !ok {
+			idx.byName[mc.ModelName] = mc
+		}
+
+		full := strings.TrimSpace(mc.Model)
+		if full == "" {
+			continue
+		}
+		if !strings.Contains(full, "/") {
+			full = "openai/" + full
+		}
+		ref := ParseModelRef(full, "")
+		if ref == nil {
+			continue
+		}
+		for _, alias := range []string{ModelKey(ref.Provider, ref.Model), strings.ToLower(ref.Model)} {
+			if _, exists := idx.aliases[alias]; !exists {
+				idx.aliases[alias] = mc.ModelName
+			}
+		}
+	}
+	return idx
+}
+
+// Name returns the model_name for model, or false when it is not in model_list.
+func (idx *ModelIndex) Name(model string) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+	model = strings.TrimSpace(model)
+	if _, ok := idx.byName[model]; ok {
+		return model, true
+	}
+	key := strings.ToLower(model)
+	if strings.Contains(model, "/") {
+		if ref := ParseModelRef(model, ""); ref != nil {
+			key = ModelKey(ref.Provider, ref.Model)
+		}
+	}
+	name, ok := idx.aliases[key]
+	return name, ok
+}
+
+// Lookup returns the first model_list entry for model.
+func (idx *ModelIndex) Lookup(model string) (*config.ModelConfig, bool) {
+	name, ok := idx.Name(model)
+	if !ok {
+		return nil, false
+	}
+	return idx.byName[name], true
+}
@@ -1,6 +1,7 @@
 package openai_compat
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -26,6 +27,7 @@ type (
 	ExtraContent           = protocoltypes.ExtraContent
 	GoogleExtra            = protocoltypes.GoogleExtra
 	ReasoningDetail        = protocoltypes.ReasoningDetail
+	ContentPart            = protocoltypes.ContentPart
 )
 
 type Provider struct {
@@ -108,6 +110,69 @@ func (p *Provider) Chat(
 	model string,
 	options map[string]any,
 ) (*LLMResponse, error) {
+	req, err := p.newChatRequest(ctx, messages, tools, model, options, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+
+	return parseResponse(body)
+}
+
+// ChatStream is like Chat but requests a server-sent event stream and invokes
+// onDelta with each content delta as it arrives. The returned response is the
+// fully accumulated result, including any tool calls.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	req, err := p.newChatRequest(ctx, messages, tools, model, options, true)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+
+	return parseStream(resp.Body, onDelta)
+}
+
+// newChatRequest builds the /chat/completions request shared by Chat and ChatStream.
+func (p *Provider) newChatRequest(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	stream bool,
+) (*http.Request, error) {
 	if p.apiBase == "" {
 		return nil, fmt.Errorf("API base not configured")
 	}
@@ -119,6 +184,11 @@ func (p *Provider) Chat(
 		"messages": stripSystemParts(messages),
 	}
 
+	if stream {
+		requestBody["stream"] = true
+		requestBody["stream_options"] = map[string]any{"include_usage": true}
+	}
+
 	if len(tools) > 0 {
 		requestBody["tools"] = tools
 		requestBody["tool_choice"] = "auto"
@@ -177,22 +247,7 @@ func (p *Provider) Chat(
 		req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
 
-	resp, err := p.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
-	}
-
-	return parseResponse(body)
+	return req, nil
 }
 
 func parseResponse(body []byte) (*LLMResponse, error) {
@@ -203,19 +258,7 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 				ReasoningContent string            `json:"reasoning_content"`
 				Reasoning        string            `json:"reasoning"`
 				ReasoningDetails []ReasoningDetail `json:"reasoning_details"`
-				ToolCalls        []struct {
-					ID       string `json:"id"`
-					Type     string `json:"type"`
-					Function *struct {
-						Name      string `json:"name"`
-						Arguments string `json:"arguments"`
-					} `json:"function"`
-					ExtraContent *struct {
-						Google *struct {
-							ThoughtSignature string `json:"thought_signature"`
-						} `json:"google"`
-					} `json:"extra_content"`
-				} `json:"tool_calls"`
+				ToolCalls        []apiToolCall     `json:"tool_calls"`
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
@@ -234,8 +277,36 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 	}
 
 	choice := apiResponse.Choices[0]
-	toolCalls := make([]ToolCall, 0, len(choice.Message.ToolCalls))
-	for _, tc := range choice.Message.ToolCalls {
+
+	return &LLMResponse{
+		Content:          choice.Message.Content,
+		ReasoningContent: choice.Message.ReasoningContent,
+		Reasoning:        choice.Message.Reasoning,
+		ReasoningDetails: choice.Message.ReasoningDetails,
+		ToolCalls:        convertToolCalls(choice.Message.ToolCalls),
+		FinishReason:     choice.FinishReason,
+		Usage:            apiResponse.Usage,
+	}, nil
+}
+
+// apiToolCall is the wire-format tool call returned by OpenAI-compatible APIs.
+type apiToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function *struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+	ExtraContent *struct {
+		Google *struct {
+			ThoughtSignature string `json:"thought_signature"`
+		} `json:"google"`
+	} `json:"extra_content"`
+}
+
+func convertToolCalls(apiToolCalls []apiToolCall) []ToolCall {
+	toolCalls := make([]ToolCall, 0, len(apiToolCalls))
+	for _, tc := range apiToolCalls {
 		arguments := make(map[string]any)
 		name := ""
 
@@ -273,24 +344,149 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 
 		toolCalls = append(toolCalls, toolCall)
 	}
+	return toolCalls
+}
+
+// parseStream consumes a chat completions SSE stream, calling onDelta for every
+// content fragment and accumulating the complete response.
+func parseStream(body io.Reader, onDelta func(delta string)) (*LLMResponse, error) {
+	var (
+		content          strings.Builder
+		reasoningContent strings.Builder
+		reasoning        strings.Builder
+		reasoningDetails []ReasoningDetail
+		toolCalls        []apiToolCall
+		finishReason     string
+		usage            *UsageInfo
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content          string            `json:"content"`
+					ReasoningContent string            `json:"reasoning_content"`
+					Reasoning        string            `json:"reasoning"`
+					ReasoningDetails []ReasoningDetail `json:"reasoning_details"`
+					ToolCalls        []struct {
+						Index int `json:"index"`
+						apiToolCall
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *UsageInfo `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+
+		delta := choice.Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if onDelta != nil {
+				onDelta(delta.Content)
+			}
+		}
+		reasoningContent.WriteString(delta.ReasoningContent)
+		reasoning.WriteString(delta.Reasoning)
+		reasoningDetails = append(reasoningDetails, delta.ReasoningDetails...)
+
+		for _, tc := range delta.ToolCalls {
+			if tc.Index < 0 || tc.Index >= len(toolCalls)+maxToolCallIndexGap {
+				return nil, fmt.Errorf("invalid tool call index %d in stream chunk", tc.Index)
+			}
+			for len(toolCalls) <= tc.Index {
+				toolCalls = append(toolCalls, apiToolCall{})
+			}
+			mergeToolCallDelta(&toolCalls[tc.Index], tc.apiToolCall)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if finishReason == "" {
+		finishReason = "stop"
+	}
 
 	return &LLMResponse{
-		Content:          choice.Message.Content,
-		ReasoningContent: choice.Message.ReasoningContent,
-		Reasoning:        choice.Message.Reasoning,
-		ReasoningDetails: choice.Message.ReasoningDetails,
-		ToolCalls:        toolCalls,
-		FinishReason:     choice.FinishReason,
-		Usage:            apiResponse.Usage,
+		Content:          content.String(),
+		ReasoningContent: reasoningContent.String(),
+		Reasoning:        reasoning.String(),
+		ReasoningDetails: reasoningDetails,
+		ToolCalls:        convertToolCalls(toolCalls),
+		FinishReason:     finishReason,
+		Usage:            usage,
 	}, nil
 }
 
+// maxToolCallIndexGap bounds how far past the tool calls seen so far a
+// streamed tool call index may point, so a bogus index cannot force a huge
+// allocation.
+const maxToolCallIndexGap = 16
+
+// mergeToolCallDelta folds a streamed tool call fragment into dst. IDs, names
+// and extra content arrive once; arguments arrive as concatenated pieces.
+func mergeToolCallDelta(dst *apiToolCall, delta apiToolCall) {
+	if delta.ID != "" {
+		dst.ID = delta.ID
+	}
+	if delta.Type != "" {
+		dst.Type = delta.Type
+	}
+	if delta.ExtraContent != nil {
+		dst.ExtraContent = delta.ExtraContent
+	}
+	if delta.Function == nil {
+		return
+	}
+	if dst.Function == nil {
+		dst.Function = &struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		}{}
+	}
+	if delta.Function.Name != "" {
+		dst.Function.Name = delta.Function.Name
+	}
+	dst.Function.Arguments += delta.Function.Arguments
+}
+
 // openaiMessage is the wire-format message for OpenAI-compatible APIs.
 // It mirrors protocoltypes.Message but omits SystemParts, which is an
 // internal field that would be unknown to third-party endpoints.
 type openaiMessage struct {
 	Role       string     `json:"role"`
-	Content    string     `json:"content"`
+	Content    any        `json:"content"` // string, or []map[string]any when the message has attachments
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
@@ -298,6 +494,8 @@ type openaiMessage struct {
 // stripSystemParts converts []Message to []openaiMessage, dropping the
 // SystemParts field so it doesn't leak into the JSON payload sent to
 // OpenAI-compatible APIs (some strict endpoints reject unknown fields).
+// Messages carrying content parts are expanded into the multimodal
+// content array format.
 func stripSystemParts(messages []Message) []openaiMessage {
 	out := make([]openaiMessage, len(messages))
 	for i, m := range messages {
@@ -307,10 +505,71 @@ func stripSystemParts(messages []Message) []openaiMessage {
 			ToolCalls:  m.ToolCalls,
 			ToolCallID: m.ToolCallID,
 		}
+		if len(m.Parts) > 0 {
+			out[i].Content = buildContentParts(m.Content, m.Parts)
+		}
 	}
 	return out
 }
 
+// buildContentParts renders text plus attachments as an OpenAI content array.
+func buildContentParts(text string, parts []ContentPart) []map[string]any {
+	content := make([]map[string]any, 0, len(parts)+1)
+	if text != "" {
+		content = append(content, map[string]any{"type": "text", "text": text})
+	}
+	for _, part := range parts {
+		dataURL := "data:" + part.MIMEType + ";base64," + part.Data
+		switch part.Type {
+		case "image":
+			content = append(content, map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]any{"url": dataURL},
+			})
+		case "audio":
+			format, ok := audioFormat(part.MIMEType)
+			if !ok {
+				// input_audio only accepts wav and mp3; anything else would
+				// fail the whole request.
+				content = append(content, map[string]any{
+					"type": "text",
+					"text": fmt.Sprintf("[audio attachment %s (%s) omitted: only wav and mp3 audio can be sent]",
+						part.Filename, part.MIMEType),
+				})
+				continue
+			}
+			content = append(content, map[string]any{
+				"type": "input_audio",
+				"input_audio": map[string]any{
+					"data":   part.Data,
+					"format": format,
+				},
+			})
+		default:
+			content = append(content, map[string]any{
+				"type": "file",
+				"file": map[string]any{
+					"filename":  part.Filename,
+					"file_data": dataURL,
+				},
+			})
+		}
+	}
+	return content
+}
+
+// audioFormat maps an audio MIME type to the input_audio format name. ok is
+// false for formats input_audio does not accept.
+func audioFormat(mimeType string) (format string, ok bool) {
+	switch mimeType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3", true
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav", true
+	}
+	return "", false
+}
+
 func normalizeModel(model, apiBase string) string {
 	idx := strings.Index(model, "/")
 	if idx == -1 {
@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ProviderPool hands out one provider per model_list entry. Each provider is
+// created with CreateProviderFromConfig the first time a call needs it and
+// reused afterwards, so models on different protocols or endpoints each use
+// their own API base, key and proxy. Entries sharing a model_name (load
+// balancing) are used in turn.
+type ProviderPool struct {
+	cfg   *config.Config
+	index *ModelIndex
+	wrap  func(LLMProvider) LLMProvider
+
+	mu        sync.Mutex
+	entries   map[string][]int // model_name -> model_list indexes
+	next      map[string]int   // model_name -> next entry to use
+	providers map[int]LLMProvider
+	failed    map[int]bool
+}
+
+// NewProviderPool creates a pool over cfg's model_list. wrap, if not nil, is
+// applied to every provider the pool creates (e.g. to add rate limiting).
+func NewProviderPool(cfg *config.Config, wrap func(LLMProvider) LLMProvider) *ProviderPool {
+	pp := &ProviderPool{
+		cfg:       cfg,
+		index:     NewModelIndex(cfg.ModelList),
+		wrap:      wrap,
+		entries:   make(map[string][]int),
+		next:      make(map[string]int),
+		providers: make(map[int]LLMProvider),
+		failed:    make(map[int]bool),
+	}
+	for i, mc := range cfg.ModelList {
+		if mc.ModelName != "" {
+			pp.entries[mc.ModelName] = append(pp.entries[mc.ModelName], i)
+		}
+	}
+	return pp
+}
+
+// Set makes p the provider of the model_list entry declaring model, which may
+// be a model_name, a "protocol/model" reference or a bare model ID. With
+// several entries of that name, p serves the first. p is used as is, without
+// wrap. It reports false when model has no entry.
+func (pp *ProviderPool) Set(model string, p LLMProvider) bool {
+	name, ok := pp.index.Name(model)
+	if !ok {
+		return false
+	}
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	i := pp.entries[name][0]
+	pp.providers[i] = p
+	delete(pp.failed, i)
+	return true
+}
+
+// Get returns the provider of a model_list entry declaring model and the
+// model ID to send it. It reports false when model has no entry or no
+// provider can be created for it; creation errors are logged once.
+func (pp *ProviderPool) Get(model string) (LLMProvider, string, bool) {
+	if pp == nil {
+		return nil, "", false
+	}
+	name, ok := pp.index.Name(model)
+	if !ok {
+		return nil, "", false
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	entries := pp.entries[name]
+	start := pp.next[name]
+	pp.next[name] = (start + 1) % len(entries)
+	for n := range entries {
+		i := entries[(start+n)%len(entries)]
+		if p := pp.provider(i); p != nil {
+			_, modelID := ExtractProtocol(pp.cfg.ModelList[i].Model)
+			return p, modelID, true
+		}
+	}
+	return nil, "", false
+}
+
+// ForCandidate is Get for a fallback candidate.
+func (pp *ProviderPool) ForCandidate(c FallbackCandidate) (LLMProvider, string, bool) {
+	return pp.Get(ModelKey(c.Provider, c.Model))
+}
+
+// provider returns the provider of model_list entry i, creating it if
+// needed, or nil if it can't be created. Must be called with pp.mu held.
+func (pp *ProviderPool) provider(i int) LLMProvider {
+	if p, ok := pp.providers[i]; ok {
+		return p
+	}
+	if pp.failed[i] {
+		return nil
+	}
+
+	entry := pp.cfg.ModelList[i]
+	if entry.Workspace == "" {
+		entry.Workspace = pp.cfg.WorkspacePath()
+	}
+	p, _, err := CreateProviderFromConfig(&entry)
+	if err != nil {
+		pp.failed[i] = true
+		logger.WarnCF("providers", "Failed to create provider for model", map[string]any{
+			"model": entry.ModelName,
+			"error": err.Error(),
+		})
+		return nil
+	}
+	if pp.wrap != nil {
+		p = pp.wrap(p)
+	}
+	pp.providers[i] = p
+	return p
+}
@@ -0,0 +1,72 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProviderPool_CreatesProvidersLazily(t *testing.T) {
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "main", Model: "openai/gpt-4o", APIKey: "k"},
+		{ModelName: "local", Model: "ollama/qwen", APIBase: "http://localhost:11434/v1"},
+		{ModelName: "broken", Model: "openai/missing-key"},
+	}}
+	wrapped := 0
+	pool := NewProviderPool(cfg, func(p LLMProvider) LLMProvider {
+		wrapped++
+		return p
+	})
+
+	seeded := &countingProvider{}
+	if !pool.Set("gpt-4o", seeded) {
+		t.Fatal("expected bare model ID to resolve to the main entry")
+	}
+	if p, modelID, ok := pool.Get("main"); !ok || p != seeded || modelID != "gpt-4o" {
+		t.Fatalf("expected seeded provider for main, got %v %q %v", p, modelID, ok)
+	}
+	if wrapped != 0 {
+		t.Fatalf("expected seeded provider not to be wrapped, wrapped %d", wrapped)
+	}
+
+	local, modelID, ok := pool.ForCandidate(FallbackCandidate{Provider: "ollama", Model: "qwen"})
+	if !ok || modelID != "qwen" {
+		t.Fatalf("expected local provider for ollama/qwen, got %v %q %v", local, modelID, ok)
+	}
+	if _, isHTTP := local.(*HTTPProvider); !isHTTP {
+		t.Errorf("expected an HTTP provider for the ollama entry, got %T", local)
+	}
+	if again, _, _ := pool.Get("local"); again != local {
+		t.Error("expected the local provider to be reused")
+	}
+	if wrapped != 1 {
+		t.Errorf("expected one created provider to be wrapped, wrapped %d", wrapped)
+	}
+
+	if _, _, ok := pool.Get("broken"); ok {
+		t.Error("expected an entry without credentials to have no provider")
+	}
+	if _, _, ok := pool.Get("unknown"); ok {
+		t.Error("expected a model without an entry to have no provider")
+	}
+
+	var nilPool *ProviderPool
+	if _, _, ok := nilPool.Get("main"); ok {
+		t.Error("expected a nil pool to have no providers")
+	}
+}
+
+func TestProviderPool_RotatesEntriesOfAModelName(t *testing.T) {
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt-4o", APIBase: "https://api1.example.com/v1"},
+		{ModelName: "gpt", Model: "openai/gpt-4o", APIBase: "https://api2.example.com/v1"},
+	}}
+	pool := NewProviderPool(cfg, nil)
+
+	first, _, _ := pool.Get("gpt")
+	second, _, _ := pool.Get("gpt")
+	third, _, _ := pool.Get("gpt")
+	if first == nil || first == second || first != third {
+		t.Errorf("expected the two endpoints to be used in turn, got %p %p %p", first, second, third)
+	}
+}
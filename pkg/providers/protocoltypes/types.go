@@ -62,11 +62,22 @@ type ContentBlock struct {
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
+// ContentPart is a binary attachment carried alongside the text of a user
+// message, such as an image, an audio clip or a document. Each adapter maps
+// it to its own multimodal wire format.
+type ContentPart struct {
+	Type     string `json:"type"` // "image", "audio" or "file"
+	MIMEType string `json:"mime_type,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Data     string `json:"data"` // base64-encoded content
+}
+
 type Message struct {
 	Role             string         `json:"role"`
 	Content          string         `json:"content"`
 	ReasoningContent string         `json:"reasoning_content,omitempty"`
 	SystemParts      []ContentBlock `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
+	Parts            []ContentPart  `json:"parts,omitempty"`        // multimodal attachments on user messages
 	ToolCalls        []ToolCall     `json:"tool_calls,omitempty"`
 	ToolCallID       string         `json:"tool_call_id,omitempty"`
 }
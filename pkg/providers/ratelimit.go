@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// RateLimiter enforces the per-model requests-per-minute limits declared by
+// model_list entries (the "rpm" field). Each model_name gets one token bucket
+// that refills at rpm/60 per second and holds up to rpm tokens; entries sharing
+// a model_name (load balancing) share a bucket whose capacity is the sum of
+// their limits. Models without an rpm are never limited.
+//
+// Calls may name a model by model_name, by "protocol/model" or by the bare
+// model ID sent to the provider; see ModelIndex.
+type RateLimiter struct {
+	buckets map[string]*rate.Limiter // model_name -> bucket
+	index   *ModelIndex
+}
+
+// NewRateLimiter builds a limiter from model_list entries.
+func NewRateLimiter(models []config.ModelConfig) *RateLimiter {
+	rl := &RateLimiter{
+		buckets: make(map[string]*rate.Limiter),
+		index:   NewModelIndex(models),
+	}
+
+	rpm := make(map[string]int)
+	var order []string
+	for _, mc := range models {
+		if mc.RPM <= 0 || mc.ModelName == "" {
+			continue
+		}
+		if _, ok := rpm[mc.ModelName]; !ok {
+			order = append(order, mc.ModelName)
+		}
+		rpm[mc.ModelName] += mc.RPM
+	}
+	for _, name := range order {
+		rl.buckets[name] = rate.NewLimiter(rate.Limit(float64(rpm[name])/60), rpm[name])
+	}
+	return rl
+}
+
+// Enabled reports whether any model has a limit.
+func (rl *RateLimiter) Enabled() bool {
+	return rl != nil && len(rl.buckets) > 0
+}
+
+// bucket returns the token bucket for model, or nil when it is unlimited.
+func (rl *RateLimiter) bucket(model string) *rate.Limiter {
+	if !rl.Enabled() {
+		return nil
+	}
+	if name, ok := rl.index.Name(model); ok {
+		return rl.buckets[name]
+	}
+	return nil
+}
+
+// Wait blocks until a request to model is allowed or ctx is done.
+func (rl *RateLimiter) Wait(ctx context.Context, model string) error {
+	b := rl.bucket(model)
+	if b == nil {
+		return nil
+	}
+	return b.Wait(ctx)
+}
+
+// Delay returns how long a request to model would have to wait right now,
+// without consuming a token. Zero means the model is not saturated.
+func (rl *RateLimiter) Delay(model string) time.Duration {
+	b := rl.bucket(model)
+	if b == nil {
+		return 0
+	}
+
+	now := time.Now()
+	r := b.ReserveN(now, 1)
+	if !r.OK() {
+		return 0
+	}
+	d := r.DelayFrom(now)
+	r.CancelAt(now)
+	return d
+}
+
+// rateLimitedProvider waits for the model's RPM budget before every call.
+type rateLimitedProvider struct {
+	LLMProvider
+	limiter *RateLimiter
+}
+
+// WithRateLimit wraps provider so that Chat and ChatStream wait for the
+// requested model's RPM budget. It returns provider unchanged when no model
+// has a limit.
+func WithRateLimit(provider LLMProvider, limiter *RateLimiter) LLMProvider {
+	if provider == nil || !limiter.Enabled() {
+		return provider
+	}
+	if _, ok := provider.(*rateLimitedProvider); ok {
+		return provider
+	}
+	return &rateLimitedProvider{LLMProvider: provider, limiter: limiter}
+}
+
+func (p *rateLimitedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if err := p.limiter.Wait(ctx, model); err != nil {
+		return nil, err
+	}
+	return p.LLMProvider.Chat(ctx, messages, tools, model, options)
+}
+
+// ChatStream streams when the wrapped provider supports it and falls back to
+// a plain Chat otherwise.
+func (p *rateLimitedProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	if err := p.limiter.Wait(ctx, model); err != nil {
+		return nil, err
+	}
+	if sp, ok := p.LLMProvider.(StreamingProvider); ok {
+		return sp.ChatStream(ctx, messages, tools, model, options, onDelta)
+	}
+	return p.LLMProvider.Chat(ctx, messages, tools, model, options)
+}
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRateLimiter_ResolvesAliases(t *testing.T) {
+	rl := NewRateLimiter([]config.ModelConfig{
+		{ModelName: "fast", Model: "groq/llama-3.3", RPM: 60},
+		{ModelName: "fast", Model: "groq/llama-3.3", RPM: 60},
+		{ModelName: "free", Model: "openai/gpt-4o"},
+	})
+
+	if !rl.Enabled() {
+		t.Fatal("expected limiter to be enabled")
+	}
+	for _, model := range []string{"fast", "groq/llama-3.3", "llama-3.3"} {
+		if rl.bucket(model) == nil {
+			t.Errorf("expected %q to resolve to the fast bucket", model)
+		}
+	}
+	if rl.bucket("free") != nil || rl.bucket("gpt-4o") != nil {
+		t.Error("expected models without rpm to be unlimited")
+	}
+	if got := rl.bucket("fast").Burst(); got != 120 {
+		t.Errorf("expected shared model_name limits to add up, burst = %d", got)
+	}
+}
+
+func TestRateLimiter_DelayDoesNotConsume(t *testing.T) {
+	rl := NewRateLimiter([]config.ModelConfig{{ModelName: "m", Model: "openai/m", RPM: 1}})
+
+	if d := rl.Delay("m"); d != 0 {
+		t.Fatalf("expected fresh bucket to be available, delay = %s", d)
+	}
+	if d := rl.Delay("m"); d != 0 {
+		t.Fatalf("expected Delay to leave the token in place, delay = %s", d)
+	}
+	if err := rl.Wait(context.Background(), "m"); err != nil {
+		t.Fatal(err)
+	}
+	if d := rl.Delay("m"); d <= 0 {
+		t.Fatal("expected bucket to be saturated after one request")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx, "m"); err == nil {
+		t.Fatal("expected Wait to give up when the deadline is shorter than the delay")
+	}
+}
+
+type countingProvider struct{ models []string }
+
+func (p *countingProvider) Chat(
+	_ context.Context,
+	_ []Message,
+	_ []ToolDefinition,
+	model string,
+	_ map[string]any,
+) (*LLMResponse, error) {
+	p.models = append(p.models, model)
+	return &LLMResponse{Content: "ok"}, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string { return "m" }
+
+func TestWithRateLimit(t *testing.T) {
+	p := &countingProvider{}
+	if got := WithRateLimit(p, NewRateLimiter(nil)); got != LLMProvider(p) {
+		t.Fatal("expected provider to be returned unchanged without limits")
+	}
+
+	rl := NewRateLimiter([]config.ModelConfig{{ModelName: "m", Model: "openai/m-1", RPM: 1}})
+	limited := WithRateLimit(p, rl)
+	if _, err := limited.Chat(context.Background(), nil, nil, "m-1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limited.Chat(ctx, nil, nil, "m-1", nil); err == nil {
+		t.Fatal("expected second call within the minute to be held back")
+	}
+	if len(p.models) != 1 {
+		t.Errorf("expected one call to reach the provider, got %d", len(p.models))
+	}
+}
+
+func TestFallback_SkipsSaturatedCandidate(t *testing.T) {
+	rl := NewRateLimiter([]config.ModelConfig{{ModelName: "primary", Model: "openai/gpt-4", RPM: 1}})
+	if err := rl.Wait(context.Background(), "primary"); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := NewFallbackChain(NewCooldownTracker())
+	fc.SetRateLimiter(rl)
+
+	var called []string
+	result, err := fc.Execute(context.Background(),
+		[]FallbackCandidate{makeCandidate("openai", "gpt-4"), makeCandidate("anthropic", "claude")},
+		func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+			called = append(called, model)
+			return &LLMResponse{Content: "ok"}, nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(called) != 1 || called[0] != "claude" || result.Model != "claude" {
+		t.Fatalf("expected saturated candidate to be skipped, called %v", called)
+	}
+	if len(result.Attempts) != 1 || !result.Attempts[0].Skipped || result.Attempts[0].Reason != FailoverRateLimit {
+		t.Errorf("expected a skipped rate-limit attempt, got %+v", result.Attempts)
+	}
+	if !fc.cooldown.IsAvailable("openai") {
+		t.Error("expected skipping a saturated candidate not to put it in cooldown")
+	}
+}
+
+func TestFallback_WaitsWhenAllSaturated(t *testing.T) {
+	// 600 RPM refills one request every 100ms.
+	rl := NewRateLimiter([]config.ModelConfig{{ModelName: "only", Model: "openai/gpt-4", RPM: 600}})
+	for rl.Delay("only") == 0 {
+		if err := rl.Wait(context.Background(), "only"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fc := NewFallbackChain(NewCooldownTracker())
+	fc.SetRateLimiter(rl)
+
+	start := time.Now()
+	result, err := fc.Execute(context.Background(),
+		[]FallbackCandidate{makeCandidate("openai", "gpt-4")}, successRun("late"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Response.Content != "late" {
+		t.Errorf("content = %q, want late", result.Response.Content)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("expected the chain to wait for the RPM budget")
+	}
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// usageProvider records the token usage of every successful call in a
+// usage.Tracker, billed to the agent and session found on the context.
+type usageProvider struct {
+	LLMProvider
+	tracker *usage.Tracker
+	index   *ModelIndex
+}
+
+// WithUsage wraps provider so that each response's Usage is recorded in
+// tracker under its model_name, priced with the model_list input/output
+// prices. It returns provider unchanged when tracker is nil.
+func WithUsage(provider LLMProvider, tracker *usage.Tracker, models []config.ModelConfig) LLMProvider {
+	if provider == nil || tracker == nil {
+		return provider
+	}
+	if _, ok := provider.(*usageProvider); ok {
+		return provider
+	}
+	return &usageProvider{LLMProvider: provider, tracker: tracker, index: NewModelIndex(models)}
+}
+
+// ModelPrice returns the configured price of model, or zero when unknown.
+func (idx *ModelIndex) ModelPrice(model string) usage.Price {
+	mc, ok := idx.Lookup(model)
+	if !ok {
+		return usage.Price{}
+	}
+	return usage.Price{Input: mc.InputPrice, Output: mc.OutputPrice}
+}
+
+func (p *usageProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	resp, err := p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	p.record(ctx, model, resp, err)
+	return resp, err
+}
+
+func (p *usageProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	var (
+		resp *LLMResponse
+		err  error
+	)
+	if sp, ok := p.LLMProvider.(StreamingProvider); ok {
+		resp, err = sp.ChatStream(ctx, messages, tools, model, options, onDelta)
+	} else {
+		resp, err = p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	}
+	p.record(ctx, model, resp, err)
+	return resp, err
+}
+
+func (p *usageProvider) record(ctx context.Context, model string, resp *LLMResponse, err error) {
+	if err != nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	name, ok := p.index.Name(model)
+	if !ok {
+		name = model
+	}
+	agentID, sessionKey := usage.ScopeFromContext(ctx)
+	u := resp.Usage
+	p.tracker.Record(usage.Entry{
+		AgentID:          agentID,
+		SessionKey:       sessionKey,
+		Model:            name,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CostUSD:          p.index.ModelPrice(model).Cost(u.PromptTokens, u.CompletionTokens),
+	})
+}
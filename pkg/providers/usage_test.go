@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+type usageReportingProvider struct{ usage *UsageInfo }
+
+func (p *usageReportingProvider) Chat(
+	_ context.Context,
+	_ []Message,
+	_ []ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*LLMResponse, error) {
+	return &LLMResponse{Content: "ok", Usage: p.usage}, nil
+}
+
+func (p *usageReportingProvider) GetDefaultModel() string { return "" }
+
+func TestWithUsage_RecordsUnderModelName(t *testing.T) {
+	tracker := usage.NewTracker(t.TempDir())
+	models := []config.ModelConfig{{
+		ModelName:   "sonnet",
+		Model:       "anthropic/claude-sonnet-4.6",
+		InputPrice:  3,
+		OutputPrice: 15,
+	}}
+	p := WithUsage(&usageReportingProvider{
+		usage: &UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 100_000, TotalTokens: 1_100_000},
+	}, tracker, models)
+
+	ctx := usage.WithScope(context.Background(), "main", "cli:direct")
+	if _, err := p.Chat(ctx, nil, nil, "claude-sonnet-4.6", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := tracker.Summary(usage.PeriodToday)
+	got, ok := s.Models["sonnet"]
+	if !ok {
+		t.Fatalf("expected usage under model_name, got %+v", s.Models)
+	}
+	if math.Abs(got.CostUSD-4.5) > 1e-9 {
+		t.Errorf("cost = %f, want 4.5", got.CostUSD)
+	}
+	if s.Agents["main"].Requests != 1 || s.Sessions["cli:direct"].Requests != 1 {
+		t.Errorf("expected usage billed to the context scope, got %+v", s)
+	}
+}
+
+func TestWithUsage_IgnoresMissingUsage(t *testing.T) {
+	tracker := usage.NewTracker(t.TempDir())
+	p := WithUsage(&usageReportingProvider{}, tracker, nil)
+
+	if _, err := p.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := tracker.Summary(usage.PeriodToday); s.Total.Requests != 0 {
+		t.Errorf("expected nothing recorded, got %+v", s.Total)
+	}
+}
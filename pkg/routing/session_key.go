@@ -42,6 +42,20 @@ func BuildAgentMainSessionKey(agentID string) string {
 	return fmt.Sprintf("agent:%s:%s", NormalizeAgentID(agentID), DefaultMainKey)
 }
 
+// BuildAgentDelegationSessionKey returns
+// "agent:<agentId>:delegate:<parentAgentId>:<taskId>", the session of a task
+// delegated to agentId.
+func BuildAgentDelegationSessionKey(agentID, parentAgentID, taskID string) string {
+	return fmt.Sprintf("agent:%s:delegate:%s:%s",
+		NormalizeAgentID(agentID), NormalizeAgentID(parentAgentID), strings.ToLower(taskID))
+}
+
+// BuildAgentTriggerSessionKey returns "agent:<agentId>:trigger:<ruleId>", the
+// session in which agentId handles the fires of a trigger rule.
+func BuildAgentTriggerSessionKey(agentID, ruleID string) string {
+	return fmt.Sprintf("agent:%s:trigger:%s", NormalizeAgentID(agentID), strings.ToLower(ruleID))
+}
+
 // BuildAgentPeerSessionKey constructs a session key based on agent, channel, peer, and DM scope.
 func BuildAgentPeerSessionKey(params SessionKeyParams) string {
 	agentID := NormalizeAgentID(params.AgentID)
@@ -18,6 +18,22 @@ func TestBuildAgentMainSessionKey_Normalizes(t *testing.T) {
 	}
 }
 
+func TestBuildAgentDelegationSessionKey(t *testing.T) {
+	got := BuildAgentDelegationSessionKey("Coder", "main", "subagent-3")
+	want := "agent:coder:delegate:main:subagent-3"
+	if got != want {
+		t.Errorf("BuildAgentDelegationSessionKey = %q, want %q", got, want)
+	}
+}
+
+func TestBuildAgentTriggerSessionKey(t *testing.T) {
+	got := BuildAgentTriggerSessionKey("Ops", "A1B2")
+	want := "agent:ops:trigger:a1b2"
+	if got != want {
+		t.Errorf("BuildAgentTriggerSessionKey = %q, want %q", got, want)
+	}
+}
+
 func TestBuildAgentPeerSessionKey_DMScopeMain(t *testing.T) {
 	got := BuildAgentPeerSessionKey(SessionKeyParams{
 		AgentID: "main",
@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// containerSandbox runs each command in a fresh rootless container through
+// the podman or docker CLI.
+type containerSandbox struct {
+	opts    Options
+	runtime string
+}
+
+func newContainer(opts Options) (Sandbox, error) {
+	rt := opts.Runtime
+	if rt == "" {
+		for _, candidate := range []string{"podman", "docker"} {
+			if _, err := exec.LookPath(candidate); err == nil {
+				rt = candidate
+				break
+			}
+		}
+		if rt == "" {
+			return nil, fmt.Errorf("sandbox: no container runtime found (install podman or docker, or set runtime)")
+		}
+	}
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	return &containerSandbox{opts: opts, runtime: rt}, nil
+}
+
+func (s *containerSandbox) Name() string {
+	return BackendContainer
+}
+
+func (s *containerSandbox) Command(ctx context.Context, script, dir string) (*exec.Cmd, func(), error) {
+	if err := ensureDirs(s.opts); err != nil {
+		return nil, nil, err
+	}
+	name := "picoclaw-" + randomSuffix()
+	cmd := exec.CommandContext(ctx, s.runtime, s.runArgs(name, script, dir)...)
+	// Killing the CLI does not always stop the container, so remove it
+	// explicitly once the command is done.
+	cleanup := func() {
+		rmCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = exec.CommandContext(rmCtx, s.runtime, "rm", "-f", name).Run()
+	}
+	return cmd, cleanup, nil
+}
+
+// runArgs builds the "run" arguments for one command.
+func (s *containerSandbox) runArgs(name, script, dir string) []string {
+	o := s.opts
+	wsMode := "ro"
+	if o.WorkspaceWritable {
+		wsMode = "rw"
+	}
+	args := []string{
+		"run", "--rm", "-i", "--name", name,
+		"--read-only", "--tmpfs", "/tmp",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"-v", o.Workspace + ":" + o.Workspace + ":" + wsMode,
+		"-v", o.ScratchDir + ":" + o.ScratchDir + ":rw",
+		"-w", dir,
+	}
+	if filepath.Base(s.runtime) == "podman" {
+		// Run as the invoking user so files in the scratch dir stay theirs;
+		// rootless docker already maps container root to the invoking user.
+		args = append(args, "--userns", "keep-id")
+	}
+	if !o.Network {
+		args = append(args, "--network", "none")
+	}
+	if o.MemoryMB > 0 {
+		args = append(args, "--memory", strconv.Itoa(o.MemoryMB)+"m")
+	}
+	if o.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(o.CPUs, 'f', -1, 64))
+	}
+	if o.PIDs > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(o.PIDs))
+	}
+	for _, kv := range commandEnv(o.ScratchDir) {
+		args = append(args, "-e", kv)
+	}
+	return append(args, o.Image, "sh", "-c", script)
+}
+
+func randomSuffix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,482 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// initArg0 is the argv[0] the binary is re-executed with to set up the
+	// sandbox from inside the new namespaces.
+	initArg0 = "picoclaw-sandbox-init"
+	specEnv  = "PICOCLAW_SANDBOX_SPEC"
+)
+
+// systemPaths are bind-mounted read-only into the sandbox when present.
+var systemPaths = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr", "/etc", "/opt"}
+
+// devices are bind-mounted from the host into the sandbox's /dev.
+var devices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+func init() {
+	if len(os.Args) == 0 || os.Args[0] != initArg0 {
+		return
+	}
+	// Capabilities, no_new_privs and Landlock apply per thread; keep setup
+	// and the final exec on one.
+	runtime.LockOSThread()
+	err := initSandbox()
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(125)
+}
+
+// nsSpec is passed from the parent to the sandbox init process.
+type nsSpec struct {
+	Root              string   `json:"root"`
+	Workspace         string   `json:"workspace"`
+	WorkspaceWritable bool     `json:"workspace_writable"`
+	Scratch           string   `json:"scratch"`
+	Dir               string   `json:"dir"`
+	Script            string   `json:"script"`
+	Env               []string `json:"env"`
+	MemoryMB          int      `json:"memory_mb"`
+	PIDs              int      `json:"pids"`
+	LowerPriority     bool     `json:"lower_priority"`
+}
+
+// namespaceSandbox isolates commands with Linux user, mount, PID, IPC, UTS
+// and network namespaces, then drops capabilities and applies Landlock and
+// seccomp filters. It needs unprivileged user namespaces but no daemon.
+type namespaceSandbox struct {
+	opts Options
+}
+
+func newNamespace(opts Options) (Sandbox, error) {
+	if _, err := os.Stat("/proc/self/ns/user"); err != nil {
+		return nil, fmt.Errorf("sandbox: kernel lacks user namespace support")
+	}
+	if opts.CgroupParent != "" {
+		if _, err := os.Stat(filepath.Join(opts.CgroupParent, "cgroup.controllers")); err != nil {
+			return nil, fmt.Errorf("sandbox: cgroup_parent %s is not a cgroup v2 directory", opts.CgroupParent)
+		}
+	}
+	return &namespaceSandbox{opts: opts}, nil
+}
+
+func (s *namespaceSandbox) Name() string {
+	return BackendNamespace
+}
+
+func (s *namespaceSandbox) Command(ctx context.Context, script, dir string) (*exec.Cmd, func(), error) {
+	o := s.opts
+	if err := ensureDirs(o); err != nil {
+		return nil, nil, err
+	}
+	root, err := os.MkdirTemp("", "picoclaw-sandbox-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("sandbox: %w", err)
+	}
+	cleanups := []func(){func() { os.RemoveAll(root) }}
+	cleanup := func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+
+	spec := nsSpec{
+		Root:              root,
+		Workspace:         o.Workspace,
+		WorkspaceWritable: o.WorkspaceWritable,
+		Scratch:           o.ScratchDir,
+		Dir:               dir,
+		Script:            script,
+		Env:               commandEnv(o.ScratchDir),
+	}
+	attr := &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	if !o.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	if o.CgroupParent != "" {
+		cgDir, fd, err := newCgroup(o)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		cleanups = append(cleanups, func() {
+			unix.Close(fd)
+			removeCgroup(cgDir)
+		})
+		attr.UseCgroupFD = true
+		attr.CgroupFD = fd
+	} else {
+		spec.MemoryMB = o.MemoryMB
+		spec.PIDs = o.PIDs
+		spec.LowerPriority = o.CPUs > 0
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("sandbox: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{initArg0}
+	cmd.Env = []string{specEnv + "=" + string(data)}
+	cmd.SysProcAttr = attr
+	return cmd, cleanup, nil
+}
+
+// newCgroup creates a cgroup for one command under the configured parent and
+// returns its path and an open descriptor for CLONE_INTO_CGROUP.
+func newCgroup(o Options) (string, int, error) {
+	dir := filepath.Join(o.CgroupParent, "picoclaw-"+randomSuffix())
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", -1, fmt.Errorf("sandbox: create cgroup: %w", err)
+	}
+	limits := map[string]string{"pids.max": strconv.Itoa(o.PIDs)}
+	if o.MemoryMB > 0 {
+		limits["memory.max"] = strconv.FormatInt(int64(o.MemoryMB)<<20, 10)
+		limits["memory.swap.max"] = "0"
+	}
+	if o.CPUs > 0 {
+		limits["cpu.max"] = fmt.Sprintf("%d 100000", int(o.CPUs*100000))
+	}
+	for file, value := range limits {
+		err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0o644)
+		if err != nil && !(file == "memory.swap.max" && errors.Is(err, os.ErrNotExist)) {
+			removeCgroup(dir)
+			return "", -1, fmt.Errorf("sandbox: set %s (is the controller enabled in %s?): %w",
+				file, o.CgroupParent, err)
+		}
+	}
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		removeCgroup(dir)
+		return "", -1, fmt.Errorf("sandbox: open cgroup: %w", err)
+	}
+	return dir, fd, nil
+}
+
+// removeCgroup removes a command's cgroup, waiting briefly for the killed
+// processes in it to be reaped.
+func removeCgroup(dir string) {
+	for i := 0; i < 20; i++ {
+		if err := unix.Rmdir(dir); err == nil || errors.Is(err, unix.ENOENT) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// initSandbox runs in the re-executed child, inside the new namespaces. It
+// builds the root filesystem, applies limits and restrictions, and execs
+// the shell. It only returns on error.
+func initSandbox() error {
+	var spec nsSpec
+	if err := json.Unmarshal([]byte(os.Getenv(specEnv)), &spec); err != nil {
+		return fmt.Errorf("invalid spec: %w", err)
+	}
+	if err := buildRoot(spec); err != nil {
+		return err
+	}
+	if err := unix.Sethostname([]byte("sandbox")); err != nil {
+		return fmt.Errorf("set hostname: %w", err)
+	}
+	if err := os.Chdir(spec.Dir); err != nil {
+		return err
+	}
+	if err := applyLimits(spec); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	if err := restrictFilesystem(spec); err != nil {
+		return err
+	}
+	if err := installSeccomp(); err != nil {
+		return err
+	}
+	return unix.Exec("/bin/sh", []string{"sh", "-c", spec.Script}, spec.Env)
+}
+
+// buildRoot assembles a tmpfs root with the system directories, workspace
+// and scratch directory bind-mounted in, and pivots into it.
+func buildRoot(spec nsSpec) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	root := spec.Root
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+
+	tmp := filepath.Join(root, "tmp")
+	if err := mkdirMount("tmpfs", tmp, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return err
+	}
+	proc := filepath.Join(root, "proc")
+	if err := mkdirMount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return err
+	}
+	if err := buildDev(filepath.Join(root, "dev")); err != nil {
+		return err
+	}
+
+	for _, p := range systemPaths {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			continue
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			// Merged-/usr systems link /bin and friends into /usr.
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(target, filepath.Join(root, p)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := bindMount(p, filepath.Join(root, p), false); err != nil {
+			return err
+		}
+	}
+	if err := bindMount(spec.Workspace, filepath.Join(root, spec.Workspace), spec.WorkspaceWritable); err != nil {
+		return err
+	}
+	if err := bindMount(spec.Scratch, filepath.Join(root, spec.Scratch), true); err != nil {
+		return err
+	}
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.Mkdir(oldRoot, 0o700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+	return nil
+}
+
+// buildDev populates a minimal /dev with the host's harmless devices.
+func buildDev(dev string) error {
+	if err := mkdirMount("tmpfs", dev, "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, "mode=0755"); err != nil {
+		return err
+	}
+	for _, name := range devices {
+		src := filepath.Join("/dev", name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(dev, name)
+		if err := os.WriteFile(dst, nil, 0o666); err != nil {
+			return err
+		}
+		if err := unix.Mount(src, dst, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", src, err)
+		}
+	}
+	for name, target := range map[string]string{
+		"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0", "stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, filepath.Join(dev, name)); err != nil {
+			return err
+		}
+	}
+	return mkdirMount("tmpfs", filepath.Join(dev, "shm"), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777")
+}
+
+func mkdirMount(source, target, fstype string, flags uintptr, data string) error {
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount(source, target, fstype, flags, data); err != nil {
+		return fmt.Errorf("mount %s: %w", target, err)
+	}
+	return nil
+}
+
+// bindMount binds src at dst, read-only unless writable. Mounts nested under
+// src are made read-only as well.
+func bindMount(src, dst string, writable bool) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount(src, dst, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", src, err)
+	}
+	var flags uintptr = unix.MS_NOSUID | unix.MS_NODEV
+	if writable {
+		return remount(dst, flags)
+	}
+	mounts, err := mountsUnder(dst)
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if err := remount(m, flags|unix.MS_RDONLY); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remount applies flags to the bind mount at dst. Flags the kernel locks on
+// mounts inherited from the parent namespace must be kept.
+func remount(dst string, flags uintptr) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dst, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", dst, err)
+	}
+	for stFlag, msFlag := range map[int64]uintptr{
+		unix.ST_RDONLY:      unix.MS_RDONLY,
+		unix.ST_NOSUID:      unix.MS_NOSUID,
+		unix.ST_NODEV:       unix.MS_NODEV,
+		unix.ST_NOEXEC:      unix.MS_NOEXEC,
+		unix.ST_NOATIME:     unix.MS_NOATIME,
+		unix.ST_NODIRATIME:  unix.MS_NODIRATIME,
+		unix.ST_RELATIME:    unix.MS_RELATIME,
+		unix.ST_SYNCHRONOUS: unix.MS_SYNCHRONOUS,
+	} {
+		if int64(st.Flags)&stFlag != 0 { //nolint:unconvert // int32 on 32-bit platforms
+			flags |= msFlag
+		}
+	}
+	if err := unix.Mount("", dst, "", unix.MS_BIND|unix.MS_REMOUNT|flags, ""); err != nil {
+		return fmt.Errorf("remount %s: %w", dst, err)
+	}
+	return nil
+}
+
+// mountsUnder lists dst and the mount points below it.
+func mountsUnder(dst string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := map[string]bool{dst: true}
+	mounts := []string{dst}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mp := unescapeMountPath(fields[4])
+		if strings.HasPrefix(mp, dst+"/") && !seen[mp] {
+			seen[mp] = true
+			mounts = append(mounts, mp)
+		}
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space) in
+// /proc/self/mountinfo paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// applyLimits sets rlimits when no cgroup enforces the limits.
+func applyLimits(spec nsSpec) error {
+	if spec.MemoryMB > 0 {
+		lim := uint64(spec.MemoryMB) << 20
+		if err := unix.Setrlimit(unix.RLIMIT_AS, &unix.Rlimit{Cur: lim, Max: lim}); err != nil {
+			return fmt.Errorf("set memory limit: %w", err)
+		}
+	}
+	if spec.PIDs > 0 {
+		lim := uint64(spec.PIDs)
+		if err := unix.Setrlimit(unix.RLIMIT_NPROC, &unix.Rlimit{Cur: lim, Max: lim}); err != nil {
+			return fmt.Errorf("set process limit: %w", err)
+		}
+	}
+	if spec.LowerPriority {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, 10); err != nil {
+			return fmt.Errorf("set priority: %w", err)
+		}
+	}
+	return nil
+}
+
+// dropCapabilities clears every capability and the bounding set, so the
+// shell cannot regain them on exec even though it runs as uid 0 inside the
+// user namespace.
+func dropCapabilities() error {
+	last := 63
+	if data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			last = n
+		}
+	}
+	for c := 0; c <= last; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && err != unix.EINVAL {
+			return fmt.Errorf("drop capability %d: %w", c, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil &&
+		err != unix.EINVAL {
+		return fmt.Errorf("clear ambient capabilities: %w", err)
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("clear capabilities: %w", err)
+	}
+	return nil
+}
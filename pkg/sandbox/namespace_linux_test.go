@@ -0,0 +1,122 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runSandboxed runs script in a namespace sandbox and returns its combined
+// output and exit code. The test is skipped when the kernel does not allow
+// unprivileged user namespaces.
+func runSandboxed(t *testing.T, opts Options, script string) (string, int) {
+	t.Helper()
+	sb, err := New(BackendNamespace, opts)
+	if err != nil {
+		t.Skipf("namespace sandbox unavailable: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd, cleanup, err := sb.Command(ctx, script, opts.Workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot create namespaces: %v", err)
+	}
+	_ = cmd.Wait()
+	code := cmd.ProcessState.ExitCode()
+	if code == 125 && strings.HasPrefix(out.String(), "sandbox: ") {
+		t.Skipf("sandbox setup failed in this environment: %s", out.String())
+	}
+	return out.String(), code
+}
+
+func TestNamespace_Isolation(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ws, "input.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PICOCLAW_SECRET_TOKEN", "do-not-leak")
+
+	script := `cat input.txt; echo
+if (echo x > input.txt) 2>/dev/null; then echo "workspace writable"; fi
+echo out > "$HOME/result.txt" && echo "scratch ok"
+echo tmp > /tmp/t && echo "tmp ok"
+hostname
+env | grep -c PICOCLAW_SECRET_TOKEN
+grep -c : /proc/net/dev`
+
+	out, code := runSandboxed(t, Options{Workspace: ws}, script)
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	for _, want := range []string{"hello", "scratch ok", "tmp ok", "sandbox"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "workspace writable") {
+		t.Errorf("workspace is writable inside the sandbox:\n%s", out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 6 || lines[4] != "0" {
+		t.Errorf("host environment leaked into the sandbox:\n%s", out)
+	} else if lines[5] != "1" {
+		t.Errorf("sandbox sees host network interfaces:\n%s", out)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ws, DefaultScratchDir, "result.txt"))
+	if err != nil || string(data) != "out\n" {
+		t.Errorf("scratch file = %q, %v", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "input.txt")); string(data) != "hello" {
+		t.Errorf("workspace file modified: %q", data)
+	}
+}
+
+func TestNamespace_WritableWorkspaceAndSeccomp(t *testing.T) {
+	ws := t.TempDir()
+	script := `echo changed > file.txt && echo "write ok"
+unshare -r true 2>/dev/null && echo "unshare allowed"
+mount -t tmpfs none /tmp 2>/dev/null && echo "mount allowed"
+true`
+	out, code := runSandboxed(t, Options{Workspace: ws, WorkspaceWritable: true}, script)
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	if !strings.Contains(out, "write ok") {
+		t.Errorf("writable workspace not writable:\n%s", out)
+	}
+	for _, unwanted := range []string{"unshare allowed", "mount allowed"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("%s inside the sandbox:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestNamespace_ProcessLimit(t *testing.T) {
+	ws := t.TempDir()
+	script := `i=0; while [ $i -lt 20 ]; do sleep 5 & i=$((i+1)); done 2>/dev/null; jobs -p | wc -l`
+	out, _ := runSandboxed(t, Options{Workspace: ws, PIDs: 5}, script)
+	if n := strings.TrimSpace(out); n == "20" {
+		t.Errorf("process limit not enforced, started %s background jobs", n)
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	if got := unescapeMountPath(`/mnt/my\040disk`); got != "/mnt/my disk" {
+		t.Errorf("got %q", got)
+	}
+}
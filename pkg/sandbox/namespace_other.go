@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newNamespace(Options) (Sandbox, error) {
+	return nil, fmt.Errorf("sandbox: the %s backend requires Linux, not %s", BackendNamespace, runtime.GOOS)
+}
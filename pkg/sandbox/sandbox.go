@@ -0,0 +1,129 @@
+// Package sandbox runs shell commands isolated from the host. Backends give
+// the command a read-only view of the workspace, a writable scratch
+// directory, optional network isolation and CPU, memory and process limits.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Backend names accepted by New.
+const (
+	BackendNone      = "none"
+	BackendNamespace = "namespace"
+	BackendContainer = "container"
+)
+
+// Default values for Options fields left empty.
+const (
+	DefaultImage      = "docker.io/library/alpine:3"
+	DefaultScratchDir = ".sandbox"
+	DefaultPIDs       = 256
+)
+
+// Sandbox prepares commands to run inside an isolated environment.
+type Sandbox interface {
+	// Name returns the backend name.
+	Name() string
+	// Command returns a command that runs script with sh -c in dir. The
+	// returned cleanup function must be called once the command has exited.
+	Command(ctx context.Context, script, dir string) (cmd *exec.Cmd, cleanup func(), err error)
+}
+
+// Options configures a sandbox backend.
+type Options struct {
+	// Workspace is bind-mounted read-only at its host path.
+	Workspace string
+	// WorkspaceWritable mounts the workspace read-write instead.
+	WorkspaceWritable bool
+	// ScratchDir is a writable directory mounted at its host path and used
+	// as HOME and TMPDIR. Relative paths are resolved against Workspace.
+	ScratchDir string
+	// Network keeps network access; by default commands get none.
+	Network bool
+	// MemoryMB, CPUs and PIDs limit resources; zero means no limit, except
+	// PIDs which defaults to DefaultPIDs.
+	MemoryMB int
+	CPUs     float64
+	PIDs     int
+	// CgroupParent is a delegated cgroup v2 directory the namespace backend
+	// creates per-command cgroups in. Without it, memory and process limits
+	// fall back to rlimits and CPUs only lowers the scheduling priority.
+	CgroupParent string
+	// Runtime and Image select the container runtime ("podman" or "docker",
+	// auto-detected when empty) and the image commands run in.
+	Runtime string
+	Image   string
+}
+
+// New returns the sandbox for backend, or nil for "none" and "".
+func New(backend string, opts Options) (Sandbox, error) {
+	backend = strings.ToLower(strings.TrimSpace(backend))
+	if backend == "" || backend == BackendNone {
+		return nil, nil
+	}
+
+	if opts.Workspace == "" {
+		return nil, fmt.Errorf("sandbox: workspace is required")
+	}
+	ws, err := filepath.Abs(opts.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	opts.Workspace = ws
+	if opts.ScratchDir == "" {
+		opts.ScratchDir = DefaultScratchDir
+	}
+	if !filepath.IsAbs(opts.ScratchDir) {
+		opts.ScratchDir = filepath.Join(ws, opts.ScratchDir)
+	}
+	if opts.PIDs == 0 {
+		opts.PIDs = DefaultPIDs
+	}
+	if opts.MemoryMB < 0 || opts.CPUs < 0 || opts.PIDs < 0 {
+		return nil, fmt.Errorf("sandbox: resource limits must not be negative")
+	}
+
+	switch backend {
+	case BackendNamespace:
+		return newNamespace(opts)
+	case BackendContainer:
+		return newContainer(opts)
+	default:
+		return nil, fmt.Errorf("sandbox: unknown backend %q (want %s, %s or %s)",
+			backend, BackendNone, BackendNamespace, BackendContainer)
+	}
+}
+
+// ensureDirs creates the workspace and scratch directories so they can be
+// mounted.
+func ensureDirs(opts Options) error {
+	for _, dir := range []string{opts.Workspace, opts.ScratchDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// commandEnv is the environment of sandboxed commands. The host environment
+// is not passed through since it typically holds API keys and tokens.
+func commandEnv(scratch string) []string {
+	env := []string{
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"HOME=" + scratch,
+		"TMPDIR=" + scratch,
+		"TERM=dumb",
+	}
+	for _, key := range []string{"LANG", "LC_ALL", "TZ"} {
+		if v, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	return env
+}
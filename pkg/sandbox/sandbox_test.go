@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew_NoneReturnsNil(t *testing.T) {
+	for _, backend := range []string{"", "none", " None "} {
+		sb, err := New(backend, Options{})
+		if err != nil || sb != nil {
+			t.Errorf("New(%q) = %v, %v; want nil, nil", backend, sb, err)
+		}
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	if _, err := New("chroot", Options{Workspace: t.TempDir()}); err == nil ||
+		!strings.Contains(err.Error(), "unknown backend") {
+		t.Errorf("unknown backend: err = %v", err)
+	}
+	if _, err := New(BackendContainer, Options{Runtime: "podman"}); err == nil {
+		t.Error("missing workspace: expected error")
+	}
+	if _, err := New(BackendContainer, Options{Workspace: t.TempDir(), Runtime: "podman", MemoryMB: -1}); err == nil {
+		t.Error("negative limit: expected error")
+	}
+}
+
+func TestContainer_RunArgs(t *testing.T) {
+	ws := t.TempDir()
+	sb, err := New(BackendContainer, Options{
+		Workspace: ws,
+		Runtime:   "/usr/bin/podman",
+		MemoryMB:  512,
+		CPUs:      1.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scratch := filepath.Join(ws, DefaultScratchDir)
+	args := strings.Join(sb.(*containerSandbox).runArgs("picoclaw-test", "echo hi", ws), " ")
+
+	for _, want := range []string{
+		"run --rm -i --name picoclaw-test --read-only",
+		"--cap-drop ALL --security-opt no-new-privileges",
+		"-v " + ws + ":" + ws + ":ro",
+		"-v " + scratch + ":" + scratch + ":rw",
+		"-w " + ws,
+		"--userns keep-id",
+		"--network none",
+		"--memory 512m --cpus 1.5 --pids-limit 256",
+		"-e HOME=" + scratch,
+		DefaultImage + " sh -c echo hi",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args missing %q:\n%s", want, args)
+		}
+	}
+}
+
+func TestContainer_NetworkAndWritableWorkspace(t *testing.T) {
+	ws := t.TempDir()
+	sb, err := New(BackendContainer, Options{
+		Workspace:         ws,
+		WorkspaceWritable: true,
+		Network:           true,
+		Runtime:           "docker",
+		Image:             "debian:stable",
+		ScratchDir:        "/var/tmp/scratch",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(sb.(*containerSandbox).runArgs("n", "true", ws), " ")
+	for _, unwanted := range []string{"--network", "--userns", "--memory", "--cpus"} {
+		if strings.Contains(args, unwanted) {
+			t.Errorf("args contain %q:\n%s", unwanted, args)
+		}
+	}
+	for _, want := range []string{
+		ws + ":" + ws + ":rw",
+		"/var/tmp/scratch:/var/tmp/scratch:rw",
+		"debian:stable sh -c true",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args missing %q:\n%s", want, args)
+		}
+	}
+}
+
+func TestCommandEnv_DoesNotLeakHostEnv(t *testing.T) {
+	t.Setenv("PICOCLAW_PROVIDERS_OPENAI_API_KEY", "secret")
+	env := strings.Join(commandEnv("/scratch"), "\n")
+	if strings.Contains(env, "secret") {
+		t.Errorf("host environment leaked: %s", env)
+	}
+	if !strings.Contains(env, "HOME=/scratch") || !strings.Contains(env, "TMPDIR=/scratch") {
+		t.Errorf("env = %s", env)
+	}
+}
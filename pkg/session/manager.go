@@ -1,16 +1,18 @@
 package session
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"strings"
+	"container/list"
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// DefaultCacheSize is the number of sessions a SessionManager keeps in
+// memory when no cache size is configured.
+const DefaultCacheSize = 64
+
 type Session struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
@@ -19,44 +21,147 @@ type Session struct {
 	Updated  time.Time           `json:"updated"`
 }
 
+// SessionManager keeps recently used sessions in memory and loads others
+// from its store on first use. Once more than cacheSize sessions are loaded,
+// the least recently used sessions without unsaved changes are dropped from
+// memory. Without a store, sessions only live in memory.
 type SessionManager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-	storage  string
+	sessions  map[string]*cachedSession
+	lru       *list.List // of session keys, most recently used first
+	mu        sync.RWMutex
+	store     SessionStore
+	cacheSize int
+}
+
+// cachedSession is a loaded session and what its store holds of it.
+type cachedSession struct {
+	session *Session
+	elem    *list.Element
+	// stored is the number of leading messages the store holds unchanged,
+	// or -1 when the session must be rewritten in full.
+	stored int
+	// changes counts modifications; saved is the count at the last save.
+	changes uint64
+	saved   uint64
+	// rewrites counts changes that invalidate stored.
+	rewrites uint64
 }
 
+// NewSessionManager creates a manager that stores sessions as JSON files in
+// storage. An empty storage keeps sessions in memory only.
 func NewSessionManager(storage string) *SessionManager {
-	sm := &SessionManager{
-		sessions: make(map[string]*Session),
-		storage:  storage,
+	if storage == "" {
+		return NewSessionManagerWithStore(nil, 0)
 	}
-
-	if storage != "" {
-		os.MkdirAll(storage, 0o755)
-		sm.loadSessions()
+	store, err := NewJSONStore(storage)
+	if err != nil {
+		logger.ErrorCF("session", "Failed to open session storage, keeping sessions in memory",
+			map[string]any{"storage": storage, "error": err.Error()})
+		return NewSessionManagerWithStore(nil, 0)
 	}
+	return NewSessionManagerWithStore(store, 0)
+}
 
-	return sm
+// NewSessionManagerWithStore creates a manager backed by store, keeping up to
+// cacheSize sessions in memory (DefaultCacheSize if cacheSize <= 0). A nil
+// store keeps all sessions in memory.
+func NewSessionManagerWithStore(store SessionStore, cacheSize int) *SessionManager {
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+	return &SessionManager{
+		sessions:  make(map[string]*cachedSession),
+		lru:       list.New(),
+		store:     store,
+		cacheSize: cacheSize,
+	}
 }
 
-func (sm *SessionManager) GetOrCreate(key string) *Session {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// get returns the cached session with key, loading it from the store if
+// needed, or nil if there is none. Must be called with sm.mu held for
+// writing.
+func (sm *SessionManager) get(key string) *cachedSession {
+	if cs, ok := sm.sessions[key]; ok {
+		sm.lru.MoveToFront(cs.elem)
+		return cs
+	}
+	if sm.store == nil {
+		return nil
+	}
 
-	session, ok := sm.sessions[key]
-	if ok {
-		return session
+	session, err := sm.store.Load(key)
+	if err != nil {
+		logger.WarnCF("session", "Failed to load session",
+			map[string]any{"session_key": key, "error": err.Error()})
+		return nil
+	}
+	if session == nil {
+		return nil
 	}
+	if session.Messages == nil {
+		session.Messages = []providers.Message{}
+	}
+	return sm.add(session, len(session.Messages))
+}
 
-	session = &Session{
+// getOrCreate is get, creating the session if it doesn't exist. Must be
+// called with sm.mu held for writing.
+func (sm *SessionManager) getOrCreate(key string) *cachedSession {
+	if cs := sm.get(key); cs != nil {
+		return cs
+	}
+	now := time.Now()
+	return sm.add(&Session{
 		Key:      key,
 		Messages: []providers.Message{},
-		Created:  time.Now(),
-		Updated:  time.Now(),
+		Created:  now,
+		Updated:  now,
+	}, -1)
+}
+
+// add caches session and evicts the least recently used sessions beyond the
+// cache size. Must be called with sm.mu held for writing.
+func (sm *SessionManager) add(session *Session, stored int) *cachedSession {
+	cs := &cachedSession{
+		session: session,
+		elem:    sm.lru.PushFront(session.Key),
+		stored:  stored,
+	}
+	if stored < 0 {
+		cs.changes = 1 // not in the store yet
+	}
+	sm.sessions[session.Key] = cs
+
+	if sm.store == nil {
+		return cs
 	}
-	sm.sessions[key] = session
+	for elem := sm.lru.Back(); elem != nil && len(sm.sessions) > sm.cacheSize; {
+		prev := elem.Prev()
+		key := elem.Value.(string)
+		if evicted := sm.sessions[key]; evicted.changes == evicted.saved {
+			sm.lru.Remove(elem)
+			delete(sm.sessions, key)
+		}
+		elem = prev
+	}
+	return cs
+}
 
-	return session
+// touch records a modification of cs. Must be called with sm.mu held for
+// writing.
+func (cs *cachedSession) touch(rewrite bool) {
+	cs.changes++
+	if rewrite {
+		cs.rewrites++
+		cs.stored = -1
+	}
+	cs.session.Updated = time.Now()
+}
+
+func (sm *SessionManager) GetOrCreate(key string) *Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.getOrCreate(key).session
 }
 
 func (sm *SessionManager) AddMessage(sessionKey, role, content string) {
@@ -72,53 +177,43 @@ func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Messag
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[sessionKey]
-	if !ok {
-		session = &Session{
-			Key:      sessionKey,
-			Messages: []providers.Message{},
-			Created:  time.Now(),
-		}
-		sm.sessions[sessionKey] = session
-	}
-
-	session.Messages = append(session.Messages, msg)
-	session.Updated = time.Now()
+	cs := sm.getOrCreate(sessionKey)
+	cs.session.Messages = append(cs.session.Messages, msg)
+	cs.touch(false)
 }
 
 func (sm *SessionManager) GetHistory(key string) []providers.Message {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	cs := sm.get(key)
+	if cs == nil {
 		return []providers.Message{}
 	}
 
-	history := make([]providers.Message, len(session.Messages))
-	copy(history, session.Messages)
+	history := make([]providers.Message, len(cs.session.Messages))
+	copy(history, cs.session.Messages)
 	return history
 }
 
 func (sm *SessionManager) GetSummary(key string) string {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	cs := sm.get(key)
+	if cs == nil {
 		return ""
 	}
-	return session.Summary
+	return cs.session.Summary
 }
 
 func (sm *SessionManager) SetSummary(key string, summary string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if ok {
-		session.Summary = summary
-		session.Updated = time.Now()
+	if cs := sm.get(key); cs != nil {
+		cs.session.Summary = summary
+		cs.touch(false)
 	}
 }
 
@@ -126,157 +221,89 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	cs := sm.get(key)
+	if cs == nil {
 		return
 	}
 
 	if keepLast <= 0 {
-		session.Messages = []providers.Message{}
-		session.Updated = time.Now()
+		cs.session.Messages = []providers.Message{}
+		cs.touch(true)
 		return
 	}
 
-	if len(session.Messages) <= keepLast {
+	if len(cs.session.Messages) <= keepLast {
 		return
 	}
 
-	session.Messages = session.Messages[len(session.Messages)-keepLast:]
-	session.Updated = time.Now()
+	cs.session.Messages = cs.session.Messages[len(cs.session.Messages)-keepLast:]
+	cs.touch(true)
 }
 
-// sanitizeFilename converts a session key into a cross-platform safe filename.
-// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
-// volume separator on Windows, so filepath.Base would misinterpret the key.
-// We replace it with '_'. The original key is preserved inside the JSON file,
-// so loadSessions still maps back to the right in-memory key.
-func sanitizeFilename(key string) string {
-	return strings.ReplaceAll(key, ":", "_")
+// SetHistory updates the messages of a session.
+func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if cs := sm.get(key); cs != nil {
+		// Create a deep copy to strictly isolate internal state
+		// from the caller's slice.
+		msgs := make([]providers.Message, len(history))
+		copy(msgs, history)
+		cs.session.Messages = msgs
+		cs.touch(true)
+	}
 }
 
+// Save writes the session's changes to the store. Sessions whose history was
+// only appended to since the last save are appended to in the store.
 func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
+	if sm.store == nil {
 		return nil
 	}
 
-	filename := sanitizeFilename(key)
-
-	// filepath.IsLocal rejects empty names, "..", absolute paths, and
-	// OS-reserved device names (NUL, COM1 … on Windows).
-	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
-	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
-	}
-
-	// Snapshot under read lock, then perform slow file I/O after unlock.
-	sm.mu.RLock()
-	stored, ok := sm.sessions[key]
+	// Snapshot under lock, then perform slow I/O after unlock.
+	sm.mu.Lock()
+	cs, ok := sm.sessions[key]
 	if !ok {
-		sm.mu.RUnlock()
+		sm.mu.Unlock()
 		return nil
 	}
-
-	snapshot := Session{
-		Key:     stored.Key,
-		Summary: stored.Summary,
-		Created: stored.Created,
-		Updated: stored.Updated,
+	stored := cs.stored
+	if stored > len(cs.session.Messages) {
+		stored = -1
 	}
-	if len(stored.Messages) > 0 {
-		snapshot.Messages = make([]providers.Message, len(stored.Messages))
-		copy(snapshot.Messages, stored.Messages)
+	changes, rewrites := cs.changes, cs.rewrites
+	snapshot := *cs.session
+	snapshot.Messages = make([]providers.Message, len(cs.session.Messages))
+	copy(snapshot.Messages, cs.session.Messages)
+	sm.mu.Unlock()
+
+	var err error
+	if stored >= 0 {
+		err = sm.store.Append(&snapshot, stored)
 	} else {
-		snapshot.Messages = []providers.Message{}
+		err = sm.store.Save(&snapshot)
 	}
-	sm.mu.RUnlock()
-
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	sessionPath := filepath.Join(sm.storage, filename+".json")
-	tmpFile, err := os.CreateTemp(sm.storage, "session-*.tmp")
 	if err != nil {
 		return err
 	}
 
-	tmpPath := tmpFile.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpPath)
-		}
-	}()
-
-	if _, err := tmpFile.Write(data); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Chmod(0o644); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Sync(); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Close(); err != nil {
-		return err
-	}
-
-	if err := os.Rename(tmpPath, sessionPath); err != nil {
-		return err
-	}
-	cleanup = false
-	return nil
-}
-
-func (sm *SessionManager) loadSessions() error {
-	files, err := os.ReadDir(sm.storage)
-	if err != nil {
-		return err
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if cs.rewrites == rewrites {
+		cs.stored = len(snapshot.Messages)
 	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
-		}
-
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
-		if err != nil {
-			continue
-		}
-
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
-
-		sm.sessions[session.Key] = &session
+	if cs.saved < changes {
+		cs.saved = changes
 	}
-
 	return nil
 }
 
-// SetHistory updates the messages of a session.
-func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	session, ok := sm.sessions[key]
-	if ok {
-		// Create a deep copy to strictly isolate internal state
-		// from the caller's slice.
-		msgs := make([]providers.Message, len(history))
-		copy(msgs, history)
-		session.Messages = msgs
-		session.Updated = time.Now()
+// Close closes the manager's store. Unsaved changes are lost.
+func (sm *SessionManager) Close() error {
+	if sm.store == nil {
+		return nil
 	}
+	return sm.store.Close()
 }
@@ -0,0 +1,159 @@
+//go:build sqlite
+
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	key     TEXT PRIMARY KEY,
+	summary TEXT NOT NULL DEFAULT '',
+	created INTEGER NOT NULL,
+	updated INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS messages (
+	session_key TEXT NOT NULL REFERENCES sessions(key) ON DELETE CASCADE,
+	seq         INTEGER NOT NULL,
+	message     TEXT NOT NULL,
+	PRIMARY KEY (session_key, seq)
+) WITHOUT ROWID;
+`
+
+// SQLiteStore keeps sessions in an SQLite database with one row per
+// message, so saving a session only writes its new messages.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(path string) (SessionStore, error) {
+	return NewSQLiteStore(path)
+}
+
+// NewSQLiteStore opens or creates the session database at path.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+
+		"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open session database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create session tables: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (st *SQLiteStore) Load(key string) (*Session, error) {
+	s := &Session{Key: key}
+	var created, updated int64
+	err := st.db.QueryRow("SELECT summary, created, updated FROM sessions WHERE key = ?", key).
+		Scan(&s.Summary, &created, &updated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Created, s.Updated = time.UnixMilli(created), time.UnixMilli(updated)
+
+	rows, err := st.db.Query("SELECT message FROM messages WHERE session_key = ? ORDER BY seq", key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Messages = []providers.Message{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var msg providers.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("session %s: %w", key, err)
+		}
+		s.Messages = append(s.Messages, msg)
+	}
+	return s, rows.Err()
+}
+
+func (st *SQLiteStore) Save(s *Session) error {
+	return st.write(s, 0)
+}
+
+func (st *SQLiteStore) Append(s *Session, from int) error {
+	return st.write(s, from)
+}
+
+// write stores the session row of s and its messages from index from on,
+// replacing any stored messages from there.
+func (st *SQLiteStore) write(s *Session, from int) error {
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO sessions (key, summary, created, updated) VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET summary = excluded.summary, updated = excluded.updated`,
+		s.Key, s.Summary, s.Created.UnixMilli(), s.Updated.UnixMilli())
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM messages WHERE session_key = ? AND seq >= ?", s.Key, from); err != nil {
+		return err
+	}
+
+	insert, err := tx.Prepare("INSERT INTO messages (session_key, seq, message) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for i := from; i < len(s.Messages); i++ {
+		data, err := json.Marshal(s.Messages[i])
+		if err != nil {
+			return err
+		}
+		if _, err := insert.Exec(s.Key, i, string(data)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (st *SQLiteStore) Keys() ([]string, error) {
+	rows, err := st.db.Query("SELECT key FROM sessions ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (st *SQLiteStore) Close() error {
+	return st.db.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !sqlite
+
+package session
+
+import "fmt"
+
+// openSQLiteStore returns an error when the binary was not built with -tags sqlite.
+// Build with: go build -tags sqlite ./cmd/...
+func openSQLiteStore(path string) (SessionStore, error) {
+	return nil, fmt.Errorf("sqlite session store not compiled in; build with -tags sqlite")
+}
@@ -0,0 +1,82 @@
+//go:build sqlite
+
+package session
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestSQLiteStore_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSessionManagerWithStore(store, 0)
+
+	key := "telegram:123"
+	sm.AddMessage(key, "user", "hello")
+	sm.AddFullMessage(key, providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "read_file"}},
+	})
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	sm.AddMessage(key, "tool", "contents")
+	sm.SetSummary(key, "reading a file")
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	sm.TruncateHistory(key, 1)
+	sm.AddMessage(key, "assistant", "done")
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	s, err := store.Load(key)
+	if err != nil || s == nil {
+		t.Fatalf("Load = %+v, %v", s, err)
+	}
+	if len(s.Messages) != 2 || s.Messages[0].Content != "contents" || s.Messages[1].Content != "done" {
+		t.Errorf("unexpected messages %+v", s.Messages)
+	}
+	if s.Summary != "reading a file" {
+		t.Errorf("summary = %q", s.Summary)
+	}
+	if missing, err := store.Load("nope"); missing != nil || err != nil {
+		t.Errorf("Load of a missing session = %+v, %v", missing, err)
+	}
+}
+
+func TestMigrate_JSONToSQLite(t *testing.T) {
+	dir := t.TempDir()
+	from, _ := NewJSONStore(dir)
+	sm := NewSessionManagerWithStore(from, 0)
+	sm.AddMessage("agent:main:main", "user", "hi")
+	sm.AddFullMessage("agent:main:main", providers.Message{Role: "tool", Content: "ok", ToolCallID: "call_1"})
+	sm.Save("agent:main:main")
+
+	to, err := OpenStore(StoreSQLite, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer to.Close()
+	if n, err := Migrate(from, to); err != nil || n != 1 {
+		t.Fatalf("Migrate = %d, %v", n, err)
+	}
+	keys, _ := to.Keys()
+	s, _ := to.Load("agent:main:main")
+	if len(keys) != 1 || s == nil || len(s.Messages) != 2 || s.Messages[1].ToolCallID != "call_1" {
+		t.Errorf("unexpected migrated session %v %+v", keys, s)
+	}
+}
@@ -0,0 +1,211 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Session store backends, as set in session.store.
+const (
+	StoreJSON   = "json"
+	StoreSQLite = "sqlite"
+)
+
+// SessionStore persists the sessions of a SessionManager.
+type SessionStore interface {
+	// Load returns the stored session with key, or nil if there is none.
+	Load(key string) (*Session, error)
+	// Save stores s, replacing any stored version of it.
+	Save(s *Session) error
+	// Append stores s, whose first from messages are stored already and
+	// unchanged. Stores that can't append write s in full.
+	Append(s *Session, from int) error
+	// Keys returns the keys of all stored sessions.
+	Keys() ([]string, error)
+	Close() error
+}
+
+// OpenStore opens the session store of the given backend in dir. An empty
+// backend selects the JSON store.
+func OpenStore(backend, dir string) (SessionStore, error) {
+	switch backend {
+	case "", StoreJSON:
+		return NewJSONStore(dir)
+	case StoreSQLite:
+		return openSQLiteStore(filepath.Join(dir, "sessions.db"))
+	default:
+		return nil, fmt.Errorf("unknown session store %q (want %s or %s)", backend, StoreJSON, StoreSQLite)
+	}
+}
+
+// Migrate copies every session of from into to and returns how many were
+// copied.
+func Migrate(from, to SessionStore) (int, error) {
+	keys, err := from.Keys()
+	if err != nil {
+		return 0, err
+	}
+	for i, key := range keys {
+		s, err := from.Load(key)
+		if err != nil {
+			return i, fmt.Errorf("load session %s: %w", key, err)
+		}
+		if s == nil {
+			continue
+		}
+		if err := to.Save(s); err != nil {
+			return i, fmt.Errorf("save session %s: %w", key, err)
+		}
+	}
+	return len(keys), nil
+}
+
+// JSONStore keeps each session in a JSON file of its own and rewrites the
+// whole file on every save.
+type JSONStore struct {
+	dir string
+}
+
+// NewJSONStore creates a JSON store in dir, creating dir if needed.
+func NewJSONStore(dir string) (*JSONStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &JSONStore{dir: dir}, nil
+}
+
+// sanitizeFilename converts a session key into a cross-platform safe filename.
+// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
+// volume separator on Windows, so filepath.Base would misinterpret the key.
+// We replace it with '_'. The original key is preserved inside the JSON file,
+// so Load and Keys still map back to the right key.
+func sanitizeFilename(key string) string {
+	return strings.ReplaceAll(key, ":", "_")
+}
+
+// sessionPath returns the file of the session with key.
+func (st *JSONStore) sessionPath(key string) (string, error) {
+	filename := sanitizeFilename(key)
+
+	// filepath.IsLocal rejects empty names, "..", absolute paths, and
+	// OS-reserved device names (NUL, COM1 … on Windows).
+	// The extra checks reject "." and any directory separators so that
+	// the session file is always written directly inside the store.
+	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return "", os.ErrInvalid
+	}
+	return filepath.Join(st.dir, filename+".json"), nil
+}
+
+func (st *JSONStore) Load(key string) (*Session, error) {
+	path, err := st.sessionPath(key)
+	if err != nil {
+		return nil, err
+	}
+	s, err := readSessionFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.Key != key {
+		// Another key that sanitizes to the same filename.
+		return nil, nil
+	}
+	return s, nil
+}
+
+func readSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (st *JSONStore) Save(s *Session) error {
+	sessionPath, err := st.sessionPath(s.Key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(st.dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmpFile.Name()
+	cleanup := true
+	defer func() {
+		if cleanup {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(0o644); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, sessionPath); err != nil {
+		return err
+	}
+	cleanup = false
+	return nil
+}
+
+// Append rewrites the whole session file; JSON files can't be appended to.
+func (st *JSONStore) Append(s *Session, from int) error {
+	return st.Save(s)
+}
+
+func (st *JSONStore) Keys() ([]string, error) {
+	files, err := os.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		s, err := readSessionFile(filepath.Join(st.dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		keys = append(keys, s.Key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (st *JSONStore) Close() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recordingStore is a JSONStore that records how sessions are written.
+type recordingStore struct {
+	*JSONStore
+	loads int
+	calls []string
+}
+
+func (st *recordingStore) Load(key string) (*Session, error) {
+	st.loads++
+	return st.JSONStore.Load(key)
+}
+
+func (st *recordingStore) Save(s *Session) error {
+	st.calls = append(st.calls, fmt.Sprintf("save %s %d", s.Key, len(s.Messages)))
+	return st.JSONStore.Save(s)
+}
+
+func (st *recordingStore) Append(s *Session, from int) error {
+	st.calls = append(st.calls, fmt.Sprintf("append %s %d-%d", s.Key, from, len(s.Messages)))
+	return st.JSONStore.Save(s)
+}
+
+func newRecordingStore(t *testing.T) *recordingStore {
+	t.Helper()
+	js, err := NewJSONStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &recordingStore{JSONStore: js}
+}
+
+func TestSessionManager_AppendsNewMessages(t *testing.T) {
+	store := newRecordingStore(t)
+	sm := NewSessionManagerWithStore(store, 0)
+
+	sm.AddMessage("s", "user", "one")
+	sm.Save("s")
+	sm.AddMessage("s", "assistant", "two")
+	sm.AddMessage("s", "user", "three")
+	sm.Save("s")
+	sm.SetSummary("s", "summary")
+	sm.Save("s")
+	sm.TruncateHistory("s", 1)
+	sm.Save("s")
+	sm.AddMessage("s", "assistant", "four")
+	sm.Save("s")
+
+	want := []string{"save s 1", "append s 1-3", "append s 3-3", "save s 1", "append s 1-2"}
+	if fmt.Sprint(store.calls) != fmt.Sprint(want) {
+		t.Errorf("store calls = %v, want %v", store.calls, want)
+	}
+}
+
+func TestSessionManager_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := newRecordingStore(t)
+	sm := NewSessionManagerWithStore(store, 2)
+
+	for _, key := range []string{"a", "b"} {
+		sm.AddMessage(key, "user", "hello "+key)
+		sm.Save(key)
+	}
+	sm.GetHistory("a") // a is now more recently used than b
+	sm.AddMessage("c", "user", "unsaved")
+
+	if _, ok := sm.sessions["b"]; ok {
+		t.Error("b should have been evicted")
+	}
+	if len(sm.sessions) != 2 {
+		t.Errorf("expected 2 cached sessions, got %d", len(sm.sessions))
+	}
+
+	// Unsaved sessions stay in memory even beyond the cache size.
+	sm.AddMessage("d", "user", "unsaved too")
+	if _, ok := sm.sessions["c"]; !ok {
+		t.Error("unsaved session c was evicted")
+	}
+
+	loads := store.loads
+	if history := sm.GetHistory("b"); len(history) != 1 || history[0].Content != "hello b" {
+		t.Errorf("evicted session should load from the store, got %+v", history)
+	}
+	if store.loads != loads+1 {
+		t.Errorf("expected b to be loaded once, loads went from %d to %d", loads, store.loads)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	from, _ := NewJSONStore(t.TempDir())
+	to, _ := NewJSONStore(t.TempDir())
+	sm := NewSessionManagerWithStore(from, 0)
+	for _, key := range []string{"telegram:1", "agent:main:main"} {
+		sm.AddMessage(key, "user", "hi")
+		sm.SetSummary(key, "greeting")
+		if err := sm.Save(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := Migrate(from, to)
+	if err != nil || n != 2 {
+		t.Fatalf("Migrate = %d, %v", n, err)
+	}
+	s, err := to.Load("telegram:1")
+	if err != nil || s == nil || len(s.Messages) != 1 || s.Summary != "greeting" {
+		t.Errorf("migrated session = %+v, %v", s, err)
+	}
+}
@@ -32,7 +32,12 @@ type ClawHubRegistry struct {
 }
 
 // NewClawHubRegistry creates a new ClawHub registry client from config.
+// Requests, including download redirects, follow utils.DefaultEgressPolicy().
 func NewClawHubRegistry(cfg ClawHubConfig) *ClawHubRegistry {
+	return newClawHubRegistry(cfg, utils.DefaultEgressPolicy())
+}
+
+func newClawHubRegistry(cfg ClawHubConfig, egress *utils.EgressPolicy) *ClawHubRegistry {
 	baseURL := cfg.BaseURL
 	if baseURL == "" {
 		baseURL = "https://clawhub.ai"
@@ -73,14 +78,14 @@ func NewClawHubRegistry(cfg ClawHubConfig) *ClawHubRegistry {
 		downloadPath:    downloadPath,
 		maxZipSize:      maxZip,
 		maxResponseSize: maxResp,
-		client: &http.Client{
+		client: egress.Guard(&http.Client{
 			Timeout: timeout,
 			Transport: &http.Transport{
 				MaxIdleConns:        5,
 				IdleConnTimeout:     30 * time.Second,
 				TLSHandshakeTimeout: 10 * time.Second,
 			},
-		},
+		}),
 	}
 }
 
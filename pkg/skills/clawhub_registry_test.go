@@ -17,12 +17,15 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// localEgress lets test registries reach httptest servers on loopback.
+var localEgress, _ = utils.NewEgressPolicy(utils.EgressOptions{Allow: []string{"127.0.0.1"}})
+
 func newTestRegistry(serverURL, authToken string) *ClawHubRegistry {
-	return NewClawHubRegistry(ClawHubConfig{
+	return newClawHubRegistry(ClawHubConfig{
 		Enabled:   true,
 		BaseURL:   serverURL,
 		AuthToken: authToken,
-	})
+	}, localEgress)
 }
 
 func TestClawHubRegistrySearch(t *testing.T) {
@@ -137,6 +140,19 @@ func TestClawHubRegistryDownloadAndInstall(t *testing.T) {
 	assert.Contains(t, string(readmeContent), "# Test Skill")
 }
 
+func TestClawHubRegistryDownloadRedirectToPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	reg := newTestRegistry(srv.URL, "")
+	_, err := reg.DownloadAndInstall(context.Background(), "test-skill", "1.0.0", t.TempDir())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, utils.ErrEgressBlocked)
+}
+
 func TestClawHubRegistryAuthToken(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
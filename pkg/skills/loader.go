@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -25,8 +26,9 @@ const (
 )
 
 type SkillMetadata struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	RequiredTools []string `json:"required_tools,omitempty"`
 }
 
 type SkillInfo struct {
@@ -34,6 +36,21 @@ type SkillInfo struct {
 	Path        string `json:"path"`
 	Source      string `json:"source"`
 	Description string `json:"description"`
+	// RequiredTools are the tools the skill needs, declared in the
+	// frontmatter as metadata: {"nanobot":{"requires":{"tools":[...]}}}.
+	RequiredTools []string `json:"required_tools,omitempty"`
+}
+
+// MissingTools returns the required tools of the skill for which hasTool
+// returns false.
+func (info SkillInfo) MissingTools(hasTool func(name string) bool) []string {
+	var missing []string
+	for _, tool := range info.RequiredTools {
+		if !hasTool(tool) {
+			missing = append(missing, tool)
+		}
+	}
+	return missing
 }
 
 func (info SkillInfo) validate() error {
@@ -62,6 +79,7 @@ type SkillsLoader struct {
 	workspaceSkills string // workspace skills (project-level)
 	globalSkills    string // global skills (~/.picoclaw/skills)
 	builtinSkills   string // builtin skills
+	filter          func(SkillInfo) bool
 }
 
 func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string) *SkillsLoader {
@@ -73,6 +91,12 @@ func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string
 	}
 }
 
+// SetFilter makes ListSkills and BuildSkillsSummary leave out the skills for
+// which keep returns false. A nil keep lists every skill.
+func (sl *SkillsLoader) SetFilter(keep func(SkillInfo) bool) {
+	sl.filter = keep
+}
+
 func (sl *SkillsLoader) ListSkills() []SkillInfo {
 	skills := make([]SkillInfo, 0)
 	seen := make(map[string]bool)
@@ -102,6 +126,7 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 			if metadata != nil {
 				info.Description = metadata.Description
 				info.Name = metadata.Name
+				info.RequiredTools = metadata.RequiredTools
 			}
 			if err := info.validate(); err != nil {
 				slog.Warn("invalid skill from "+source, "name", info.Name, "error", err)
@@ -111,6 +136,9 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 				continue
 			}
 			seen[info.Name] = true
+			if sl.filter != nil && !sl.filter(info) {
+				continue
+			}
 			skills = append(skills, info)
 		}
 	}
@@ -212,22 +240,44 @@ func (sl *SkillsLoader) getSkillMetadata(skillPath string) *SkillMetadata {
 
 	// Try JSON first (for backward compatibility)
 	var jsonMeta struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Metadata    json.RawMessage `json:"metadata"`
 	}
 	if err := json.Unmarshal([]byte(frontmatter), &jsonMeta); err == nil {
 		return &SkillMetadata{
-			Name:        jsonMeta.Name,
-			Description: jsonMeta.Description,
+			Name:          jsonMeta.Name,
+			Description:   jsonMeta.Description,
+			RequiredTools: parseRequiredTools(jsonMeta.Metadata),
 		}
 	}
 
 	// Fall back to simple YAML parsing
 	yamlMeta := sl.parseSimpleYAML(frontmatter)
 	return &SkillMetadata{
-		Name:        yamlMeta["name"],
-		Description: yamlMeta["description"],
+		Name:          yamlMeta["name"],
+		Description:   yamlMeta["description"],
+		RequiredTools: parseRequiredTools([]byte(yamlMeta["metadata"])),
+	}
+}
+
+// parseRequiredTools returns the tools listed under requires.tools in the
+// JSON metadata of a skill, under any namespace (e.g. "nanobot").
+func parseRequiredTools(metadata []byte) []string {
+	var namespaces map[string]struct {
+		Requires struct {
+			Tools []string `json:"tools"`
+		} `json:"requires"`
+	}
+	if len(metadata) == 0 || json.Unmarshal(metadata, &namespaces) != nil {
+		return nil
+	}
+	var tools []string
+	for _, ns := range namespaces {
+		tools = append(tools, ns.Requires.Tools...)
 	}
+	sort.Strings(tools)
+	return tools
 }
 
 // parseSimpleYAML parses simple key: value YAML format
@@ -326,3 +326,27 @@ func TestStripFrontmatter(t *testing.T) {
 		})
 	}
 }
+
+func TestListSkillsRequiredToolsAndFilter(t *testing.T) {
+	tmp := t.TempDir()
+	ws := filepath.Join(tmp, "workspace")
+	dir := filepath.Join(ws, "skills", "hardware")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	content := "---\nname: hardware\ndescription: Control I2C and SPI\n" +
+		`metadata: {"nanobot":{"emoji":"🔧","requires":{"tools":["spi","i2c"]}}}` + "\n---\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0o644))
+	createSkillDir(t, filepath.Join(ws, "skills"), "tmux", "tmux", "Drive tmux sessions")
+
+	sl := NewSkillsLoader(ws, "", "")
+	all := sl.ListSkills()
+	require.Len(t, all, 2)
+	assert.Equal(t, []string{"i2c", "spi"}, all[0].RequiredTools)
+	assert.Equal(t, []string{"spi"}, all[0].MissingTools(func(name string) bool { return name == "i2c" }))
+	assert.Empty(t, all[1].RequiredTools)
+
+	sl.SetFilter(func(s SkillInfo) bool { return s.Name != "tmux" })
+	filtered := sl.ListSkills()
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "hardware", filtered[0].Name)
+	assert.NotContains(t, sl.BuildSkillsSummary(), "tmux")
+}
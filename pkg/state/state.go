@@ -23,6 +23,26 @@ type State struct {
 
 	// Timestamp is the last time this state was updated
 	Timestamp time.Time `json:"timestamp"`
+
+	// PendingApprovals are tool calls waiting for a user's approval
+	PendingApprovals []PendingApproval `json:"pending_approvals,omitempty"`
+}
+
+// PendingApproval is a tool call that was paused until a user approves or
+// denies it. It is kept in the state so the prompt can still be answered
+// after a restart.
+type PendingApproval struct {
+	ID          string         `json:"id"`
+	AgentID     string         `json:"agent_id"`
+	SessionKey  string         `json:"session_key"`
+	Channel     string         `json:"channel"`
+	ChatID      string         `json:"chat_id"`
+	RequestedBy string         `json:"requested_by,omitempty"`
+	ToolCallID  string         `json:"tool_call_id"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
 }
 
 // Manager manages persistent state with atomic saves.
@@ -123,6 +143,60 @@ func (sm *Manager) GetTimestamp() time.Time {
 	return sm.state.Timestamp
 }
 
+// AddPendingApproval records a pending approval, replacing any with the same
+// ID, and saves the state.
+func (sm *Manager) AddPendingApproval(p PendingApproval) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.removePendingApproval(p.ID)
+	sm.state.PendingApprovals = append(sm.state.PendingApprovals, p)
+	sm.state.Timestamp = time.Now()
+
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
+
+	return nil
+}
+
+// RemovePendingApproval deletes the pending approval with the given ID and
+// saves the state. Unknown IDs are ignored.
+func (sm *Manager) RemovePendingApproval(id string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if !sm.removePendingApproval(id) {
+		return nil
+	}
+	sm.state.Timestamp = time.Now()
+
+	if err := sm.saveAtomic(); err != nil {
+		return fmt.Errorf("failed to save state atomically: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingApprovals returns a copy of the pending approvals.
+func (sm *Manager) GetPendingApprovals() []PendingApproval {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return append([]PendingApproval(nil), sm.state.PendingApprovals...)
+}
+
+// removePendingApproval reports whether an approval with the given ID was
+// removed. Must be called with the lock held.
+func (sm *Manager) removePendingApproval(id string) bool {
+	for i, p := range sm.state.PendingApprovals {
+		if p.ID == id {
+			sm.state.PendingApprovals = append(sm.state.PendingApprovals[:i], sm.state.PendingApprovals[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 // saveAtomic performs an atomic save using temp file + rename.
 // This ensures that the state file is never corrupted:
 // 1. Write to a temp file
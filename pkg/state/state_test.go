@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestAtomicSave(t *testing.T) {
@@ -214,3 +215,39 @@ func TestNewManager_EmptyWorkspace(t *testing.T) {
 		t.Error("Expected zero timestamp for new state")
 	}
 }
+
+func TestPendingApprovals(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewManager(tmpDir)
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	for _, id := range []string{"a1", "b2"} {
+		err := sm.AddPendingApproval(PendingApproval{
+			ID:         id,
+			SessionKey: "agent:main:main",
+			Channel:    "telegram",
+			ChatID:     "42",
+			Tool:       "exec",
+			Arguments:  map[string]any{"command": "rm -rf build"},
+			ExpiresAt:  expires,
+		})
+		if err != nil {
+			t.Fatalf("AddPendingApproval(%s) failed: %v", id, err)
+		}
+	}
+	if err := sm.RemovePendingApproval("a1"); err != nil {
+		t.Fatalf("RemovePendingApproval failed: %v", err)
+	}
+	if err := sm.RemovePendingApproval("missing"); err != nil {
+		t.Fatalf("RemovePendingApproval(missing) failed: %v", err)
+	}
+
+	// Pending approvals survive a restart
+	pending := NewManager(tmpDir).GetPendingApprovals()
+	if len(pending) != 1 || pending[0].ID != "b2" {
+		t.Fatalf("Expected only approval b2 after reload, got %+v", pending)
+	}
+	if pending[0].Arguments["command"] != "rm -rf build" || !pending[0].ExpiresAt.Equal(expires) {
+		t.Errorf("Approval not restored intact: %+v", pending[0])
+	}
+}
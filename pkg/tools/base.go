@@ -1,6 +1,9 @@
 package tools
 
-import "context"
+import (
+	"context"
+	"sync/atomic"
+)
 
 // Tool is the interface that all tools must implement.
 type Tool interface {
@@ -11,12 +14,146 @@ type Tool interface {
 }
 
 // ContextualTool is an optional interface that tools can implement
-// to receive the current message context (channel, chatID)
+// to receive a default message context (channel, chatID).
+//
+// The registry no longer calls SetContext on every execution because tool
+// instances are shared between concurrently running sessions. Per-invocation
+// context is carried on ctx instead (see WithToolContext); SetContext only
+// provides the fallback used when ctx carries no context, e.g. in the CLI.
 type ContextualTool interface {
 	Tool
 	SetContext(channel, chatID string)
 }
 
+type toolContextKey struct{}
+
+// toolContext holds the per-invocation state of a single processing round.
+type toolContext struct {
+	channel     string
+	chatID      string
+	sessionKey  string
+	callback    AsyncCallback
+	sentInRound *atomic.Bool
+}
+
+func toolContextFrom(ctx context.Context) *toolContext {
+	tc, _ := ctx.Value(toolContextKey{}).(*toolContext)
+	return tc
+}
+
+// WithToolContext returns a context carrying the channel and chat ID that tools
+// should target for this invocation. Round state (see WithRound) is inherited
+// from the parent context.
+func WithToolContext(ctx context.Context, channel, chatID string) context.Context {
+	tc := &toolContext{channel: channel, chatID: chatID}
+	if parent := toolContextFrom(ctx); parent != nil {
+		tc.sessionKey = parent.sessionKey
+		tc.callback = parent.callback
+		tc.sentInRound = parent.sentInRound
+	}
+	return context.WithValue(ctx, toolContextKey{}, tc)
+}
+
+// WithToolSession returns a context carrying the session key of this
+// invocation, for tools that keep per-session state.
+func WithToolSession(ctx context.Context, sessionKey string) context.Context {
+	tc := &toolContext{sessionKey: sessionKey}
+	if parent := toolContextFrom(ctx); parent != nil {
+		tc.channel = parent.channel
+		tc.chatID = parent.chatID
+		tc.callback = parent.callback
+		tc.sentInRound = parent.sentInRound
+	}
+	return context.WithValue(ctx, toolContextKey{}, tc)
+}
+
+// ToolSessionKey returns the session key carried by ctx, or "" if none.
+func ToolSessionKey(ctx context.Context) string {
+	if tc := toolContextFrom(ctx); tc != nil {
+		return tc.sessionKey
+	}
+	return ""
+}
+
+// ToolChannel returns the channel carried by ctx, or "" if none.
+func ToolChannel(ctx context.Context) string {
+	if tc := toolContextFrom(ctx); tc != nil {
+		return tc.channel
+	}
+	return ""
+}
+
+// ToolChatID returns the chat ID carried by ctx, or "" if none.
+func ToolChatID(ctx context.Context) string {
+	if tc := toolContextFrom(ctx); tc != nil {
+		return tc.chatID
+	}
+	return ""
+}
+
+// toolTarget returns the channel and chat ID carried by ctx, falling back to
+// the given defaults when ctx carries none.
+func toolTarget(ctx context.Context, defaultChannel, defaultChatID string) (string, string) {
+	if tc := toolContextFrom(ctx); tc != nil && tc.channel != "" && tc.chatID != "" {
+		return tc.channel, tc.chatID
+	}
+	return defaultChannel, defaultChatID
+}
+
+// withAsyncCallback returns a context carrying the async completion callback
+// for this invocation.
+func withAsyncCallback(ctx context.Context, cb AsyncCallback) context.Context {
+	tc := &toolContext{callback: cb}
+	if parent := toolContextFrom(ctx); parent != nil {
+		tc.channel = parent.channel
+		tc.chatID = parent.chatID
+		tc.sessionKey = parent.sessionKey
+		tc.sentInRound = parent.sentInRound
+	}
+	return context.WithValue(ctx, toolContextKey{}, tc)
+}
+
+// asyncCallbackFrom returns the async callback carried by ctx, or nil.
+func asyncCallbackFrom(ctx context.Context) AsyncCallback {
+	if tc := toolContextFrom(ctx); tc != nil {
+		return tc.callback
+	}
+	return nil
+}
+
+// Round tracks side effects of tools during a single processing round.
+type Round struct {
+	sent atomic.Bool
+}
+
+// WithRound starts a new processing round and returns a context carrying it.
+// Tools executed with the returned context (or one derived from it) report
+// their side effects to the returned Round.
+func WithRound(ctx context.Context) (context.Context, *Round) {
+	r := &Round{}
+	tc := &toolContext{sentInRound: &r.sent}
+	if parent := toolContextFrom(ctx); parent != nil {
+		tc.channel = parent.channel
+		tc.chatID = parent.chatID
+		tc.sessionKey = parent.sessionKey
+		tc.callback = parent.callback
+	}
+	return context.WithValue(ctx, toolContextKey{}, tc), r
+}
+
+// HasSentMessage reports whether the message tool delivered a message during
+// this round.
+func (r *Round) HasSentMessage() bool {
+	return r.sent.Load()
+}
+
+// markMessageSent records that a message was delivered in the round carried by ctx.
+func markMessageSent(ctx context.Context) {
+	if tc := toolContextFrom(ctx); tc != nil && tc.sentInRound != nil {
+		tc.sentInRound.Store(true)
+	}
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
@@ -41,7 +178,8 @@ type AsyncCallback func(ctx context.Context, result *ToolResult)
 // asynchronous execution with completion callbacks.
 //
 // Async tools return immediately with an AsyncResult, then notify completion
-// via the callback set by SetCallback.
+// via the callback carried on ctx by ToolRegistry.ExecuteWithContext, falling
+// back to the callback set by SetCallback.
 //
 // This is useful for:
 // - Long-running operations that shouldn't block the agent loop
@@ -69,6 +207,15 @@ type AsyncTool interface {
 	SetCallback(cb AsyncCallback)
 }
 
+// ConcurrentTool is an optional interface for tools that may run in parallel
+// with other calls in the same LLM turn. Only tools without side effects that
+// other calls could observe (reads, fetches, searches) should report true.
+// Tools that don't implement it always run on their own, in call order.
+type ConcurrentTool interface {
+	Tool
+	ConcurrencySafe() bool
+}
+
 func ToolToSchema(tool Tool) map[string]any {
 	return map[string]any{
 		"type": "function",
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Delegator runs subagent tasks on other agents, with the target agent's
+// own model, workspace, skills and tools.
+type Delegator interface {
+	// ResolveAgent returns the normalized ID of the named agent, or false if
+	// there is no such agent.
+	ResolveAgent(agentID string) (string, bool)
+	// Delegate runs req on its target agent and returns the final response.
+	Delegate(ctx context.Context, req DelegateRequest) (string, error)
+}
+
+// DelegateRequest is a task handed to another agent.
+type DelegateRequest struct {
+	TaskID  string
+	AgentID string // normalized target agent ID
+	Task    string
+	Channel string // chat the task was spawned from
+	ChatID  string
+}
+
+// DelegationResult is the structured result of a delegated task, returned
+// to the delegating agent as JSON.
+type DelegationResult struct {
+	TaskID  string   `json:"task_id"`
+	AgentID string   `json:"agent_id"`
+	Status  string   `json:"status"`
+	Result  string   `json:"result"`
+	Chain   []string `json:"chain"`
+}
+
+// SetDelegator lets tasks spawned with an agent ID run on that agent.
+// ownerID is the agent the manager belongs to; maxDepth bounds how many
+// times a task may be delegated onwards.
+func (sm *SubagentManager) SetDelegator(ownerID string, maxDepth int, d Delegator) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.ownerID = ownerID
+	sm.maxDepth = maxDepth
+	sm.delegator = d
+}
+
+// delegationChain returns the delegation path of a task spawned in ctx for
+// agentID, ending with the target's normalized ID. It fails for unknown
+// agents, cycles and chains longer than the depth limit. Must be called with
+// sm.mu held.
+func (sm *SubagentManager) delegationChain(ctx context.Context, agentID string) ([]string, error) {
+	target, ok := sm.delegator.ResolveAgent(agentID)
+	if !ok {
+		return nil, fmt.Errorf("unknown agent '%s'", agentID)
+	}
+	chain := DelegationChain(ctx)
+	if len(chain) == 0 {
+		chain = []string{sm.ownerID}
+	}
+	if slices.Contains(chain, target) {
+		return nil, fmt.Errorf("delegation cycle: %s -> %s", strings.Join(chain, " -> "), target)
+	}
+	if len(chain) > sm.maxDepth {
+		return nil, fmt.Errorf("delegation depth limit of %d reached: %s", sm.maxDepth, strings.Join(chain, " -> "))
+	}
+	return append(slices.Clone(chain), target), nil
+}
+
+type delegationKey struct{}
+
+func withDelegationChain(ctx context.Context, chain []string) context.Context {
+	return context.WithValue(ctx, delegationKey{}, chain)
+}
+
+// DelegationChain returns the delegation path of the task running in ctx,
+// from the first delegating agent to the agent running it, or nil outside
+// delegated tasks.
+func DelegationChain(ctx context.Context) []string {
+	chain, _ := ctx.Value(delegationKey{}).([]string)
+	return chain
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type fakeDelegator struct{}
+
+func (fakeDelegator) ResolveAgent(agentID string) (string, bool) {
+	id := strings.ToLower(agentID)
+	return id, id == "main" || id == "researcher" || id == "coder"
+}
+
+func (fakeDelegator) Delegate(ctx context.Context, req DelegateRequest) (string, error) {
+	return "done by " + req.AgentID, nil
+}
+
+func TestSubagentManager_DelegationChain(t *testing.T) {
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "", nil)
+	sm.SetDelegator("main", 2, fakeDelegator{})
+
+	tests := []struct {
+		chain   []string
+		target  string
+		want    string
+		wantErr string
+	}{
+		{nil, "Researcher", "main -> researcher", ""},
+		{[]string{"main", "researcher"}, "coder", "main -> researcher -> coder", ""},
+		{[]string{"main", "researcher"}, "main", "", "delegation cycle: main -> researcher -> main"},
+		{[]string{"main", "researcher", "coder"}, "other", "", "unknown agent 'other'"},
+		{[]string{"researcher", "coder", "x"}, "main", "", "delegation depth limit of 2 reached"},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.chain != nil {
+			ctx = withDelegationChain(ctx, tt.chain)
+		}
+		chain, err := sm.delegationChain(ctx, tt.target)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%v -> %s: expected error %q, got %v", tt.chain, tt.target, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || strings.Join(chain, " -> ") != tt.want {
+			t.Errorf("%v -> %s: got %v, %v, want %s", tt.chain, tt.target, chain, err, tt.want)
+		}
+	}
+}
+
+func TestSubagentManager_DelegatedTask(t *testing.T) {
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "", nil)
+	sm.SetDelegator("main", 2, fakeDelegator{})
+
+	results := make(chan *ToolResult, 1)
+	_, err := sm.Spawn(context.Background(), "research", "", "Researcher", "cli", "direct",
+		func(_ context.Context, r *ToolResult) { results <- r })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-results
+	want := `{"task_id":"subagent-1","agent_id":"researcher","status":"completed",` +
+		`"result":"done by researcher","chain":["main","researcher"]}`
+	if r.IsError || r.ForLLM != want {
+		t.Errorf("unexpected result %s, want %s", r.ForLLM, want)
+	}
+	if task, _ := sm.GetTask("subagent-1"); task.AgentID != "researcher" || task.Result != "done by researcher" {
+		t.Errorf("unexpected task %+v", task)
+	}
+}
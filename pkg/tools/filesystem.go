@@ -101,8 +101,12 @@ func (t *ReadFileTool) Name() string {
 	return "read_file"
 }
 
+func (t *ReadFileTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file"
+	return "Read the contents of a file. For large files, pass offset and limit to read a range of lines."
 }
 
 func (t *ReadFileTool) Parameters() map[string]any {
@@ -113,6 +117,14 @@ func (t *ReadFileTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Path to the file to read",
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Line number to start reading from (1-based)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of lines to read",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -128,7 +140,47 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
-	return NewToolResult(string(content))
+
+	offset, hasOffset := args["offset"].(float64)
+	limit, hasLimit := args["limit"].(float64)
+	if !hasOffset && !hasLimit {
+		return NewToolResult(string(content))
+	}
+	return readLineRange(string(content), int(offset), int(limit))
+}
+
+// readLineRange returns limit lines of content starting at the 1-based line
+// offset, followed by a note on where the range lies in the file. Offsets
+// below 1 start at the first line; a limit below 1 reads to the end.
+func readLineRange(content string, offset, limit int) *ToolResult {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+
+	start := max(offset, 1)
+	if start > total {
+		return ErrorResult(fmt.Sprintf("offset %d is beyond the end of the file (%d lines)", start, total))
+	}
+	end := total
+	if limit > 0 {
+		end = min(start-1+limit, total)
+	}
+
+	var sb strings.Builder
+	for _, line := range lines[start-1 : end] {
+		sb.WriteString(line)
+	}
+	if !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+	if end < total {
+		fmt.Fprintf(&sb, "[Lines %d-%d of %d. Use offset=%d to read more.]", start, end, total, end+1)
+	} else {
+		fmt.Fprintf(&sb, "[Lines %d-%d of %d.]", start, end, total)
+	}
+	return NewToolResult(sb.String())
 }
 
 type WriteFileTool struct {
@@ -206,6 +258,10 @@ func (t *ListDirTool) Name() string {
 	return "list_dir"
 }
 
+func (t *ListDirTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *ListDirTool) Description() string {
 	return "List files and directories in a path"
 }
@@ -254,6 +310,10 @@ type fileSystem interface {
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, data []byte) error
 	ReadDir(path string) ([]os.DirEntry, error)
+	Remove(path string) error
+	// DirFS returns a read-only view of the tree rooted at path, which may
+	// also name a single file, and a function releasing it.
+	DirFS(path string) (fs.FS, func(), error)
 }
 
 // hostFs is an unrestricted fileReadWriter that operates directly on the host filesystem.
@@ -277,6 +337,17 @@ func (h *hostFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return os.ReadDir(path)
 }
 
+func (h *hostFs) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (h *hostFs) DirFS(path string) (fs.FS, func(), error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+	return os.DirFS(path), func() {}, nil
+}
+
 func (h *hostFs) WriteFile(path string, data []byte) error {
 	// Use unified atomic write utility with explicit sync for flash storage reliability.
 	// Using 0o600 (owner read/write only) for secure default permissions.
@@ -394,6 +465,36 @@ func (r *sandboxFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return entries, err
 }
 
+func (r *sandboxFs) Remove(path string) error {
+	return r.execute(path, func(root *os.Root, relPath string) error {
+		return root.Remove(relPath)
+	})
+}
+
+func (r *sandboxFs) DirFS(path string) (fs.FS, func(), error) {
+	if r.workspace == "" {
+		return nil, nil, fmt.Errorf("workspace is not defined")
+	}
+	relPath, err := getSafeRelPath(r.workspace, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := os.OpenRoot(r.workspace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+	if _, err := root.Stat(relPath); err != nil {
+		root.Close()
+		return nil, nil, err
+	}
+	sub, err := fs.Sub(root.FS(), filepath.ToSlash(relPath))
+	if err != nil {
+		root.Close()
+		return nil, nil, err
+	}
+	return sub, func() { root.Close() }, nil
+}
+
 // Helper to get a safe relative path for os.Root usage
 func getSafeRelPath(workspace, path string) (string, error) {
 	if workspace == "" {
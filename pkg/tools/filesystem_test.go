@@ -486,3 +486,25 @@ func TestRootRW_Write(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, newData, content)
 }
+
+func TestFilesystemTool_ReadFile_LineRange(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "lines.txt")
+	os.WriteFile(testFile, []byte("one\ntwo\nthree\nfour\nfive\n"), 0o644)
+
+	tool := NewReadFileTool(tmpDir, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"path": "lines.txt", "offset": 2.0, "limit": 2.0})
+	assert.False(t, result.IsError)
+	assert.Equal(t, "two\nthree\n[Lines 2-3 of 5. Use offset=4 to read more.]", result.ForLLM)
+
+	result = tool.Execute(ctx, map[string]any{"path": "lines.txt", "offset": 4.0})
+	assert.Equal(t, "four\nfive\n[Lines 4-5 of 5.]", result.ForLLM)
+
+	result = tool.Execute(ctx, map[string]any{"path": "lines.txt", "limit": 1.0})
+	assert.Equal(t, "one\n[Lines 1-1 of 5. Use offset=2 to read more.]", result.ForLLM)
+
+	result = tool.Execute(ctx, map[string]any{"path": "lines.txt", "offset": 9.0})
+	assert.True(t, result.IsError)
+}
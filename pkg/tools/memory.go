@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// maxMemorySearchLimit bounds the results of one memory_search call.
+const maxMemorySearchLimit = 20
+
+// MemorySaveTool saves a record to the agent's long-term memory.
+type MemorySaveTool struct {
+	store *memory.Store
+}
+
+func NewMemorySaveTool(store *memory.Store) *MemorySaveTool {
+	return &MemorySaveTool{store: store}
+}
+
+func (t *MemorySaveTool) Name() string {
+	return "memory_save"
+}
+
+func (t *MemorySaveTool) Description() string {
+	return "Save a fact, preference or decision to long-term memory so it can be recalled in later " +
+		"conversations. Keep each memory short and self-contained; save separate facts separately."
+}
+
+func (t *MemorySaveTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The memory, written so it makes sense without the current conversation",
+			},
+			"tags": memoryTagsParameter("Short topic tags, e.g. [\"preferences\", \"work\"]"),
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *MemorySaveTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	content, _ := args["content"].(string)
+	if strings.TrimSpace(content) == "" {
+		return ErrorResult("content is required")
+	}
+	rec, err := t.store.Save(content, stringList(args["tags"]), ToolSessionKey(ctx))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save memory: %v", err))
+	}
+	return SilentResult("Saved memory " + rec.String())
+}
+
+// MemorySearchTool searches the agent's long-term memory.
+type MemorySearchTool struct {
+	store *memory.Store
+}
+
+func NewMemorySearchTool(store *memory.Store) *MemorySearchTool {
+	return &MemorySearchTool{store: store}
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+func (t *MemorySearchTool) Description() string {
+	return "Search long-term memory by keywords, best matches first. " +
+		"Use it to recall facts beyond the memories already shown in the system prompt."
+}
+
+func (t *MemorySearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Keywords to search for; may be empty when tags are given",
+			},
+			"tags": memoryTagsParameter("Only return memories carrying all of these tags"),
+			"limit": map[string]any{
+				"type": "integer",
+				"description": fmt.Sprintf("Maximum number of memories to return (default %d, max %d)",
+					memory.DefaultSearchLimit, maxMemorySearchLimit),
+			},
+		},
+	}
+}
+
+func (t *MemorySearchTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	tags := stringList(args["tags"])
+	if strings.TrimSpace(query) == "" && len(tags) == 0 {
+		return ErrorResult("query or tags is required")
+	}
+	limit := memory.DefaultSearchLimit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = min(int(l), maxMemorySearchLimit)
+	}
+
+	results, err := t.store.Search(query, memory.SearchOptions{Tags: tags, Limit: limit})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search memory: %v", err))
+	}
+	if len(results) == 0 {
+		return SilentResult("No matching memories")
+	}
+	lines := make([]string, len(results))
+	for i := range results {
+		lines[i] = results[i].String()
+	}
+	return SilentResult(strings.Join(lines, "\n"))
+}
+
+// MemoryForgetTool removes a record from the agent's long-term memory.
+type MemoryForgetTool struct {
+	store *memory.Store
+}
+
+func NewMemoryForgetTool(store *memory.Store) *MemoryForgetTool {
+	return &MemoryForgetTool{store: store}
+}
+
+func (t *MemoryForgetTool) Name() string {
+	return "memory_forget"
+}
+
+func (t *MemoryForgetTool) Description() string {
+	return "Remove a memory that is wrong or outdated, by the ID shown in brackets (e.g. mem-12). " +
+		"To correct a memory, forget it and save the corrected one."
+}
+
+func (t *MemoryForgetTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The memory ID",
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *MemoryForgetTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	id, _ := args["id"].(string)
+	id = strings.Trim(strings.TrimSpace(id), "[]")
+	if id == "" {
+		return ErrorResult("id is required")
+	}
+	rec, err := t.store.Forget(id)
+	if errors.Is(err, memory.ErrNotFound) {
+		return ErrorResult(fmt.Sprintf("no memory with id %s", id))
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to forget memory: %v", err))
+	}
+	return SilentResult("Forgot memory " + rec.String())
+}
+
+func memoryTagsParameter(description string) map[string]any {
+	return map[string]any{
+		"type":        "array",
+		"items":       map[string]any{"type": "string"},
+		"description": description,
+	}
+}
+
+// stringList returns the strings of a JSON array argument.
+func stringList(arg any) []string {
+	items, _ := arg.([]any)
+	var out []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
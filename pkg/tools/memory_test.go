@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func TestMemoryTools_SaveSearchForget(t *testing.T) {
+	store := memory.NewStore(t.TempDir())
+	ctx := WithToolSession(context.Background(), "telegram:42")
+
+	save := NewMemorySaveTool(store)
+	result := save.Execute(ctx, map[string]any{
+		"content": "User's favourite editor is Helix",
+		"tags":    []any{"preferences", "tools"},
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, "[mem-1]") {
+		t.Fatalf("unexpected save result: %+v", result)
+	}
+	if rec, _ := store.Get("mem-1"); rec.Session != "telegram:42" {
+		t.Errorf("expected the record to carry the session, got %q", rec.Session)
+	}
+	if result := save.Execute(ctx, map[string]any{"content": "  "}); !result.IsError {
+		t.Error("expected empty content to be rejected")
+	}
+
+	search := NewMemorySearchTool(store)
+	result = search.Execute(ctx, map[string]any{"query": "which editor"})
+	if result.IsError || !strings.Contains(result.ForLLM, "Helix") {
+		t.Fatalf("unexpected search result: %+v", result)
+	}
+	result = search.Execute(ctx, map[string]any{"query": "weather"})
+	if result.ForLLM != "No matching memories" {
+		t.Errorf("expected no matches, got %q", result.ForLLM)
+	}
+	if result := search.Execute(ctx, map[string]any{}); !result.IsError {
+		t.Error("expected a search without query or tags to be rejected")
+	}
+
+	forget := NewMemoryForgetTool(store)
+	if result := forget.Execute(ctx, map[string]any{"id": "[mem-1]"}); result.IsError {
+		t.Fatalf("unexpected forget result: %+v", result)
+	}
+	if result := forget.Execute(ctx, map[string]any{"id": "mem-1"}); !result.IsError ||
+		result.ForLLM != "no memory with id mem-1" {
+		t.Errorf("expected an unknown ID to be reported, got %+v", result)
+	}
+}
@@ -257,3 +257,45 @@ func TestMessageTool_Parameters(t *testing.T) {
 		t.Error("Expected chat_id type to be 'string'")
 	}
 }
+
+func TestMessageTool_Execute_UsesToolContext(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetContext("default-channel", "default-chat-id")
+
+	var sentChannel, sentChatID string
+	tool.SetSendCallback(func(channel, chatID, content string) error {
+		sentChannel = channel
+		sentChatID = chatID
+		return nil
+	})
+
+	ctx := WithToolContext(context.Background(), "telegram", "chat-1")
+	tool.Execute(ctx, map[string]any{"content": "hi"})
+
+	if sentChannel != "telegram" || sentChatID != "chat-1" {
+		t.Errorf("Expected ctx target telegram:chat-1, got %s:%s", sentChannel, sentChatID)
+	}
+}
+
+func TestMessageTool_Execute_RoundTracking(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetSendCallback(func(channel, chatID, content string) error { return nil })
+
+	ctxA, roundA := WithRound(WithToolContext(context.Background(), "telegram", "a"))
+	ctxB, roundB := WithRound(WithToolContext(context.Background(), "telegram", "b"))
+
+	tool.Execute(ctxA, map[string]any{"content": "hi"})
+
+	if !roundA.HasSentMessage() {
+		t.Error("Expected round A to record the sent message")
+	}
+	if roundB.HasSentMessage() {
+		t.Error("Expected round B to be unaffected by round A")
+	}
+
+	// Tool context set after the round starts must keep the round
+	tool.Execute(WithToolContext(ctxB, "discord", "c"), map[string]any{"content": "hi"})
+	if !roundB.HasSentMessage() {
+		t.Error("Expected round B to record the sent message")
+	}
+}
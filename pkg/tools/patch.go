@@ -0,0 +1,422 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	maxUndoEntries  = 20      // per session
+	maxUndoBytes    = 4 << 20 // per session, counting file contents kept for undo
+	maxPatchPreview = 10000
+)
+
+// ErrNothingToUndo is returned by ApplyPatchTool.Undo when the session has no
+// patch to revert.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ApplyPatchTool changes several files at once, given either a unified diff
+// or a list of exact-text edits. Every change is validated before any file
+// is written, so a patch that doesn't apply leaves the workspace untouched.
+// Applied patches are journaled per session and can be reverted with Undo.
+type ApplyPatchTool struct {
+	fs      fileSystem
+	journal *undoJournal
+}
+
+// NewApplyPatchTool creates an ApplyPatchTool with optional directory restriction.
+func NewApplyPatchTool(workspace string, restrict bool) *ApplyPatchTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &ApplyPatchTool{fs: fs, journal: newUndoJournal()}
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Change one or more files in a single step, either with a unified diff (patch) or with a list " +
+		"of exact-text replacements (edits). Nothing is written unless every change applies. " +
+		"Set dry_run to preview the resulting diff without writing. Prefer this over several " +
+		"edit_file calls when a change spans multiple places or files."
+}
+
+func (t *ApplyPatchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"patch": map[string]any{
+				"type": "string",
+				"description": "Unified diff as produced by diff -u or git diff. Use /dev/null as the old " +
+					"path to create a file and as the new path to delete one.",
+			},
+			"edits": map[string]any{
+				"type":        "array",
+				"description": "Exact-text replacements, applied in order",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{
+							"type":        "string",
+							"description": "The file path to edit",
+						},
+						"old_text": map[string]any{
+							"type":        "string",
+							"description": "The exact text to replace; must occur once. Empty to create the file.",
+						},
+						"new_text": map[string]any{
+							"type":        "string",
+							"description": "The text to replace with",
+						},
+					},
+					"required": []string{"path", "old_text", "new_text"},
+				},
+			},
+			"dry_run": map[string]any{
+				"type":        "boolean",
+				"description": "Validate and show the diff without writing anything",
+			},
+		},
+	}
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	patch, _ := args["patch"].(string)
+	edits, _ := args["edits"].([]any)
+	if (patch == "") == (len(edits) == 0) {
+		return ErrorResult("provide either patch or edits")
+	}
+
+	plan := newPatchPlan(t.fs)
+	var err error
+	if patch != "" {
+		err = plan.addPatch(patch)
+	} else {
+		err = plan.addEdits(edits)
+	}
+	if err != nil {
+		return ErrorResult(err.Error() + " (no files were changed)")
+	}
+	changes := plan.changed()
+	if len(changes) == 0 {
+		return ErrorResult("patch makes no changes")
+	}
+
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		var preview strings.Builder
+		for _, c := range changes {
+			preview.WriteString(c.diff())
+		}
+		return NewToolResult("Dry run, nothing was written.\n" + summarizeChanges(changes) + "\n\n" +
+			utils.Truncate(preview.String(), maxPatchPreview))
+	}
+
+	if err := t.commit(changes); err != nil {
+		return ErrorResult(err.Error())
+	}
+	t.journal.record(ToolSessionKey(ctx), changes)
+	return SilentResult("Patch applied:\n" + summarizeChanges(changes))
+}
+
+// commit writes changes in order. If a write fails, the files already
+// written are restored.
+func (t *ApplyPatchTool) commit(changes []*fileChange) error {
+	for i, c := range changes {
+		if err := c.apply(t.fs); err != nil {
+			for _, done := range slices.Backward(changes[:i]) {
+				if rerr := done.revert(t.fs); rerr != nil {
+					logger.WarnCF("tool", "Failed to roll back patched file", map[string]any{
+						"path":  done.path,
+						"error": rerr.Error(),
+					})
+				}
+			}
+			return fmt.Errorf("failed to write %s: %v (no files were changed)", c.path, err)
+		}
+	}
+	return nil
+}
+
+// Undo reverts the most recent patch applied in the session. It refuses if
+// any of the files was changed since; that patch is then dropped from the
+// journal.
+func (t *ApplyPatchTool) Undo(sessionKey string) (string, error) {
+	changes, ok := t.journal.pop(sessionKey)
+	if !ok {
+		return "", ErrNothingToUndo
+	}
+
+	var modified []string
+	for _, c := range changes {
+		data, err := t.fs.ReadFile(c.path)
+		exists := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) || exists != c.exists || !bytes.Equal(data, c.after) {
+			modified = append(modified, c.path)
+		}
+	}
+	if len(modified) > 0 {
+		return "", fmt.Errorf("cannot undo: %s changed since the patch was applied",
+			strings.Join(modified, ", "))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Reverted the last patch:")
+	for _, c := range slices.Backward(changes) {
+		if err := c.revert(t.fs); err != nil {
+			return "", fmt.Errorf("failed to restore %s: %v", c.path, err)
+		}
+		if c.existed {
+			sb.WriteString("\nrestored " + c.path)
+		} else {
+			sb.WriteString("\nremoved " + c.path)
+		}
+	}
+	return sb.String(), nil
+}
+
+// fileChange is the planned change of one file.
+type fileChange struct {
+	path    string
+	existed bool
+	before  []byte
+	exists  bool
+	after   []byte
+}
+
+func (c *fileChange) isChange() bool {
+	return c.existed != c.exists || !bytes.Equal(c.before, c.after)
+}
+
+func (c *fileChange) apply(fsys fileSystem) error {
+	if c.exists {
+		return fsys.WriteFile(c.path, c.after)
+	}
+	return fsys.Remove(c.path)
+}
+
+func (c *fileChange) revert(fsys fileSystem) error {
+	if c.existed {
+		return fsys.WriteFile(c.path, c.before)
+	}
+	return fsys.Remove(c.path)
+}
+
+func (c *fileChange) ops() []diffOp {
+	return diffLines(splitLines(string(c.before)), splitLines(string(c.after)))
+}
+
+func (c *fileChange) diff() string {
+	oldName, newName := c.path, c.path
+	if !c.existed {
+		oldName = "/dev/null"
+	}
+	if !c.exists {
+		newName = "/dev/null"
+	}
+	return formatUnifiedDiff(oldName, newName, c.ops())
+}
+
+// summarizeChanges lists changes like git's name-status with line counts.
+func summarizeChanges(changes []*fileChange) string {
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		added, removed := diffStat(c.ops())
+		status := "M"
+		switch {
+		case !c.existed:
+			status = "A"
+		case !c.exists:
+			status = "D"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (+%d -%d)", status, c.path, added, removed))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// patchPlan accumulates the changes of a patch in memory, so later parts of
+// a patch see the effect of earlier ones on the same file.
+type patchPlan struct {
+	fs     fileSystem
+	files  []*fileChange
+	byPath map[string]*fileChange
+}
+
+func newPatchPlan(fsys fileSystem) *patchPlan {
+	return &patchPlan{fs: fsys, byPath: make(map[string]*fileChange)}
+}
+
+func (p *patchPlan) file(path string) (*fileChange, error) {
+	key := filepath.Clean(path)
+	if c, ok := p.byPath[key]; ok {
+		return c, nil
+	}
+	c := &fileChange{path: path}
+	data, err := p.fs.ReadFile(path)
+	switch {
+	case err == nil:
+		c.existed, c.exists = true, true
+		c.before, c.after = data, data
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	p.files = append(p.files, c)
+	p.byPath[key] = c
+	return c, nil
+}
+
+// changed returns the files whose content actually changes.
+func (p *patchPlan) changed() []*fileChange {
+	var changes []*fileChange
+	for _, c := range p.files {
+		if c.isChange() {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func (p *patchPlan) addPatch(patch string) error {
+	filePatches, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return err
+	}
+	for _, fp := range filePatches {
+		if err := p.addFilePatch(fp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *patchPlan) addFilePatch(fp filePatch) error {
+	var content string
+	if fp.oldPath != "" {
+		src, err := p.file(fp.oldPath)
+		if err != nil {
+			return err
+		}
+		if !src.exists {
+			return fmt.Errorf("%s: file not found", fp.oldPath)
+		}
+		content = string(src.after)
+		if fp.newPath == "" {
+			src.exists, src.after = false, nil
+			return nil
+		}
+		if fp.newPath != fp.oldPath {
+			src.exists, src.after = false, nil // renamed
+		}
+	}
+
+	dst, err := p.file(fp.newPath)
+	if err != nil {
+		return err
+	}
+	if fp.oldPath != fp.newPath && dst.exists {
+		return fmt.Errorf("%s: file already exists", fp.newPath)
+	}
+	if fp.oldPath == fp.newPath && len(fp.hunks) == 0 {
+		return fmt.Errorf("%s: patch has no hunks", fp.newPath)
+	}
+	result, err := applyHunks(content, fp.hunks)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fp.newPath, err)
+	}
+	dst.exists, dst.after = true, []byte(result)
+	return nil
+}
+
+func (p *patchPlan) addEdits(edits []any) error {
+	for i, item := range edits {
+		edit, _ := item.(map[string]any)
+		path, _ := edit["path"].(string)
+		oldText, okOld := edit["old_text"].(string)
+		newText, okNew := edit["new_text"].(string)
+		if path == "" || !okOld || !okNew {
+			return fmt.Errorf("edit %d: path, old_text and new_text are required", i+1)
+		}
+
+		c, err := p.file(path)
+		if err != nil {
+			return err
+		}
+		if oldText == "" {
+			if c.exists && len(c.after) > 0 {
+				return fmt.Errorf("edit %d: old_text is empty but %s already exists", i+1, path)
+			}
+			c.exists, c.after = true, []byte(newText)
+			continue
+		}
+		if !c.exists {
+			return fmt.Errorf("edit %d: %s: file not found", i+1, path)
+		}
+		content, err := replaceEditContent(c.after, oldText, newText)
+		if err != nil {
+			return fmt.Errorf("edit %d: %s: %v", i+1, path, err)
+		}
+		c.after = content
+	}
+	return nil
+}
+
+// undoJournal keeps the recent patches of each session for Undo.
+type undoJournal struct {
+	mu       sync.Mutex
+	sessions map[string][][]*fileChange
+}
+
+func newUndoJournal() *undoJournal {
+	return &undoJournal{sessions: make(map[string][][]*fileChange)}
+}
+
+// record adds a patch to the session's journal, dropping the oldest patches
+// beyond maxUndoEntries or maxUndoBytes.
+func (j *undoJournal) record(sessionKey string, changes []*fileChange) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := append(j.sessions[sessionKey], changes)
+	size := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		for _, c := range entries[i] {
+			size += len(c.before) + len(c.after)
+		}
+		if len(entries)-i > maxUndoEntries || size > maxUndoBytes {
+			entries = entries[i+1:]
+			break
+		}
+	}
+	j.sessions[sessionKey] = entries
+}
+
+func (j *undoJournal) pop(sessionKey string) ([]*fileChange, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := j.sessions[sessionKey]
+	if len(entries) == 0 {
+		return nil, false
+	}
+	last := entries[len(entries)-1]
+	if len(entries) == 1 {
+		delete(j.sessions, sessionKey)
+	} else {
+		j.sessions[sessionKey] = entries[:len(entries)-1]
+	}
+	return last, true
+}
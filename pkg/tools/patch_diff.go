@@ -0,0 +1,411 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	// maxLCSCells bounds the table used to diff the changed region of a file;
+	// larger regions are shown as a single replacement.
+	maxLCSCells = 4 << 20
+)
+
+// filePatch is the part of a unified diff that applies to one file. A path is
+// empty for /dev/null, i.e. when the file is created or deleted.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []patchHunk
+}
+
+type patchHunk struct {
+	header   string
+	oldStart int // 1-based line the hunk claims to start at, 0 if unknown
+	oldCount int // -1 if unknown
+	newCount int // -1 if unknown
+	lines    []diffOp
+	oldNoEOL bool // "\ No newline at end of file" after the old side
+	newNoEOL bool // same for the new side
+}
+
+// diffOp is one line of a diff: ' ' for context, '-' removed, '+' added.
+type diffOp struct {
+	op   byte
+	text string
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff parses a unified diff as produced by diff -u or git diff.
+// Lines outside of file headers and hunks, such as git's extended headers,
+// are ignored.
+func parseUnifiedDiff(diff string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	var patches []filePatch
+	var hunk *patchHunk
+	oldLeft, newLeft := -1, -1
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath, newPath := diffPath(line[4:]), diffPath(lines[i+1][4:])
+			if strings.HasPrefix(oldPath, "a/") && (strings.HasPrefix(newPath, "b/") || newPath == "") ||
+				oldPath == "" && strings.HasPrefix(newPath, "b/") {
+				oldPath, newPath = strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+			}
+			patches = append(patches, filePatch{oldPath: oldPath, newPath: newPath})
+			hunk = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if len(patches) == 0 {
+				return nil, fmt.Errorf("hunk %q comes before any ---/+++ file header", line)
+			}
+			fp := &patches[len(patches)-1]
+			fp.hunks = append(fp.hunks, parseHunkHeader(line))
+			hunk = &fp.hunks[len(fp.hunks)-1]
+			oldLeft, newLeft = hunk.oldCount, hunk.newCount
+		case hunk == nil:
+			// Text between files, e.g. "diff --git" or "index" lines.
+		case line != "" && (line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			hunk.lines = append(hunk.lines, diffOp{op: line[0], text: line[1:]})
+			if line[0] != '+' {
+				oldLeft--
+			}
+			if line[0] != '-' {
+				newLeft--
+			}
+		case strings.HasPrefix(line, `\`):
+			if n := len(hunk.lines); n > 0 {
+				switch hunk.lines[n-1].op {
+				case '-':
+					hunk.oldNoEOL = true
+				case '+':
+					hunk.newNoEOL = true
+				default:
+					hunk.oldNoEOL, hunk.newNoEOL = true, true
+				}
+			}
+		case line == "" && (oldLeft > 0 || newLeft > 0):
+			// A blank context line whose leading space was lost.
+			hunk.lines = append(hunk.lines, diffOp{op: ' '})
+			oldLeft--
+			newLeft--
+		default:
+			hunk = nil
+		}
+	}
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file headers (---/+++) found in patch")
+	}
+	for _, fp := range patches {
+		if fp.oldPath == "" && fp.newPath == "" {
+			return nil, fmt.Errorf("patch has a file with neither an old nor a new path")
+		}
+	}
+	return patches, nil
+}
+
+// diffPath extracts the path from a ---/+++ header line, dropping any
+// timestamp. It returns "" for /dev/null.
+func diffPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+func parseHunkHeader(line string) patchHunk {
+	h := patchHunk{header: line, oldCount: -1, newCount: -1}
+	m := hunkHeaderPattern.FindStringSubmatch(line)
+	if m == nil {
+		return h
+	}
+	h.header = m[0]
+	h.oldStart, _ = strconv.Atoi(m[1])
+	h.oldCount, h.newCount = 1, 1
+	if m[2] != "" {
+		h.oldCount, _ = strconv.Atoi(m[2])
+	}
+	if m[4] != "" {
+		h.newCount, _ = strconv.Atoi(m[4])
+	}
+	return h
+}
+
+// applyHunks applies hunks, in order, to content. Each hunk is located by
+// its context and removed lines; when they occur more than once, the
+// occurrence closest to the line number in the hunk header wins.
+func applyHunks(content string, hunks []patchHunk) (string, error) {
+	crlf := strings.Contains(content, "\r\n")
+	if crlf {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	eol := content == "" || strings.HasSuffix(content, "\n")
+	lines := splitLines(content)
+
+	var out []string
+	pos := 0
+	for i, h := range hunks {
+		var oldLines, newLines []string
+		for _, l := range h.lines {
+			if l.op != '+' {
+				oldLines = append(oldLines, l.text)
+			}
+			if l.op != '-' {
+				newLines = append(newLines, l.text)
+			}
+		}
+
+		at := findLines(lines, oldLines, pos, h.oldStart-1)
+		if len(oldLines) == 0 {
+			// Pure insertion: "-N,0" inserts after line N.
+			at = min(max(h.oldStart, pos), len(lines))
+		}
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (%s) does not match the file content", i+1, h.header)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, newLines...)
+		pos = at + len(oldLines)
+		if pos == len(lines) && (h.oldNoEOL || h.newNoEOL) {
+			eol = !h.newNoEOL
+		}
+	}
+	out = append(out, lines[pos:]...)
+
+	result := strings.Join(out, "\n")
+	if eol && len(out) > 0 {
+		result += "\n"
+	}
+	if crlf {
+		result = strings.ReplaceAll(result, "\n", "\r\n")
+	}
+	return result, nil
+}
+
+// findLines returns the index at or after from where want occurs in lines,
+// preferring the occurrence closest to near. Lines are compared exactly
+// first, then ignoring trailing whitespace. It returns -1 if there is none.
+func findLines(lines, want []string, from, near int) int {
+	for _, trim := range []bool{false, true} {
+		best := -1
+		for k := from; k+len(want) <= len(lines); k++ {
+			if !linesEqual(lines[k:k+len(want)], want, trim) {
+				continue
+			}
+			if best < 0 || abs(k-near) < abs(best-near) {
+				best = k
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+func linesEqual(a, b []string, trim bool) bool {
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		if !trim || strings.TrimRight(a[i], " \t") != strings.TrimRight(b[i], " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// splitLines splits content into lines without their terminators.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines returns the line operations turning a into b.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b)-prefix-suffix)
+	for _, l := range a[:prefix] {
+		ops = append(ops, diffOp{op: ' ', text: l})
+	}
+	ops = append(ops, lcsDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{op: ' ', text: l})
+	}
+	return ops
+}
+
+func lcsDiff(a, b []string) []diffOp {
+	var ops []diffOp
+	n, m := len(a), len(b)
+	if n*m > maxLCSCells {
+		for _, l := range a {
+			ops = append(ops, diffOp{op: '-', text: l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{op: '+', text: l})
+		}
+		return ops
+	}
+
+	// lcs[i*(m+1)+j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{op: ' ', text: a[i]})
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			ops = append(ops, diffOp{op: '-', text: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{op: '+', text: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{op: '-', text: a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{op: '+', text: b[j]})
+	}
+	return ops
+}
+
+// diffStat counts the added and removed lines in ops.
+func diffStat(ops []diffOp) (added, removed int) {
+	for _, o := range ops {
+		switch o.op {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// formatUnifiedDiff renders ops as a unified diff between oldName and newName.
+func formatUnifiedDiff(oldName, newName string, ops []diffOp) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	// oldLine and newLine count the lines before ops[i].
+	i, oldLine, newLine := 0, 0, 0
+	advance := func(o diffOp) {
+		if o.op != '+' {
+			oldLine++
+		}
+		if o.op != '-' {
+			newLine++
+		}
+	}
+	for i < len(ops) {
+		if ops[i].op == ' ' {
+			advance(ops[i])
+			i++
+			continue
+		}
+
+		// Back up over leading context, then extend the hunk while the next
+		// change is close enough for the contexts to touch.
+		start := i
+		for start > 0 && i-start < diffContextLines && ops[start-1].op == ' ' {
+			start--
+			if ops[start].op != '+' {
+				oldLine--
+			}
+			if ops[start].op != '-' {
+				newLine--
+			}
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].op != ' ' {
+				end++
+				continue
+			}
+			k := end
+			for k < len(ops) && ops[k].op == ' ' {
+				k++
+			}
+			if k == len(ops) || k-end > 2*diffContextLines {
+				end = min(end+diffContextLines, len(ops))
+				break
+			}
+			end = k
+		}
+
+		oldCount, newCount := 0, 0
+		for _, o := range ops[start:end] {
+			if o.op != '+' {
+				oldCount++
+			}
+			if o.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n",
+			hunkStart(oldLine, oldCount), oldCount, hunkStart(newLine, newCount), newCount)
+		for _, o := range ops[start:end] {
+			sb.WriteByte(o.op)
+			sb.WriteString(o.text)
+			sb.WriteByte('\n')
+			advance(o)
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkStart returns the start line of a hunk side in a header: the first
+// line, or for an empty side the line it follows.
+func hunkStart(before, count int) int {
+	if count == 0 {
+		return before
+	}
+	return before + 1
+}
@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyPatchTool_UnifiedDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":   "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+		"old.txt": "obsolete\n",
+	})
+	tool := NewApplyPatchTool(dir, true)
+
+	// Line numbers in the first hunk are off; the hunk is found by content.
+	patch := `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -5,3 +5,3 @@
+ one
+-two
++TWO
+ three
+@@ -8,3 +8,4 @@
+ eight
+ nine
++nine and a half
+ ten
+--- /dev/null
++++ b/new/b.txt
+@@ -0,0 +1,2 @@
++hello
++world
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-obsolete
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("apply failed: %s", result.ForLLM)
+	}
+	for _, want := range []string{"M a.txt (+2 -1)", "A new/b.txt (+2 -0)", "D old.txt (+0 -1)"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("expected %q in summary, got: %s", want, result.ForLLM)
+		}
+	}
+
+	if got, want := readFile(t, filepath.Join(dir, "a.txt")),
+		"one\nTWO\nthree\nfour\nfive\nsix\nseven\neight\nnine\nnine and a half\nten\n"; got != want {
+		t.Errorf("a.txt = %q, want %q", got, want)
+	}
+	if got := readFile(t, filepath.Join(dir, "new", "b.txt")); got != "hello\nworld\n" {
+		t.Errorf("b.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("old.txt should be deleted, stat err = %v", err)
+	}
+}
+
+func TestApplyPatchTool_AllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n"})
+	tool := NewApplyPatchTool(dir, true)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"edits": []any{
+			map[string]any{"path": "a.txt", "old_text": "alpha", "new_text": "ALPHA"},
+			map[string]any{"path": "b.txt", "old_text": "gamma", "new_text": "GAMMA"},
+		},
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "b.txt") {
+		t.Fatalf("expected error naming b.txt, got: %s", result.ForLLM)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.txt")); got != "alpha\n" {
+		t.Errorf("a.txt was modified by a failed patch: %q", got)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"patch": "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-nope\n+NOPE\n",
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "does not match") {
+		t.Errorf("expected mismatch error, got: %s", result.ForLLM)
+	}
+}
+
+func TestApplyPatchTool_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "one\ntwo\nthree\n"})
+	tool := NewApplyPatchTool(dir, true)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"edits":   []any{map[string]any{"path": "a.txt", "old_text": "two", "new_text": "2"}},
+		"dry_run": true,
+	})
+	if result.IsError {
+		t.Fatalf("dry run failed: %s", result.ForLLM)
+	}
+	want := "--- a.txt\n+++ a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"
+	if !strings.Contains(result.ForLLM, want) {
+		t.Errorf("expected diff preview %q, got: %s", want, result.ForLLM)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.txt")); got != "one\ntwo\nthree\n" {
+		t.Errorf("dry run wrote the file: %q", got)
+	}
+}
+
+func TestApplyPatchTool_Undo(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "v1\n"})
+	tool := NewApplyPatchTool(dir, true)
+	ctx := WithToolSession(context.Background(), "s1")
+
+	edit := func(oldText, newText string) {
+		t.Helper()
+		r := tool.Execute(ctx, map[string]any{"edits": []any{
+			map[string]any{"path": "a.txt", "old_text": oldText, "new_text": newText},
+			map[string]any{"path": "created.txt", "old_text": "", "new_text": newText},
+		}})
+		if r.IsError {
+			t.Fatalf("edit failed: %s", r.ForLLM)
+		}
+	}
+	edit("v1", "v2")
+	os.Remove(filepath.Join(dir, "created.txt"))
+	writeFiles(t, dir, map[string]string{"created.txt": ""})
+
+	if _, err := tool.Undo("other"); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("expected nothing to undo in another session, got %v", err)
+	}
+
+	reply, err := tool.Undo("s1")
+	if err == nil || !strings.Contains(err.Error(), "created.txt") {
+		t.Fatalf("expected undo to refuse after created.txt changed, got %q, %v", reply, err)
+	}
+
+	// The refused patch was dropped; undo the next one.
+	os.Remove(filepath.Join(dir, "created.txt"))
+	edit("v2", "v3")
+	reply, err = tool.Undo("s1")
+	if err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if !strings.Contains(reply, "restored a.txt") || !strings.Contains(reply, "removed created.txt") {
+		t.Errorf("unexpected undo reply: %s", reply)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.txt")); got != "v2\n" {
+		t.Errorf("a.txt after undo = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "created.txt")); !os.IsNotExist(err) {
+		t.Errorf("created.txt should be removed by undo, stat err = %v", err)
+	}
+}
+
+func TestApplyHunks_LineEndings(t *testing.T) {
+	hunks := func(patch string) []patchHunk {
+		fps, err := parseUnifiedDiff(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fps[0].hunks
+	}
+
+	got, err := applyHunks("a\r\nb\r\n", hunks("--- f\n+++ f\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"))
+	if err != nil || got != "a\r\nc\r\n" {
+		t.Errorf("CRLF: got %q, %v", got, err)
+	}
+
+	patch := "--- f\n+++ f\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n"
+	got, err = applyHunks("a", hunks(patch))
+	if err != nil || got != "b\n" {
+		t.Errorf("no EOL: got %q, %v", got, err)
+	}
+}
+
+func TestFormatUnifiedDiff_SeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		line := string(rune('a' + i - 1))
+		a = append(a, line)
+		if i == 2 {
+			line = "B"
+		}
+		if i == 18 {
+			line = "R"
+		}
+		b = append(b, line)
+	}
+	got := formatUnifiedDiff("x", "x", diffLines(a, b))
+	if strings.Count(got, "@@ -") != 2 {
+		t.Fatalf("expected two hunks, got:\n%s", got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@") || !strings.Contains(got, "@@ -15,6 +15,6 @@") {
+		t.Errorf("unexpected hunk headers:\n%s", got)
+	}
+}
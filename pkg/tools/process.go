@@ -0,0 +1,540 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultMaxProcesses    = 4
+	defaultProcessBufferKB = 64
+	maxProcessRead         = 10000
+	maxProcessWait         = 30 * time.Second
+)
+
+var processNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ProcessTool runs long-lived commands in the background, such as dev
+// servers, log tails and REPLs, and lets the agent interact with them across
+// tool calls. Commands go through the same guards and sandbox as the exec
+// tool. The output of each process is kept in a fixed-size ring buffer, so
+// chatty processes cannot exhaust memory.
+type ProcessTool struct {
+	exec         *ExecTool
+	maxProcesses int
+	bufferSize   int
+
+	mu        sync.Mutex
+	processes map[string]*backgroundProcess
+}
+
+type backgroundProcess struct {
+	name    string
+	command string
+	cmd     *exec.Cmd
+	stdinMu sync.Mutex
+	stdin   io.WriteCloser // nil once closed; guarded by stdinMu
+	output  *outputRing
+	cancel  context.CancelFunc
+	started time.Time
+	done    chan struct{} // closed when the process has exited
+	exitErr error         // valid once done is closed
+}
+
+// NewProcessTool creates a process tool that starts commands the way
+// execTool runs them. cfg may be nil to use the default limits.
+func NewProcessTool(execTool *ExecTool, cfg *config.Config) *ProcessTool {
+	maxProcesses, bufferKB := defaultMaxProcesses, defaultProcessBufferKB
+	if cfg != nil {
+		if cfg.Tools.Exec.MaxProcesses > 0 {
+			maxProcesses = cfg.Tools.Exec.MaxProcesses
+		}
+		if cfg.Tools.Exec.ProcessBufferKB > 0 {
+			bufferKB = cfg.Tools.Exec.ProcessBufferKB
+		}
+	}
+	return &ProcessTool{
+		exec:         execTool,
+		maxProcesses: maxProcesses,
+		bufferSize:   bufferKB * 1024,
+		processes:    make(map[string]*backgroundProcess),
+	}
+}
+
+func (t *ProcessTool) Name() string {
+	return "process"
+}
+
+func (t *ProcessTool) Description() string {
+	return "Run and manage long-running background processes such as dev servers, log tails and REPLs. " +
+		"Actions: start (run a command under a name), read (output since a cursor; stdout and stderr " +
+		"are interleaved), write (send text to stdin; include \"\\n\" to submit a line), signal " +
+		"(e.g. INT, TERM, HUP), list, and kill (stop the process and forget it). " +
+		"Commands follow the same safety rules as exec. Use exec for short commands."
+}
+
+func (t *ProcessTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"start", "read", "write", "signal", "list", "kill"},
+				"description": "The operation to perform",
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Process name (letters, digits, '.', '_' or '-'); required except for list",
+			},
+			"command": map[string]any{
+				"type":        "string",
+				"description": "Shell command to run (start)",
+			},
+			"working_dir": map[string]any{
+				"type":        "string",
+				"description": "Optional working directory for the command (start)",
+			},
+			"cursor": map[string]any{
+				"type":        "integer",
+				"description": "Cursor returned by the previous read; omit to read all buffered output (read)",
+			},
+			"wait_seconds": map[string]any{
+				"type":        "number",
+				"description": "Wait up to this many seconds (max 30) for new output (read)",
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "Text to write to stdin (write)",
+			},
+			"close_stdin": map[string]any{
+				"type":        "boolean",
+				"description": "Close stdin after writing, signalling end of input (write)",
+			},
+			"signal": map[string]any{
+				"type":        "string",
+				"description": "Signal name such as INT, TERM, HUP, KILL, USR1 (signal)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ProcessTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	if action == "list" {
+		return t.list()
+	}
+
+	name, _ := args["name"].(string)
+	if !processNamePattern.MatchString(name) {
+		return ErrorResult("a valid process name is required (letters, digits, '.', '_' or '-')")
+	}
+
+	switch action {
+	case "start":
+		command, _ := args["command"].(string)
+		if strings.TrimSpace(command) == "" {
+			return ErrorResult("command is required to start a process")
+		}
+		workingDir, _ := args["working_dir"].(string)
+		return t.start(name, command, workingDir)
+	case "read":
+		cursor := int64(-1)
+		if c, ok := args["cursor"].(float64); ok && c >= 0 {
+			cursor = int64(c)
+		}
+		wait, _ := args["wait_seconds"].(float64)
+		return t.read(ctx, name, cursor, min(time.Duration(wait*float64(time.Second)), maxProcessWait))
+	case "write":
+		input, _ := args["input"].(string)
+		closeStdin, _ := args["close_stdin"].(bool)
+		return t.write(name, input, closeStdin)
+	case "signal":
+		sig, _ := args["signal"].(string)
+		if sig == "" {
+			return ErrorResult("signal is required")
+		}
+		return t.signal(name, sig)
+	case "kill":
+		return t.kill(name)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q", action))
+	}
+}
+
+func (t *ProcessTool) start(name, command, workingDir string) *ToolResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, ok := t.processes[name]; ok {
+		if !p.exited() {
+			return ErrorResult(fmt.Sprintf("process %q is already running; kill it first or pick another name", name))
+		}
+		delete(t.processes, name)
+	}
+	if len(t.processes) >= t.maxProcesses && !t.evictExitedLocked() {
+		return ErrorResult(fmt.Sprintf("too many background processes (max %d); kill one first", t.maxProcesses))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, cleanup, blocked := t.exec.prepareCommand(ctx, command, workingDir)
+	if blocked != nil {
+		cancel()
+		return blocked
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		cleanup()
+		return ErrorResult(fmt.Sprintf("failed to open stdin: %v", err))
+	}
+	output := newOutputRing(t.bufferSize)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		cleanup()
+		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
+	}
+
+	p := &backgroundProcess{
+		name:    name,
+		command: command,
+		cmd:     cmd,
+		stdin:   stdin,
+		output:  output,
+		cancel:  cancel,
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	t.processes[name] = p
+	go func() {
+		p.exitErr = cmd.Wait()
+		cleanup()
+		cancel()
+		output.close()
+		close(p.done)
+		logger.DebugCF("tool", "Background process exited", map[string]any{
+			"name":   name,
+			"status": p.status(),
+		})
+	}()
+
+	logger.InfoCF("tool", "Started background process", map[string]any{
+		"name": name,
+		"pid":  cmd.Process.Pid,
+	})
+	return NewToolResult(fmt.Sprintf("Started process %q (pid %d). Read its output with action=read.",
+		name, cmd.Process.Pid))
+}
+
+// evictExitedLocked forgets the exited process that started first. It
+// reports whether one was found. Must be called with t.mu held.
+func (t *ProcessTool) evictExitedLocked() bool {
+	var oldest *backgroundProcess
+	for _, p := range t.processes {
+		if p.exited() && (oldest == nil || p.started.Before(oldest.started)) {
+			oldest = p
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	delete(t.processes, oldest.name)
+	return true
+}
+
+func (t *ProcessTool) get(name string) (*backgroundProcess, *ToolResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.processes[name]
+	if !ok {
+		return nil, ErrorResult(fmt.Sprintf("no process named %q", name))
+	}
+	return p, nil
+}
+
+func (t *ProcessTool) read(ctx context.Context, name string, cursor int64, wait time.Duration) *ToolResult {
+	p, errResult := t.get(name)
+	if errResult != nil {
+		return errResult
+	}
+	if wait > 0 {
+		p.output.wait(ctx, cursor, wait)
+	}
+
+	data, next, dropped := p.output.readFrom(cursor, maxProcessRead)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Process %q (pid %d) %s.\n", name, p.cmd.Process.Pid, p.status())
+	if dropped > 0 {
+		fmt.Fprintf(&sb, "[%d bytes of older output were dropped]\n", dropped)
+	}
+	if len(data) == 0 {
+		sb.WriteString("(no new output)\n")
+	} else {
+		sb.Write(data)
+		if data[len(data)-1] != '\n' {
+			sb.WriteString("\n")
+		}
+	}
+	if next < p.output.written() {
+		sb.WriteString("[more output available]\n")
+	}
+	fmt.Fprintf(&sb, "Next cursor: %d", next)
+	return NewToolResult(sb.String())
+}
+
+func (t *ProcessTool) write(name, input string, closeStdin bool) *ToolResult {
+	p, errResult := t.get(name)
+	if errResult != nil {
+		return errResult
+	}
+
+	p.stdinMu.Lock()
+	defer p.stdinMu.Unlock()
+	if p.exited() {
+		return ErrorResult(fmt.Sprintf("process %q %s", name, p.status()))
+	}
+	if p.stdin == nil {
+		return ErrorResult(fmt.Sprintf("stdin of process %q is closed", name))
+	}
+	if input != "" {
+		if _, err := io.WriteString(p.stdin, input); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to write to process %q: %v", name, err))
+		}
+	}
+	if closeStdin {
+		_ = p.stdin.Close()
+		p.stdin = nil
+		return NewToolResult(fmt.Sprintf("Wrote %d bytes to process %q and closed its stdin.", len(input), name))
+	}
+	return NewToolResult(fmt.Sprintf("Wrote %d bytes to process %q.", len(input), name))
+}
+
+func (t *ProcessTool) signal(name, sig string) *ToolResult {
+	p, errResult := t.get(name)
+	if errResult != nil {
+		return errResult
+	}
+	if p.exited() {
+		return ErrorResult(fmt.Sprintf("process %q %s", name, p.status()))
+	}
+	if err := signalProcessTree(p.cmd, sig); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to signal process %q: %v", name, err))
+	}
+	return NewToolResult(fmt.Sprintf("Sent %s to process %q.", strings.ToUpper(sig), name))
+}
+
+func (t *ProcessTool) kill(name string) *ToolResult {
+	p, errResult := t.get(name)
+	if errResult != nil {
+		return errResult
+	}
+
+	if !p.exited() {
+		_ = terminateProcessTree(p.cmd)
+		select {
+		case <-p.done:
+		case <-time.After(2 * time.Second):
+			// Output pipes held open by escaped children; stop waiting on them.
+			p.cancel()
+		}
+	}
+
+	t.mu.Lock()
+	if t.processes[name] == p {
+		delete(t.processes, name)
+	}
+	t.mu.Unlock()
+	return NewToolResult(fmt.Sprintf("Process %q stopped and removed.", name))
+}
+
+// KillAll stops all running processes and forgets every process.
+func (t *ProcessTool) KillAll() {
+	t.mu.Lock()
+	names := make([]string, 0, len(t.processes))
+	for name := range t.processes {
+		names = append(names, name)
+	}
+	t.mu.Unlock()
+
+	for _, name := range names {
+		t.kill(name)
+	}
+}
+
+func (t *ProcessTool) list() *ToolResult {
+	t.mu.Lock()
+	procs := make([]*backgroundProcess, 0, len(t.processes))
+	for _, p := range t.processes {
+		procs = append(procs, p)
+	}
+	t.mu.Unlock()
+
+	if len(procs) == 0 {
+		return NewToolResult("No background processes.")
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].started.Before(procs[j].started) })
+
+	var sb strings.Builder
+	for _, p := range procs {
+		fmt.Fprintf(&sb, "%s (pid %d) %s, started %s ago: %s\n",
+			p.name, p.cmd.Process.Pid, p.status(),
+			time.Since(p.started).Round(time.Second), p.command)
+	}
+	return NewToolResult(strings.TrimSuffix(sb.String(), "\n"))
+}
+
+func (p *backgroundProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *backgroundProcess) status() string {
+	if !p.exited() {
+		return "is running"
+	}
+	if p.exitErr != nil {
+		return "has exited (" + p.exitErr.Error() + ")"
+	}
+	return "has exited (exit status 0)"
+}
+
+// outputRing keeps the last size bytes written to it. Offsets into the
+// output count every byte ever written, so readers can tell what they missed.
+type outputRing struct {
+	mu      sync.Mutex
+	buf     []byte // grows up to size, then wraps around at head
+	size    int
+	head    int   // index of the oldest byte once buf is full
+	total   int64 // bytes ever written
+	closed  bool
+	changed chan struct{} // closed and replaced on every write
+}
+
+func newOutputRing(size int) *outputRing {
+	return &outputRing{size: size, changed: make(chan struct{})}
+}
+
+func (r *outputRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	r.total += int64(n)
+	if n >= r.size {
+		r.buf = append(r.buf[:0], p[n-r.size:]...)
+		r.head = 0
+	} else if room := r.size - len(r.buf); room > 0 {
+		k := min(room, n)
+		r.buf = append(r.buf, p[:k]...)
+		r.writeWrapped(p[k:])
+	} else {
+		r.writeWrapped(p)
+	}
+
+	close(r.changed)
+	r.changed = make(chan struct{})
+	return n, nil
+}
+
+// writeWrapped overwrites the oldest bytes of a full buffer with p.
+func (r *outputRing) writeWrapped(p []byte) {
+	for len(p) > 0 {
+		k := copy(r.buf[r.head:], p)
+		p = p[k:]
+		r.head = (r.head + k) % r.size
+	}
+}
+
+func (r *outputRing) written() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+func (r *outputRing) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// readFrom returns up to limit bytes of output starting at offset cursor (a
+// negative cursor means the oldest buffered byte), the offset to continue
+// from, and how many bytes between cursor and the oldest buffered byte were
+// already overwritten.
+func (r *outputRing) readFrom(cursor int64, limit int) (data []byte, next, dropped int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldest := r.total - int64(len(r.buf))
+	switch {
+	case cursor < 0:
+		cursor = oldest
+	case cursor < oldest:
+		dropped = oldest - cursor
+		cursor = oldest
+	case cursor > r.total:
+		cursor = r.total
+	}
+
+	n := int(r.total - cursor)
+	if n > limit {
+		n = limit
+	}
+	data = make([]byte, n)
+	start := int(cursor - oldest)
+	for i := range data {
+		data[i] = r.buf[(r.head+start+i)%len(r.buf)]
+	}
+	// Don't split a UTF-8 sequence when output is cut short.
+	if cursor+int64(n) < r.total {
+		for n > 0 && !utf8.RuneStart(r.buf[(r.head+start+n)%len(r.buf)]) {
+			n--
+		}
+		data = data[:n]
+	}
+	return data, cursor + int64(n), dropped
+}
+
+// wait blocks until output beyond cursor exists, the writer is closed,
+// timeout passes or ctx ends.
+func (r *outputRing) wait(ctx context.Context, cursor int64, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		r.mu.Lock()
+		if r.closed || (cursor < 0 && r.total > 0) || (cursor >= 0 && r.total > cursor) {
+			r.mu.Unlock()
+			return
+		}
+		changed := r.changed
+		r.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestProcessTool(t *testing.T) *ProcessTool {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("process tests use POSIX shell commands")
+	}
+	tool := NewProcessTool(NewExecTool(t.TempDir(), false), nil)
+	t.Cleanup(tool.KillAll)
+	return tool
+}
+
+func TestProcessTool_StartAndRead(t *testing.T) {
+	tool := newTestProcessTool(t)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"action":  "start",
+		"name":    "greeter",
+		"command": "echo first; sleep 0.2; echo second",
+	})
+	if result.IsError {
+		t.Fatalf("start failed: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "read", "name": "greeter", "wait_seconds": 5.0})
+	if !strings.Contains(result.ForLLM, "first") {
+		t.Fatalf("expected first line, got: %s", result.ForLLM)
+	}
+	cursor := int64(len("first\n"))
+
+	// Reading from the cursor returns only the new output.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		result = tool.Execute(ctx, map[string]any{
+			"action":       "read",
+			"name":         "greeter",
+			"cursor":       float64(cursor),
+			"wait_seconds": 1.0,
+		})
+		if strings.Contains(result.ForLLM, "has exited") {
+			break
+		}
+	}
+	if strings.Contains(result.ForLLM, "first") || !strings.Contains(result.ForLLM, "second") {
+		t.Errorf("expected only the second line, got: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "exit status 0") {
+		t.Errorf("expected exit status, got: %s", result.ForLLM)
+	}
+}
+
+func TestProcessTool_WriteStdin(t *testing.T) {
+	tool := newTestProcessTool(t)
+	ctx := context.Background()
+
+	if r := tool.Execute(ctx, map[string]any{"action": "start", "name": "cat", "command": "cat"}); r.IsError {
+		t.Fatalf("start failed: %s", r.ForLLM)
+	}
+	r := tool.Execute(ctx, map[string]any{
+		"action":      "write",
+		"name":        "cat",
+		"input":       "ping\n",
+		"close_stdin": true,
+	})
+	if r.IsError {
+		t.Fatalf("write failed: %s", r.ForLLM)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"action": "read", "name": "cat", "wait_seconds": 5.0})
+	if !strings.Contains(r.ForLLM, "ping") {
+		t.Errorf("expected echoed input, got: %s", r.ForLLM)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"action": "write", "name": "cat", "input": "more"})
+	if !r.IsError {
+		t.Errorf("expected error writing after stdin was closed")
+	}
+}
+
+func TestProcessTool_SignalAndKill(t *testing.T) {
+	tool := newTestProcessTool(t)
+	ctx := context.Background()
+
+	command := "trap 'echo got-hup' HUP; while true; do sleep 0.1; done"
+	if r := tool.Execute(ctx, map[string]any{"action": "start", "name": "loop", "command": command}); r.IsError {
+		t.Fatalf("start failed: %s", r.ForLLM)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if r := tool.Execute(ctx, map[string]any{"action": "signal", "name": "loop", "signal": "HUP"}); r.IsError {
+		t.Fatalf("signal failed: %s", r.ForLLM)
+	}
+	// The signal goes to the whole process group, so the shell may first
+	// report its sleep child being hung up.
+	var r *ToolResult
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r = tool.Execute(ctx, map[string]any{"action": "read", "name": "loop", "wait_seconds": 1.0})
+		if strings.Contains(r.ForLLM, "got-hup") {
+			break
+		}
+	}
+	if !strings.Contains(r.ForLLM, "got-hup") {
+		t.Errorf("expected trap output, got: %s", r.ForLLM)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(r.ForLLM, "loop") || !strings.Contains(r.ForLLM, "is running") {
+		t.Errorf("expected running process in list, got: %s", r.ForLLM)
+	}
+
+	if r := tool.Execute(ctx, map[string]any{"action": "kill", "name": "loop"}); r.IsError {
+		t.Fatalf("kill failed: %s", r.ForLLM)
+	}
+	r = tool.Execute(ctx, map[string]any{"action": "list"})
+	if r.ForLLM != "No background processes." {
+		t.Errorf("expected empty list after kill, got: %s", r.ForLLM)
+	}
+}
+
+func TestProcessTool_Guards(t *testing.T) {
+	tool := newTestProcessTool(t)
+	ctx := context.Background()
+
+	r := tool.Execute(ctx, map[string]any{"action": "start", "name": "bad", "command": "rm -rf /"})
+	if !r.IsError || !strings.Contains(r.ForLLM, "blocked") {
+		t.Errorf("expected dangerous command to be blocked, got: %s", r.ForLLM)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"action": "start", "name": "../x", "command": "true"})
+	if !r.IsError {
+		t.Errorf("expected invalid name to be rejected")
+	}
+
+	r = tool.Execute(ctx, map[string]any{"action": "read", "name": "missing"})
+	if !r.IsError {
+		t.Errorf("expected error for unknown process")
+	}
+}
+
+func TestProcessTool_MaxProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process tests use POSIX shell commands")
+	}
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.MaxProcesses = 1
+	tool := NewProcessTool(NewExecTool(t.TempDir(), false), cfg)
+	t.Cleanup(tool.KillAll)
+	ctx := context.Background()
+
+	if r := tool.Execute(ctx, map[string]any{"action": "start", "name": "a", "command": "sleep 10"}); r.IsError {
+		t.Fatalf("start failed: %s", r.ForLLM)
+	}
+	r := tool.Execute(ctx, map[string]any{"action": "start", "name": "b", "command": "sleep 10"})
+	if !r.IsError || !strings.Contains(r.ForLLM, "too many") {
+		t.Errorf("expected limit error, got: %s", r.ForLLM)
+	}
+
+	// Once a process has exited its slot can be reused.
+	tool.Execute(ctx, map[string]any{"action": "signal", "name": "a", "signal": "KILL"})
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r = tool.Execute(ctx, map[string]any{"action": "start", "name": "b", "command": "true"})
+		if !r.IsError {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if r.IsError {
+		t.Errorf("expected exited process to be evicted, got: %s", r.ForLLM)
+	}
+}
+
+func TestOutputRing_DropsOldest(t *testing.T) {
+	r := newOutputRing(8)
+	r.Write([]byte("abcdef"))
+	r.Write([]byte("ghijkl"))
+
+	data, next, dropped := r.readFrom(0, 100)
+	if string(data) != "efghijkl" || next != 12 || dropped != 4 {
+		t.Errorf("readFrom(0) = %q, %d, %d", data, next, dropped)
+	}
+
+	data, next, dropped = r.readFrom(10, 100)
+	if string(data) != "kl" || next != 12 || dropped != 0 {
+		t.Errorf("readFrom(10) = %q, %d, %d", data, next, dropped)
+	}
+
+	data, next, _ = r.readFrom(4, 3)
+	if string(data) != "efg" || next != 7 {
+		t.Errorf("readFrom(4, 3) = %q, %d", data, next)
+	}
+
+	r.Write([]byte("0123456789"))
+	data, _, _ = r.readFrom(-1, 100)
+	if string(data) != "23456789" {
+		t.Errorf("readFrom(-1) after large write = %q", data)
+	}
+}
+
+func TestOutputRing_UTF8Boundary(t *testing.T) {
+	r := newOutputRing(64)
+	r.Write([]byte("aé"))
+	data, next, _ := r.readFrom(0, 2)
+	if string(data) != "a" || next != 1 {
+		t.Errorf("expected cut before multi-byte rune, got %q, %d", data, next)
+	}
+}
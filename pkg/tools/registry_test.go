@@ -27,15 +27,25 @@ func (m *mockRegistryTool) Execute(_ context.Context, _ map[string]any) *ToolRes
 
 type mockCtxTool struct {
 	mockRegistryTool
-	channel string
-	chatID  string
+	channel    string
+	chatID     string
+	setCalled  bool
+	ctxChannel string
+	ctxChatID  string
 }
 
 func (m *mockCtxTool) SetContext(channel, chatID string) {
+	m.setCalled = true
 	m.channel = channel
 	m.chatID = chatID
 }
 
+func (m *mockCtxTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	m.ctxChannel = ToolChannel(ctx)
+	m.ctxChatID = ToolChatID(ctx)
+	return m.result
+}
+
 type mockAsyncRegistryTool struct {
 	mockRegistryTool
 	cb AsyncCallback
@@ -45,6 +55,13 @@ func (m *mockAsyncRegistryTool) SetCallback(cb AsyncCallback) {
 	m.cb = cb
 }
 
+func (m *mockAsyncRegistryTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	if cb := asyncCallbackFrom(ctx); cb != nil {
+		m.cb = cb
+	}
+	return m.result
+}
+
 // --- helpers ---
 
 func newMockTool(name, desc string) *mockRegistryTool {
@@ -104,6 +121,21 @@ func TestToolRegistry_RegisterOverwrite(t *testing.T) {
 	}
 }
 
+func TestToolRegistry_Unregister(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("gone", "to be removed"))
+
+	if !r.Unregister("gone") {
+		t.Error("expected Unregister to report the registered tool")
+	}
+	if _, ok := r.Get("gone"); ok {
+		t.Error("expected tool to be removed")
+	}
+	if r.Unregister("gone") {
+		t.Error("expected Unregister of an unknown tool to return false")
+	}
+}
+
 func TestToolRegistry_Execute_Success(t *testing.T) {
 	r := NewToolRegistry()
 	r.Register(&mockRegistryTool{
@@ -145,11 +177,14 @@ func TestToolRegistry_ExecuteWithContext_ContextualTool(t *testing.T) {
 
 	r.ExecuteWithContext(context.Background(), "ctx_tool", nil, "telegram", "chat-42", nil)
 
-	if ct.channel != "telegram" {
-		t.Errorf("expected channel 'telegram', got %q", ct.channel)
+	if ct.ctxChannel != "telegram" {
+		t.Errorf("expected ctx channel 'telegram', got %q", ct.ctxChannel)
+	}
+	if ct.ctxChatID != "chat-42" {
+		t.Errorf("expected ctx chatID 'chat-42', got %q", ct.ctxChatID)
 	}
-	if ct.chatID != "chat-42" {
-		t.Errorf("expected chatID 'chat-42', got %q", ct.chatID)
+	if ct.setCalled {
+		t.Error("SetContext should not be called on shared tool instances")
 	}
 }
 
@@ -162,8 +197,8 @@ func TestToolRegistry_ExecuteWithContext_SkipsEmptyContext(t *testing.T) {
 
 	r.ExecuteWithContext(context.Background(), "ctx_tool", nil, "", "", nil)
 
-	if ct.channel != "" || ct.chatID != "" {
-		t.Error("SetContext should not be called with empty channel/chatID")
+	if ct.ctxChannel != "" || ct.ctxChatID != "" {
+		t.Error("ctx should not carry an empty channel/chatID")
 	}
 }
 
@@ -180,7 +215,7 @@ func TestToolRegistry_ExecuteWithContext_AsyncCallback(t *testing.T) {
 
 	result := r.ExecuteWithContext(context.Background(), "async_tool", nil, "", "", cb)
 	if at.cb == nil {
-		t.Error("expected SetCallback to have been called")
+		t.Error("expected callback to be carried on ctx")
 	}
 	if !result.Async {
 		t.Error("expected async result")
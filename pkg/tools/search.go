@@ -0,0 +1,460 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultGlobLimit  = 200
+	defaultGrepLimit  = 100
+	maxSearchLimit    = 1000
+	maxGrepContext    = 10
+	maxGrepFileSize   = 4 << 20
+	maxGrepLineLength = 300
+	binarySniffLength = 8000
+	searchSkipDirName = ".git"
+)
+
+const searchLimitReached = "[results truncated at %d; narrow the search or raise limit]"
+
+// errSearchLimit stops a walk once enough results were collected.
+var errSearchLimit = errors.New("search limit reached")
+
+// GlobTool finds files by name pattern within the workspace.
+type GlobTool struct {
+	fs fileSystem
+}
+
+func NewGlobTool(workspace string, restrict bool) *GlobTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &GlobTool{fs: fs}
+}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *GlobTool) Description() string {
+	return "Find files whose path matches a glob pattern, e.g. \"**/*.go\" or \"docs/*.{md,txt}\". " +
+		"\"*\" matches within a path segment and \"**\" matches any number of directories."
+}
+
+func (t *GlobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Glob pattern, matched against paths relative to path",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search in (default: workspace root)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of paths to return (default 200)",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	patterns := expandBraces(filepath.ToSlash(pattern))
+	for _, p := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(p, "**", "*"), ""); err != nil {
+			return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+		}
+	}
+	dir := searchPath(args)
+	limit := searchLimit(args, defaultGlobLimit)
+
+	fsys, release, err := t.fs.DirFS(dir)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search %s: %v", dir, err))
+	}
+	defer release()
+
+	var matches []string
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if name == "." {
+			return nil
+		}
+		if d.IsDir() && d.Name() == searchSkipDirName {
+			return fs.SkipDir
+		}
+		if !d.IsDir() && matchAnyGlob(patterns, name) {
+			if len(matches) == limit {
+				return errSearchLimit
+			}
+			matches = append(matches, displayPath(dir, name))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSearchLimit) {
+		return ErrorResult(fmt.Sprintf("search failed: %v", err))
+	}
+
+	if len(matches) == 0 {
+		return NewToolResult("No files found")
+	}
+	out := strings.Join(matches, "\n")
+	if errors.Is(err, errSearchLimit) {
+		out += "\n" + fmt.Sprintf(searchLimitReached, limit)
+	}
+	return NewToolResult(out)
+}
+
+// GrepTool searches file contents within the workspace with a regular
+// expression.
+type GrepTool struct {
+	fs fileSystem
+}
+
+func NewGrepTool(workspace string, restrict bool) *GrepTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &GrepTool{fs: fs}
+}
+
+func (t *GrepTool) Name() string {
+	return "grep"
+}
+
+func (t *GrepTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *GrepTool) Description() string {
+	return "Search file contents with a regular expression (RE2 syntax). Returns matching lines as " +
+		"path:line:text. Binary files and .git directories are skipped."
+}
+
+func (t *GrepTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Regular expression to search for",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File or directory to search in (default: workspace root)",
+			},
+			"include": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Only search files matching these globs, e.g. [\"*.go\"]",
+			},
+			"exclude": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Skip files and directories matching these globs, e.g. [\"vendor\"]",
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "Match case-insensitively",
+			},
+			"context": map[string]any{
+				"type":        "integer",
+				"description": "Lines of context to show around each match (max 10)",
+			},
+			"files_only": map[string]any{
+				"type":        "boolean",
+				"description": "Only list the paths of files that match",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of matching lines (or files) to return (default 100)",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	if ignoreCase, _ := args["ignore_case"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+
+	include := globList(args["include"])
+	exclude := globList(args["exclude"])
+	filesOnly, _ := args["files_only"].(bool)
+	contextLines := 0
+	if c, ok := args["context"].(float64); ok && c > 0 {
+		contextLines = min(int(c), maxGrepContext)
+	}
+	dir := searchPath(args)
+	limit := searchLimit(args, defaultGrepLimit)
+
+	fsys, release, err := t.fs.DirFS(dir)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search %s: %v", dir, err))
+	}
+	defer release()
+
+	var out strings.Builder
+	found := 0
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() {
+			if name != "." && (d.Name() == searchSkipDirName || matchesFilter(exclude, name)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		// name is "." when path names a single file; filters don't apply then.
+		if name != "." && (matchesFilter(exclude, name) || (len(include) > 0 && !matchesFilter(include, name))) {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxGrepFileSize {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil || bytes.IndexByte(data[:min(len(data), binarySniffLength)], 0) >= 0 {
+			return nil
+		}
+
+		display := displayPath(dir, name)
+		if filesOnly {
+			if !re.Match(data) {
+				return nil
+			}
+			if found == limit {
+				return errSearchLimit
+			}
+			found++
+			out.WriteString(display + "\n")
+			return nil
+		}
+		return grepFile(&out, display, data, re, contextLines, limit, &found)
+	})
+	if err != nil && !errors.Is(err, errSearchLimit) {
+		return ErrorResult(fmt.Sprintf("search failed: %v", err))
+	}
+
+	if found == 0 {
+		return NewToolResult("No matches found")
+	}
+	if errors.Is(err, errSearchLimit) {
+		fmt.Fprintf(&out, searchLimitReached, limit)
+	}
+	return NewToolResult(strings.TrimSuffix(out.String(), "\n"))
+}
+
+// grepFile writes the lines of data matching re to out, with contextLines of
+// context around them, counting matches in found. It returns errSearchLimit
+// once limit matches have been written.
+func grepFile(
+	out *strings.Builder,
+	display string,
+	data []byte,
+	re *regexp.Regexp,
+	contextLines, limit int,
+	found *int,
+) error {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	printed := -1 // index of the last line written
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		if *found == limit {
+			return errSearchLimit
+		}
+		*found++
+
+		from := max(i-contextLines, printed+1)
+		if printed >= 0 && from > printed+1 && contextLines > 0 {
+			out.WriteString("--\n")
+		}
+		for j := from; j < i; j++ {
+			fmt.Fprintf(out, "%s-%d-%s\n", display, j+1, grepLine(lines[j]))
+		}
+		fmt.Fprintf(out, "%s:%d:%s\n", display, i+1, grepLine(line))
+		printed = i
+
+		// Trailing context stops at the next match, which prints itself.
+		for j := i + 1; j <= min(i+contextLines, len(lines)-1) && !re.MatchString(lines[j]); j++ {
+			fmt.Fprintf(out, "%s-%d-%s\n", display, j+1, grepLine(lines[j]))
+			printed = j
+		}
+	}
+	return nil
+}
+
+func grepLine(line string) string {
+	return utils.Truncate(strings.TrimSuffix(line, "\r"), maxGrepLineLength)
+}
+
+// searchPath returns the directory argument of a search tool.
+func searchPath(args map[string]any) string {
+	if dir, ok := args["path"].(string); ok && dir != "" {
+		return dir
+	}
+	return "."
+}
+
+// searchLimit returns the limit argument of a search tool, capped at
+// maxSearchLimit.
+func searchLimit(args map[string]any, def int) int {
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		return min(int(l), maxSearchLimit)
+	}
+	return def
+}
+
+// displayPath joins the searched directory and the slash-separated path of a
+// result found within it.
+func displayPath(dir, name string) string {
+	if name == "." {
+		return dir
+	}
+	return filepath.Join(dir, filepath.FromSlash(name))
+}
+
+func globList(v any) []string {
+	var globs []string
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			globs = append(globs, v)
+		}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				globs = append(globs, s)
+			}
+		}
+	}
+	var expanded []string
+	for _, g := range globs {
+		expanded = append(expanded, expandBraces(filepath.ToSlash(g))...)
+	}
+	return expanded
+}
+
+// matchesFilter reports whether name matches any of globs. Globs without a
+// slash are matched against the base name, others against the whole path.
+func matchesFilter(globs []string, name string) bool {
+	for _, g := range globs {
+		if !strings.Contains(g, "/") {
+			if ok, _ := path.Match(g, path.Base(name)); ok {
+				return true
+			}
+			continue
+		}
+		if matchGlob(g, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against pattern, where a "**"
+// segment matches any number of path segments and other segments follow
+// path.Match.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandBraces expands "{a,b}" alternatives in pattern, e.g. "*.{go,md}"
+// becomes "*.go" and "*.md".
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		return []string{pattern}
+	}
+	closing := strings.IndexByte(pattern[open:], '}')
+	if closing < 0 {
+		return []string{pattern}
+	}
+	closing += open
+
+	var out []string
+	for _, alt := range strings.Split(pattern[open+1:closing], ",") {
+		out = append(out, expandBraces(pattern[:open]+alt+pattern[closing+1:])...)
+	}
+	return out
+}
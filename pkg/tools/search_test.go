@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSearchTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":               "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"README.md":             "# Title\nHello world\n",
+		"pkg/util/util.go":      "package util\n\n// Hello says hi.\nfunc Hello() {}\n",
+		"pkg/util/util_test.go": "package util\n",
+		"vendor/dep/dep.go":     "package dep // hello\n",
+		".git/config":           "hello\n",
+		"bin/blob":              "hello\x00world",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGlobTool(t *testing.T) {
+	dir := writeSearchTree(t)
+	tool := NewGlobTool(dir, true)
+	ctx := context.Background()
+
+	tests := []struct {
+		pattern string
+		want    []string
+		notWant []string
+	}{
+		{"**/*.go", []string{"main.go", "pkg/util/util.go", "vendor/dep/dep.go"}, []string{"README.md"}},
+		{"*.go", []string{"main.go"}, []string{"util.go"}},
+		{"pkg/**/*_test.go", []string{"pkg/util/util_test.go"}, []string{"util.go\n"}},
+		{"*.{md,go}", []string{"main.go", "README.md"}, []string{"pkg/"}},
+		{"**/config", nil, []string{".git"}},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(ctx, map[string]any{"pattern": tt.pattern})
+		if result.IsError {
+			t.Fatalf("glob %q failed: %s", tt.pattern, result.ForLLM)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(result.ForLLM, filepath.FromSlash(w)) {
+				t.Errorf("glob %q: expected %s in %q", tt.pattern, w, result.ForLLM)
+			}
+		}
+		for _, nw := range tt.notWant {
+			if strings.Contains(result.ForLLM, filepath.FromSlash(nw)) {
+				t.Errorf("glob %q: unexpected %s in %q", tt.pattern, nw, result.ForLLM)
+			}
+		}
+	}
+
+	result := tool.Execute(ctx, map[string]any{"pattern": "**/*.go", "limit": 1.0})
+	if !strings.Contains(result.ForLLM, "truncated at 1") {
+		t.Errorf("expected truncation note, got: %s", result.ForLLM)
+	}
+}
+
+func TestGlobTool_RestrictedPath(t *testing.T) {
+	dir := writeSearchTree(t)
+	tool := NewGlobTool(dir, true)
+
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "*", "path": "../"})
+	if !result.IsError {
+		t.Errorf("expected error searching outside the workspace, got: %s", result.ForLLM)
+	}
+}
+
+func TestGrepTool(t *testing.T) {
+	dir := writeSearchTree(t)
+	tool := NewGrepTool(dir, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"pattern": "hello", "ignore_case": true})
+	if result.IsError {
+		t.Fatalf("grep failed: %s", result.ForLLM)
+	}
+	for _, want := range []string{"main.go:4:", "README.md:2:Hello world", "util.go:3:// Hello says hi."} {
+		if !strings.Contains(result.ForLLM, filepath.FromSlash(want)) {
+			t.Errorf("expected %q in %q", want, result.ForLLM)
+		}
+	}
+	if strings.Contains(result.ForLLM, "config") || strings.Contains(result.ForLLM, "blob") {
+		t.Errorf(".git and binary files should be skipped: %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{
+		"pattern": "hello",
+		"include": []any{"*.go"},
+		"exclude": []any{"vendor"},
+	})
+	if strings.Contains(result.ForLLM, "dep.go") || strings.Contains(result.ForLLM, "README") {
+		t.Errorf("include/exclude not applied: %q", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "main.go:4:") {
+		t.Errorf("expected match in main.go: %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "Hello", "files_only": true})
+	if strings.TrimSpace(result.ForLLM) != strings.Join([]string{
+		"README.md", filepath.FromSlash("pkg/util/util.go"),
+	}, "\n") {
+		t.Errorf("unexpected files_only result: %q", result.ForLLM)
+	}
+}
+
+func TestGrepTool_Context(t *testing.T) {
+	dir := t.TempDir()
+	content := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	if err := os.WriteFile(filepath.Join(dir, "n.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewGrepTool(dir, true)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"pattern": "two|seven",
+		"path":    "n.txt",
+		"context": 1.0,
+	})
+	want := "n.txt-1-one\nn.txt:2:two\nn.txt-3-three\n--\nn.txt-6-six\nn.txt:7:seven\nn.txt-8-eight"
+	if result.ForLLM != want {
+		t.Errorf("unexpected context output:\n%s\nwant:\n%s", result.ForLLM, want)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "(", "path": "n.txt"})
+	if !result.IsError {
+		t.Errorf("expected error for invalid regex")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**", "a/b/c", true},
+		{"**/*.go", "a.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/x/y/c", true},
+		{"a/*", "a/b/c", false},
+		{"*.go", "a/b.go", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
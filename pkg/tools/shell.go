@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
 )
 
 type ExecTool struct {
@@ -22,6 +24,11 @@ type ExecTool struct {
 	denyPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
 	restrictToWorkspace bool
+	sandbox             sandbox.Sandbox
+	sandboxConfig       config.ExecSandboxConfig
+	// sandboxErr is set when a sandbox is configured but cannot be used;
+	// commands are then refused rather than run on the host.
+	sandboxErr error
 }
 
 var defaultDenyPatterns = []*regexp.Regexp{
@@ -100,13 +107,40 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		denyPatterns = append(denyPatterns, defaultDenyPatterns...)
 	}
 
-	return &ExecTool{
+	tool := &ExecTool{
 		workingDir:          workingDir,
 		timeout:             60 * time.Second,
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		restrictToWorkspace: restrict,
 	}
+	if config != nil {
+		tool.sandboxConfig = config.Tools.Exec.Sandbox
+		tool.sandbox, tool.sandboxErr = newSandbox(workingDir, tool.sandboxConfig)
+		if tool.sandboxErr != nil {
+			logger.ErrorCF("tool", "Exec sandbox unavailable, commands will be refused",
+				map[string]any{
+					"backend": tool.sandboxConfig.Backend,
+					"error":   tool.sandboxErr.Error(),
+				})
+		}
+	}
+	return tool
+}
+
+func newSandbox(workingDir string, cfg config.ExecSandboxConfig) (sandbox.Sandbox, error) {
+	return sandbox.New(cfg.Backend, sandbox.Options{
+		Workspace:         workingDir,
+		WorkspaceWritable: cfg.WritableWorkspace,
+		ScratchDir:        cfg.ScratchDir,
+		Network:           cfg.Network,
+		MemoryMB:          cfg.MemoryMB,
+		CPUs:              cfg.CPUs,
+		PIDs:              cfg.PIDs,
+		CgroupParent:      cfg.CgroupParent,
+		Runtime:           cfg.Runtime,
+		Image:             cfg.Image,
+	})
 }
 
 func (t *ExecTool) Name() string {
@@ -114,7 +148,29 @@ func (t *ExecTool) Name() string {
 }
 
 func (t *ExecTool) Description() string {
-	return "Execute a shell command and return its output. Use with caution."
+	desc := "Execute a shell command and return its output. Use with caution."
+	if t.sandbox == nil {
+		return desc
+	}
+	cfg := t.sandboxConfig
+	scratch := cfg.ScratchDir
+	if scratch == "" {
+		scratch = sandbox.DefaultScratchDir
+	}
+	if !filepath.IsAbs(scratch) {
+		scratch = filepath.Join(t.workingDir, scratch)
+	}
+	desc += " Commands run in a sandbox: the workspace is "
+	if cfg.WritableWorkspace {
+		desc += "writable"
+	} else {
+		desc += "read-only"
+	}
+	desc += ", write scratch files to " + scratch + " ($HOME)"
+	if !cfg.Network {
+		desc += ", and there is no network access"
+	}
+	return desc + "."
 }
 
 func (t *ExecTool) Parameters() map[string]any {
@@ -140,30 +196,6 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		return ErrorResult("command is required")
 	}
 
-	cwd := t.workingDir
-	if wd, ok := args["working_dir"].(string); ok && wd != "" {
-		if t.restrictToWorkspace && t.workingDir != "" {
-			resolvedWD, err := validatePath(wd, t.workingDir, true)
-			if err != nil {
-				return ErrorResult("Command blocked by safety guard (" + err.Error() + ")")
-			}
-			cwd = resolvedWD
-		} else {
-			cwd = wd
-		}
-	}
-
-	if cwd == "" {
-		wd, err := os.Getwd()
-		if err == nil {
-			cwd = wd
-		}
-	}
-
-	if guardError := t.guardCommand(command, cwd); guardError != "" {
-		return ErrorResult(guardError)
-	}
-
 	// timeout == 0 means no timeout
 	var cmdCtx context.Context
 	var cancel context.CancelFunc
@@ -174,17 +206,12 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 	defer cancel()
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
-	} else {
-		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
-	}
-	if cwd != "" {
-		cmd.Dir = cwd
+	wd, _ := args["working_dir"].(string)
+	cmd, cleanup, blocked := t.prepareCommand(cmdCtx, command, wd)
+	if blocked != nil {
+		return blocked
 	}
-
-	prepareCommandForTermination(cmd)
+	defer cleanup()
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -255,6 +282,72 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
+// prepareCommand applies the safety guards to command and builds the command
+// that runs it in workingDir (default: the workspace), inside the sandbox if
+// one is configured. The returned cleanup must be called once the command has
+// exited. If the command is refused, the result explaining why is returned
+// instead.
+func (t *ExecTool) prepareCommand(
+	ctx context.Context,
+	command, workingDir string,
+) (*exec.Cmd, func(), *ToolResult) {
+	cwd := t.workingDir
+	if workingDir != "" {
+		if t.restrictToWorkspace && t.workingDir != "" {
+			resolvedWD, err := validatePath(workingDir, t.workingDir, true)
+			if err != nil {
+				return nil, nil, ErrorResult("Command blocked by safety guard (" + err.Error() + ")")
+			}
+			cwd = resolvedWD
+		} else {
+			cwd = workingDir
+		}
+	}
+
+	if cwd == "" {
+		wd, err := os.Getwd()
+		if err == nil {
+			cwd = wd
+		}
+	}
+
+	if guardError := t.guardCommand(command, cwd); guardError != "" {
+		return nil, nil, ErrorResult(guardError)
+	}
+	if t.sandboxErr != nil {
+		return nil, nil, ErrorResult(fmt.Sprintf("exec sandbox unavailable: %v", t.sandboxErr))
+	}
+	if t.sandbox != nil {
+		// Only the workspace is mounted inside the sandbox.
+		resolved, err := validatePath(cwd, t.workingDir, true)
+		if err != nil {
+			return nil, nil, ErrorResult("Command blocked by sandbox (" + err.Error() + ")")
+		}
+		cwd = resolved
+	}
+
+	var cmd *exec.Cmd
+	cleanup := func() {}
+	switch {
+	case t.sandbox != nil:
+		var err error
+		cmd, cleanup, err = t.sandbox.Command(ctx, command, cwd)
+		if err != nil {
+			return nil, nil, ErrorResult(fmt.Sprintf("failed to prepare sandbox: %v", err))
+		}
+	case runtime.GOOS == "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	if cwd != "" && t.sandbox == nil {
+		cmd.Dir = cwd
+	}
+
+	prepareCommandForTermination(cmd)
+	return cmd, cleanup, nil
+}
+
 func (t *ExecTool) guardCommand(command, cwd string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
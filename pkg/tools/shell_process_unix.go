@@ -3,7 +3,9 @@
 package tools
 
 import (
+	"fmt"
 	"os/exec"
+	"strings"
 	"syscall"
 )
 
@@ -11,7 +13,10 @@ func prepareCommandForTermination(cmd *exec.Cmd) {
 	if cmd == nil {
 		return
 	}
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
 }
 
 func terminateProcessTree(cmd *exec.Cmd) error {
@@ -30,3 +35,28 @@ func terminateProcessTree(cmd *exec.Cmd) error {
 	_ = cmd.Process.Kill()
 	return nil
 }
+
+var processSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"CONT": syscall.SIGCONT,
+	"STOP": syscall.SIGSTOP,
+}
+
+// signalProcessTree sends the named signal (e.g. "INT" or "SIGINT") to the
+// process group started by prepareCommandForTermination.
+func signalProcessTree(cmd *exec.Cmd, name string) error {
+	sig, ok := processSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", name)
+	}
+	if cmd == nil || cmd.Process == nil || cmd.Process.Pid <= 0 {
+		return fmt.Errorf("process not started")
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -3,8 +3,10 @@
 package tools
 
 import (
+	"fmt"
 	"os/exec"
 	"strconv"
+	"strings"
 )
 
 func prepareCommandForTermination(cmd *exec.Cmd) {
@@ -25,3 +27,14 @@ func terminateProcessTree(cmd *exec.Cmd) error {
 	_ = cmd.Process.Kill()
 	return nil
 }
+
+// signalProcessTree emulates KILL and TERM with terminateProcessTree; other
+// signals do not exist on Windows.
+func signalProcessTree(cmd *exec.Cmd, name string) error {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "KILL", "TERM":
+		return terminateProcessTree(cmd)
+	default:
+		return fmt.Errorf("signal %q is not supported on Windows", name)
+	}
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestShellTool_NamespaceSandbox(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "notes.txt"), []byte("sandboxed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox.Backend = "namespace"
+	tool := NewExecToolWithConfig(workspace, false, cfg)
+	if tool.sandboxErr != nil {
+		t.Skipf("namespace sandbox unavailable: %v", tool.sandboxErr)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "cat notes.txt && hostname && (touch new.txt 2>/dev/null || echo read-only) && touch $HOME/ok",
+	})
+	if strings.Contains(result.ForLLM, "sandbox:") {
+		t.Skipf("namespace sandbox cannot run here: %s", result.ForLLM)
+	}
+	if result.IsError {
+		t.Fatalf("Expected success, got: %s", result.ForLLM)
+	}
+	for _, want := range []string{"sandboxed", "sandbox\n", "read-only"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("Expected output to contain %q, got: %s", want, result.ForLLM)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workspace, ".sandbox", "ok")); err != nil {
+		t.Errorf("Expected scratch file to be written: %v", err)
+	}
+}
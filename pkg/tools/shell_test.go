@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // TestShellTool_Success verifies successful command execution
@@ -272,3 +274,34 @@ func TestShellTool_RestrictToWorkspace(t *testing.T) {
 		)
 	}
 }
+
+// TestShellTool_SandboxUnavailable verifies commands are refused, not run on
+// the host, when the configured sandbox cannot be set up
+func TestShellTool_SandboxUnavailable(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox.Backend = "jail"
+	tool := NewExecToolWithConfig(t.TempDir(), true, cfg)
+
+	result := tool.Execute(context.Background(), map[string]any{"command": "echo hi"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "sandbox unavailable") {
+		t.Errorf("Expected sandbox error, got: %+v", result)
+	}
+}
+
+// TestShellTool_SandboxDescription verifies the LLM is told about the sandbox
+func TestShellTool_SandboxDescription(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if strings.Contains(NewExecToolWithConfig(t.TempDir(), true, cfg).Description(), "sandbox") {
+		t.Error("Description mentions a sandbox although none is configured")
+	}
+
+	cfg.Tools.Exec.Sandbox.Backend = "container"
+	cfg.Tools.Exec.Sandbox.Runtime = "podman"
+	workspace := t.TempDir()
+	desc := NewExecToolWithConfig(workspace, true, cfg).Description()
+	for _, want := range []string{"read-only", filepath.Join(workspace, ".sandbox"), "no network access"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("Description missing %q: %s", want, desc)
+		}
+	}
+}
@@ -19,9 +19,10 @@ import (
 // It shares the same RegistryManager that FindSkillsTool uses,
 // so all registries configured in config are available for installation.
 type InstallSkillTool struct {
-	registryMgr *skills.RegistryManager
-	workspace   string
-	mu          sync.Mutex
+	registryMgr    *skills.RegistryManager
+	workspace      string
+	mu             sync.Mutex
+	allowlistCheck func(slug string) bool
 }
 
 // NewInstallSkillTool creates a new InstallSkillTool.
@@ -35,6 +36,11 @@ func NewInstallSkillTool(registryMgr *skills.RegistryManager, workspace string)
 	}
 }
 
+// SetAllowlistChecker restricts installation to the skills the agent may use.
+func (t *InstallSkillTool) SetAllowlistChecker(check func(slug string) bool) {
+	t.allowlistCheck = check
+}
+
 func (t *InstallSkillTool) Name() string {
 	return "install_skill"
 }
@@ -79,6 +85,9 @@ func (t *InstallSkillTool) Execute(ctx context.Context, args map[string]any) *To
 	if err := utils.ValidateSkillIdentifier(slug); err != nil {
 		return ErrorResult(fmt.Sprintf("invalid slug %q: error: %s", slug, err.Error()))
 	}
+	if t.allowlistCheck != nil && !t.allowlistCheck(slug) {
+		return ErrorResult(fmt.Sprintf("skill %q is not allowed for this agent", slug))
+	}
 
 	// Validate registry
 	registryName, _ := args["registry"].(string)
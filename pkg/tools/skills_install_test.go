@@ -102,3 +102,19 @@ func TestInstallSkillToolMissingRegistry(t *testing.T) {
 	assert.True(t, result.IsError)
 	assert.Contains(t, result.ForLLM, "invalid registry")
 }
+
+func TestInstallSkillToolRejectsDisallowedSkill(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewInstallSkillTool(skills.NewRegistryManager(), workspace)
+	tool.SetAllowlistChecker(func(slug string) bool { return slug == "weather" })
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"slug":     "github",
+		"registry": "clawhub",
+	})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "not allowed")
+
+	_, err := os.Stat(filepath.Join(workspace, "skills", "github"))
+	assert.True(t, os.IsNotExist(err))
+}
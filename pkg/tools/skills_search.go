@@ -10,8 +10,9 @@ import (
 
 // FindSkillsTool allows the LLM agent to search for installable skills from registries.
 type FindSkillsTool struct {
-	registryMgr *skills.RegistryManager
-	cache       *skills.SearchCache
+	registryMgr    *skills.RegistryManager
+	cache          *skills.SearchCache
+	allowlistCheck func(slug string) bool
 }
 
 // NewFindSkillsTool creates a new FindSkillsTool.
@@ -24,10 +25,19 @@ func NewFindSkillsTool(registryMgr *skills.RegistryManager, cache *skills.Search
 	}
 }
 
+// SetAllowlistChecker hides search results for skills the agent may not use.
+func (t *FindSkillsTool) SetAllowlistChecker(check func(slug string) bool) {
+	t.allowlistCheck = check
+}
+
 func (t *FindSkillsTool) Name() string {
 	return "find_skills"
 }
 
+func (t *FindSkillsTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *FindSkillsTool) Description() string {
 	return "Search for installable skills from skill registries. Returns skill slugs, descriptions, versions, and relevance scores. Use this to discover skills before installing them with install_skill."
 }
@@ -69,7 +79,7 @@ func (t *FindSkillsTool) Execute(ctx context.Context, args map[string]any) *Tool
 	// Check cache first.
 	if t.cache != nil {
 		if cached, hit := t.cache.Get(query); hit {
-			return SilentResult(formatSearchResults(query, cached, true))
+			return SilentResult(formatSearchResults(query, t.allowed(cached), true))
 		}
 	}
 
@@ -84,7 +94,21 @@ func (t *FindSkillsTool) Execute(ctx context.Context, args map[string]any) *Tool
 		t.cache.Put(query, results)
 	}
 
-	return SilentResult(formatSearchResults(query, results, false))
+	return SilentResult(formatSearchResults(query, t.allowed(results), false))
+}
+
+// allowed returns the results that pass the allowlist checker.
+func (t *FindSkillsTool) allowed(results []skills.SearchResult) []skills.SearchResult {
+	if t.allowlistCheck == nil {
+		return results
+	}
+	kept := make([]skills.SearchResult, 0, len(results))
+	for _, r := range results {
+		if t.allowlistCheck(r.Slug) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
 }
 
 func formatSearchResults(query string, results []skills.SearchResult, cached bool) string {
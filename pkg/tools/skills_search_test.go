@@ -88,3 +88,21 @@ func TestFormatSearchResultsWithData(t *testing.T) {
 	assert.Contains(t, output, "clawhub")
 	assert.Contains(t, output, "install_skill")
 }
+
+func TestFindSkillsToolHidesDisallowedSkills(t *testing.T) {
+	cache := skills.NewSearchCache(10, 5*60*1000*1000*1000) // 5 min
+	cache.Put("dev", []skills.SearchResult{
+		{Slug: "github", Score: 0.9, RegistryName: "clawhub"},
+		{Slug: "weather", Score: 0.5, RegistryName: "clawhub"},
+	})
+
+	tool := NewFindSkillsTool(skills.NewRegistryManager(), cache)
+	tool.SetAllowlistChecker(func(slug string) bool { return slug == "weather" })
+	result := tool.Execute(context.Background(), map[string]any{
+		"query": "dev",
+	})
+
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "weather")
+	assert.NotContains(t, result.ForLLM, "github")
+}
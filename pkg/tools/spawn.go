@@ -32,7 +32,7 @@ func (t *SpawnTool) Name() string {
 }
 
 func (t *SpawnTool) Description() string {
-	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done."
+	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done. With agent_id, the task is delegated to that agent, which runs it with its own model, workspace and tools."
 }
 
 func (t *SpawnTool) Parameters() map[string]any {
@@ -49,7 +49,7 @@ func (t *SpawnTool) Parameters() map[string]any {
 			},
 			"agent_id": map[string]any{
 				"type":        "string",
-				"description": "Optional target agent ID to delegate the task to",
+				"description": "Optional ID of another agent to delegate the task to",
 			},
 		},
 		"required": []string{"task"},
@@ -86,7 +86,12 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]any) *ToolResul
 	}
 
 	// Pass callback to manager for async completion notification
-	result, err := t.manager.Spawn(ctx, task, label, agentID, t.originChannel, t.originChatID, t.callback)
+	callback := asyncCallbackFrom(ctx)
+	if callback == nil {
+		callback = t.callback
+	}
+	originChannel, originChatID := toolTarget(ctx, t.originChannel, t.originChatID)
+	result, err := t.manager.Spawn(ctx, task, label, agentID, originChannel, originChatID, callback)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to spawn subagent: %v", err))
 	}
@@ -2,33 +2,68 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// Subagent task statuses.
+const (
+	TaskRunning     = "running"
+	TaskCompleted   = "completed"
+	TaskFailed      = "failed"
+	TaskCanceled    = "canceled"
+	TaskInterrupted = "interrupted" // still running when picoclaw stopped
+)
+
+// maxStoredTasks bounds the finished tasks kept in the task file.
+const maxStoredTasks = 100
+
 type SubagentTask struct {
-	ID            string
-	Task          string
-	Label         string
-	AgentID       string
-	OriginChannel string
-	OriginChatID  string
-	Status        string
-	Result        string
-	Created       int64
+	ID            string `json:"id"`
+	Task          string `json:"task"`
+	Label         string `json:"label,omitempty"`
+	AgentID       string `json:"agent_id,omitempty"`
+	OriginChannel string `json:"origin_channel"`
+	OriginChatID  string `json:"origin_chat_id"`
+	Status        string `json:"status"`
+	Result        string `json:"result,omitempty"`
+	Created       int64  `json:"created"`
+	Finished      int64  `json:"finished,omitempty"`
+	// Chain is the delegation path of a task run on another agent, from the
+	// first delegating agent to AgentID; empty for plain subagent tasks.
+	Chain []string `json:"chain,omitempty"`
+}
+
+// DisplayName returns the task's label, or its ID if it has none.
+func (t *SubagentTask) DisplayName() string {
+	if t.Label != "" {
+		return t.Label
+	}
+	return t.ID
 }
 
 type SubagentManager struct {
 	tasks          map[string]*SubagentTask
+	cancels        map[string]context.CancelFunc
 	mu             sync.RWMutex
 	provider       providers.LLMProvider
 	defaultModel   string
 	bus            *bus.MessageBus
 	workspace      string
+	tasksFile      string          // "" when tasks are not persisted
+	owned          map[string]bool // tasks run or settled here; the task file holds the others
 	tools          *ToolRegistry
 	maxIterations  int
 	maxTokens      int
@@ -36,15 +71,25 @@ type SubagentManager struct {
 	hasMaxTokens   bool
 	hasTemperature bool
 	nextID         int
+
+	// Delegation to other agents; see SetDelegator.
+	ownerID   string
+	delegator Delegator
+	maxDepth  int
 }
 
+// NewSubagentManager creates a manager whose tasks are persisted in
+// workspace/state/tasks.json, next to those of other processes sharing the
+// workspace. See MarkInterrupted for tasks a previous run left running.
 func NewSubagentManager(
 	provider providers.LLMProvider,
 	defaultModel, workspace string,
 	bus *bus.MessageBus,
 ) *SubagentManager {
-	return &SubagentManager{
+	sm := &SubagentManager{
 		tasks:         make(map[string]*SubagentTask),
+		cancels:       make(map[string]context.CancelFunc),
+		owned:         make(map[string]bool),
 		provider:      provider,
 		defaultModel:  defaultModel,
 		bus:           bus,
@@ -53,6 +98,140 @@ func NewSubagentManager(
 		maxIterations: 10,
 		nextID:        1,
 	}
+	if workspace != "" {
+		sm.tasksFile = filepath.Join(workspace, "state", "tasks.json")
+		sm.loadTasks()
+	}
+	return sm
+}
+
+// loadTasks restores the tasks of a previous run.
+func (sm *SubagentManager) loadTasks() {
+	tasks, ok := sm.readTasks()
+	if !ok {
+		return
+	}
+	for _, task := range tasks {
+		sm.addLocked(task)
+	}
+}
+
+// readTasks reads the task file. ok is false if it can't be read.
+func (sm *SubagentManager) readTasks() (tasks []*SubagentTask, ok bool) {
+	data, err := os.ReadFile(sm.tasksFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.WarnCF("subagent", "Failed to read tasks", map[string]any{"error": err.Error()})
+		}
+		return nil, false
+	}
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		logger.WarnCF("subagent", "Failed to parse tasks", map[string]any{"error": err.Error()})
+		return nil, false
+	}
+	return tasks, true
+}
+
+func (sm *SubagentManager) addLocked(task *SubagentTask) {
+	sm.tasks[task.ID] = task
+	if n, err := strconv.Atoi(strings.TrimPrefix(task.ID, "subagent-")); err == nil && n >= sm.nextID {
+		sm.nextID = n + 1
+	}
+}
+
+// syncLocked takes in the tasks that other processes sharing the workspace,
+// e.g. the gateway and `picoclaw agent`, wrote to the task file since it was
+// read, so that saving does not overwrite them and new IDs stay unique. Must
+// be called with sm.mu held.
+func (sm *SubagentManager) syncLocked() {
+	if sm.tasksFile == "" {
+		return
+	}
+	tasks, ok := sm.readTasks()
+	if !ok {
+		return
+	}
+	for id := range sm.tasks {
+		if !sm.owned[id] {
+			delete(sm.tasks, id)
+		}
+	}
+	for _, task := range tasks {
+		if !sm.owned[task.ID] {
+			sm.addLocked(task)
+		}
+	}
+}
+
+// MarkInterrupted marks the tasks a previous run left running as
+// interrupted. Only the process that runs in the background, the gateway,
+// calls it; others, e.g. `picoclaw agent`, would take the gateway's running
+// tasks for leftovers.
+func (sm *SubagentManager) MarkInterrupted() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.syncLocked()
+
+	interrupted := 0
+	now := time.Now().UnixMilli()
+	for _, task := range sm.tasks {
+		if task.Status != TaskRunning || sm.owned[task.ID] {
+			continue
+		}
+		task.Status = TaskInterrupted
+		task.Result = "Interrupted by a restart before it finished"
+		task.Finished = now
+		sm.owned[task.ID] = true
+		interrupted++
+	}
+	if interrupted > 0 {
+		logger.InfoCF("subagent", "Marked tasks interrupted by restart", map[string]any{"count": interrupted})
+		sm.saveLocked()
+	}
+}
+
+// saveLocked writes the tasks to the task file, dropping the oldest finished
+// tasks beyond maxStoredTasks. Must be called with sm.mu held.
+func (sm *SubagentManager) saveLocked() {
+	if sm.tasksFile == "" {
+		return
+	}
+	sm.syncLocked()
+	tasks := sortedTasks(sm.tasks)
+	finished := 0
+	for i := len(tasks) - 1; i >= 0; i-- {
+		if tasks[i].Status == TaskRunning {
+			continue
+		}
+		if finished++; finished > maxStoredTasks {
+			delete(sm.tasks, tasks[i].ID)
+		}
+	}
+	if finished > maxStoredTasks {
+		tasks = sortedTasks(sm.tasks)
+	}
+
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(sm.tasksFile, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("subagent", "Failed to save tasks", map[string]any{"error": err.Error()})
+	}
+}
+
+func sortedTasks(m map[string]*SubagentTask) []*SubagentTask {
+	tasks := make([]*SubagentTask, 0, len(m))
+	for _, task := range m {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Created != tasks[j].Created {
+			return tasks[i].Created < tasks[j].Created
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
 }
 
 // SetLLMOptions sets max tokens and temperature for subagent LLM calls.
@@ -88,6 +267,16 @@ func (sm *SubagentManager) Spawn(
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	var chain []string
+	if agentID != "" && sm.delegator != nil {
+		var err error
+		if chain, err = sm.delegationChain(ctx, agentID); err != nil {
+			return "", err
+		}
+		agentID = chain[len(chain)-1]
+	}
+
+	sm.syncLocked()
 	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
 	sm.nextID++
 
@@ -98,24 +287,105 @@ func (sm *SubagentManager) Spawn(
 		AgentID:       agentID,
 		OriginChannel: originChannel,
 		OriginChatID:  originChatID,
-		Status:        "running",
+		Status:        TaskRunning,
 		Created:       time.Now().UnixMilli(),
+		Chain:         chain,
 	}
 	sm.tasks[taskID] = subagentTask
+	sm.owned[taskID] = true
+	taskCtx, cancel := context.WithCancel(ctx)
+	sm.cancels[taskID] = cancel
+	sm.saveLocked()
 
 	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	go func() {
+		defer cancel()
+		sm.runTask(taskCtx, subagentTask, callback)
+	}()
 
+	if len(chain) > 0 {
+		return fmt.Sprintf("Delegated task %s to agent '%s': %s", taskID, agentID, task), nil
+	}
 	if label != "" {
-		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
+		return fmt.Sprintf("Spawned subagent '%s' (%s) for task: %s", label, taskID, task), nil
 	}
-	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
+	return fmt.Sprintf("Spawned subagent %s for task: %s", taskID, task), nil
+}
+
+// Cancel stops a running task.
+func (sm *SubagentManager) Cancel(taskID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	task, ok := sm.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("no task with id %s", taskID)
+	}
+	cancel, running := sm.cancels[taskID]
+	if !running {
+		return fmt.Errorf("task %s is not running (%s)", taskID, task.Status)
+	}
+	cancel()
+	return nil
+}
+
+// finishLocked records the outcome of task. Must be called with sm.mu held.
+func (sm *SubagentManager) finishLocked(task *SubagentTask, status, result string) {
+	task.Status = status
+	task.Result = result
+	task.Finished = time.Now().UnixMilli()
+	delete(sm.cancels, task.ID)
+	sm.saveLocked()
 }
 
 func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
-	task.Status = "running"
-	task.Created = time.Now().UnixMilli()
+	// Check if context is already canceled before starting
+	select {
+	case <-ctx.Done():
+		sm.mu.Lock()
+		sm.finishLocked(task, TaskCanceled, "Task canceled before execution")
+		sm.mu.Unlock()
+		return
+	default:
+	}
+
+	var result *ToolResult
+	if len(task.Chain) > 0 {
+		result = sm.runDelegated(ctx, task)
+	} else {
+		result = sm.runLocal(ctx, task)
+	}
+
+	// Send announce message back to main agent
+	if sm.bus != nil {
+		sm.mu.RLock()
+		name, status := task.DisplayName(), task.Status
+		sm.mu.RUnlock()
+		var metadata map[string]string
+		if sm.ownerID != "" {
+			metadata = map[string]string{"agent_id": sm.ownerID}
+		}
+		announceContent := fmt.Sprintf("Task '%s' %s.\n\nResult:\n%s", name, status, result.ForLLM)
+		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer pubCancel()
+		sm.bus.PublishInbound(pubCtx, bus.InboundMessage{
+			Channel:  "system",
+			SenderID: fmt.Sprintf("subagent:%s", task.ID),
+			// Format: "original_channel:original_chat_id" for routing back
+			ChatID:   fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
+			Content:  announceContent,
+			Metadata: metadata,
+		})
+	}
+
+	// Call callback if provided
+	if callback != nil {
+		callback(ctx, result)
+	}
+}
 
+// runLocal runs task in a tool loop with the manager's own provider and tools.
+func (sm *SubagentManager) runLocal(ctx context.Context, task *SubagentTask) *ToolResult {
 	// Build system prompt for subagent
 	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
 You have access to tools - use them as needed to complete your task.
@@ -132,17 +402,6 @@ After completing the task, provide a clear summary of what was done.`
 		},
 	}
 
-	// Check if context is already canceled before starting
-	select {
-	case <-ctx.Done():
-		sm.mu.Lock()
-		task.Status = "canceled"
-		task.Result = "Task canceled before execution"
-		sm.mu.Unlock()
-		return
-	default:
-	}
-
 	// Run tool loop with access to tools
 	sm.mu.RLock()
 	tools := sm.tools
@@ -173,24 +432,11 @@ After completing the task, provide a clear summary of what was done.`
 	}, messages, task.OriginChannel, task.OriginChatID)
 
 	sm.mu.Lock()
-	var result *ToolResult
-	defer func() {
-		sm.mu.Unlock()
-		// Call callback if provided and result is set
-		if callback != nil && result != nil {
-			callback(ctx, result)
-		}
-	}()
+	defer sm.mu.Unlock()
 
 	if err != nil {
-		task.Status = "failed"
-		task.Result = fmt.Sprintf("Error: %v", err)
-		// Check if it was canceled
-		if ctx.Err() != nil {
-			task.Status = "canceled"
-			task.Result = "Task canceled during execution"
-		}
-		result = &ToolResult{
+		sm.finishFailedLocked(ctx, task, err)
+		return &ToolResult{
 			ForLLM:  task.Result,
 			ForUser: "",
 			Silent:  false,
@@ -198,56 +444,108 @@ After completing the task, provide a clear summary of what was done.`
 			Async:   false,
 			Err:     err,
 		}
+	}
+	sm.finishLocked(task, TaskCompleted, loopResult.Content)
+	return &ToolResult{
+		ForLLM: fmt.Sprintf(
+			"Subagent '%s' completed (iterations: %d): %s",
+			task.Label,
+			loopResult.Iterations,
+			loopResult.Content,
+		),
+		ForUser: loopResult.Content,
+		Silent:  false,
+		IsError: false,
+		Async:   false,
+	}
+}
+
+// runDelegated runs task on its target agent. The result for the LLM is a
+// DelegationResult in JSON.
+func (sm *SubagentManager) runDelegated(ctx context.Context, task *SubagentTask) *ToolResult {
+	content, err := sm.delegator.Delegate(withDelegationChain(ctx, task.Chain), DelegateRequest{
+		TaskID:  task.ID,
+		AgentID: task.AgentID,
+		Task:    task.Task,
+		Channel: task.OriginChannel,
+		ChatID:  task.OriginChatID,
+	})
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err != nil {
+		sm.finishFailedLocked(ctx, task, err)
 	} else {
-		task.Status = "completed"
-		task.Result = loopResult.Content
-		result = &ToolResult{
-			ForLLM: fmt.Sprintf(
-				"Subagent '%s' completed (iterations: %d): %s",
-				task.Label,
-				loopResult.Iterations,
-				loopResult.Content,
-			),
-			ForUser: loopResult.Content,
-			Silent:  false,
-			IsError: false,
-			Async:   false,
-		}
+		sm.finishLocked(task, TaskCompleted, content)
+	}
+	payload, _ := json.Marshal(DelegationResult{
+		TaskID:  task.ID,
+		AgentID: task.AgentID,
+		Status:  task.Status,
+		Result:  task.Result,
+		Chain:   task.Chain,
+	})
+	result := &ToolResult{ForLLM: string(payload), IsError: err != nil, Err: err}
+	if err == nil {
+		result.ForUser = content
 	}
+	return result
+}
 
-	// Send announce message back to main agent
-	if sm.bus != nil {
-		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
-		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer pubCancel()
-		sm.bus.PublishInbound(pubCtx, bus.InboundMessage{
-			Channel:  "system",
-			SenderID: fmt.Sprintf("subagent:%s", task.ID),
-			// Format: "original_channel:original_chat_id" for routing back
-			ChatID:  fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
-			Content: announceContent,
-		})
+// finishFailedLocked records that task stopped with err, as canceled if ctx
+// was canceled. Must be called with sm.mu held.
+func (sm *SubagentManager) finishFailedLocked(ctx context.Context, task *SubagentTask, err error) {
+	if ctx.Err() != nil {
+		sm.finishLocked(task, TaskCanceled, "Task canceled during execution")
+	} else {
+		sm.finishLocked(task, TaskFailed, fmt.Sprintf("Error: %v", err))
 	}
 }
 
+// GetTask returns a snapshot of the task with the given ID.
 func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 	task, ok := sm.tasks[taskID]
-	return task, ok
+	if !ok {
+		return nil, false
+	}
+	snapshot := *task
+	return &snapshot, true
 }
 
+// ListTasks returns snapshots of all tasks, oldest first.
 func (sm *SubagentManager) ListTasks() []*SubagentTask {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	tasks := make([]*SubagentTask, 0, len(sm.tasks))
-	for _, task := range sm.tasks {
-		tasks = append(tasks, task)
+	tasks := sortedTasks(sm.tasks)
+	for i, task := range tasks {
+		snapshot := *task
+		tasks[i] = &snapshot
 	}
 	return tasks
 }
 
+// ListTasksFor returns snapshots of the tasks spawned from the given chat,
+// oldest first. An empty channel matches every task.
+func (sm *SubagentManager) ListTasksFor(channel, chatID string) []*SubagentTask {
+	var tasks []*SubagentTask
+	for _, task := range sm.ListTasks() {
+		if task.SpawnedFrom(channel, chatID) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// SpawnedFrom reports whether the task was spawned from the given chat. An
+// empty channel matches every task.
+func (t *SubagentTask) SpawnedFrom(channel, chatID string) bool {
+	return channel == "" || (t.OriginChannel == channel && t.OriginChatID == chatID)
+}
+
 // SubagentTool executes a subagent task synchronously and returns the result.
 // Unlike SpawnTool which runs tasks asynchronously, SubagentTool waits for completion
 // and returns the result directly in the ToolResult.
@@ -341,13 +639,14 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		}
 	}
 
+	originChannel, originChatID := toolTarget(ctx, t.originChannel, t.originChatID)
 	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
 		Provider:      sm.provider,
 		Model:         sm.defaultModel,
 		Tools:         tools,
 		MaxIterations: maxIter,
 		LLMOptions:    llmOptions,
-	}, messages, t.originChannel, t.originChatID)
+	}, messages, originChannel, originChatID)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
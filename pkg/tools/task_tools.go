@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// maxTaskResultPreview bounds the result shown per task in a task list.
+const maxTaskResultPreview = 120
+
+// TaskListTool lists the subagent tasks spawned from the current chat.
+type TaskListTool struct {
+	manager *SubagentManager
+}
+
+func NewTaskListTool(manager *SubagentManager) *TaskListTool {
+	return &TaskListTool{manager: manager}
+}
+
+func (t *TaskListTool) Name() string {
+	return "task_list"
+}
+
+func (t *TaskListTool) Description() string {
+	return "List the background tasks spawned from this chat with their status. " +
+		"Use task_status for a task's full result."
+}
+
+func (t *TaskListTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{
+				"type":        "string",
+				"description": "Only list tasks with this status",
+				"enum":        []string{TaskRunning, TaskCompleted, TaskFailed, TaskCanceled, TaskInterrupted},
+			},
+		},
+	}
+}
+
+func (t *TaskListTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *TaskListTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	status, _ := args["status"].(string)
+	var tasks []*SubagentTask
+	for _, task := range t.manager.ListTasksFor(ToolChannel(ctx), ToolChatID(ctx)) {
+		if status == "" || task.Status == status {
+			tasks = append(tasks, task)
+		}
+	}
+	return SilentResult(FormatTaskList(tasks))
+}
+
+// TaskStatusTool shows the status and result of one subagent task.
+type TaskStatusTool struct {
+	manager *SubagentManager
+}
+
+func NewTaskStatusTool(manager *SubagentManager) *TaskStatusTool {
+	return &TaskStatusTool{manager: manager}
+}
+
+func (t *TaskStatusTool) Name() string {
+	return "task_status"
+}
+
+func (t *TaskStatusTool) Description() string {
+	return "Show the status, timing and full result of a background task."
+}
+
+func (t *TaskStatusTool) Parameters() map[string]any {
+	return taskIDParameters("The task ID, as returned by spawn or task_list")
+}
+
+func (t *TaskStatusTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *TaskStatusTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	task, errResult := lookupTask(ctx, t.manager, args)
+	if errResult != nil {
+		return errResult
+	}
+	return SilentResult(FormatTask(task))
+}
+
+// TaskCancelTool cancels a running subagent task.
+type TaskCancelTool struct {
+	manager *SubagentManager
+}
+
+func NewTaskCancelTool(manager *SubagentManager) *TaskCancelTool {
+	return &TaskCancelTool{manager: manager}
+}
+
+func (t *TaskCancelTool) Name() string {
+	return "task_cancel"
+}
+
+func (t *TaskCancelTool) Description() string {
+	return "Cancel a running background task."
+}
+
+func (t *TaskCancelTool) Parameters() map[string]any {
+	return taskIDParameters("The ID of the task to cancel")
+}
+
+func (t *TaskCancelTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	task, errResult := lookupTask(ctx, t.manager, args)
+	if errResult != nil {
+		return errResult
+	}
+	if err := t.manager.Cancel(task.ID); err != nil {
+		return ErrorResult(err.Error())
+	}
+	return SilentResult(fmt.Sprintf("Canceled task %s", task.ID))
+}
+
+func taskIDParameters(description string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"task_id": map[string]any{
+				"type":        "string",
+				"description": description,
+			},
+		},
+		"required": []string{"task_id"},
+	}
+}
+
+// lookupTask returns the task named by args["task_id"]. Tasks spawned from
+// other chats are reported as not found.
+func lookupTask(ctx context.Context, manager *SubagentManager, args map[string]any) (*SubagentTask, *ToolResult) {
+	id, _ := args["task_id"].(string)
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, ErrorResult("task_id is required")
+	}
+	task, ok := manager.GetTask(id)
+	if !ok || !task.SpawnedFrom(ToolChannel(ctx), ToolChatID(ctx)) {
+		return nil, ErrorResult(fmt.Sprintf("no task with id %s", id))
+	}
+	return task, nil
+}
+
+// FormatTaskList renders tasks one per line with their status and a preview
+// of their result.
+func FormatTaskList(tasks []*SubagentTask) string {
+	if len(tasks) == 0 {
+		return "No tasks"
+	}
+	var sb strings.Builder
+	for i, task := range tasks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%s [%s]", task.ID, task.Status)
+		if task.Label != "" {
+			fmt.Fprintf(&sb, " %s", task.Label)
+		}
+		fmt.Fprintf(&sb, " (%s)", taskTiming(task))
+		if task.Result != "" {
+			preview := strings.Join(strings.Fields(task.Result), " ")
+			fmt.Fprintf(&sb, ": %s", utils.Truncate(preview, maxTaskResultPreview))
+		}
+	}
+	return sb.String()
+}
+
+// FormatTask renders the details and full result of a task.
+func FormatTask(task *SubagentTask) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Task: %s\n", task.ID)
+	if task.Label != "" {
+		fmt.Fprintf(&sb, "Label: %s\n", task.Label)
+	}
+	fmt.Fprintf(&sb, "Status: %s (%s)\n", task.Status, taskTiming(task))
+	fmt.Fprintf(&sb, "Started: %s\n", time.UnixMilli(task.Created).Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Origin: %s:%s\n", task.OriginChannel, task.OriginChatID)
+	fmt.Fprintf(&sb, "Prompt: %s", task.Task)
+	if task.Result != "" {
+		fmt.Fprintf(&sb, "\n\nResult:\n%s", task.Result)
+	}
+	return sb.String()
+}
+
+// taskTiming describes how long a task has been running, or how long it ran.
+func taskTiming(task *SubagentTask) string {
+	started := time.UnixMilli(task.Created)
+	if task.Finished == 0 {
+		return "running for " + time.Since(started).Round(time.Second).String()
+	}
+	return "took " + time.UnixMilli(task.Finished).Sub(started).Round(time.Second).String()
+}
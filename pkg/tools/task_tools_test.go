@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// blockingProvider answers only once its context is canceled.
+type blockingProvider struct{}
+
+func (p *blockingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *blockingProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+func waitForTaskStatus(t *testing.T, sm *SubagentManager, id, status string) *SubagentTask {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, ok := sm.GetTask(id)
+		if ok && task.Status == status {
+			return task
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s did not reach status %s: %+v", id, status, task)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubagentManager_MarksInterruptedTasks(t *testing.T) {
+	workspace := t.TempDir()
+	tasks := []*SubagentTask{
+		{ID: "subagent-3", Task: "done", Status: TaskCompleted, Result: "ok", Created: 1, Finished: 2},
+		{ID: "subagent-7", Task: "long", Status: TaskRunning, Created: 3},
+	}
+	data, _ := json.Marshal(tasks)
+	if err := os.MkdirAll(filepath.Join(workspace, "state"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "state", "tasks.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", workspace, nil)
+	if task, _ := sm.GetTask("subagent-7"); task.Status != TaskRunning {
+		t.Fatalf("loading must leave running tasks alone, got %+v", task)
+	}
+	sm.MarkInterrupted()
+	task, ok := sm.GetTask("subagent-7")
+	if !ok || task.Status != TaskInterrupted || task.Finished == 0 {
+		t.Fatalf("expected running task to be interrupted, got %+v", task)
+	}
+	if task, _ := sm.GetTask("subagent-3"); task.Status != TaskCompleted || task.Result != "ok" {
+		t.Errorf("completed task changed on load: %+v", task)
+	}
+
+	// New IDs continue after the stored ones, and the file is kept up to date.
+	msg, err := sm.Spawn(context.Background(), "next", "", "", "cli", "direct", nil)
+	if err != nil || !strings.Contains(msg, "subagent-8") {
+		t.Fatalf("expected subagent-8, got %q, %v", msg, err)
+	}
+	waitForTaskStatus(t, sm, "subagent-8", TaskCompleted)
+
+	reloaded := NewSubagentManager(&MockLLMProvider{}, "test-model", workspace, nil)
+	task, ok = reloaded.GetTask("subagent-8")
+	if !ok || task.Status != TaskCompleted || !strings.Contains(task.Result, "next") {
+		t.Errorf("completed task not persisted: %+v", task)
+	}
+}
+
+func TestSubagentManager_KeepsTasksOfOtherProcesses(t *testing.T) {
+	workspace := t.TempDir()
+	gateway := NewSubagentManager(&blockingProvider{}, "test-model", workspace, nil)
+	if _, err := gateway.Spawn(context.Background(), "wait forever", "", "", "cli", "direct", nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gateway.Cancel("subagent-1")
+		waitForTaskStatus(t, gateway, "subagent-1", TaskCanceled)
+	})
+
+	// A second process, e.g. `picoclaw agent`, loads the file and spawns a
+	// task of its own; it must neither interrupt nor drop the gateway's.
+	cli := NewSubagentManager(&MockLLMProvider{}, "test-model", workspace, nil)
+	msg, err := cli.Spawn(context.Background(), "quick", "", "", "cli", "direct", nil)
+	if err != nil || !strings.Contains(msg, "subagent-2") {
+		t.Fatalf("expected subagent-2, got %q, %v", msg, err)
+	}
+	waitForTaskStatus(t, cli, "subagent-2", TaskCompleted)
+
+	if _, err := gateway.Spawn(context.Background(), "another", "", "", "cli", "direct", nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gateway.Cancel("subagent-3")
+		waitForTaskStatus(t, gateway, "subagent-3", TaskCanceled)
+	})
+
+	reloaded := NewSubagentManager(&MockLLMProvider{}, "test-model", workspace, nil)
+	for id, status := range map[string]string{
+		"subagent-1": TaskRunning, "subagent-2": TaskCompleted, "subagent-3": TaskRunning,
+	} {
+		if task, ok := reloaded.GetTask(id); !ok || task.Status != status {
+			t.Errorf("task %s = %+v, want status %s", id, task, status)
+		}
+	}
+}
+
+func TestTaskTools_CancelAndScope(t *testing.T) {
+	sm := NewSubagentManager(&blockingProvider{}, "test-model", t.TempDir(), nil)
+	if _, err := sm.Spawn(context.Background(), "wait forever", "waiter", "", "telegram", "42", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	here := WithToolContext(context.Background(), "telegram", "42")
+	elsewhere := WithToolContext(context.Background(), "telegram", "99")
+
+	list := NewTaskListTool(sm)
+	if r := list.Execute(here, map[string]any{}); !strings.Contains(r.ForLLM, "subagent-1 [running] waiter") {
+		t.Errorf("expected running task in list, got: %s", r.ForLLM)
+	}
+	if r := list.Execute(elsewhere, map[string]any{}); r.ForLLM != "No tasks" {
+		t.Errorf("task leaked into another chat: %s", r.ForLLM)
+	}
+
+	cancel := NewTaskCancelTool(sm)
+	if r := cancel.Execute(elsewhere, map[string]any{"task_id": "subagent-1"}); !r.IsError {
+		t.Errorf("expected another chat not to cancel the task, got: %s", r.ForLLM)
+	}
+	if r := cancel.Execute(here, map[string]any{"task_id": "subagent-1"}); r.IsError {
+		t.Fatalf("cancel failed: %s", r.ForLLM)
+	}
+	waitForTaskStatus(t, sm, "subagent-1", TaskCanceled)
+
+	if r := cancel.Execute(here, map[string]any{"task_id": "subagent-1"}); !r.IsError ||
+		!strings.Contains(r.ForLLM, "not running") {
+		t.Errorf("expected error canceling a finished task, got: %s", r.ForLLM)
+	}
+
+	status := NewTaskStatusTool(sm).Execute(here, map[string]any{"task_id": "subagent-1"})
+	for _, want := range []string{"Status: canceled", "Prompt: wait forever", "Result:\nTask canceled"} {
+		if !strings.Contains(status.ForLLM, want) {
+			t.Errorf("expected %q in status, got: %s", want, status.ForLLM)
+		}
+	}
+}
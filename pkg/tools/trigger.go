@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/triggers"
+)
+
+// TriggerTool lets the agent manage event-driven trigger rules.
+type TriggerTool struct {
+	service  *triggers.Service
+	restrict bool
+	usb      bool
+	channel  string
+	chatID   string
+	mu       sync.RWMutex
+}
+
+// NewTriggerTool creates a TriggerTool. With restrict, file sources must be
+// inside the workspace. usb reports whether USB devices are monitored; USB
+// sources are refused without it, since they would never fire.
+func NewTriggerTool(service *triggers.Service, restrict, usb bool) *TriggerTool {
+	return &TriggerTool{service: service, restrict: restrict, usb: usb}
+}
+
+func (t *TriggerTool) Name() string {
+	return "trigger"
+}
+
+func (t *TriggerTool) Description() string {
+	return "Run a prompt or send a message when something happens: a workspace file changes (source=file), " +
+		"an HTTP POST reaches a webhook (source=webhook), a USB device is plugged in or out (source=usb), " +
+		"or a cron schedule ticks (source=cron). The message is a Go template over the event fields, e.g. " +
+		"'{{.path}} was {{.op}}' for files, '{{.body}}' or '{{.json.field}}' for webhooks, " +
+		"'{{.vendor}} {{.product}}' for USB. For time-only reminders use the cron tool instead."
+}
+
+func (t *TriggerTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"add", "list", "remove", "enable", "disable"},
+				"description": "Action to perform",
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Short rule name (for add)",
+			},
+			"source": map[string]any{
+				"type":        "string",
+				"enum":        []string{triggers.SourceFile, triggers.SourceWebhook, triggers.SourceUSB, triggers.SourceCron},
+				"description": "Event source (for add)",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "file source: workspace file, directory or glob pattern to watch",
+			},
+			"vendor": map[string]any{
+				"type":        "string",
+				"description": "usb source: vendor name or hex ID to match (default: any)",
+			},
+			"product": map[string]any{
+				"type":        "string",
+				"description": "usb source: product name or hex ID to match (default: any)",
+			},
+			"device_action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"add", "remove"},
+				"description": "usb source: only fire when the device is plugged in (add) or out (remove)",
+			},
+			"cron_expr": map[string]any{
+				"type":        "string",
+				"description": "cron source: cron expression (e.g. '*/15 * * * *')",
+			},
+			"message": map[string]any{
+				"type":        "string",
+				"description": "Message template; a default describing the event is used if empty",
+			},
+			"deliver": map[string]any{
+				"type": "boolean",
+				"description": "If true, send the message directly to this chat. " +
+					"If false, run it as a prompt for the agent. Default: false",
+			},
+			"agent_id": map[string]any{
+				"type":        "string",
+				"description": "Agent to run the prompt on (default: the agent this chat is routed to)",
+			},
+			"debounce_seconds": map[string]any{
+				"type":        "integer",
+				"description": "Fire once after events stop arriving for this many seconds (default 0: every event)",
+			},
+			"rule_id": map[string]any{
+				"type":        "string",
+				"description": "Rule ID (for remove/enable/disable)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// SetContext sets the fallback session context used when ctx carries none
+func (t *TriggerTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *TriggerTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "add":
+		return t.addRule(ctx, args)
+	case "list":
+		return SilentResult(FormatTriggerRules(t.service.ListRules()))
+	case "remove":
+		id, errResult := triggerRuleID(args)
+		if errResult != nil {
+			return errResult
+		}
+		if !t.service.RemoveRule(id) {
+			return ErrorResult(fmt.Sprintf("Trigger %s not found", id))
+		}
+		return SilentResult(fmt.Sprintf("Trigger removed: %s", id))
+	case "enable", "disable":
+		id, errResult := triggerRuleID(args)
+		if errResult != nil {
+			return errResult
+		}
+		rule := t.service.EnableRule(id, action == "enable")
+		if rule == nil {
+			return ErrorResult(fmt.Sprintf("Trigger %s not found", id))
+		}
+		return SilentResult(fmt.Sprintf("Trigger '%s' %sd", rule.Name, action))
+	case "":
+		return ErrorResult("action is required")
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+func (t *TriggerTool) addRule(ctx context.Context, args map[string]any) *ToolResult {
+	t.mu.RLock()
+	channel, chatID := toolTarget(ctx, t.channel, t.chatID)
+	t.mu.RUnlock()
+	if channel == "" || chatID == "" {
+		return ErrorResult("no session context (channel/chat_id not set). Use this tool in an active conversation.")
+	}
+
+	str := func(key string) string {
+		v, _ := args[key].(string)
+		return strings.TrimSpace(v)
+	}
+	rule := triggers.Rule{
+		Name: str("name"),
+		Source: triggers.Source{
+			Kind:    str("source"),
+			Path:    str("path"),
+			Vendor:  str("vendor"),
+			Product: str("product"),
+			Action:  str("device_action"),
+			Expr:    str("cron_expr"),
+		},
+		Action: triggers.Action{
+			Kind:     triggers.ActionAgent,
+			Template: str("message"),
+			AgentID:  str("agent_id"),
+			Channel:  channel,
+			To:       chatID,
+		},
+	}
+	if deliver, _ := args["deliver"].(bool); deliver {
+		rule.Action.Kind = triggers.ActionDeliver
+	}
+	if d, ok := args["debounce_seconds"].(float64); ok && d > 0 {
+		rule.DebounceMS = int64(d * 1000)
+	}
+	if t.restrict && rule.Source.Kind == triggers.SourceFile && !filepath.IsLocal(rule.Source.Path) {
+		return ErrorResult("path must be inside the workspace")
+	}
+	if !t.usb && rule.Source.Kind == triggers.SourceUSB {
+		return ErrorResult("USB monitoring is off: set devices.enabled and devices.monitor_usb in the config " +
+			"to use usb triggers")
+	}
+
+	added, err := t.service.AddRule(rule)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Error adding trigger: %v", err))
+	}
+	result := fmt.Sprintf("Trigger added: %s (id: %s, %s)", added.Name, added.ID, added.Describe())
+	if added.Source.Kind == triggers.SourceWebhook {
+		result += fmt.Sprintf("\nPOST to the gateway at %s with header \"Authorization: Bearer %s\"",
+			triggers.WebhookPath(added.ID), added.Source.Token)
+	}
+	return SilentResult(result)
+}
+
+func triggerRuleID(args map[string]any) (string, *ToolResult) {
+	id, _ := args["rule_id"].(string)
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", ErrorResult("rule_id is required")
+	}
+	return id, nil
+}
+
+// FormatTriggerRules lists rules one per line for display.
+func FormatTriggerRules(rules []triggers.Rule) string {
+	if len(rules) == 0 {
+		return "No triggers"
+	}
+	var sb strings.Builder
+	sb.WriteString("Triggers:")
+	for _, r := range rules {
+		status := r.Action.Kind
+		if !r.Enabled {
+			status += ", disabled"
+		}
+		if r.DebounceMS > 0 {
+			status += fmt.Sprintf(", debounce %gs", float64(r.DebounceMS)/1000)
+		}
+		if r.State.LastStatus != "" {
+			status += fmt.Sprintf(", fired %d times, last %s", r.State.FireCount, r.State.LastStatus)
+		}
+		fmt.Fprintf(&sb, "\n- %s (id: %s, %s; %s)", r.Name, r.ID, r.Describe(), status)
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/triggers"
+)
+
+func TestTriggerTool_AddListRemove(t *testing.T) {
+	workspace := t.TempDir()
+	service := triggers.NewService(filepath.Join(workspace, "triggers", "rules.json"), workspace, nil)
+	tool := NewTriggerTool(service, true, false)
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+
+	if result := tool.Execute(context.Background(), map[string]any{
+		"action": "add", "name": "hook", "source": "webhook",
+	}); !result.IsError {
+		t.Error("expected add without session context to be rejected")
+	}
+	if result := tool.Execute(ctx, map[string]any{
+		"action": "add", "name": "arduino", "source": "usb",
+	}); !result.IsError || !strings.Contains(result.ForLLM, "monitor_usb") {
+		t.Errorf("expected a usb source to be rejected while USB monitoring is off, got %+v", result)
+	}
+	if result := tool.Execute(ctx, map[string]any{
+		"action": "add", "name": "escape", "source": "file", "path": "../outside.txt",
+	}); !result.IsError {
+		t.Error("expected a file path outside the workspace to be rejected")
+	}
+
+	result := tool.Execute(ctx, map[string]any{
+		"action":           "add",
+		"name":             "deploy",
+		"source":           "webhook",
+		"message":          "Deploy {{.json.status}}",
+		"deliver":          true,
+		"debounce_seconds": float64(5),
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, "Authorization: Bearer ") {
+		t.Fatalf("unexpected add result: %+v", result)
+	}
+
+	rules := service.ListRules()
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	rule := rules[0]
+	if rule.Action.Kind != triggers.ActionDeliver || rule.Action.Channel != "telegram" || rule.Action.To != "42" {
+		t.Errorf("unexpected action: %+v", rule.Action)
+	}
+	if rule.DebounceMS != 5000 {
+		t.Errorf("DebounceMS = %d, want 5000", rule.DebounceMS)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "disable", "rule_id": rule.ID}); result.IsError {
+		t.Fatalf("unexpected disable result: %+v", result)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, "deploy (id: "+rule.ID) || !strings.Contains(result.ForLLM, "disabled") {
+		t.Errorf("unexpected list result: %q", result.ForLLM)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "remove", "rule_id": rule.ID}); result.IsError {
+		t.Fatalf("unexpected remove result: %+v", result)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "remove", "rule_id": rule.ID}); !result.IsError {
+		t.Error("expected removing an unknown rule to fail")
+	}
+}
@@ -6,11 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 const (
@@ -19,11 +24,7 @@ const (
 
 // Pre-compiled regexes for HTML text extraction
 var (
-	reScript     = regexp.MustCompile(`<script[\s\S]*?</script>`)
-	reStyle      = regexp.MustCompile(`<style[\s\S]*?</style>`)
-	reTags       = regexp.MustCompile(`<[^>]+>`)
-	reWhitespace = regexp.MustCompile(`[^\S\n]+`)
-	reBlankLines = regexp.MustCompile(`\n{3,}`)
+	reTags = regexp.MustCompile(`<[^>]+>`)
 
 	// DuckDuckGo result extraction
 	reDDGLink    = regexp.MustCompile(`<a[^>]*class="[^"]*result__a[^"]*"[^>]*href="([^"]+)"[^>]*>([\s\S]*?)</a>`)
@@ -458,6 +459,10 @@ func (t *WebSearchTool) Name() string {
 	return "web_search"
 }
 
+func (t *WebSearchTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *WebSearchTool) Description() string {
 	return "Search the web for current information. Returns titles, URLs, and snippets from search results."
 }
@@ -508,6 +513,7 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) *ToolR
 type WebFetchTool struct {
 	maxChars int
 	proxy    string
+	egress   *utils.EgressPolicy // nil means utils.DefaultEgressPolicy()
 }
 
 func NewWebFetchTool(maxChars int) *WebFetchTool {
@@ -533,8 +539,14 @@ func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
+func (t *WebFetchTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL and extract its readable content as Markdown (HTML pages keep headings, links, " +
+		"lists, tables and code; JSON, plain text and PDF are supported too). Long documents are returned " +
+		"in pages: use offset to continue where the previous call stopped."
 }
 
 func (t *WebFetchTool) Parameters() map[string]any {
@@ -547,9 +559,14 @@ func (t *WebFetchTool) Parameters() map[string]any {
 			},
 			"maxChars": map[string]any{
 				"type":        "integer",
-				"description": "Maximum characters to extract",
+				"description": "Maximum characters to return",
 				"minimum":     100.0,
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Character offset to start from, for reading the next page of a long document",
+				"minimum":     0.0,
+			},
 		},
 		"required": []string{"url"},
 	}
@@ -574,12 +591,24 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("missing domain in URL")
 	}
 
+	egress := t.egress
+	if egress == nil {
+		egress = utils.DefaultEgressPolicy()
+	}
+	if err := egress.CheckURL(ctx, parsedURL); err != nil {
+		return ErrorResult(fmt.Sprintf("fetch not allowed: %v", err))
+	}
+
 	maxChars := t.maxChars
 	if mc, ok := args["maxChars"].(float64); ok {
 		if int(mc) > 100 {
 			maxChars = int(mc)
 		}
 	}
+	offset := 0
+	if o, ok := args["offset"].(float64); ok && o > 0 {
+		offset = int(o)
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
@@ -593,6 +622,9 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult(fmt.Sprintf("failed to create HTTP client: %v", err))
 	}
 
+	// Every redirect and dialed address is re-checked against the policy.
+	client = egress.Guard(client)
+
 	// Configure redirect handling
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		if len(via) >= 5 {
@@ -607,80 +639,95 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, bodyTruncated, err := egress.ReadBody(resp.Body)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to read response: %v", err))
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-
-	var text, extractor string
-
-	if strings.Contains(contentType, "application/json") {
-		var jsonData any
-		if err := json.Unmarshal(body, &jsonData); err == nil {
-			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
-			text = string(formatted)
-			extractor = "json"
-		} else {
-			text = string(body)
-			extractor = "raw"
-		}
-	} else if strings.Contains(contentType, "text/html") || len(body) > 0 &&
-		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
-		text = t.extractText(string(body))
-		extractor = "text"
-	} else {
-		text = string(body)
-		extractor = "raw"
+	finalURL := resp.Request.URL
+	title, text, extractor := extractContent(body, resp.Header.Get("Content-Type"), finalURL)
+	if extractor == "binary" {
+		return ErrorResult(fmt.Sprintf("%s returned %s content, which cannot be read as text", urlStr, text))
 	}
 
-	truncated := len(text) > maxChars
-	if truncated {
-		text = text[:maxChars]
+	page := []rune(text)
+	if offset > len(page) {
+		return ErrorResult(fmt.Sprintf("offset %d is past the end of the document (%d characters)", offset, len(page)))
 	}
+	end := min(offset+maxChars, len(page))
 
-	result := map[string]any{
-		"url":       urlStr,
-		"status":    resp.StatusCode,
-		"extractor": extractor,
-		"truncated": truncated,
-		"length":    len(text),
-		"text":      text,
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "URL: %s\n", finalURL)
+	if title != "" {
+		fmt.Fprintf(&sb, "Title: %s\n", title)
 	}
-
-	resultJSON, _ := json.MarshalIndent(result, "", "  ")
-
-	return &ToolResult{
-		ForLLM: fmt.Sprintf(
-			"Fetched %d bytes from %s (extractor: %s, truncated: %v)",
-			len(text),
-			urlStr,
-			extractor,
-			truncated,
-		),
-		ForUser: string(resultJSON),
+	fmt.Fprintf(&sb, "Status: %d\nExtractor: %s\n", resp.StatusCode, extractor)
+	if offset > 0 || end < len(page) {
+		fmt.Fprintf(&sb, "Showing characters %d-%d of %d.", offset, end, len(page))
+		if end < len(page) {
+			fmt.Fprintf(&sb, " Call web_fetch again with offset=%d to read more.", end)
+		}
+		sb.WriteString("\n")
 	}
-}
+	if bodyTruncated {
+		fmt.Fprintf(&sb, "Note: the response exceeded %d bytes and was cut off.\n", egress.MaxResponseBytes())
+	}
+	sb.WriteString("\n")
+	sb.WriteString(string(page[offset:end]))
 
-func (t *WebFetchTool) extractText(htmlContent string) string {
-	result := reScript.ReplaceAllLiteralString(htmlContent, "")
-	result = reStyle.ReplaceAllLiteralString(result, "")
-	result = reTags.ReplaceAllLiteralString(result, "")
+	return SilentResult(sb.String())
+}
 
-	result = strings.TrimSpace(result)
+// extractContent turns a response body into readable text according to its
+// content type: Markdown for HTML, pretty-printed JSON, text for PDFs, and
+// the decoded body for other text. For binary bodies the extractor is
+// "binary" and text holds the content type.
+func extractContent(body []byte, contentType string, base *url.URL) (title, text, extractor string) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+
+	switch {
+	case mediaType == "application/pdf" || bytes.HasPrefix(body, []byte("%PDF-")):
+		text = extractPDFText(body)
+		if text == "" {
+			text = "(no extractable text: the PDF may be scanned or use embedded font encodings)"
+		}
+		return "", text, "pdf"
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var jsonData any
+		if err := json.Unmarshal(body, &jsonData); err == nil {
+			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
+			return "", string(formatted), "json"
+		}
+		return "", decodeText(body, contentType), "raw"
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || looksLikeHTML(body):
+		title, text = htmlToMarkdown(decodeText(body, contentType), base)
+		return title, text, "markdown"
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/xml" || mediaType == "application/javascript":
+		return "", decodeText(body, contentType), "text"
+	}
+	return "", mediaType, "binary"
+}
 
-	result = reWhitespace.ReplaceAllString(result, " ")
-	result = reBlankLines.ReplaceAllString(result, "\n\n")
+func looksLikeHTML(body []byte) bool {
+	head := strings.ToLower(strings.TrimSpace(string(body[:min(len(body), 512)])))
+	return strings.HasPrefix(head, "<!doctype html") || strings.HasPrefix(head, "<html")
+}
 
-	lines := strings.Split(result, "\n")
-	var cleanLines []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			cleanLines = append(cleanLines, line)
-		}
+// decodeText converts body to UTF-8 using the charset from the content type,
+// a byte order mark or an HTML meta tag, falling back to windows-1252 for
+// bodies that are not valid UTF-8.
+func decodeText(body []byte, contentType string) string {
+	enc, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		return strings.ToValidUTF8(string(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))), "\uFFFD")
 	}
-
-	return strings.Join(cleanLines, "\n")
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return strings.ToValidUTF8(string(body), "\uFFFD")
+	}
+	return string(decoded)
 }
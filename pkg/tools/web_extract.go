@@ -0,0 +1,466 @@
+package tools
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minContentChars is the least text a candidate main-content element must
+// hold; below it the whole body is used.
+const minContentChars = 200
+
+var (
+	reSpaces        = regexp.MustCompile(`\s+`)
+	reListBlankRuns = regexp.MustCompile(`\n{2,}`)
+
+	// reBoilerplate matches class and id values of page chrome.
+	reBoilerplate = regexp.MustCompile(`(?i)(^|[\s_-])(comments?|footer|sidebar|nav|navbar|navigation|menu|share|` +
+		`social|related|promo|ads?|advert|advertisement|cookie|banner|popup|modal|newsletter|breadcrumbs?)($|[\s_-])`)
+	// reContentHint matches class and id values of article bodies.
+	reContentHint = regexp.MustCompile(`(?i)article|content|main|post|entry|story|text`)
+	reCodeLang    = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+)
+
+// droppedTags never contribute readable content.
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Svg: true, atom.Canvas: true, atom.Object: true, atom.Embed: true,
+	atom.Form: true, atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true,
+	atom.Nav: true, atom.Aside: true, atom.Footer: true,
+}
+
+// htmlToMarkdown extracts the main content of an HTML document as Markdown.
+// Relative links are resolved against base when it is set.
+func htmlToMarkdown(htmlContent string, base *url.URL) (title, markdown string) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", ""
+	}
+	title = documentTitle(doc)
+	prune(doc, false)
+
+	c := &mdConverter{base: base}
+	markdown = c.children(mainContent(doc), 0)
+	return title, tidyMarkdown(markdown)
+}
+
+// documentTitle returns the <title> of doc, or its og:title.
+func documentTitle(doc *html.Node) string {
+	var title, ogTitle string
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = collapse(textContent(n))
+			}
+		case atom.Meta:
+			if attr(n, "property") == "og:title" && ogTitle == "" {
+				ogTitle = collapse(attr(n, "content"))
+			}
+		case atom.Body:
+			return false
+		}
+		return true
+	})
+	if title == "" {
+		return ogTitle
+	}
+	return title
+}
+
+// prune removes scripts, forms, navigation and other page chrome from the
+// tree. Page headers are kept inside articles, where they hold the headline.
+func prune(n *html.Node, inArticle bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			if isChrome(c, inArticle) {
+				n.RemoveChild(c)
+			} else {
+				prune(c, inArticle || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+			}
+		}
+		c = next
+	}
+}
+
+func isChrome(n *html.Node, inArticle bool) bool {
+	if droppedTags[n.DataAtom] || (n.DataAtom == atom.Header && !inArticle) {
+		return true
+	}
+	if _, hidden := attrOK(n, "hidden"); hidden || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Html {
+		return false
+	}
+	hints := attr(n, "class") + " " + attr(n, "id")
+	return reBoilerplate.MatchString(hints) && !reContentHint.MatchString(hints)
+}
+
+// mainContent picks the element holding the page's main text: the largest
+// article or main element, else the block whose paragraphs score highest,
+// else the body.
+func mainContent(doc *html.Node) *html.Node {
+	body := doc
+	walk(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.Body {
+			body = n
+			return false
+		}
+		return true
+	})
+
+	var best *html.Node
+	bestLen := 0
+	walk(body, func(n *html.Node) bool {
+		if n.DataAtom == atom.Article || n.DataAtom == atom.Main || attr(n, "role") == "main" {
+			if l := textLen(n); l > bestLen {
+				best, bestLen = n, l
+			}
+		}
+		return true
+	})
+	if bestLen >= minContentChars {
+		return best
+	}
+
+	scores := make(map[*html.Node]float64)
+	walk(body, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		default:
+			return true
+		}
+		text := collapse(textContent(n))
+		if len(text) < 25 || n.Parent == nil {
+			return false
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		scores[n.Parent] += score
+		if gp := n.Parent.Parent; gp != nil {
+			scores[gp] += score / 2
+		}
+		return false
+	})
+
+	best = nil
+	var bestScore float64
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil || textLen(best) < minContentChars {
+		return body
+	}
+	return best
+}
+
+// mdConverter renders an HTML tree as Markdown.
+type mdConverter struct {
+	base *url.URL
+}
+
+func (c *mdConverter) children(n *html.Node, depth int) string {
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(c.node(ch, depth))
+	}
+	return sb.String()
+}
+
+// node renders n. depth is the list nesting level.
+func (c *mdConverter) node(n *html.Node, depth int) string {
+	switch n.Type {
+	case html.TextNode:
+		return reSpaces.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return c.children(n, depth)
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := collapse(c.children(n, depth))
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+	case atom.Br:
+		return "\n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.A:
+		return c.link(n, depth)
+	case atom.Img:
+		alt := collapse(attr(n, "alt"))
+		src := c.resolve(attr(n, "src"))
+		if alt == "" || src == "" || strings.HasPrefix(src, "data:") {
+			return ""
+		}
+		return "![" + alt + "](" + src + ")"
+	case atom.Strong, atom.B:
+		return wrapInline(c.children(n, depth), "**")
+	case atom.Em, atom.I:
+		return wrapInline(c.children(n, depth), "*")
+	case atom.Del, atom.S:
+		return wrapInline(c.children(n, depth), "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		return wrapInline(reSpaces.ReplaceAllString(textContent(n), " "), "`")
+	case atom.Pre:
+		return c.pre(n)
+	case atom.Ul, atom.Ol:
+		return c.list(n, depth)
+	case atom.Blockquote:
+		inner := tidyMarkdown(c.children(n, depth))
+		if inner == "" {
+			return ""
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case atom.Table:
+		return c.table(n, depth)
+	case atom.Dt:
+		return "\n\n**" + collapse(c.children(n, depth)) + "**\n"
+	case atom.Dd:
+		return ": " + collapse(c.children(n, depth)) + "\n"
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Figure, atom.Figcaption,
+		atom.Address, atom.Details, atom.Summary, atom.Dl, atom.Li, atom.Tr:
+		return "\n\n" + c.children(n, depth) + "\n\n"
+	}
+	return c.children(n, depth)
+}
+
+func (c *mdConverter) link(n *html.Node, depth int) string {
+	text := collapse(c.children(n, depth))
+	href := attr(n, "href")
+	if text == "" || href == "" || strings.HasPrefix(href, "#") {
+		return text
+	}
+	href = c.resolve(href)
+	if href == "" {
+		return text
+	}
+	return "[" + text + "](" + href + ")"
+}
+
+// resolve makes ref absolute and drops script and data URLs.
+func (c *mdConverter) resolve(ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme == "javascript" {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	return u.String()
+}
+
+func (c *mdConverter) pre(n *html.Node) string {
+	lang := reCodeLang.FindStringSubmatch(attr(n, "class"))
+	if lang == nil {
+		for ch := n.FirstChild; ch != nil && lang == nil; ch = ch.NextSibling {
+			if ch.DataAtom == atom.Code {
+				lang = reCodeLang.FindStringSubmatch(attr(ch, "class"))
+			}
+		}
+	}
+	fence := "```"
+	if lang != nil {
+		fence += lang[1]
+	}
+	code := strings.Trim(textContent(n), "\n")
+	return "\n\n" + fence + "\n" + code + "\n```\n\n"
+}
+
+func (c *mdConverter) list(n *html.Node, depth int) string {
+	num := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = start
+	}
+	indent := strings.Repeat("  ", depth)
+
+	var sb strings.Builder
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		body := tidyMarkdown(c.children(li, depth+1))
+		body = reListBlankRuns.ReplaceAllString(body, "\n")
+		// Continuation lines line up with the item text; nested lists
+		// carry their own indentation.
+		pad := strings.Repeat(" ", len(indent+marker))
+		lines := strings.Split(body, "\n")
+		for i := 1; i < len(lines); i++ {
+			if !strings.HasPrefix(lines[i], " ") {
+				lines[i] = pad + lines[i]
+			}
+		}
+		sb.WriteString(indent + marker + strings.Join(lines, "\n") + "\n")
+	}
+	if depth > 0 {
+		return "\n" + sb.String()
+	}
+	return "\n\n" + sb.String() + "\n\n"
+}
+
+// table renders data tables as Markdown tables; layout tables with a single
+// column are rendered as blocks.
+func (c *mdConverter) table(n *html.Node, depth int) string {
+	var rows [][]string
+	cols := 0
+	nested := false
+	walk(n, func(el *html.Node) bool {
+		if el != n && el.DataAtom == atom.Table {
+			nested = true
+			return false
+		}
+		if el.DataAtom != atom.Tr {
+			return true
+		}
+		var row []string
+		for cell := el.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+				text := collapse(c.children(cell, depth))
+				row = append(row, strings.ReplaceAll(text, "|", `\|`))
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+			cols = max(cols, len(row))
+		}
+		return false
+	})
+	if nested || cols < 2 {
+		return "\n\n" + c.children(n, depth) + "\n\n"
+	}
+
+	var sb strings.Builder
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return "\n\n" + sb.String() + "\n\n"
+}
+
+// tidyMarkdown trims stray spaces and collapses runs of blank lines outside
+// code fences.
+func tidyMarkdown(s string) string {
+	var out []string
+	inFence := false
+	blank := 0
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		} else if inFence {
+			out = append(out, line)
+			continue
+		}
+		line = strings.TrimRight(line, " \t")
+		if strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "  ") {
+			line = line[1:]
+		}
+		if line == "" {
+			if blank++; blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// wrapInline surrounds s with mark, keeping the outer spaces outside.
+func wrapInline(s, mark string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+	return lead + mark + trimmed + mark + trail
+}
+
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && !fn(c) {
+			continue
+		}
+		walk(c, fn)
+	}
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+func textLen(n *html.Node) int {
+	return len(collapse(textContent(n)))
+}
+
+// linkDensity is the share of n's text inside links.
+func linkDensity(n *html.Node) float64 {
+	total := textLen(n)
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(el *html.Node) bool {
+		if el.DataAtom == atom.A {
+			linked += textLen(el)
+			return false
+		}
+		return true
+	})
+	return float64(linked) / float64(total)
+}
+
+func collapse(s string) string {
+	return strings.TrimSpace(reSpaces.ReplaceAllString(s, " "))
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOK(n, key)
+	return v
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
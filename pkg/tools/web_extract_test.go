@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html><head><title>Release notes</title></head>
+<body>
+<header class="site-header"><a href="/">Home</a> <a href="/blog">Blog</a></header>
+<nav><ul><li><a href="/a">Nav A</a></li><li><a href="/b">Nav B</a></li></ul></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, get offers, deals, and more, every week.</p></div>
+<article>
+  <h1>Version 2.0</h1>
+  <p>This release adds <strong>streaming</strong>, see the <a href="/docs/streaming">streaming guide</a>
+     and the <a href="#changes">list below</a>, which covers everything in detail, one change at a time.</p>
+  <h2 id="changes">Changes</h2>
+  <ul>
+    <li>Faster startup
+      <ul><li>Lazy config loading</li></ul>
+    </li>
+    <li>New <code>--stream</code> flag</li>
+  </ul>
+  <ol start="3"><li>Third</li><li>Fourth</li></ol>
+  <table>
+    <tr><th>Option</th><th>Default</th></tr>
+    <tr><td>timeout</td><td>30 | 60</td></tr>
+  </table>
+  <pre><code class="language-go">func main() {
+	run()
+}</code></pre>
+  <blockquote><p>Quoted text</p></blockquote>
+  <script>trackPageView()</script>
+</article>
+<footer><p>Copyright 2026, all rights reserved, terms, privacy, cookies.</p></footer>
+</body></html>`
+
+func TestHTMLToMarkdown_Article(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/v2")
+	title, md := htmlToMarkdown(articlePage, base)
+
+	if title != "Release notes" {
+		t.Errorf("title = %q", title)
+	}
+	for _, want := range []string{
+		"# Version 2.0",
+		"## Changes",
+		"**streaming**",
+		"[streaming guide](https://example.com/docs/streaming)",
+		"and the list below,",
+		"- Faster startup\n  - Lazy config loading\n- New `--stream` flag",
+		"3. Third\n4. Fourth",
+		"| Option | Default |\n| --- | --- |\n| timeout | 30 \\| 60 |",
+		"```go\nfunc main() {\n\trun()\n}\n```",
+		"> Quoted text",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	for _, unwanted := range []string{"Nav A", "Home", "newsletter", "Copyright", "trackPageView"} {
+		if strings.Contains(md, unwanted) {
+			t.Errorf("markdown contains page chrome %q:\n%s", unwanted, md)
+		}
+	}
+}
+
+func TestHTMLToMarkdown_ScoresContentWithoutArticle(t *testing.T) {
+	para := "<p>" + strings.Repeat("The main story goes on, and on, with plenty of words. ", 5) + "</p>"
+	page := `<html><body>
+<div class="links"><p><a href="/1">A long list of links that is not the story at all</a></p></div>
+<div class="story">` + para + para + `</div>
+</body></html>`
+
+	_, md := htmlToMarkdown(page, nil)
+	if !strings.Contains(md, "The main story") || strings.Contains(md, "list of links") {
+		t.Errorf("markdown = %q, want only the story block", md)
+	}
+}
+
+func TestExtractContent_Charset(t *testing.T) {
+	latin1 := []byte("<html><head><meta charset=\"iso-8859-1\"></head><body><p>Caf\xe9 cr\xe8me</p></body></html>")
+	if _, text, _ := extractContent(latin1, "text/html", nil); !strings.Contains(text, "Café crème") {
+		t.Errorf("meta charset: text = %q", text)
+	}
+
+	plain := []byte("na\xefve")
+	if _, text, ext := extractContent(plain, "text/plain; charset=windows-1252", nil); text != "naïve" || ext != "text" {
+		t.Errorf("header charset: text = %q (%s)", text, ext)
+	}
+
+	if _, text, ext := extractContent([]byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a}, "", nil); ext != "binary" ||
+		text != "image/png" {
+		t.Errorf("binary: %q (%s)", text, ext)
+	}
+}
+
+func TestWebTool_WebFetch_Offset(t *testing.T) {
+	content := strings.Repeat("a", 150) + strings.Repeat("b", 150)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	tool := newLocalWebFetchTool(t, 150)
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL, "offset": 150.0})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	header, page, _ := strings.Cut(result.ForLLM, "\n\n")
+	if page != strings.Repeat("b", 150) {
+		t.Errorf("page = %q, want the second half", page)
+	}
+	if !strings.Contains(header, "Showing characters 150-300 of 300.") || strings.Contains(header, "offset=") {
+		t.Errorf("header = %q", header)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL, "offset": 400.0})
+	if !result.IsError || !strings.Contains(result.ForLLM, "past the end") {
+		t.Errorf("offset past end: %+v", result)
+	}
+}
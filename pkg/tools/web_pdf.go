@@ -0,0 +1,363 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamBytes caps the inflated size of a single PDF content stream.
+const maxPDFStreamBytes = 8 << 20
+
+// extractPDFText pulls the text out of the content streams of a PDF. It
+// handles uncompressed and Flate-compressed streams and the common text
+// operators, but not fonts with custom encodings, so it is best effort and
+// returns "" when nothing readable is found.
+func extractPDFText(data []byte) string {
+	var sb strings.Builder
+	rest := data
+	for {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		dict := rest[:start]
+		if i := bytes.LastIndex(dict, []byte(" obj")); i >= 0 {
+			dict = dict[i:]
+		}
+		body := rest[start+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+		if bytes.HasSuffix(dict, []byte("end")) { // "endstream" matched as "stream"
+			continue
+		}
+
+		content, ok := decodePDFStream(dict, body[:end])
+		if !ok || !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+		if text := pdfContentText(content); text != "" {
+			sb.WriteString(text)
+			sb.WriteString("\n\n")
+		}
+	}
+	return tidyMarkdown(sb.String())
+}
+
+// decodePDFStream returns the decoded bytes of a stream, or false for
+// streams that cannot hold page text (images, fonts, unsupported filters).
+func decodePDFStream(dict, raw []byte) ([]byte, bool) {
+	for _, skip := range []string{"/Image", "/Length1", "/FontFile", "/XRef", "/Metadata"} {
+		if bytes.Contains(dict, []byte(skip)) {
+			return nil, false
+		}
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return raw, true
+	}
+	if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Contains(dict, []byte("/DecodeParms")) {
+		return nil, false
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	// Truncated streams still yield the text decoded so far.
+	out, _ := io.ReadAll(io.LimitReader(zr, maxPDFStreamBytes))
+	return out, len(out) > 0
+}
+
+// pdfContentText interprets the text operators of a page content stream.
+func pdfContentText(content []byte) string {
+	var sb strings.Builder
+	var operands []pdfToken
+	var lastY string
+	lex := &pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok.text {
+		case "Tj":
+			writeOperandText(&sb, operands)
+		case "'", `"`:
+			sb.WriteString("\n")
+			writeOperandText(&sb, operands)
+		case "TJ":
+			for _, op := range operands {
+				for _, el := range op.array {
+					if el.kind == pdfString {
+						sb.WriteString(el.text)
+					} else if n, err := strconv.ParseFloat(el.text, 64); err == nil && n < -200 {
+						sb.WriteString(" ")
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) == 2 && operands[1].text != "0" {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString(" ")
+			}
+		case "Tm":
+			// Only a change of baseline starts a new line.
+			if len(operands) == 6 && operands[5].text != lastY {
+				lastY = operands[5].text
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString(" ")
+			}
+		case "T*", "ET":
+			sb.WriteString("\n")
+		}
+		operands = operands[:0]
+	}
+	return sb.String()
+}
+
+func writeOperandText(sb *strings.Builder, operands []pdfToken) {
+	if n := len(operands); n > 0 && operands[n-1].kind == pdfString {
+		sb.WriteString(operands[n-1].text)
+	}
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperand pdfTokenKind = iota
+	pdfString
+	pdfArray
+	pdfOperator
+)
+
+type pdfToken struct {
+	kind  pdfTokenKind
+	text  string
+	array []pdfToken
+}
+
+// pdfLexer splits a content stream into operands and operators.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return pdfToken{}, false
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return pdfToken{kind: pdfString, text: decodePDFString(l.literal())}, true
+	case c == '<' && l.peek(1) == '<':
+		l.skipDict()
+		return pdfToken{kind: pdfOperand}, true
+	case c == '<':
+		return pdfToken{kind: pdfString, text: decodePDFString(l.hex())}, true
+	case c == '[':
+		l.pos++
+		var arr []pdfToken
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				break
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				break
+			}
+			tok, ok := l.next()
+			if !ok {
+				break
+			}
+			arr = append(arr, tok)
+		}
+		return pdfToken{kind: pdfArray, array: arr}, true
+	case c == '/' || c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		start := l.pos
+		l.pos++
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfToken{kind: pdfOperand, text: string(l.data[start:l.pos])}, true
+	case isPDFDelimiter(c):
+		l.pos++
+		return pdfToken{kind: pdfOperand}, true
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return pdfToken{kind: pdfOperator, text: string(l.data[start:l.pos])}, true
+}
+
+func (l *pdfLexer) peek(off int) byte {
+	if l.pos+off < len(l.data) {
+		return l.data[l.pos+off]
+	}
+	return 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch l.data[l.pos] {
+		case ' ', '\t', '\r', '\n', '\f', 0:
+			l.pos++
+		case '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *pdfLexer) skipDict() {
+	depth := 0
+	for l.pos < len(l.data) {
+		switch {
+		case l.data[l.pos] == '<' && l.peek(1) == '<':
+			depth++
+			l.pos += 2
+		case l.data[l.pos] == '>' && l.peek(1) == '>':
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			l.pos++
+		}
+	}
+}
+
+// literal reads a (string) with escapes and balanced parentheses.
+func (l *pdfLexer) literal() []byte {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n': // line continuation
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth--; depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// hex reads a <hex string>.
+func (l *pdfLexer) hex() []byte {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; isHexDigit(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// decodePDFString converts a PDF string to UTF-8. UTF-16BE strings carry a
+// byte order mark; others are read as Latin-1, which matches PDFDocEncoding
+// for printable text. Strings of glyph IDs from embedded fonts come out as
+// control characters and are dropped.
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	control := 0
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' {
+			control++
+		}
+		runes[i] = rune(c)
+	}
+	if control*2 > len(b) {
+		return ""
+	}
+	return string(runes)
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF wraps content streams in a minimal PDF skeleton.
+func buildPDF(streams ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	for i, s := range streams {
+		var zb bytes.Buffer
+		zw := zlib.NewWriter(&zb)
+		zw.Write([]byte(s))
+		zw.Close()
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", i+3, zb.Len())
+		b.Write(zb.Bytes())
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("4 0 obj\n<< /Length 10 /Subtype /Image >>\nstream\nBT (nope) Tj\nendstream\nendobj\n")
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractPDFText(t *testing.T) {
+	pdf := buildPDF(
+		"BT /F1 12 Tf 72 720 Td (Hello, \\(PDF\\) world) Tj 0 -14 Td [(Kerned) -250 (words)] TJ ET",
+		"BT /F1 12 Tf 1 0 0 1 72 700 Tm <FEFF00500061006700650020003200> Tj T* (caf\\351) Tj ET",
+	)
+
+	text := extractPDFText(pdf)
+	for _, want := range []string{"Hello, (PDF) world\nKerned words", "Page 2\ncafé"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "nope") {
+		t.Errorf("image stream was read as text: %q", text)
+	}
+
+	if _, text, ext := extractContent(pdf, "application/pdf", nil); ext != "pdf" || !strings.Contains(text, "Hello") {
+		t.Errorf("extractContent = %q (%s)", text, ext)
+	}
+}
+
+func TestExtractPDFText_NoText(t *testing.T) {
+	if text := extractPDFText(buildPDF()); text != "" {
+		t.Errorf("text = %q, want empty", text)
+	}
+}
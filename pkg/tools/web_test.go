@@ -8,8 +8,23 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// newLocalWebFetchTool returns a web_fetch tool allowed to reach httptest
+// servers on loopback.
+func newLocalWebFetchTool(t *testing.T, maxChars int) *WebFetchTool {
+	t.Helper()
+	egress, err := utils.NewEgressPolicy(utils.EgressOptions{Allow: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("NewEgressPolicy: %v", err)
+	}
+	tool := NewWebFetchTool(maxChars)
+	tool.egress = egress
+	return tool
+}
+
 // TestWebTool_WebFetch_Success verifies successful URL fetching
 func TestWebTool_WebFetch_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,7 +34,7 @@ func TestWebTool_WebFetch_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLocalWebFetchTool(t, 50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -32,15 +47,57 @@ func TestWebTool_WebFetch_Success(t *testing.T) {
 		t.Errorf("Expected success, got IsError=true: %s", result.ForLLM)
 	}
 
-	// ForUser should contain the fetched content
-	if !strings.Contains(result.ForUser, "Test Page") {
-		t.Errorf("Expected ForUser to contain 'Test Page', got: %s", result.ForUser)
+	// ForLLM should contain the fetched content as Markdown
+	if !strings.Contains(result.ForLLM, "# Test Page") {
+		t.Errorf("Expected ForLLM to contain '# Test Page', got: %s", result.ForLLM)
 	}
 
-	// ForLLM should contain summary
-	if !strings.Contains(result.ForLLM, "bytes") && !strings.Contains(result.ForLLM, "extractor") {
+	// ForLLM should start with a header describing the fetch
+	if !strings.Contains(result.ForLLM, "Extractor: markdown") {
 		t.Errorf("Expected ForLLM to contain summary, got: %s", result.ForLLM)
 	}
+
+	// The page goes to the model, not the chat
+	if !result.Silent {
+		t.Errorf("Expected silent result")
+	}
+}
+
+func TestWebTool_WebFetch_BlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal admin panel"))
+	}))
+	defer server.Close()
+
+	tool := NewWebFetchTool(50000)
+	for _, u := range []string{server.URL, "http://169.254.169.254/latest/meta-data/"} {
+		result := tool.Execute(context.Background(), map[string]any{"url": u})
+		if !result.IsError || !strings.Contains(result.ForLLM, "egress blocked") {
+			t.Errorf("fetch %s: got %+v, want blocked", u, result)
+		}
+	}
+}
+
+func TestWebTool_WebFetch_ResponseSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 4096)))
+	}))
+	defer server.Close()
+
+	tool := newLocalWebFetchTool(t, 50000)
+	tool.egress, _ = utils.NewEgressPolicy(utils.EgressOptions{
+		Allow:            []string{"127.0.0.1"},
+		MaxResponseBytes: 1024,
+	})
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	header, page, _ := strings.Cut(result.ForLLM, "\n\n")
+	if len(page) != 1024 || !strings.Contains(header, "exceeded 1024 bytes") {
+		t.Errorf("ForLLM = %q, want body capped at 1024 bytes", result.ForLLM)
+	}
 }
 
 // TestWebTool_WebFetch_JSON verifies JSON content handling
@@ -55,7 +112,7 @@ func TestWebTool_WebFetch_JSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLocalWebFetchTool(t, 50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -68,9 +125,9 @@ func TestWebTool_WebFetch_JSON(t *testing.T) {
 		t.Errorf("Expected success, got IsError=true: %s", result.ForLLM)
 	}
 
-	// ForUser should contain formatted JSON
-	if !strings.Contains(result.ForUser, "key") && !strings.Contains(result.ForUser, "value") {
-		t.Errorf("Expected ForUser to contain JSON data, got: %s", result.ForUser)
+	// ForLLM should contain formatted JSON
+	if !strings.Contains(result.ForLLM, string(expectedJSON)) {
+		t.Errorf("Expected ForLLM to contain JSON data, got: %s", result.ForLLM)
 	}
 }
 
@@ -146,7 +203,7 @@ func TestWebTool_WebFetch_Truncation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(1000) // Limit to 1000 chars
+	tool := newLocalWebFetchTool(t, 1000) // Limit to 1000 chars
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -159,18 +216,14 @@ func TestWebTool_WebFetch_Truncation(t *testing.T) {
 		t.Errorf("Expected success, got IsError=true: %s", result.ForLLM)
 	}
 
-	// ForUser should contain truncated content (not the full 20000 chars)
-	resultMap := make(map[string]any)
-	json.Unmarshal([]byte(result.ForUser), &resultMap)
-	if text, ok := resultMap["text"].(string); ok {
-		if len(text) > 1100 { // Allow some margin
-			t.Errorf("Expected content to be truncated to ~1000 chars, got: %d", len(text))
-		}
+	// ForLLM should contain one page of content (not the full 20000 chars)
+	if _, page, _ := strings.Cut(result.ForLLM, "\n\n"); len(page) != 1000 {
+		t.Errorf("Expected content to be truncated to 1000 chars, got: %d", len(page))
 	}
 
-	// Should be marked as truncated
-	if truncated, ok := resultMap["truncated"].(bool); !ok || !truncated {
-		t.Errorf("Expected 'truncated' to be true in result")
+	// Should point at the next page
+	if !strings.Contains(result.ForLLM, "offset=1000") {
+		t.Errorf("Expected a hint to continue at offset=1000, got: %s", result.ForLLM)
 	}
 }
 
@@ -215,7 +268,7 @@ func TestWebTool_WebFetch_HTMLExtraction(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLocalWebFetchTool(t, 50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -228,21 +281,19 @@ func TestWebTool_WebFetch_HTMLExtraction(t *testing.T) {
 		t.Errorf("Expected success, got IsError=true: %s", result.ForLLM)
 	}
 
-	// ForUser should contain extracted text (without script/style tags)
-	if !strings.Contains(result.ForUser, "Title") && !strings.Contains(result.ForUser, "Content") {
-		t.Errorf("Expected ForUser to contain extracted text, got: %s", result.ForUser)
+	// ForLLM should contain extracted text (without script/style tags)
+	if !strings.Contains(result.ForLLM, "Title") && !strings.Contains(result.ForLLM, "Content") {
+		t.Errorf("Expected ForLLM to contain extracted text, got: %s", result.ForLLM)
 	}
 
 	// Should NOT contain script or style tags
-	if strings.Contains(result.ForUser, "<script>") || strings.Contains(result.ForUser, "<style>") {
-		t.Errorf("Expected script/style tags to be removed, got: %s", result.ForUser)
+	if strings.Contains(result.ForLLM, "alert") || strings.Contains(result.ForLLM, "color:red") {
+		t.Errorf("Expected script/style content to be removed, got: %s", result.ForLLM)
 	}
 }
 
-// TestWebFetchTool_extractText verifies text extraction preserves newlines
-func TestWebFetchTool_extractText(t *testing.T) {
-	tool := &WebFetchTool{}
-
+// TestHTMLToMarkdown_Text verifies text extraction preserves newlines
+func TestHTMLToMarkdown_Text(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -308,7 +359,7 @@ func TestWebFetchTool_extractText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tool.extractText(tt.input)
+			_, got := htmlToMarkdown(tt.input, nil)
 			tt.wantFunc(t, got)
 		})
 	}
@@ -0,0 +1,59 @@
+package triggers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+// HandleDeviceEvent fires the USB rules matching ev. Register it with
+// devices.Service.AddListener.
+func (s *Service) HandleDeviceEvent(ev *events.DeviceEvent) {
+	if ev == nil || ev.Kind != events.KindUSB {
+		return
+	}
+	fields := map[string]any{
+		"action":       string(ev.Action),
+		"vendor":       ev.Vendor,
+		"product":      ev.Product,
+		"serial":       ev.Serial,
+		"device":       ev.DeviceID,
+		"capabilities": ev.Capabilities,
+		"message":      ev.FormatMessage(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := range s.store.Rules {
+		rule := &s.store.Rules[i]
+		if rule.Source.Kind == SourceUSB && matchUSB(rule.Source, ev) {
+			s.emitLocked(rule, Event{Kind: SourceUSB, Time: now, Fields: fields})
+		}
+	}
+}
+
+// matchUSB reports whether ev matches the device filters of a USB source.
+// Vendor and product match, case-insensitively, part of the device's name
+// or its hex ID.
+func matchUSB(src Source, ev *events.DeviceEvent) bool {
+	if src.Action != "" && src.Action != string(ev.Action) {
+		return false
+	}
+	return matchDevice(src.Vendor, ev.Vendor, ev.Raw["ID_VENDOR_ID"]) &&
+		matchDevice(src.Product, ev.Product, ev.Raw["ID_MODEL_ID"])
+}
+
+func matchDevice(want string, values ...string) bool {
+	want = strings.ToLower(strings.TrimSpace(want))
+	if want == "" {
+		return true
+	}
+	for _, v := range values {
+		if v != "" && strings.Contains(strings.ToLower(v), want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+// Package triggers runs agent prompts or direct messages when events happen:
+// a workspace file changes, an HTTP POST reaches a webhook, a USB device is
+// plugged in or out, or a cron expression ticks. Rules are persisted in
+// workspace/triggers/rules.json.
+package triggers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+// Source kinds.
+const (
+	SourceFile    = "file"
+	SourceWebhook = "webhook"
+	SourceUSB     = "usb"
+	SourceCron    = "cron"
+)
+
+// Action kinds.
+const (
+	ActionAgent   = "agent"   // run the message as an agent prompt
+	ActionDeliver = "deliver" // send the message to the channel as is
+)
+
+// Source is the event source a rule listens to.
+type Source struct {
+	Kind string `json:"kind"`
+	// Path is the watched file, directory or glob pattern of a file source,
+	// relative to the workspace unless absolute.
+	Path string `json:"path,omitempty"`
+	// Token authenticates webhook requests (Authorization: Bearer <token>).
+	Token string `json:"token,omitempty"`
+	// Vendor and Product match a USB device by name or hex ID; empty
+	// matches any. Action is "add", "remove" or empty for both.
+	Vendor  string `json:"vendor,omitempty"`
+	Product string `json:"product,omitempty"`
+	Action  string `json:"action,omitempty"`
+	// Expr is the cron expression of a cron source.
+	Expr string `json:"expr,omitempty"`
+}
+
+// Action is what a rule does when it fires.
+type Action struct {
+	Kind string `json:"kind"`
+	// Template is a text/template rendered with the event fields (see
+	// Event) into the message; empty uses a default per source kind.
+	Template string `json:"template,omitempty"`
+	AgentID  string `json:"agentId,omitempty"` // agent to prompt; empty routes by channel
+	Channel  string `json:"channel,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// RuleState records the last time a rule fired.
+type RuleState struct {
+	LastFiredAtMS *int64 `json:"lastFiredAtMs,omitempty"`
+	LastStatus    string `json:"lastStatus,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	FireCount     int    `json:"fireCount,omitempty"`
+}
+
+// Rule binds an event source to an action.
+type Rule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Source  Source `json:"source"`
+	Action  Action `json:"action"`
+	// DebounceMS coalesces events: the rule fires once events stop arriving
+	// for this long, with the last event. 0 fires on every event.
+	DebounceMS  int64     `json:"debounceMs,omitempty"`
+	State       RuleState `json:"state"`
+	CreatedAtMS int64     `json:"createdAtMs"`
+	UpdatedAtMS int64     `json:"updatedAtMs"`
+}
+
+// defaultTemplates are used for rules without a template.
+var defaultTemplates = map[string]string{
+	SourceFile:    "File {{.path}} was {{.op}}",
+	SourceWebhook: "Webhook {{.rule}} received:\n{{.body}}",
+	SourceUSB:     "{{.message}}",
+	SourceCron:    "Trigger {{.rule}} fired at {{.time}}",
+}
+
+// Validate checks that the rule is complete and its template and cron
+// expression parse.
+func (r *Rule) Validate() error {
+	var errs error
+	if strings.TrimSpace(r.Name) == "" {
+		errs = errors.Join(errs, errors.New("name is required"))
+	}
+
+	switch r.Source.Kind {
+	case SourceFile:
+		if strings.TrimSpace(r.Source.Path) == "" {
+			errs = errors.Join(errs, errors.New("file source needs a path"))
+		}
+	case SourceWebhook, SourceUSB:
+	case SourceCron:
+		if !gronx.New().IsValid(r.Source.Expr) {
+			errs = errors.Join(errs, fmt.Errorf("invalid cron expression %q", r.Source.Expr))
+		}
+	default:
+		errs = errors.Join(errs, fmt.Errorf("unknown source kind %q", r.Source.Kind))
+	}
+	if a := r.Source.Action; r.Source.Kind == SourceUSB && a != "" && a != "add" && a != "remove" {
+		errs = errors.Join(errs, fmt.Errorf("usb action must be add or remove, not %q", a))
+	}
+
+	switch r.Action.Kind {
+	case ActionAgent, ActionDeliver:
+	default:
+		errs = errors.Join(errs, fmt.Errorf("unknown action kind %q", r.Action.Kind))
+	}
+	if _, err := r.template(); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("invalid template: %w", err))
+	}
+	if r.DebounceMS < 0 {
+		errs = errors.Join(errs, errors.New("debounce must not be negative"))
+	}
+	return errs
+}
+
+func (r *Rule) template() (*template.Template, error) {
+	text := r.Action.Template
+	if text == "" {
+		text = defaultTemplates[r.Source.Kind]
+	}
+	return template.New(r.ID).Option("missingkey=zero").Parse(text)
+}
+
+// Render renders the rule's message for ev.
+func (r *Rule) Render(ev Event) (string, error) {
+	tmpl, err := r.template()
+	if err != nil {
+		return "", err
+	}
+	data := make(map[string]any, len(ev.Fields)+5)
+	for k, v := range ev.Fields {
+		data[k] = v
+	}
+	data["rule"] = r.Name
+	data["id"] = r.ID
+	data["kind"] = ev.Kind
+	data["time"] = ev.Time.Format(time.RFC3339)
+	data["count"] = ev.Count
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Describe summarizes the rule's source, e.g. "file notes/*.md".
+func (r *Rule) Describe() string {
+	s := r.Source
+	switch s.Kind {
+	case SourceFile:
+		return "file " + s.Path
+	case SourceWebhook:
+		return "webhook " + WebhookPath(r.ID)
+	case SourceUSB:
+		var parts []string
+		for _, p := range []string{s.Action, s.Vendor, s.Product} {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		if len(parts) == 0 {
+			return "usb any device"
+		}
+		return "usb " + strings.Join(parts, " ")
+	case SourceCron:
+		return "cron " + s.Expr
+	}
+	return s.Kind
+}
+
+// Event is something that happened at a source. Fields are available to
+// templates by name, along with rule, id, kind, time and count:
+//
+//   - file: path, op (created, modified or deleted)
+//   - webhook: body, json (the body parsed as JSON, if it is), query
+//   - usb: action, vendor, product, serial, device, capabilities, message
+type Event struct {
+	Kind   string
+	Time   time.Time
+	Fields map[string]any
+	// Count is the number of events coalesced into this one by debouncing.
+	Count int
+}
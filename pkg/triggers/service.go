@@ -0,0 +1,540 @@
+package triggers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Handler delivers the rendered message of a fired rule.
+type Handler func(rule *Rule, message string) error
+
+type Store struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Service keeps the trigger rules and, once started, watches their sources
+// and fires them. Rule changes made by other processes (e.g. the CLI) are
+// picked up when the store file changes.
+type Service struct {
+	storePath string
+	workspace string
+	store     *Store
+	onFire    Handler
+	mu        sync.RWMutex
+	running   bool
+	stopChan  chan struct{}
+
+	storeMtime time.Time                      // store file mtime when last loaded or saved
+	nextCron   map[string]time.Time           // rule ID -> next cron tick
+	files      map[string]map[string]fileStat // rule ID -> last file snapshot
+	pending    map[string]*pendingFire        // rule ID -> debounced event
+	fires      map[string][]Event             // rule ID -> events waiting to fire, present while firing
+}
+
+type fileStat struct {
+	mtime time.Time
+	size  int64
+}
+
+type pendingFire struct {
+	event Event
+	timer *time.Timer
+}
+
+// NewService creates a service over the store at storePath. Relative file
+// source paths are resolved against workspace.
+func NewService(storePath, workspace string, onFire Handler) *Service {
+	s := &Service{
+		storePath: storePath,
+		workspace: workspace,
+		onFire:    onFire,
+		nextCron:  make(map[string]time.Time),
+		files:     make(map[string]map[string]fileStat),
+		pending:   make(map[string]*pendingFire),
+		fires:     make(map[string][]Event),
+	}
+	if err := s.loadStore(); err != nil {
+		logger.WarnCF("triggers", "Failed to load trigger rules", map[string]any{"error": err.Error()})
+	}
+	return s
+}
+
+func (s *Service) SetOnFire(handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onFire = handler
+}
+
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+	if err := s.loadStore(); err != nil {
+		return fmt.Errorf("failed to load store: %w", err)
+	}
+
+	s.stopChan = make(chan struct{})
+	s.running = true
+	s.pollLocked(time.Now())
+	go s.runLoop(s.stopChan)
+	return nil
+}
+
+func (s *Service) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	s.stopChan = nil
+	for id, p := range s.pending {
+		p.timer.Stop()
+		delete(s.pending, id)
+	}
+}
+
+func (s *Service) runLoop(stopChan chan struct{}) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			if s.running {
+				s.reloadIfChangedLocked()
+				s.pollLocked(now)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// pollLocked fires due cron rules and checks watched files for changes. The
+// first poll of a file rule only records a baseline, and changes seen while
+// the rule is firing are taken as its own doing, e.g. an agent editing the
+// watched file, and only update the baseline. Must be called with s.mu held.
+func (s *Service) pollLocked(now time.Time) {
+	for i := range s.store.Rules {
+		rule := &s.store.Rules[i]
+		if !rule.Enabled {
+			continue
+		}
+		switch rule.Source.Kind {
+		case SourceCron:
+			next, ok := s.nextCron[rule.ID]
+			if ok && !now.Before(next) {
+				s.emitLocked(rule, Event{Kind: SourceCron, Time: now})
+			}
+			if !ok || !now.Before(next) {
+				if t, err := gronx.NextTickAfter(rule.Source.Expr, now, false); err == nil {
+					s.nextCron[rule.ID] = t
+				}
+			}
+		case SourceFile:
+			snapshot := s.snapshot(rule.Source.Path)
+			if prev, ok := s.files[rule.ID]; ok && !s.firingLocked(rule.ID) {
+				for _, ev := range diffSnapshots(prev, snapshot) {
+					ev.Time = now
+					ev.Fields["path"] = s.displayPath(ev.Fields["path"].(string))
+					s.emitLocked(rule, ev)
+				}
+			}
+			s.files[rule.ID] = snapshot
+		}
+	}
+}
+
+// snapshot stats the files matched by a file source path: a glob pattern,
+// the files directly inside a directory, or a single file.
+func (s *Service) snapshot(path string) map[string]fileStat {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.workspace, path)
+	}
+	var paths []string
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		entries, _ := os.ReadDir(path)
+		for _, e := range entries {
+			paths = append(paths, filepath.Join(path, e.Name()))
+		}
+	} else if matches, err := filepath.Glob(path); err == nil {
+		paths = matches
+	}
+
+	snapshot := make(map[string]fileStat, len(paths))
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			snapshot[p] = fileStat{mtime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return snapshot
+}
+
+func diffSnapshots(prev, cur map[string]fileStat) []Event {
+	var evs []Event
+	fileEvent := func(path, op string) Event {
+		return Event{Kind: SourceFile, Fields: map[string]any{"path": path, "op": op}}
+	}
+	for path, st := range cur {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			evs = append(evs, fileEvent(path, "created"))
+		case !old.mtime.Equal(st.mtime) || old.size != st.size:
+			evs = append(evs, fileEvent(path, "modified"))
+		}
+	}
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			evs = append(evs, fileEvent(path, "deleted"))
+		}
+	}
+	return evs
+}
+
+// displayPath makes paths inside the workspace relative to it.
+func (s *Service) displayPath(path string) string {
+	if rel, err := filepath.Rel(s.workspace, path); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// emitLocked fires rule for ev, or with a debounce, schedules it to fire
+// once no further events arrive for the debounce period. Must be called
+// with s.mu held.
+func (s *Service) emitLocked(rule *Rule, ev Event) {
+	if !s.running || !rule.Enabled {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	id := rule.ID
+	if rule.DebounceMS <= 0 {
+		ev.Count = 1
+		s.queueFireLocked(id, ev)
+		return
+	}
+
+	delay := time.Duration(rule.DebounceMS) * time.Millisecond
+	if p, ok := s.pending[id]; ok {
+		ev.Count = p.event.Count + 1
+		p.event = ev
+		p.timer.Reset(delay)
+		return
+	}
+	ev.Count = 1
+	p := &pendingFire{event: ev}
+	p.timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if cur, ok := s.pending[id]; ok && cur == p {
+			delete(s.pending, id)
+			if s.running {
+				s.queueFireLocked(id, p.event)
+			}
+		}
+	})
+	s.pending[id] = p
+}
+
+// queueFireLocked queues ev to fire rule id. The fires of one rule run one
+// at a time, in order, so that a slow handler never sees a rule overlap
+// itself. Must be called with s.mu held.
+func (s *Service) queueFireLocked(id string, ev Event) {
+	queue, firing := s.fires[id]
+	s.fires[id] = append(queue, ev)
+	if !firing {
+		go s.drainFires(id)
+	}
+}
+
+// firingLocked reports whether rule id has fires queued or running. Must be
+// called with s.mu held.
+func (s *Service) firingLocked(id string) bool {
+	_, firing := s.fires[id]
+	return firing
+}
+
+// drainFires fires the queued events of rule id until none are left. A file
+// rule's baseline is then taken again, so that what its last fire changed
+// does not fire it once more.
+func (s *Service) drainFires(id string) {
+	for {
+		s.mu.Lock()
+		queue := s.fires[id]
+		if len(queue) == 0 {
+			delete(s.fires, id)
+			if rule := s.findRuleUnsafe(id); rule != nil && rule.Source.Kind == SourceFile {
+				if _, ok := s.files[id]; ok {
+					s.files[id] = s.snapshot(rule.Source.Path)
+				}
+			}
+			s.mu.Unlock()
+			return
+		}
+		ev := queue[0]
+		s.fires[id] = queue[1:]
+		s.mu.Unlock()
+
+		s.fire(id, ev)
+	}
+}
+
+// fire renders the message of rule id for ev, passes it to the handler and
+// records the outcome.
+func (s *Service) fire(id string, ev Event) {
+	s.mu.RLock()
+	rule := s.findRuleUnsafe(id)
+	var ruleCopy Rule
+	if rule != nil {
+		ruleCopy = *rule
+	}
+	handler := s.onFire
+	s.mu.RUnlock()
+	if rule == nil {
+		return
+	}
+
+	message, err := ruleCopy.Render(ev)
+	if err == nil {
+		if handler == nil {
+			err = fmt.Errorf("no trigger handler")
+		} else {
+			err = handler(&ruleCopy, message)
+		}
+	}
+
+	fields := map[string]any{"id": id, "name": ruleCopy.Name, "source": ev.Kind}
+	if err != nil {
+		fields["error"] = err.Error()
+		logger.WarnCF("triggers", "Trigger failed", fields)
+	} else {
+		logger.InfoCF("triggers", "Trigger fired", fields)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule = s.findRuleUnsafe(id)
+	if rule == nil {
+		return
+	}
+	firedAt := ev.Time.UnixMilli()
+	rule.State.LastFiredAtMS = &firedAt
+	rule.State.FireCount++
+	if err != nil {
+		rule.State.LastStatus = "error"
+		rule.State.LastError = err.Error()
+	} else {
+		rule.State.LastStatus = "ok"
+		rule.State.LastError = ""
+	}
+	if err := s.saveStoreUnsafe(); err != nil {
+		logger.WarnCF("triggers", "Failed to save trigger rules", map[string]any{"error": err.Error()})
+	}
+}
+
+// AddRule validates rule and stores it, enabled, under a new ID. Webhook
+// rules without a token get a random one.
+func (s *Service) AddRule(rule Rule) (*Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	rule.ID = generateID(8)
+	rule.Enabled = true
+	rule.State = RuleState{}
+	rule.CreatedAtMS = now
+	rule.UpdatedAtMS = now
+	if rule.Action.Kind == "" {
+		rule.Action.Kind = ActionAgent
+	}
+	if rule.Source.Kind == SourceWebhook && rule.Source.Token == "" {
+		rule.Source.Token = generateID(16)
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.store.Rules = append(s.store.Rules, rule)
+	if err := s.saveStoreUnsafe(); err != nil {
+		s.store.Rules = s.store.Rules[:len(s.store.Rules)-1]
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *Service) RemoveRule(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := s.store.Rules[:0]
+	removed := false
+	for _, rule := range s.store.Rules {
+		if rule.ID == id {
+			removed = true
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	s.store.Rules = rules
+	if !removed {
+		return false
+	}
+	s.forgetRuntimeLocked(id)
+	if err := s.saveStoreUnsafe(); err != nil {
+		logger.WarnCF("triggers", "Failed to save trigger rules", map[string]any{"error": err.Error()})
+	}
+	return true
+}
+
+func (s *Service) EnableRule(id string, enabled bool) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule := s.findRuleUnsafe(id)
+	if rule == nil {
+		return nil
+	}
+	rule.Enabled = enabled
+	rule.UpdatedAtMS = time.Now().UnixMilli()
+	s.forgetRuntimeLocked(id)
+	if err := s.saveStoreUnsafe(); err != nil {
+		logger.WarnCF("triggers", "Failed to save trigger rules", map[string]any{"error": err.Error()})
+	}
+	ruleCopy := *rule
+	return &ruleCopy
+}
+
+func (s *Service) GetRule(id string) (Rule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if rule := s.findRuleUnsafe(id); rule != nil {
+		return *rule, true
+	}
+	return Rule{}, false
+}
+
+func (s *Service) ListRules() []Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Rule(nil), s.store.Rules...)
+}
+
+func (s *Service) findRuleUnsafe(id string) *Rule {
+	for i := range s.store.Rules {
+		if s.store.Rules[i].ID == id {
+			return &s.store.Rules[i]
+		}
+	}
+	return nil
+}
+
+// forgetRuntimeLocked drops the watch state of rule id, so it starts over
+// from a fresh baseline. Must be called with s.mu held.
+func (s *Service) forgetRuntimeLocked(id string) {
+	delete(s.nextCron, id)
+	delete(s.files, id)
+	if p, ok := s.pending[id]; ok {
+		p.timer.Stop()
+		delete(s.pending, id)
+	}
+}
+
+func (s *Service) loadStore() error {
+	s.store = &Store{
+		Version: 1,
+		Rules:   []Rule{},
+	}
+
+	info, err := os.Stat(s.storePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(s.storePath)
+	if err != nil {
+		return err
+	}
+	s.storeMtime = info.ModTime()
+	return json.Unmarshal(data, s.store)
+}
+
+// reloadIfChangedLocked reloads the store when another process changed the
+// store file. Must be called with s.mu held.
+func (s *Service) reloadIfChangedLocked() {
+	info, err := os.Stat(s.storePath)
+	if err != nil || info.ModTime().Equal(s.storeMtime) {
+		return
+	}
+	if err := s.loadStore(); err != nil {
+		logger.WarnCF("triggers", "Failed to reload trigger rules", map[string]any{"error": err.Error()})
+		return
+	}
+	for _, id := range s.runtimeIDsLocked() {
+		if rule := s.findRuleUnsafe(id); rule == nil || !rule.Enabled {
+			s.forgetRuntimeLocked(id)
+		}
+	}
+	logger.InfoCF("triggers", "Reloaded trigger rules", map[string]any{"rules": len(s.store.Rules)})
+}
+
+func (s *Service) runtimeIDsLocked() []string {
+	var ids []string
+	for id := range s.nextCron {
+		ids = append(ids, id)
+	}
+	for id := range s.files {
+		ids = append(ids, id)
+	}
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *Service) saveStoreUnsafe() error {
+	data, err := json.MarshalIndent(s.store, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(s.storePath, data, 0o600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.storePath); err == nil {
+		s.storeMtime = info.ModTime()
+	}
+	return nil
+}
+
+// generateID returns n random bytes, hex encoded.
+func generateID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,312 @@
+package triggers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestService returns a service marked running without starting its poll
+// loop, so tests can drive pollLocked themselves. Fires still in flight when
+// the test ends are waited for, since they save the rules to the workspace.
+func newTestService(t *testing.T, onFire Handler) (*Service, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	s := NewService(filepath.Join(workspace, "triggers", "rules.json"), workspace, onFire)
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	t.Cleanup(func() { waitFires(s) })
+	return s, workspace
+}
+
+// waitFires waits for the queued fires of s, including recording their
+// outcome, to finish.
+func waitFires(s *Service) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		idle := len(s.fires) == 0
+		s.mu.Unlock()
+		if idle {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func collect() (Handler, chan string) {
+	ch := make(chan string, 10)
+	return func(_ *Rule, message string) error {
+		ch <- message
+		return nil
+	}, ch
+}
+
+func waitMessage(t *testing.T, ch chan string) string {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("trigger did not fire")
+		return ""
+	}
+}
+
+func TestAddRule_ValidatesAndPersists(t *testing.T) {
+	s, _ := newTestService(t, nil)
+
+	if _, err := s.AddRule(Rule{Name: "bad", Source: Source{Kind: SourceCron, Expr: "not cron"}}); err == nil {
+		t.Fatal("expected error for invalid cron expression")
+	}
+	if _, err := s.AddRule(Rule{Name: "bad", Source: Source{Kind: SourceFile}}); err == nil {
+		t.Fatal("expected error for file source without path")
+	}
+
+	rule, err := s.AddRule(Rule{Name: "hook", Source: Source{Kind: SourceWebhook}})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	if rule.Source.Token == "" {
+		t.Error("webhook rule should get a token")
+	}
+	if rule.Action.Kind != ActionAgent {
+		t.Errorf("default action = %q, want %q", rule.Action.Kind, ActionAgent)
+	}
+
+	reloaded := NewService(s.storePath, "", nil)
+	got, ok := reloaded.GetRule(rule.ID)
+	if !ok {
+		t.Fatal("rule not persisted")
+	}
+	if got.Source.Token != rule.Source.Token {
+		t.Errorf("token = %q, want %q", got.Source.Token, rule.Source.Token)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(s.storePath)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("trigger store has permission %04o, want 0600", perm)
+		}
+	}
+
+	if disabled := s.EnableRule(rule.ID, false); disabled == nil || disabled.Enabled {
+		t.Error("EnableRule(false) should disable the rule")
+	}
+	if !s.RemoveRule(rule.ID) {
+		t.Error("RemoveRule should find the rule")
+	}
+	if len(s.ListRules()) != 0 {
+		t.Error("rule should be removed")
+	}
+}
+
+func TestFileRule_FiresOnChange(t *testing.T) {
+	onFire, fired := collect()
+	s, workspace := newTestService(t, onFire)
+	if err := os.MkdirAll(filepath.Join(workspace, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.AddRule(Rule{Name: "notes", Source: Source{Kind: SourceFile, Path: "notes/*.md"}}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.pollLocked(now)
+	s.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(workspace, "notes", "a.md"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "notes", "b.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.pollLocked(now.Add(time.Second))
+	s.mu.Unlock()
+
+	if msg := waitMessage(t, fired); msg != "File notes/a.md was created" {
+		t.Errorf("message = %q", msg)
+	}
+	select {
+	case msg := <-fired:
+		t.Errorf("unexpected second message %q", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFileRule_IgnoresChangesMadeByItsOwnFire(t *testing.T) {
+	var workspace string
+	var fires atomic.Int32
+	s, workspace := newTestService(t, func(_ *Rule, _ string) error {
+		fires.Add(1)
+		return os.WriteFile(filepath.Join(workspace, "log.md"), []byte("appended by the agent"), 0o644)
+	})
+	if _, err := s.AddRule(Rule{Name: "log", Source: Source{Kind: SourceFile, Path: "log.md"}}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.pollLocked(now)
+	s.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(workspace, "log.md"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.pollLocked(now.Add(time.Second))
+	s.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		firing := s.firingLocked(s.store.Rules[0].ID)
+		s.mu.Unlock()
+		if !firing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("trigger did not finish firing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.Lock()
+	s.pollLocked(now.Add(2 * time.Second))
+	s.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	if n := fires.Load(); n != 1 {
+		t.Errorf("rule fired %d times, want 1", n)
+	}
+}
+
+func TestEmit_DebounceCoalescesEvents(t *testing.T) {
+	onFire, fired := collect()
+	s, _ := newTestService(t, onFire)
+
+	rule, err := s.AddRule(Rule{
+		Name:       "burst",
+		Source:     Source{Kind: SourceWebhook},
+		Action:     Action{Template: "{{.count}} events, last {{.body}}"},
+		DebounceMS: 50,
+	})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	s.mu.Lock()
+	for _, body := range []string{"a", "b", "c"} {
+		s.emitLocked(s.findRuleUnsafe(rule.ID), Event{Kind: SourceWebhook, Fields: map[string]any{"body": body}})
+	}
+	s.mu.Unlock()
+
+	if msg := waitMessage(t, fired); msg != "3 events, last c" {
+		t.Errorf("message = %q", msg)
+	}
+
+	waitFires(s)
+	got, _ := s.GetRule(rule.ID)
+	if got.State.FireCount != 1 || got.State.LastStatus != "ok" {
+		t.Errorf("state = %+v, want one successful fire", got.State)
+	}
+}
+
+func TestEmit_FiresOfOneRuleRunInOrder(t *testing.T) {
+	var active, peak atomic.Int32
+	fired := make(chan string, 10)
+	s, _ := newTestService(t, func(_ *Rule, message string) error {
+		if n := active.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		active.Add(-1)
+		fired <- message
+		return nil
+	})
+
+	rule, err := s.AddRule(Rule{
+		Name:   "hook",
+		Source: Source{Kind: SourceWebhook},
+		Action: Action{Template: "{{.body}}"},
+	})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	want := []string{"1", "2", "3", "4", "5"}
+	s.mu.Lock()
+	for _, body := range want {
+		s.emitLocked(s.findRuleUnsafe(rule.ID), Event{Kind: SourceWebhook, Fields: map[string]any{"body": body}})
+	}
+	s.mu.Unlock()
+
+	for _, w := range want {
+		if msg := waitMessage(t, fired); msg != w {
+			t.Fatalf("message = %q, want %q", msg, w)
+		}
+	}
+	if peak.Load() != 1 {
+		t.Errorf("expected fires to run one at a time, %d overlapped", peak.Load())
+	}
+}
+
+func TestCronRule_FiresWhenDue(t *testing.T) {
+	onFire, fired := collect()
+	s, _ := newTestService(t, onFire)
+
+	if _, err := s.AddRule(Rule{Name: "tick", Source: Source{Kind: SourceCron, Expr: "* * * * *"}}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 30, 0, time.Local)
+	s.mu.Lock()
+	s.pollLocked(start)
+	s.pollLocked(start.Add(10 * time.Second))
+	s.mu.Unlock()
+	select {
+	case msg := <-fired:
+		t.Fatalf("fired before the next tick: %q", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.mu.Lock()
+	s.pollLocked(start.Add(31 * time.Second))
+	s.mu.Unlock()
+	if msg := waitMessage(t, fired); !strings.HasPrefix(msg, "Trigger tick fired at 2026-01-02T03:05:01") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestRender_Template(t *testing.T) {
+	rule := Rule{
+		ID:     "abc",
+		Name:   "deploy",
+		Source: Source{Kind: SourceWebhook},
+		Action: Action{Template: "{{.rule}}: {{.json.status}} {{.missing}}"},
+	}
+	msg, err := rule.Render(Event{
+		Kind:   SourceWebhook,
+		Time:   time.Now(),
+		Fields: map[string]any{"json": map[string]any{"status": "done"}},
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if msg != "deploy: done <no value>" {
+		t.Errorf("message = %q", msg)
+	}
+
+	rule.Action.Template = "{{.broken"
+	if err := rule.Validate(); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
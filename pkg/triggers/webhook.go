@@ -0,0 +1,78 @@
+package triggers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookPrefix is the gateway path webhook rules are served under.
+const WebhookPrefix = "/triggers/"
+
+// maxWebhookBody bounds the request body passed to a webhook rule.
+const maxWebhookBody = 1 << 20
+
+// WebhookPath returns the gateway path of the webhook rule with the given ID.
+func WebhookPath(id string) string {
+	return WebhookPrefix + id
+}
+
+// ServeHTTP fires the webhook rule named by the request path
+// (POST /triggers/<id>). Requests must carry the rule's token as
+// "Authorization: Bearer <token>".
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeWebhookStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, WebhookPrefix)
+	rule, ok := s.GetRule(id)
+	if !ok || rule.Source.Kind != SourceWebhook || !rule.Enabled {
+		writeWebhookStatus(w, http.StatusNotFound, "no such trigger")
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if rule.Source.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(rule.Source.Token)) != 1 {
+		writeWebhookStatus(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeWebhookStatus(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	fields := map[string]any{"body": string(body)}
+	var parsed any
+	if json.Unmarshal(body, &parsed) == nil {
+		fields["json"] = parsed
+	}
+	query := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		query[k] = v[0]
+	}
+	fields["query"] = query
+
+	s.mu.Lock()
+	running := s.running
+	if cur := s.findRuleUnsafe(id); cur != nil {
+		s.emitLocked(cur, Event{Kind: SourceWebhook, Time: time.Now(), Fields: fields})
+	}
+	s.mu.Unlock()
+	if !running {
+		writeWebhookStatus(w, http.StatusServiceUnavailable, "triggers are not running")
+		return
+	}
+	writeWebhookStatus(w, http.StatusAccepted, "accepted")
+}
+
+func writeWebhookStatus(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
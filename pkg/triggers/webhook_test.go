@@ -0,0 +1,94 @@
+package triggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+func TestServeHTTP_Webhook(t *testing.T) {
+	onFire, fired := collect()
+	s, _ := newTestService(t, onFire)
+
+	rule, err := s.AddRule(Rule{
+		Name:   "deploy",
+		Source: Source{Kind: SourceWebhook, Token: "secret"},
+		Action: Action{Template: "{{.json.status}} ({{.query.env}})"},
+	})
+	if err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"wrong method", http.MethodGet, WebhookPath(rule.ID), "secret", http.StatusMethodNotAllowed},
+		{"unknown rule", http.MethodPost, WebhookPath("nope"), "secret", http.StatusNotFound},
+		{"missing token", http.MethodPost, WebhookPath(rule.ID), "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, WebhookPath(rule.ID), "guess", http.StatusUnauthorized},
+		{"accepted", http.MethodPost, WebhookPath(rule.ID) + "?env=prod", "secret", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"status":"done"}`))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	if msg := waitMessage(t, fired); msg != "done (prod)" {
+		t.Errorf("message = %q", msg)
+	}
+
+	s.EnableRule(rule.ID, false)
+	req := httptest.NewRequest(http.MethodPost, WebhookPath(rule.ID), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("disabled rule: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMatchUSB(t *testing.T) {
+	ev := &events.DeviceEvent{
+		Action:  events.ActionAdd,
+		Kind:    events.KindUSB,
+		Vendor:  "Arduino SA",
+		Product: "Uno R3",
+		Raw:     map[string]string{"ID_VENDOR_ID": "2341", "ID_MODEL_ID": "0043"},
+	}
+
+	tests := []struct {
+		name string
+		src  Source
+		want bool
+	}{
+		{"any device", Source{}, true},
+		{"vendor name", Source{Vendor: "arduino"}, true},
+		{"vendor id", Source{Vendor: "2341", Product: "0043"}, true},
+		{"other vendor", Source{Vendor: "espressif"}, false},
+		{"action matches", Source{Action: "add"}, true},
+		{"action differs", Source{Action: "remove"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchUSB(tt.src, ev); got != tt.want {
+				t.Errorf("matchUSB(%+v) = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
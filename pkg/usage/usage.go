@@ -0,0 +1,406 @@
+// Package usage accumulates LLM token usage and cost per agent, session,
+// model and day, persisted in the workspace.
+package usage
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const dayLayout = "2006-01-02"
+
+const (
+	// flushDelay batches ledger writes: a call marks the ledger dirty and it
+	// is written at most this long after, instead of once per call.
+	flushDelay = 30 * time.Second
+	// sessionRetention is how long per-session rows are kept; day, agent and
+	// model totals are kept for dayRetention.
+	sessionRetention = 62 * 24 * time.Hour
+	dayRetention     = 366 * 24 * time.Hour
+)
+
+// Periods accepted by Tracker.Summary.
+const (
+	PeriodToday = "today"
+	PeriodMonth = "month"
+	PeriodAll   = "all"
+)
+
+// Totals is the usage accumulated for one key.
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+func (t *Totals) add(o Totals) {
+	t.Requests += o.Requests
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+	t.TotalTokens += o.TotalTokens
+	t.CostUSD += o.CostUSD
+}
+
+// Entry is the usage of a single LLM call.
+type Entry struct {
+	AgentID          string
+	SessionKey       string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	CostUSD          float64
+}
+
+// Price is a model's price in USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the price of a call with the given token counts.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// dayUsage is the persisted record of one calendar day.
+type dayUsage struct {
+	Total    Totals             `json:"total"`
+	Agents   map[string]*Totals `json:"agents,omitempty"`
+	Sessions map[string]*Totals `json:"sessions,omitempty"`
+	Models   map[string]*Totals `json:"models,omitempty"`
+}
+
+type ledger struct {
+	Days map[string]*dayUsage `json:"days"`
+}
+
+// Summary aggregates usage over a period.
+type Summary struct {
+	Period   string            `json:"period"`
+	From     string            `json:"from,omitempty"`
+	To       string            `json:"to,omitempty"`
+	Total    Totals            `json:"total"`
+	Agents   map[string]Totals `json:"agents"`
+	Sessions map[string]Totals `json:"sessions"`
+	Models   map[string]Totals `json:"models"`
+}
+
+// Tracker records usage and persists it to <workspace>/state/usage.json.
+// Writes are batched; call Close to save pending usage. It is safe for
+// concurrent use.
+type Tracker struct {
+	mu         sync.Mutex
+	path       string
+	data       *ledger
+	dirty      bool
+	flushTimer *time.Timer
+	nowFunc    func() time.Time // for testing
+}
+
+// NewTracker opens the usage ledger of the given workspace.
+func NewTracker(workspace string) *Tracker {
+	t := &Tracker{
+		path:    filepath.Join(workspace, "state", "usage.json"),
+		data:    &ledger{Days: make(map[string]*dayUsage)},
+		nowFunc: time.Now,
+	}
+	if data, err := os.ReadFile(t.path); err == nil {
+		if err := json.Unmarshal(data, t.data); err != nil || t.data.Days == nil {
+			t.data = &ledger{Days: make(map[string]*dayUsage)}
+		}
+	}
+	return t
+}
+
+// Record adds one call's usage to today's totals. The ledger is saved
+// shortly after, by Flush or by Close.
+func (t *Tracker) Record(e Entry) {
+	if t == nil {
+		return
+	}
+	delta := Totals{
+		Requests:         1,
+		PromptTokens:     e.PromptTokens,
+		CompletionTokens: e.CompletionTokens,
+		TotalTokens:      e.TotalTokens,
+		CostUSD:          e.CostUSD,
+	}
+	if delta.TotalTokens == 0 {
+		delta.TotalTokens = e.PromptTokens + e.CompletionTokens
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.nowFunc()
+	day := now.Format(dayLayout)
+	d, ok := t.data.Days[day]
+	if !ok {
+		d = &dayUsage{}
+		t.data.Days[day] = d
+		t.pruneLocked(now)
+	}
+	d.Total.add(delta)
+	addTo(&d.Agents, e.AgentID, delta)
+	addTo(&d.Sessions, e.SessionKey, delta)
+	addTo(&d.Models, e.Model, delta)
+
+	t.dirty = true
+	if t.flushTimer == nil {
+		t.flushTimer = time.AfterFunc(flushDelay, func() {
+			if err := t.Flush(); err != nil {
+				logger.WarnCF("usage", "Failed to save usage", map[string]any{"error": err.Error()})
+			}
+		})
+	}
+}
+
+// pruneLocked drops per-session rows older than sessionRetention and days
+// older than dayRetention. Must be called with the lock held.
+func (t *Tracker) pruneLocked(now time.Time) {
+	sessionCutoff := now.Add(-sessionRetention).Format(dayLayout)
+	dayCutoff := now.Add(-dayRetention).Format(dayLayout)
+	for date, d := range t.data.Days {
+		switch {
+		case date < dayCutoff:
+			delete(t.data.Days, date)
+		case date < sessionCutoff:
+			d.Sessions = nil
+		}
+	}
+}
+
+// Flush saves recorded usage that has not been written yet.
+func (t *Tracker) Flush() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.flushTimer != nil {
+		t.flushTimer.Stop()
+		t.flushTimer = nil
+	}
+	if !t.dirty {
+		return nil
+	}
+	if err := t.save(); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+// Close saves pending usage. The tracker remains usable.
+func (t *Tracker) Close() error {
+	return t.Flush()
+}
+
+func addTo(m *map[string]*Totals, key string, delta Totals) {
+	if key == "" {
+		key = "unknown"
+	}
+	if *m == nil {
+		*m = make(map[string]*Totals)
+	}
+	tot, ok := (*m)[key]
+	if !ok {
+		tot = &Totals{}
+		(*m)[key] = tot
+	}
+	tot.add(delta)
+}
+
+// AgentTotals returns an agent's usage for the current day and month.
+func (t *Tracker) AgentTotals(agentID string) (day, month Totals) {
+	if t == nil {
+		return Totals{}, Totals{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.nowFunc()
+	today := now.Format(dayLayout)
+	monthPrefix := now.Format("2006-01-")
+	for date, d := range t.data.Days {
+		if !strings.HasPrefix(date, monthPrefix) {
+			continue
+		}
+		tot, ok := d.Agents[agentID]
+		if !ok {
+			continue
+		}
+		month.add(*tot)
+		if date == today {
+			day.add(*tot)
+		}
+	}
+	return day, month
+}
+
+// Summary aggregates usage for PeriodToday, PeriodMonth or PeriodAll.
+func (t *Tracker) Summary(period string) (Summary, error) {
+	s := Summary{
+		Period:   period,
+		Agents:   make(map[string]Totals),
+		Sessions: make(map[string]Totals),
+		Models:   make(map[string]Totals),
+	}
+	if t == nil {
+		return s, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.nowFunc()
+	var match func(date string) bool
+	switch period {
+	case PeriodToday, "":
+		s.Period = PeriodToday
+		today := now.Format(dayLayout)
+		match = func(date string) bool { return date == today }
+	case PeriodMonth:
+		prefix := now.Format("2006-01-")
+		match = func(date string) bool { return strings.HasPrefix(date, prefix) }
+	case PeriodAll:
+		match = func(string) bool { return true }
+	default:
+		return s, fmt.Errorf("unknown period %q (want %s, %s or %s)", period, PeriodToday, PeriodMonth, PeriodAll)
+	}
+
+	for date, d := range t.data.Days {
+		if !match(date) {
+			continue
+		}
+		if s.From == "" || date < s.From {
+			s.From = date
+		}
+		if date > s.To {
+			s.To = date
+		}
+		s.Total.add(d.Total)
+		merge(s.Agents, d.Agents)
+		merge(s.Sessions, d.Sessions)
+		merge(s.Models, d.Models)
+	}
+	return s, nil
+}
+
+func merge(dst map[string]Totals, src map[string]*Totals) {
+	for k, v := range src {
+		tot := dst[k]
+		tot.add(*v)
+		dst[k] = tot
+	}
+}
+
+// save writes the ledger atomically. Must be called with the lock held.
+func (t *Tracker) save() error {
+	data, err := json.MarshalIndent(t.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+	return fileutil.WriteFileAtomic(t.path, data, 0o600)
+}
+
+// Handler serves a JSON Summary to requests carrying token as a bearer
+// token; the period comes from the "period" query parameter and defaults to
+// today. With an empty token every request is rejected.
+func (t *Tracker) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s, err := t.Summary(r.URL.Query().Get("period"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	})
+}
+
+// Format renders a summary as plain text for chat and the CLI.
+func Format(s Summary) string {
+	var sb strings.Builder
+	switch {
+	case s.From == "":
+		fmt.Fprintf(&sb, "Usage (%s): no requests recorded\n", s.Period)
+		return sb.String()
+	case s.From == s.To:
+		fmt.Fprintf(&sb, "Usage (%s, %s)\n", s.Period, s.From)
+	default:
+		fmt.Fprintf(&sb, "Usage (%s, %s to %s)\n", s.Period, s.From, s.To)
+	}
+	fmt.Fprintf(&sb, "Total: %s\n", formatTotals(s.Total))
+	writeSection(&sb, "By agent", s.Agents)
+	writeSection(&sb, "By model", s.Models)
+	writeSection(&sb, "By session", s.Sessions)
+	return sb.String()
+}
+
+func writeSection(sb *strings.Builder, title string, m map[string]Totals) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(sb, "\n%s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(sb, "  %s: %s\n", k, formatTotals(m[k]))
+	}
+}
+
+func formatTotals(t Totals) string {
+	return fmt.Sprintf("%d requests, %d tokens (%d in / %d out), $%.4f",
+		t.Requests, t.TotalTokens, t.PromptTokens, t.CompletionTokens, t.CostUSD)
+}
+
+type scopeKey struct{}
+
+type scope struct {
+	agentID    string
+	sessionKey string
+}
+
+// WithScope tags ctx with the agent and session that LLM calls made under it
+// are billed to.
+func WithScope(ctx context.Context, agentID, sessionKey string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{agentID: agentID, sessionKey: sessionKey})
+}
+
+// ScopeFromContext returns the agent and session set by WithScope.
+func ScopeFromContext(ctx context.Context) (agentID, sessionKey string) {
+	if s, ok := ctx.Value(scopeKey{}).(scope); ok {
+		return s.agentID, s.sessionKey
+	}
+	return "", ""
+}
@@ -0,0 +1,178 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTracker(t *testing.T, now time.Time) *Tracker {
+	t.Helper()
+	tr := NewTracker(t.TempDir())
+	tr.nowFunc = func() time.Time { return now }
+	return tr
+}
+
+func TestTracker_RecordAndSummary(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	tr := newTestTracker(t, now)
+
+	price := Price{Input: 3, Output: 15}
+	record := func(agent, session, model string, in, out int) {
+		t.Helper()
+		tr.Record(Entry{
+			AgentID:          agent,
+			SessionKey:       session,
+			Model:            model,
+			PromptTokens:     in,
+			CompletionTokens: out,
+			CostUSD:          price.Cost(in, out),
+		})
+	}
+
+	record("main", "s1", "sonnet", 1000, 100)
+	tr.nowFunc = func() time.Time { return now.AddDate(0, 0, -1) }
+	record("main", "s2", "sonnet", 2000, 200)
+	tr.nowFunc = func() time.Time { return now.AddDate(0, -1, 0) }
+	record("helper", "s3", "mini", 500, 50)
+	tr.nowFunc = func() time.Time { return now }
+
+	today, err := tr.Summary(PeriodToday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if today.Total.Requests != 1 || today.Total.TotalTokens != 1100 {
+		t.Errorf("unexpected today totals: %+v", today.Total)
+	}
+	if want := 0.0045; math.Abs(today.Total.CostUSD-want) > 1e-9 {
+		t.Errorf("cost = %f, want %f", today.Total.CostUSD, want)
+	}
+
+	month, _ := tr.Summary(PeriodMonth)
+	if month.Total.Requests != 2 || month.Sessions["s2"].Requests != 1 || month.From != "2026-03-14" {
+		t.Errorf("unexpected month summary: %+v", month)
+	}
+
+	all, _ := tr.Summary(PeriodAll)
+	if all.Total.Requests != 3 || all.Models["mini"].TotalTokens != 550 || all.Agents["helper"].Requests != 1 {
+		t.Errorf("unexpected all-time summary: %+v", all)
+	}
+
+	if _, err := tr.Summary("yesterday"); err == nil {
+		t.Error("expected unknown period to fail")
+	}
+
+	day, monthTotals := tr.AgentTotals("main")
+	if day.Requests != 1 || monthTotals.Requests != 2 {
+		t.Errorf("AgentTotals = %+v / %+v", day, monthTotals)
+	}
+}
+
+func TestTracker_Persists(t *testing.T) {
+	workspace := t.TempDir()
+	tr := NewTracker(workspace)
+	tr.Record(Entry{AgentID: "main", Model: "m", TotalTokens: 42})
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := NewTracker(workspace).Summary(PeriodToday)
+	if s.Total.TotalTokens != 42 || s.Sessions["unknown"].Requests != 1 {
+		t.Errorf("expected usage to survive a restart, got %+v", s)
+	}
+	if !strings.Contains(Format(s), "main: 1 requests, 42 tokens") {
+		t.Errorf("unexpected formatted summary:\n%s", Format(s))
+	}
+}
+
+func TestTracker_BatchesSavesAndPrunes(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	tr := newTestTracker(t, now.AddDate(-2, 0, 0))
+
+	tr.Record(Entry{AgentID: "main", SessionKey: "ancient", Model: "m", TotalTokens: 1})
+	tr.nowFunc = func() time.Time { return now.AddDate(0, -3, 0) }
+	tr.Record(Entry{AgentID: "main", SessionKey: "old", Model: "m", TotalTokens: 1})
+	tr.nowFunc = func() time.Time { return now }
+	tr.Record(Entry{AgentID: "main", SessionKey: "new", Model: "m", TotalTokens: 1})
+
+	if _, err := os.Stat(tr.path); !os.IsNotExist(err) {
+		t.Fatalf("expected Record not to write the ledger, stat err = %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tr.path); err != nil {
+		t.Fatalf("expected Flush to write the ledger: %v", err)
+	}
+
+	all, _ := tr.Summary(PeriodAll)
+	if all.Total.Requests != 2 {
+		t.Errorf("expected the day older than a year to be pruned, got %+v", all.Total)
+	}
+	if _, ok := all.Sessions["old"]; ok {
+		t.Error("expected old per-session rows to be pruned")
+	}
+	if all.Sessions["new"].Requests != 1 || all.Agents["main"].Requests != 2 {
+		t.Errorf("unexpected summary after pruning: %+v", all)
+	}
+}
+
+func TestTracker_Handler(t *testing.T) {
+	tr := NewTracker(t.TempDir())
+	tr.Record(Entry{AgentID: "main", Model: "m", PromptTokens: 10, CompletionTokens: 5})
+	h := tr.Handler("secret")
+	get := func(target, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		if rec := get("/usage", auth); rec.Code != http.StatusUnauthorized {
+			t.Errorf("auth %q: status = %d, want 401", auth, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	tr.Handler("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/usage", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("empty token: status = %d, want 401", rec.Code)
+	}
+
+	rec = get("/usage?period=month", "Bearer secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var s Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Period != PeriodMonth || s.Total.TotalTokens != 15 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+
+	rec = get("/usage?period=bogus", "Bearer secret")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown period, got %d", rec.Code)
+	}
+}
+
+func TestScope(t *testing.T) {
+	ctx := WithScope(context.Background(), "main", "telegram:1")
+	agentID, sessionKey := ScopeFromContext(ctx)
+	if agentID != "main" || sessionKey != "telegram:1" {
+		t.Errorf("got %q/%q", agentID, sessionKey)
+	}
+	if a, s := ScopeFromContext(context.Background()); a != "" || s != "" {
+		t.Error("expected empty scope")
+	}
+}
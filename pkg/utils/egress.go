@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxResponseBytes caps response bodies read into memory.
+	DefaultMaxResponseBytes = 10 << 20 // 10 MB
+	// DefaultMaxDownloadBytes caps files downloaded to disk.
+	DefaultMaxDownloadBytes = 50 << 20 // 50 MB
+)
+
+// ErrEgressBlocked is returned for requests to addresses the egress policy
+// does not allow.
+var ErrEgressBlocked = errors.New("egress blocked")
+
+// blockedPrefixes are reserved ranges not covered by the netip predicates.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, some cloud metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, broadcast
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+	netip.MustParsePrefix("fec0::/10"),      // deprecated site-local
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+}
+
+// nat64Prefix is the well-known NAT64 prefix; the embedded IPv4 address is
+// checked instead.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// EgressOptions configures an EgressPolicy.
+type EgressOptions struct {
+	// AllowPrivate turns off the private-address block entirely.
+	AllowPrivate bool
+	// Allow lists hostnames ("internal.example.com", "*.corp.example"), IPs
+	// and CIDR ranges that may be reached even if they are private.
+	Allow []string
+	// MaxResponseBytes caps response bodies read into memory, 0 means default.
+	MaxResponseBytes int64
+	// MaxDownloadBytes caps files downloaded to disk, 0 means default.
+	MaxDownloadBytes int64
+}
+
+// EgressPolicy restricts outbound HTTP requests made on behalf of agents and
+// users: it blocks loopback, link-local, private and other reserved
+// addresses unless allowlisted, and caps how much may be downloaded.
+//
+// Hosts are checked when a request (or redirect) is sent and again when the
+// connection is dialed, so a hostname that re-resolves to a private address
+// between the two checks is still refused. Requests sent through a proxy
+// can only be checked up front; the proxy itself is always reachable.
+type EgressPolicy struct {
+	allowPrivate bool
+	hosts        map[string]bool
+	suffixes     []string
+	prefixes     []netip.Prefix
+	maxResponse  int64
+	maxDownload  int64
+}
+
+// NewEgressPolicy builds a policy from opts.
+func NewEgressPolicy(opts EgressOptions) (*EgressPolicy, error) {
+	p := &EgressPolicy{
+		allowPrivate: opts.AllowPrivate,
+		hosts:        make(map[string]bool),
+		maxResponse:  opts.MaxResponseBytes,
+		maxDownload:  opts.MaxDownloadBytes,
+	}
+	if p.maxResponse <= 0 {
+		p.maxResponse = DefaultMaxResponseBytes
+	}
+	if p.maxDownload <= 0 {
+		p.maxDownload = DefaultMaxDownloadBytes
+	}
+
+	for _, entry := range opts.Allow {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid egress allow entry %q: %w", entry, err)
+			}
+			p.prefixes = append(p.prefixes, prefix.Masked())
+		case strings.HasPrefix(entry, "*."):
+			p.suffixes = append(p.suffixes, entry[1:])
+		default:
+			if ip, err := netip.ParseAddr(strings.Trim(entry, "[]")); err == nil {
+				p.prefixes = append(p.prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+				continue
+			}
+			p.hosts[entry] = true
+		}
+	}
+	return p, nil
+}
+
+var (
+	builtinEgress, _ = NewEgressPolicy(EgressOptions{})
+	defaultEgress    atomic.Pointer[EgressPolicy]
+)
+
+// DefaultEgressPolicy returns the process-wide policy set with
+// SetDefaultEgressPolicy, or a policy with the built-in defaults.
+func DefaultEgressPolicy() *EgressPolicy {
+	if p := defaultEgress.Load(); p != nil {
+		return p
+	}
+	return builtinEgress
+}
+
+// SetDefaultEgressPolicy replaces the process-wide policy.
+func SetDefaultEgressPolicy(p *EgressPolicy) {
+	defaultEgress.Store(p)
+}
+
+// MaxResponseBytes returns the cap on response bodies read into memory.
+func (p *EgressPolicy) MaxResponseBytes() int64 {
+	return p.maxResponse
+}
+
+// MaxDownloadBytes returns the cap on files downloaded to disk.
+func (p *EgressPolicy) MaxDownloadBytes() int64 {
+	return p.maxDownload
+}
+
+// CheckIP reports whether ip may be connected to.
+func (p *EgressPolicy) CheckIP(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if p.allowPrivate || !isBlockedIP(ip) {
+		return nil
+	}
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is a private or reserved address", ErrEgressBlocked, ip)
+}
+
+// CheckURL reports whether u may be requested. The host is resolved and
+// every address it resolves to must be allowed.
+func (p *EgressPolicy) CheckURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrEgressBlocked, u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrEgressBlocked)
+	}
+	if p.allowPrivate || p.hostAllowed(host) {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return p.CheckIP(ip)
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if err := p.CheckIP(ip); err != nil {
+			return fmt.Errorf("%s: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// Guard makes client enforce the policy on every request, redirect and
+// dialed connection. The client's transport must be nil or an
+// *http.Transport; it is cloned, not modified. Guard returns client.
+func (p *EgressPolicy) Guard(client *http.Client) *http.Client {
+	base, ok := client.Transport.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	g := &guardedTransport{policy: p, base: t}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	checked := &net.Dialer{
+		Timeout:   dialer.Timeout,
+		KeepAlive: dialer.KeepAlive,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return p.CheckIP(addr.Addr())
+		},
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		if g.isProxy(addr) || p.hostAllowed(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return checked.DialContext(ctx, network, addr)
+	}
+	if proxy := t.Proxy; proxy != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := proxy(req)
+			if u != nil {
+				g.proxies.Store(canonicalAddr(u), true)
+			}
+			return u, err
+		}
+	}
+
+	client.Transport = g
+	return client
+}
+
+// ReadBody reads r up to the response cap. truncated reports whether the
+// body was longer.
+func (p *EgressPolicy) ReadBody(r io.Reader) (data []byte, truncated bool, err error) {
+	data, err = io.ReadAll(io.LimitReader(r, p.maxResponse+1))
+	if int64(len(data)) > p.maxResponse {
+		return data[:p.maxResponse], true, err
+	}
+	return data, false, err
+}
+
+func (p *EgressPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if p.hosts[host] {
+		return true
+	}
+	for _, suffix := range p.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlockedIP(ip netip.Addr) bool {
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		return isBlockedIP(netip.AddrFrom4([4]byte(b[12:])))
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardedTransport checks each outgoing request, including redirects,
+// before handing it to the underlying transport.
+type guardedTransport struct {
+	policy  *EgressPolicy
+	base    *http.Transport
+	proxies sync.Map // host:port of proxies in use
+}
+
+func (g *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := g.policy.CheckURL(req.Context(), req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return g.base.RoundTrip(req)
+}
+
+func (g *guardedTransport) CloseIdleConnections() {
+	g.base.CloseIdleConnections()
+}
+
+func (g *guardedTransport) isProxy(addr string) bool {
+	_, ok := g.proxies.Load(addr)
+	return ok
+}
+
+// canonicalAddr returns the host:port a proxy URL is dialed at.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEgressPolicy_CheckIP(t *testing.T) {
+	p, err := NewEgressPolicy(EgressOptions{Allow: []string{"10.1.0.0/16", "192.168.1.5"}})
+	if err != nil {
+		t.Fatalf("NewEgressPolicy: %v", err)
+	}
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"10.0.0.1", true},
+		{"172.16.3.4", true},
+		{"192.168.1.1", true},
+		{"100.100.100.200", true},
+		{"0.0.0.0", true},
+		{"fd00:ec2::254", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true}, // NAT64 for 169.254.169.254
+		{"10.1.2.3", false},          // allowlisted range
+		{"192.168.1.5", false},       // allowlisted address
+	}
+	for _, tt := range tests {
+		err := p.CheckIP(netip.MustParseAddr(tt.ip))
+		if blocked := errors.Is(err, ErrEgressBlocked); blocked != tt.blocked {
+			t.Errorf("CheckIP(%s) = %v, want blocked=%v", tt.ip, err, tt.blocked)
+		}
+	}
+}
+
+func TestEgressPolicy_CheckURL(t *testing.T) {
+	p, err := NewEgressPolicy(EgressOptions{Allow: []string{"intranet.example", "*.corp.example"}})
+	if err != nil {
+		t.Fatalf("NewEgressPolicy: %v", err)
+	}
+	tests := []struct {
+		raw     string
+		blocked bool
+	}{
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://[::1]:8080/", true},
+		{"http://localhost/", true},
+		{"file:///etc/passwd", true},
+		{"http://intranet.example/", false},
+		{"https://wiki.corp.example/page", false},
+		{"http://93.184.216.34/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.raw)
+		err := p.CheckURL(context.Background(), u)
+		if blocked := errors.Is(err, ErrEgressBlocked); blocked != tt.blocked {
+			t.Errorf("CheckURL(%s) = %v, want blocked=%v", tt.raw, err, tt.blocked)
+		}
+	}
+
+	open, _ := NewEgressPolicy(EgressOptions{AllowPrivate: true})
+	if err := open.CheckURL(context.Background(), &url.URL{Scheme: "http", Host: "127.0.0.1"}); err != nil {
+		t.Errorf("AllowPrivate: CheckURL = %v", err)
+	}
+}
+
+func TestNewEgressPolicy_InvalidCIDR(t *testing.T) {
+	if _, err := NewEgressPolicy(EgressOptions{Allow: []string{"10.0.0.0/99"}}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestEgressPolicy_GuardBlocksRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer target.Close()
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer front.Close()
+
+	// The front server is reachable by its allowlisted name, the target is not.
+	frontURL, _ := url.Parse(front.URL)
+	p, _ := NewEgressPolicy(EgressOptions{Allow: []string{"localhost"}})
+	client := p.Guard(&http.Client{})
+
+	resp, err := client.Get("http://localhost:" + frontURL.Port() + "/")
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrEgressBlocked) {
+		t.Fatalf("Get = %v, want redirect to loopback blocked", err)
+	}
+}
+
+func TestEgressPolicy_GuardChecksDialedAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	p, _ := NewEgressPolicy(EgressOptions{})
+	g := p.Guard(&http.Client{}).Transport.(*guardedTransport)
+
+	// A hostname that passed the request check but re-resolved to loopback
+	// ends up dialing a blocked address.
+	_, err := g.base.DialContext(context.Background(), "tcp", srv.Listener.Addr().String())
+	if !errors.Is(err, ErrEgressBlocked) {
+		t.Fatalf("DialContext = %v, want blocked", err)
+	}
+
+	// Proxies are exempt: they are dialed instead of the target.
+	proxyURL, _ := url.Parse("http://" + srv.Listener.Addr().String())
+	g.proxies.Store(canonicalAddr(proxyURL), true)
+	conn, err := g.base.DialContext(context.Background(), "tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext to proxy = %v", err)
+	}
+	conn.Close()
+}
+
+func TestEgressPolicy_ReadBody(t *testing.T) {
+	p, _ := NewEgressPolicy(EgressOptions{MaxResponseBytes: 4})
+
+	data, truncated, err := p.ReadBody(strings.NewReader("abcdef"))
+	if err != nil || !truncated || string(data) != "abcd" {
+		t.Errorf("ReadBody = %q, %v, %v", data, truncated, err)
+	}
+	data, truncated, _ = p.ReadBody(strings.NewReader("abcd"))
+	if truncated || string(data) != "abcd" {
+		t.Errorf("ReadBody at limit = %q, truncated=%v", data, truncated)
+	}
+}
+
+func TestDownloadFile_BlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	if path := DownloadFile(srv.URL, "a.txt", DownloadOptions{}); path != "" {
+		t.Errorf("DownloadFile = %q, want loopback download refused", path)
+	}
+
+	p, _ := NewEgressPolicy(EgressOptions{AllowPrivate: true, MaxDownloadBytes: 2})
+	SetDefaultEgressPolicy(p)
+	defer SetDefaultEgressPolicy(nil)
+	if path := DownloadFile(srv.URL, "a.txt", DownloadOptions{}); path != "" {
+		t.Errorf("DownloadFile = %q, want oversized download refused", path)
+	}
+}
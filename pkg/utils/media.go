@@ -91,7 +91,8 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 		req.Header.Set(key, value)
 	}
 
-	client := &http.Client{Timeout: opts.Timeout}
+	egress := DefaultEgressPolicy()
+	client := egress.Guard(&http.Client{Timeout: opts.Timeout})
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to download file", map[string]any{
@@ -119,7 +120,9 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, resp.Body); err != nil {
+	maxBytes := egress.MaxDownloadBytes()
+	written, err := io.Copy(out, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
 		out.Close()
 		os.Remove(localPath)
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to write file", map[string]any{
@@ -127,6 +130,15 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 		})
 		return ""
 	}
+	if written > maxBytes {
+		out.Close()
+		os.Remove(localPath)
+		logger.ErrorCF(opts.LoggerPrefix, "File download exceeds size limit", map[string]any{
+			"max_bytes": maxBytes,
+			"url":       url,
+		})
+		return ""
+	}
 
 	logger.DebugCF(opts.LoggerPrefix, "File downloaded successfully", map[string]any{
 		"path": localPath,
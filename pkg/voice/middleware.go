@@ -0,0 +1,90 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+var audioExtensions = map[string]bool{
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+	".mp3":  true,
+	".m4a":  true,
+	".wav":  true,
+	".flac": true,
+	".aac":  true,
+	".amr":  true,
+	".webm": true,
+	".silk": true,
+}
+
+// NewInboundMiddleware returns a function that transcribes audio media:// refs
+// attached to an inbound message and prepends the transcripts to its Content.
+// The original refs are left in Media so multimodal models still receive the
+// audio. Messages without audio, or a nil/unavailable transcriber, pass
+// through unchanged; transcription failures are logged and skipped.
+func NewInboundMiddleware(
+	t Transcriber,
+	store media.MediaStore,
+) func(ctx context.Context, msg bus.InboundMessage) bus.InboundMessage {
+	return func(ctx context.Context, msg bus.InboundMessage) bus.InboundMessage {
+		if t == nil || store == nil || len(msg.Media) == 0 || !t.IsAvailable() {
+			return msg
+		}
+
+		var transcripts []string
+		for _, ref := range msg.Media {
+			if !strings.HasPrefix(ref, "media://") {
+				continue
+			}
+			path, meta, err := store.ResolveWithMeta(ref)
+			if err != nil || !isAudio(path, meta) {
+				continue
+			}
+
+			result, err := t.Transcribe(ctx, path)
+			if err != nil {
+				logger.WarnCF("voice", "Failed to transcribe inbound audio", map[string]any{
+					"channel": msg.Channel,
+					"ref":     ref,
+					"error":   err.Error(),
+				})
+				continue
+			}
+			if text := strings.TrimSpace(result.Text); text != "" {
+				transcripts = append(transcripts, fmt.Sprintf("[voice transcription: %s]", text))
+			}
+		}
+
+		if len(transcripts) == 0 {
+			return msg
+		}
+		prefix := strings.Join(transcripts, "\n")
+		if msg.Content == "" {
+			msg.Content = prefix
+		} else {
+			msg.Content = prefix + "\n" + msg.Content
+		}
+		return msg
+	}
+}
+
+// isAudio reports whether a stored media file is audio, judging by its
+// declared content type or, failing that, its file extension.
+func isAudio(path string, meta media.MediaMeta) bool {
+	if strings.HasPrefix(strings.ToLower(meta.ContentType), "audio/") {
+		return true
+	}
+	name := meta.Filename
+	if name == "" {
+		name = path
+	}
+	return audioExtensions[strings.ToLower(filepath.Ext(name))]
+}
@@ -0,0 +1,81 @@
+package voice
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+type fakeTranscriber struct {
+	text  string
+	err   error
+	calls []string
+}
+
+func (f *fakeTranscriber) Transcribe(_ context.Context, path string) (*TranscriptionResponse, error) {
+	f.calls = append(f.calls, filepath.Base(path))
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &TranscriptionResponse{Text: f.text}, nil
+}
+
+func (f *fakeTranscriber) IsAvailable() bool { return true }
+
+func storeTestFile(t *testing.T, store media.MediaStore, name string, meta media.MediaMeta) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := store.Store(path, meta, "scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func TestInboundMiddleware_PrependsTranscript(t *testing.T) {
+	store := media.NewFileMediaStore()
+	voiceRef := storeTestFile(t, store, "voice.ogg", media.MediaMeta{Filename: "voice.ogg"})
+	photoRef := storeTestFile(t, store, "photo.jpg", media.MediaMeta{Filename: "photo.jpg"})
+
+	tr := &fakeTranscriber{text: " turn on the lights "}
+	mw := NewInboundMiddleware(tr, store)
+
+	msg := mw(context.Background(), bus.InboundMessage{
+		Content: "[voice]",
+		Media:   []string{photoRef, voiceRef},
+	})
+
+	if want := "[voice transcription: turn on the lights]\n[voice]"; msg.Content != want {
+		t.Errorf("Content = %q, want %q", msg.Content, want)
+	}
+	if len(tr.calls) != 1 || tr.calls[0] != "voice.ogg" {
+		t.Errorf("expected only the audio file to be transcribed, got %v", tr.calls)
+	}
+	if len(msg.Media) != 2 {
+		t.Errorf("expected original media to be kept, got %v", msg.Media)
+	}
+}
+
+func TestInboundMiddleware_PassesThrough(t *testing.T) {
+	store := media.NewFileMediaStore()
+	voiceRef := storeTestFile(t, store, "clip", media.MediaMeta{ContentType: "audio/amr"})
+	in := bus.InboundMessage{Content: "[voice]", Media: []string{voiceRef}}
+
+	failing := NewInboundMiddleware(&fakeTranscriber{err: errors.New("boom")}, store)
+	if got := failing(context.Background(), in); got.Content != in.Content {
+		t.Errorf("expected content unchanged on failure, got %q", got.Content)
+	}
+
+	disabled := NewInboundMiddleware(nil, store)
+	if got := disabled(context.Background(), in); got.Content != in.Content {
+		t.Errorf("expected content unchanged without transcriber, got %q", got.Content)
+	}
+}
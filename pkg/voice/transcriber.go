@@ -10,38 +10,88 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-type GroqTranscriber struct {
+// Transcriber converts an audio file into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error)
+	IsAvailable() bool
+}
+
+const (
+	groqAPIBase       = "https://api.groq.com/openai/v1"
+	defaultModel      = "whisper-large-v3"
+	defaultOpenAIBase = "https://api.openai.com/v1"
+	openAIModel       = "whisper-1" // the only whisper model the OpenAI API serves
+	defaultTimeout    = 60 * time.Second
+)
+
+// OpenAITranscriber calls an OpenAI-compatible /audio/transcriptions endpoint,
+// such as OpenAI, Groq or a local whisper server.
+type OpenAITranscriber struct {
+	name       string
 	apiKey     string
 	apiBase    string
+	model      string
+	language   string
 	httpClient *http.Client
 }
 
+type GroqTranscriber struct {
+	*OpenAITranscriber
+}
+
 type TranscriptionResponse struct {
 	Text     string  `json:"text"`
 	Language string  `json:"language,omitempty"`
 	Duration float64 `json:"duration,omitempty"`
 }
 
-func NewGroqTranscriber(apiKey string) *GroqTranscriber {
-	logger.DebugCF("voice", "Creating Groq transcriber", map[string]any{"has_api_key": apiKey != ""})
-
-	apiBase := "https://api.groq.com/openai/v1"
-	return &GroqTranscriber{
-		apiKey:  apiKey,
-		apiBase: apiBase,
+// NewOpenAITranscriber creates a transcriber for an OpenAI-compatible endpoint.
+// An empty apiBase falls back to the OpenAI API. An empty model falls back to
+// whisper-1 on the OpenAI API and to whisper-large-v3 elsewhere, e.g. on Groq
+// or a local whisper server.
+func NewOpenAITranscriber(apiKey, apiBase, model, language string, timeout time.Duration) *OpenAITranscriber {
+	apiBase = strings.TrimRight(apiBase, "/")
+	if apiBase == "" {
+		apiBase = defaultOpenAIBase
+	}
+	if model == "" {
+		model = defaultModel
+		if apiBase == defaultOpenAIBase {
+			model = openAIModel
+		}
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &OpenAITranscriber{
+		name:     "openai",
+		apiKey:   apiKey,
+		apiBase:  apiBase,
+		model:    model,
+		language: language,
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout: timeout,
 		},
 	}
 }
 
-func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+func NewGroqTranscriber(apiKey string) *GroqTranscriber {
+	logger.DebugCF("voice", "Creating Groq transcriber", map[string]any{"has_api_key": apiKey != ""})
+
+	t := NewOpenAITranscriber(apiKey, groqAPIBase, defaultModel, "", defaultTimeout)
+	t.name = "groq"
+	return &GroqTranscriber{OpenAITranscriber: t}
+}
+
+func (t *OpenAITranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
 	logger.InfoCF("voice", "Starting transcription", map[string]any{"audio_file": audioFilePath})
 
 	audioFile, err := os.Open(audioFilePath)
@@ -79,7 +129,7 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 
 	logger.DebugCF("voice", "File copied to request", map[string]any{"bytes_copied": copied})
 
-	if err = writer.WriteField("model", "whisper-large-v3"); err != nil {
+	if err = writer.WriteField("model", t.model); err != nil {
 		logger.ErrorCF("voice", "Failed to write model field", map[string]any{"error": err})
 		return nil, fmt.Errorf("failed to write model field: %w", err)
 	}
@@ -89,6 +139,13 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 		return nil, fmt.Errorf("failed to write response_format field: %w", err)
 	}
 
+	if t.language != "" {
+		if err = writer.WriteField("language", t.language); err != nil {
+			logger.ErrorCF("voice", "Failed to write language field", map[string]any{"error": err})
+			return nil, fmt.Errorf("failed to write language field: %w", err)
+		}
+	}
+
 	if err = writer.Close(); err != nil {
 		logger.ErrorCF("voice", "Failed to close multipart writer", map[string]any{"error": err})
 		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
@@ -102,9 +159,12 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
 
-	logger.DebugCF("voice", "Sending transcription request to Groq API", map[string]any{
+	logger.DebugCF("voice", "Sending transcription request", map[string]any{
+		"backend":            t.name,
 		"url":                url,
 		"request_size_bytes": requestBody.Len(),
 		"file_size_bytes":    fileInfo.Size(),
@@ -131,7 +191,8 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
-	logger.DebugCF("voice", "Received response from Groq API", map[string]any{
+	logger.DebugCF("voice", "Received transcription response", map[string]any{
+		"backend":             t.name,
 		"status_code":         resp.StatusCode,
 		"response_size_bytes": len(body),
 	})
@@ -152,8 +213,47 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	return &result, nil
 }
 
-func (t *GroqTranscriber) IsAvailable() bool {
-	available := t.apiKey != ""
+// IsAvailable reports whether the transcriber can be used. Groq requires an API
+// key; other OpenAI-compatible endpoints (e.g. a local whisper server) may not.
+func (t *OpenAITranscriber) IsAvailable() bool {
+	available := t.apiKey != "" || t.name != "groq"
 	logger.DebugCF("voice", "Checking transcriber availability", map[string]any{"available": available})
 	return available
 }
+
+// NewTranscriber builds the transcriber selected by cfg.Voice. It returns nil
+// when transcription is disabled or no backend is configured.
+func NewTranscriber(cfg *config.Config) Transcriber {
+	vc := cfg.Voice
+	timeout := time.Duration(vc.RequestTimeout) * time.Second
+
+	switch strings.ToLower(vc.Backend) {
+	case "none":
+		return nil
+	case "openai":
+		return NewOpenAITranscriber(vc.APIKey, vc.APIBase, vc.Model, vc.Language, timeout)
+	case "groq", "":
+		apiKey := vc.APIKey
+		if apiKey == "" {
+			apiKey = cfg.Providers.Groq.APIKey
+		}
+		if apiKey == "" {
+			return nil
+		}
+		apiBase := vc.APIBase
+		if apiBase == "" {
+			apiBase = cfg.Providers.Groq.APIBase
+		}
+		if apiBase == "" {
+			apiBase = groqAPIBase
+		}
+		t := NewOpenAITranscriber(apiKey, apiBase, vc.Model, vc.Language, timeout)
+		t.name = "groq"
+		return &GroqTranscriber{OpenAITranscriber: t}
+	default:
+		logger.WarnCF("voice", "Unknown voice backend, transcription disabled", map[string]any{
+			"backend": vc.Backend,
+		})
+		return nil
+	}
+}
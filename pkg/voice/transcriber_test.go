@@ -0,0 +1,120 @@
+package voice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestOpenAITranscriber_Transcribe(t *testing.T) {
+	var gotModel, gotLanguage, gotAuth, gotFile string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		gotModel = r.FormValue("model")
+		gotLanguage = r.FormValue("language")
+		gotAuth = r.Header.Get("Authorization")
+		_, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotFile = header.Filename
+		w.Write([]byte(`{"text":"hello there","language":"en"}`))
+	}))
+	defer server.Close()
+
+	audio := filepath.Join(t.TempDir(), "voice.ogg")
+	if err := os.WriteFile(audio, []byte("OggS"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewOpenAITranscriber("", server.URL+"/v1/", "whisper-1", "en", 0)
+	if !tr.IsAvailable() {
+		t.Fatal("expected keyless OpenAI-compatible transcriber to be available")
+	}
+	result, err := tr.Transcribe(context.Background(), audio)
+	if err != nil {
+		t.Fatalf("Transcribe() error: %v", err)
+	}
+	if result.Text != "hello there" {
+		t.Errorf("Text = %q", result.Text)
+	}
+	if gotModel != "whisper-1" || gotLanguage != "en" || gotFile != "voice.ogg" {
+		t.Errorf("unexpected form: model=%q language=%q file=%q", gotModel, gotLanguage, gotFile)
+	}
+	if gotAuth != "" {
+		t.Errorf("expected no Authorization header without an API key, got %q", gotAuth)
+	}
+}
+
+func TestNewTranscriber(t *testing.T) {
+	tests := []struct {
+		name  string
+		voice config.VoiceConfig
+		groq  string
+		want  string
+	}{
+		{name: "none", voice: config.VoiceConfig{Backend: "none"}, groq: "gsk", want: ""},
+		{name: "unconfigured", want: ""},
+		{name: "groq from provider key", groq: "gsk", want: "groq"},
+		{name: "groq without key", voice: config.VoiceConfig{Backend: "groq"}, want: ""},
+		{
+			name:  "openai",
+			voice: config.VoiceConfig{Backend: "openai", APIBase: "http://localhost:8000/v1"},
+			want:  "openai",
+		},
+		{name: "unknown", voice: config.VoiceConfig{Backend: "bogus"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Voice: tt.voice}
+			cfg.Providers.Groq.APIKey = tt.groq
+
+			got := NewTranscriber(cfg)
+			switch tr := got.(type) {
+			case nil:
+				if tt.want != "" {
+					t.Fatalf("expected %s transcriber, got nil", tt.want)
+				}
+			case *GroqTranscriber:
+				if tt.want != "groq" || tr.apiBase != groqAPIBase {
+					t.Fatalf("unexpected groq transcriber %+v", tr.OpenAITranscriber)
+				}
+			case *OpenAITranscriber:
+				if tt.want != "openai" || tr.apiBase != tt.voice.APIBase {
+					t.Fatalf("unexpected openai transcriber %+v", tr)
+				}
+			}
+		})
+	}
+}
+
+func TestNewOpenAITranscriber_DefaultModel(t *testing.T) {
+	tests := []struct {
+		apiBase string
+		want    string
+	}{
+		{"", "whisper-1"},
+		{"https://api.openai.com/v1/", "whisper-1"},
+		{"http://localhost:8000/v1", "whisper-large-v3"},
+		{groqAPIBase, "whisper-large-v3"},
+	}
+	for _, tt := range tests {
+		if got := NewOpenAITranscriber("", tt.apiBase, "", "", 0).model; got != tt.want {
+			t.Errorf("api base %q: model = %q, want %q", tt.apiBase, got, tt.want)
+		}
+	}
+	if got := NewOpenAITranscriber("", "", "gpt-4o-transcribe", "", 0).model; got != "gpt-4o-transcribe" {
+		t.Errorf("configured model replaced by %q", got)
+	}
+}